@@ -0,0 +1,104 @@
+package evoke
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AggregateBase does the bookkeeping of an Aggregate so implementations only
+// have to write their state transitions. Embed it and pass an apply function
+// that folds events into the state:
+//
+//	type Order struct {
+//		evoke.AggregateBase[OrderState]
+//	}
+//
+//	func NewOrder(id uuid.UUID) evoke.Aggregate {
+//		return &Order{evoke.NewAggregateBase(applyOrder)}
+//	}
+//
+//	func (o *Order) HandleCommand(cmd evoke.Command) ([]evoke.Event, error) {
+//		if err := o.Raise(OrderPlaced{}); err != nil {
+//			return nil, err
+//		}
+//		return o.TakeChanges(), nil
+//	}
+type AggregateBase[TState any] struct {
+	State   TState
+	version int64
+	changes []Event
+	apply   func(*TState, Event) error
+	// reminders are the reminders set by the command being handled
+	reminders []Reminder
+}
+
+func NewAggregateBase[TState any](apply func(*TState, Event) error) AggregateBase[TState] {
+	return AggregateBase[TState]{apply: apply}
+}
+
+// Apply folds an event into the state and advances the version.
+func (a *AggregateBase[TState]) Apply(e Event) error {
+	if err := a.apply(&a.State, e); err != nil {
+		return err
+	}
+	a.version++
+	return nil
+}
+
+// Raise applies a new event and records it as uncommitted.
+func (a *AggregateBase[TState]) Raise(e Event) error {
+	if err := a.Apply(e); err != nil {
+		return err
+	}
+	a.changes = append(a.changes, e)
+	return nil
+}
+
+// Version is the number of events applied, including uncommitted ones.
+func (a *AggregateBase[TState]) Version() int64 {
+	return a.version
+}
+
+// Changes returns the uncommitted events raised so far.
+func (a *AggregateBase[TState]) Changes() []Event {
+	return a.changes
+}
+
+// TakeChanges returns the uncommitted events and clears them, which is what
+// HandleCommand usually returns.
+func (a *AggregateBase[TState]) TakeChanges() []Event {
+	changes := a.changes
+	a.changes = nil
+	return changes
+}
+
+// RemindAt asks for cmd, a command to this same aggregate, to be sent at t.
+// The reminder is scheduled once the events of the command being handled
+// are recorded, by an AggregateHandler created WithReminders, and dropped
+// if handling fails.
+func (a *AggregateBase[TState]) RemindAt(t time.Time, cmd Command) {
+	a.reminders = append(a.reminders, Reminder{At: t, Command: cmd})
+}
+
+// MarshalSnapshot serializes the state as JSON.
+func (a *AggregateBase[TState]) MarshalSnapshot() ([]byte, error) {
+	return json.Marshal(a.State)
+}
+
+// UnmarshalSnapshot restores the state MarshalSnapshot serialized, as of
+// version.
+func (a *AggregateBase[TState]) UnmarshalSnapshot(data []byte, version int64) error {
+	var state TState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	a.State, a.version, a.changes = state, version, nil
+	return nil
+}
+
+// TakeReminders returns the reminders set so far and clears them.
+func (a *AggregateBase[TState]) TakeReminders() []Reminder {
+	reminders := a.reminders
+	a.reminders = nil
+	return reminders
+}
@@ -0,0 +1,100 @@
+package evoke
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregateBase(t *testing.T) {
+	c := newCart(NewID()).(*cart)
+	if err := c.Apply(itemAdded{SKU: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version() != 1 || len(c.Changes()) != 0 {
+		t.Fatalf("after applying history: version %d, %d changes; want 1, none", c.Version(), len(c.Changes()))
+	}
+
+	for _, e := range []Event{itemAdded{SKU: "b"}, itemRemoved{SKU: "a"}} {
+		if err := c.Raise(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.State.Items != 1 || c.Version() != 3 {
+		t.Errorf("state %+v at version %d, want 1 item at version 3", c.State, c.Version())
+	}
+	want := []Event{itemAdded{SKU: "b"}, itemRemoved{SKU: "a"}}
+	if got := c.Changes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes %v, want %v", got, want)
+	}
+
+	if got := c.TakeChanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TakeChanges %v, want %v", got, want)
+	}
+	if got := c.TakeChanges(); len(got) != 0 {
+		t.Errorf("TakeChanges again returned %v, want nothing", got)
+	}
+	if c.Version() != 3 {
+		t.Errorf("taking changes moved the version to %d", c.Version())
+	}
+}
+
+// An event the apply function rejects is neither counted nor kept.
+func TestAggregateBaseRaiseFails(t *testing.T) {
+	errRejected := errors.New("rejected")
+	a := NewAggregateBase(func(n *int, e Event) error {
+		if _, ok := e.(itemRemoved); ok {
+			return errRejected
+		}
+		*n++
+		return nil
+	})
+	if err := a.Raise(itemRemoved{}); !errors.Is(err, errRejected) {
+		t.Fatalf("Raise: %v, want the apply error", err)
+	}
+	if a.Version() != 0 || len(a.Changes()) != 0 {
+		t.Errorf("a rejected event left version %d and changes %v", a.Version(), a.Changes())
+	}
+}
+
+func TestAggregateBaseReminders(t *testing.T) {
+	c := newCart(NewID()).(*cart)
+	at := time.Now().Add(time.Hour)
+	c.RemindAt(at, addItem{SKU: "a"})
+
+	want := []Reminder{{At: at, Command: addItem{SKU: "a"}}}
+	if got := c.TakeReminders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TakeReminders %v, want %v", got, want)
+	}
+	if got := c.TakeReminders(); len(got) != 0 {
+		t.Errorf("TakeReminders again returned %v, want nothing", got)
+	}
+}
+
+func TestAggregateBaseSnapshot(t *testing.T) {
+	c := newCart(NewID()).(*cart)
+	for _, e := range []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}} {
+		if err := c.Apply(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := c.MarshalSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newCart(NewID()).(*cart)
+	if err := restored.Raise(itemAdded{SKU: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.UnmarshalSnapshot(data, 2); err != nil {
+		t.Fatal(err)
+	}
+	if restored.State != c.State || restored.Version() != 2 || len(restored.Changes()) != 0 {
+		t.Errorf("restored %+v at version %d with %d changes, want %+v at 2 with none", restored.State, restored.Version(), len(restored.Changes()), c.State)
+	}
+	if err := restored.UnmarshalSnapshot([]byte("not json"), 3); err == nil {
+		t.Error("restored a snapshot that isn't JSON")
+	}
+}
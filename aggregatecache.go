@@ -0,0 +1,91 @@
+package evoke
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type AggregateHandlerOption func(*AggregateHandler)
+
+// WithHydrationCache keeps up to maxSize hydrated aggregates in memory, so a
+// hot aggregate only has the events recorded since it was cached applied to
+// it instead of being rebuilt from its first event on every command.
+//
+// A cached aggregate is checked out for the duration of a command, so
+// concurrent commands for the same aggregate never share an instance; the
+// loser of such a race simply hydrates from the store. An aggregate whose
+// command fails is dropped, since it may have been left half mutated.
+//
+// After a successful command the cached aggregate is advanced by applying
+// the new events, unless it reports through a Version() method (as
+// AggregateBase does) that it already applied them while handling the
+// command. Only stores that check versions, VersionedRecorders, have
+// aggregates cached, since with others events recorded by another writer
+// between hydrating and recording would be missed.
+func WithHydrationCache(maxSize int) AggregateHandlerOption {
+	return func(h *AggregateHandler) {
+		h.cache = newAggregateCache(maxSize)
+	}
+}
+
+type cachedAggregate struct {
+	id      uuid.UUID
+	agg     Aggregate
+	version int64
+	// snapshot is the aggregate's latest snapshot, without its state
+	snapshot Snapshot
+}
+
+type aggregateCache struct {
+	mu      sync.Mutex
+	maxSize int
+	order   *list.List
+	entries map[uuid.UUID]*list.Element
+}
+
+func newAggregateCache(maxSize int) *aggregateCache {
+	return &aggregateCache{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[uuid.UUID]*list.Element),
+	}
+}
+
+// checkout removes and returns the cached aggregate for id, if any
+func (c *aggregateCache) checkout(id uuid.UUID) (*cachedAggregate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	c.order.Remove(el)
+	delete(c.entries, id)
+	return el.Value.(*cachedAggregate), true
+}
+
+// checkin caches agg as hydrated up to version, evicting the least recently
+// used aggregates beyond maxSize
+func (c *aggregateCache) checkin(id uuid.UUID, agg Aggregate, version int64, snap Snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[id]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[id] = c.order.PushFront(&cachedAggregate{id: id, agg: agg, version: version, snapshot: snap})
+	for c.order.Len() > c.maxSize {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*cachedAggregate).id)
+	}
+}
+
+// Invalidate drops a cached aggregate, for example after its stream was
+// changed outside of this handler.
+func (h *AggregateHandler) Invalidate(id uuid.UUID) {
+	if h.cache != nil {
+		h.cache.checkout(id)
+	}
+}
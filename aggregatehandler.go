@@ -1,7 +1,11 @@
 package evoke
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -9,50 +13,232 @@ import (
 type AggregateHandler struct {
 	aggregateFactory func(id uuid.UUID) Aggregate
 	store            EventStore
+	cache            *aggregateCache
+	snapshots        *snapshots
+	tracer           Tracer
+	logger           Logger
+	conflictRetries  int
+	conflictBackoff  time.Duration
+	reminders        ReminderScheduler
 }
 
-func NewAggregateHandler(store EventStore, factory func(id uuid.UUID) Aggregate) *AggregateHandler {
-	return &AggregateHandler{
+// WithConflictRetries sets how many times a command whose events lose a race
+// with another writer is retried against the freshly hydrated aggregate,
+// waiting backoff before the first retry and twice as long before each
+// next one. The default is 3 retries from 10ms; 0 disables retries.
+func WithConflictRetries(retries int, backoff time.Duration) AggregateHandlerOption {
+	return func(h *AggregateHandler) {
+		h.conflictRetries = retries
+		h.conflictBackoff = backoff
+	}
+}
+
+func NewAggregateHandler(store EventStore, factory func(id uuid.UUID) Aggregate, opts ...AggregateHandlerOption) *AggregateHandler {
+	h := &AggregateHandler{
 		aggregateFactory: factory,
 		store:            store,
+		tracer:           nopTracer{},
+		logger:           slog.Default(),
+		conflictRetries:  3,
+		conflictBackoff:  10 * time.Millisecond,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func NewAggregateHandler2(store EventStore, factory func(id uuid.UUID) Aggregate) *AggregateHandler {
 	return &AggregateHandler{
 		aggregateFactory: factory,
 		store:            store,
+		tracer:           nopTracer{},
+		logger:           slog.Default(),
+		conflictRetries:  3,
+		conflictBackoff:  10 * time.Millisecond,
 	}
 }
 
 func (h *AggregateHandler) Handle(cmd Command) error {
-	aggID := cmd.AggregateID()
+	return h.HandleContext(context.Background(), cmd)
+}
 
-	// rehydrate aggregate from store
-	agg := h.aggregateFactory(aggID)
-	recs, err := h.store.LoadStream(aggID)
-	if err != nil {
-		return fmt.Errorf("LoadStream(%s): %w", aggID, err)
+// HandleContext handles a command as part of the trace in ctx, which is
+// passed on to the store when it accepts one. A command that fails with
+// ErrConcurrencyConflict is retried as set by WithConflictRetries.
+func (h *AggregateHandler) HandleContext(ctx context.Context, cmd Command) error {
+	backoff := h.conflictBackoff
+	for retry := 0; ; retry++ {
+		err := h.handle(ctx, cmd)
+		if !errors.Is(err, ErrConcurrencyConflict) || retry == h.conflictRetries {
+			if err != nil && retry > 0 {
+				return fmt.Errorf("%w (after %d retries)", err, retry)
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
 	}
+}
 
-	for _, rec := range recs {
-		err := agg.Apply(rec.Event)
-		if err != nil {
-			return fmt.Errorf("Apply(%T): %w", rec.Event, err)
-		}
+// handle makes one attempt at handling a command. Stores supporting
+// optimistic concurrency only take its events if no others were recorded
+// to the stream since it was hydrated.
+func (h *AggregateHandler) handle(ctx context.Context, cmd Command) error {
+	aggID := cmd.AggregateID()
+
+	// rehydrate aggregate, from the cache if possible, then the store
+	info, err := h.hydrate(aggID)
+	if err != nil {
+		return err
 	}
+	agg, version := info.Aggregate, info.Version
 
 	// handle command
+	_, end := h.tracer.Start(ctx, TypeName(agg)+".HandleCommand "+TypeName(cmd))
+	before, counted := appliedEvents(agg)
 	newEvents, err := agg.HandleCommand(cmd)
+	after, _ := appliedEvents(agg)
+	end(err)
+	reminders, rerr := h.takeReminders(agg, aggID)
 	if err != nil {
 		return fmt.Errorf("%T.HandleCommand(%T): error: %w", agg, cmd, err)
 	}
+	if rerr != nil {
+		return rerr
+	}
 
 	// persist
-	err = h.store.Record(aggID, newEvents)
-	if err != nil {
+	if err := recordAggregate(ctx, h.store, agg, aggID, version, newEvents); err != nil {
 		return err
 	}
+	noteHandled(ctx, aggID, version, newEvents)
+
+	if h.cache != nil || h.snapshots != nil {
+		// the aggregate applied its events itself if it counted as many
+		// more while handling the command, as those raised on an
+		// AggregateBase are
+		h.advance(info, newEvents, counted && after-before == int64(len(newEvents)))
+	}
+
+	return h.scheduleReminders(reminders)
+}
+
+// hydrate returns the aggregate a command is for, brought up to date from
+// the cache, or its latest snapshot, and the store
+func (h *AggregateHandler) hydrate(aggID uuid.UUID) (SnapshotInfo, error) {
+	var cached *cachedAggregate
+	if h.cache != nil {
+		cached, _ = h.cache.checkout(aggID)
+	}
+	if cached == nil {
+		return hydrateAggregate(h.store, h.snapshots, func() Aggregate { return h.aggregateFactory(aggID) }, aggID)
+	}
+
+	start := time.Now()
+	info := SnapshotInfo{AggregateID: aggID, Aggregate: cached.agg, Snapshot: cached.snapshot}
+	recs, err := loadStreamAfter(h.store, aggID, cached.version)
+	if err != nil {
+		return info, err
+	}
+	info.Version, err = hydrate(cached.agg, recs, cached.version)
+	if err != nil {
+		return info, err
+	}
+	info.Replayed = recs
+	info.HydrationTime = time.Since(start)
+	return info, nil
+}
+
+// loadStreamAfter returns the events of a stream after version, reading
+// only those if the store can page streams
+func loadStreamAfter(store EventStore, aggID uuid.UUID, version int64) ([]RecordedEvent, error) {
+	if pager, ok := store.(StreamPager); ok && version > 0 {
+		recs, err := pager.LoadStreamFrom(aggID, version+1, 0)
+		if err != nil {
+			return nil, fmt.Errorf("LoadStreamFrom(%s, %d): %w", aggID, version+1, err)
+		}
+		return recs, nil
+	}
+	recs, err := store.LoadStream(aggID)
+	if err != nil {
+		return nil, fmt.Errorf("LoadStream(%s): %w", aggID, err)
+	}
+	return recs, nil
+}
+
+// hydrate applies the events after version to agg, returning the version
+// it is at
+func hydrate(agg Aggregate, recs []RecordedEvent, version int64) (int64, error) {
+	for _, rec := range recs {
+		// stores that don't number stream versions leave them zero
+		if rec.Version == 0 {
+			rec.Version = version + 1
+		}
+		if rec.Version <= version {
+			continue
+		}
+		err := agg.Apply(rec.Event)
+		if err != nil {
+			return version, fmt.Errorf("Apply(%T): %w", rec.Event, err)
+		}
+		version = rec.Version
+	}
+	return version, nil
+}
+
+// recordAggregate records the events of an aggregate at version, checking
+// the version and keeping the aggregate's type if the store supports it
+func recordAggregate(ctx context.Context, store EventStore, agg Aggregate, aggID uuid.UUID, version int64, evs []Event) error {
+	if vr, ok := store.(VersionedRecorder); ok {
+		return vr.RecordAtVersion(ctx, TypeName(agg), aggID, version, evs)
+	}
+	if ar, ok := store.(AggregateRecorder); ok {
+		return ar.RecordAs(ctx, TypeName(agg), aggID, evs)
+	}
+	return store.Record(aggID, evs)
+}
+
+// appliedEvents returns how many events an aggregate that counts them has
+// applied
+func appliedEvents(agg Aggregate) (int64, bool) {
+	v, ok := agg.(interface{ Version() int64 })
+	if !ok {
+		return 0, false
+	}
+	return v.Version(), true
+}
+
+// advance brings an aggregate up to date with the events its command just
+// recorded, applying them unless it already has, then snapshots and caches
+// it
+func (h *AggregateHandler) advance(info SnapshotInfo, newEvents []Event, applied bool) {
+	// without a version check other events may have been recorded in
+	// between, which the aggregate hasn't seen, so it is hydrated afresh
+	// next time
+	if _, ok := h.store.(VersionedRecorder); !ok {
+		return
+	}
+
+	agg := info.Aggregate
+	next := info.Version + int64(len(newEvents))
+	if !applied {
+		for _, e := range newEvents {
+			if err := agg.Apply(e); err != nil {
+				return
+			}
+		}
+	}
+	info.Version = next
 
-	return nil
+	if h.snapshots != nil {
+		info.Snapshot = h.snapshots.take(info, h.logger)
+	}
+	if h.cache != nil {
+		h.cache.checkin(info.AggregateID, agg, next, info.Snapshot)
+	}
 }
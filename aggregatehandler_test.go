@@ -0,0 +1,210 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// racingStore records an event of another writer just before the first
+// append it is asked for
+type racingStore struct {
+	EventStore
+	raced bool
+}
+
+func (s *racingStore) Record(id uuid.UUID, evs []Event) error {
+	if !s.raced {
+		s.raced = true
+		if err := s.EventStore.Record(id, []Event{itemRemoved{SKU: "x"}}); err != nil {
+			return err
+		}
+	}
+	return s.EventStore.Record(id, evs)
+}
+
+// A hydration cache only keeps aggregates when the store checks versions;
+// otherwise one would miss events another writer recorded while its command
+// was handled.
+func TestHydrationCacheSeesOtherWriters(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(t *testing.T) EventStore
+		// items is what the second command sees in the cart
+		items int
+		// hydrations is how many times the aggregate is built from scratch
+		// over both commands
+		hydrations int
+	}{
+		{name: "versioned", store: func(t *testing.T) EventStore { return newTestStore(t) }, items: 1, hydrations: 1},
+		{name: "unversioned", store: func(*testing.T) EventStore { return &racingStore{EventStore: NewSimpleStore(nil)} }, items: 0, hydrations: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.store(t)
+			var hydrations int
+			h := NewAggregateHandler(store, func(id uuid.UUID) Aggregate {
+				hydrations++
+				return newCart(id)
+			}, WithHydrationCache(10))
+
+			id := NewID()
+			for _, sku := range []string{"a", "b"} {
+				if err := h.Handle(addItem{ID: id, SKU: sku}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			recs := mustLoad(t, store, id)
+			if last := recs[len(recs)-1].Event.(itemAdded); last.Qty != tt.items+1 {
+				t.Errorf("the second command saw %d items, want %d", last.Qty-1, tt.items)
+			}
+			if hydrations != tt.hydrations {
+				t.Errorf("hydrated %d times, want %d", hydrations, tt.hydrations)
+			}
+		})
+	}
+}
+
+// versionedRacingStore has another writer record an event to the stream
+// before each of the first races appends it checks the version of
+type versionedRacingStore struct {
+	*fileStore
+	races int
+}
+
+func (s *versionedRacingStore) RecordAtVersion(ctx context.Context, aggregateType string, id uuid.UUID, expected int64, evs []Event) error {
+	if s.races > 0 {
+		s.races--
+		if err := s.fileStore.Record(id, []Event{itemAdded{SKU: "other"}}); err != nil {
+			return err
+		}
+	}
+	return s.fileStore.RecordAtVersion(ctx, aggregateType, id, expected, evs)
+}
+
+func TestConflictRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		races   int
+		opts    []AggregateHandlerOption
+		wantErr bool
+	}{
+		{name: "no race", races: 0},
+		{name: "retried", races: 2},
+		{name: "out of retries", races: 4, wantErr: true},
+		{name: "retries disabled", races: 1, opts: []AggregateHandlerOption{WithConflictRetries(0, 0)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &versionedRacingStore{fileStore: newTestStore(t), races: tt.races}
+			opts := append([]AggregateHandlerOption{WithConflictRetries(3, time.Millisecond)}, tt.opts...)
+			h := NewAggregateHandler(store, newCart, opts...)
+			id := NewID()
+			err := h.Handle(addItem{ID: id, SKU: "a"})
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				recs := mustLoad(t, store, id)
+				if last := recs[len(recs)-1].Event.(itemAdded); last.SKU != "a" || last.Qty != len(recs) {
+					t.Errorf("recorded %+v, want the command's event handled after the other writer's", last)
+				}
+				return
+			}
+			if !errors.Is(err, ErrConcurrencyConflict) {
+				t.Errorf("Handle returned %v, want ErrConcurrencyConflict", err)
+			}
+			for _, rec := range mustLoad(t, store, id) {
+				if rec.Event.(itemAdded).SKU == "a" {
+					t.Error("recorded the command's events despite the conflict")
+				}
+			}
+		})
+	}
+}
+
+func TestConflictRetryStopsOnCancel(t *testing.T) {
+	store := &versionedRacingStore{fileStore: newTestStore(t), races: 10}
+	h := NewAggregateHandler(store, newCart, WithConflictRetries(3, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.HandleContext(ctx, addItem{ID: NewID(), SKU: "a"}); !errors.Is(err, ErrConcurrencyConflict) {
+		t.Errorf("HandleContext returned %v, want the conflict once ctx is done", err)
+	}
+}
+
+// remindingCart handles commands like a cart, then sets a reminder for the
+// aggregate remind
+type remindingCart struct {
+	*cart
+	remind uuid.UUID
+	at     time.Time
+}
+
+func (c remindingCart) HandleCommand(cmd Command) ([]Event, error) {
+	evs, err := c.cart.HandleCommand(cmd)
+	c.RemindAt(c.at, addItem{ID: c.remind, SKU: "reminder"})
+	return evs, err
+}
+
+// recordingReminders keeps the reminders scheduled with it
+type recordingReminders struct {
+	scheduled []Reminder
+}
+
+func (r *recordingReminders) SendAt(cmd Command, at time.Time) error {
+	r.scheduled = append(r.scheduled, Reminder{At: at, Command: cmd})
+	return nil
+}
+
+func TestReminders(t *testing.T) {
+	at := time.Now().Add(time.Hour)
+	id := NewID()
+	tests := []struct {
+		name string
+		// remind is the aggregate the reminder is for
+		remind    uuid.UUID
+		scheduler bool
+		wantErr   bool
+	}{
+		{name: "scheduled", remind: id, scheduler: true},
+		{name: "without WithReminders", remind: id, wantErr: true},
+		{name: "for another aggregate", remind: NewID(), scheduler: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			var reminders recordingReminders
+			var opts []AggregateHandlerOption
+			if tt.scheduler {
+				opts = append(opts, WithReminders(&reminders))
+			}
+			h := NewAggregateHandler(s, func(id uuid.UUID) Aggregate {
+				return remindingCart{cart: newCart(id).(*cart), remind: tt.remind, at: at}
+			}, opts...)
+
+			err := h.Handle(addItem{ID: id, SKU: "a"})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Handle succeeded")
+				}
+				if n := len(mustLoad(t, s, id)); n != 0 {
+					t.Errorf("recorded %d events of a command with a bad reminder", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Reminder{{At: at, Command: addItem{ID: id, SKU: "reminder"}}}
+			if !reflect.DeepEqual(reminders.scheduled, want) {
+				t.Errorf("scheduled %+v, want %+v", reminders.scheduled, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package evoke
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AsOf is a point in the past of the log: loading a stream as of it leaves
+// out the events recorded after it. A zero field doesn't limit.
+type AsOf struct {
+	// Sequence is the last sequence included
+	Sequence int64
+	// Time is the last time included, to the second timestamps are
+	// recorded with
+	Time time.Time
+}
+
+// includes reports whether rec was recorded by the point
+func (a AsOf) includes(rec RecordedEvent) bool {
+	if a.Sequence > 0 && rec.Sequence > a.Sequence {
+		return false
+	}
+	if !a.Time.IsZero() && rec.RecordedAt > a.Time.Unix() {
+		return false
+	}
+	return true
+}
+
+// AsOfLoader is implemented by stores that can load a stream as it was at
+// a point in the past without reading the events after it.
+type AsOfLoader interface {
+	LoadStreamAsOf(aggregateID uuid.UUID, asOf AsOf) ([]RecordedEvent, error)
+}
+
+// LoadStreamAsOf returns the events of a stream of store recorded by asOf,
+// loading the whole stream if the store isn't an AsOfLoader.
+func LoadStreamAsOf(store EventStore, aggregateID uuid.UUID, asOf AsOf) ([]RecordedEvent, error) {
+	if loader, ok := store.(AsOfLoader); ok {
+		return loader.LoadStreamAsOf(aggregateID, asOf)
+	}
+	recs, err := store.LoadStream(aggregateID)
+	if err != nil {
+		return nil, err
+	}
+	return asOf.filter(recs), nil
+}
+
+// filter returns the events of a stream recorded by the point; streams are
+// in sequence order, so it cuts them at the first event after it
+func (a AsOf) filter(recs []RecordedEvent) []RecordedEvent {
+	for i, rec := range recs {
+		if !a.includes(rec) {
+			return recs[:i]
+		}
+	}
+	return recs
+}
+
+// LoadAsOf returns the aggregate as it was at asOf, to see why it did what
+// it did then. Saving it records its changes on top of the current stream,
+// if the store doesn't refuse them as a concurrency conflict.
+func (r *Repository[T]) LoadAsOf(ctx context.Context, id uuid.UUID, asOf AsOf) (T, error) {
+	agg := r.factory(id)
+	recs, err := LoadStreamAsOf(r.store, id, asOf)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if _, err := hydrate(agg, recs, 0); err != nil {
+		var zero T
+		return zero, err
+	}
+	return agg, nil
+}
+
+// LoadAsOf returns the aggregate the handler would have handled a command
+// with at asOf, and its version then.
+func (h *AggregateHandler) LoadAsOf(id uuid.UUID, asOf AsOf) (Aggregate, int64, error) {
+	agg := h.aggregateFactory(id)
+	recs, err := LoadStreamAsOf(h.store, id, asOf)
+	if err != nil {
+		return nil, 0, err
+	}
+	version, err := hydrate(agg, recs, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return agg, version, nil
+}
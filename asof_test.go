@@ -0,0 +1,92 @@
+package evoke
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadStreamAsOf(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			a, _ := recordInterleaved(t, s)
+			for _, tt := range []struct {
+				asOf AsOf
+				want int
+			}{
+				{AsOf{}, 3},
+				{AsOf{Sequence: 1}, 1},
+				{AsOf{Sequence: 3}, 2},
+				{AsOf{Sequence: 5}, 3},
+				{AsOf{Time: time.Now().Add(-time.Hour)}, 0},
+				{AsOf{Sequence: 4, Time: time.Now().Add(time.Hour)}, 3},
+			} {
+				recs, err := LoadStreamAsOf(s, a, tt.asOf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(recs) != tt.want {
+					t.Errorf("loaded %d events as of %+v, want %d", len(recs), tt.asOf, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// eventsOnly hides the methods of a store beyond EventStore
+type eventsOnly struct{ EventStore }
+
+func TestLoadStreamAsOfTime(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{}, itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.db.Exec(`update events set recorded_at = ? + (version - 1) * 86400`, day.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		at   time.Time
+		want int
+	}{
+		{day.Add(-time.Second), 0},
+		{day, 1},
+		{day.Add(36 * time.Hour), 2},
+		{day.AddDate(0, 0, 2), 3},
+	} {
+		for _, store := range []EventStore{s, eventsOnly{s}} {
+			recs, err := LoadStreamAsOf(store, id, AsOf{Time: tt.at})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recs) != tt.want {
+				t.Errorf("%T loaded %d events as of %v, want %d", store, len(recs), tt.at, tt.want)
+			}
+		}
+	}
+}
+
+func TestLoadAsOf(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{}, itemAdded{}, itemRemoved{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewRepository(s, newSavedCart).LoadAsOf(context.Background(), id, AsOf{Sequence: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version() != 2 || c.State.Items != 2 {
+		t.Errorf("repository loaded %d items at version %d, want the cart as of sequence 2", c.State.Items, c.Version())
+	}
+
+	agg, version, err := NewAggregateHandler(s, newCart).LoadAsOf(id, AsOf{Sequence: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 || agg.(*cart).State.Items != 1 {
+		t.Errorf("handler loaded %d items at version %d, want the cart as of sequence 1", agg.(*cart).State.Items, version)
+	}
+}
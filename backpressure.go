@@ -0,0 +1,173 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var errLaneClosed = errors.New("lane shut down")
+
+// rateLimiter spaces out calls to wait to at most rate a second. A nil
+// limiter, or one with a rate <= 0, doesn't wait.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+func newRateLimiter(eventsPerSecond float64) *rateLimiter {
+	return &rateLimiter{rate: eventsPerSecond}
+}
+
+func (l *rateLimiter) setRate(eventsPerSecond float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = eventsPerSecond
+}
+
+func (l *rateLimiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	at := later(l.next, now)
+	l.next = at.Add(time.Duration(float64(time.Second) / l.rate))
+	l.mu.Unlock()
+	time.Sleep(at.Sub(now))
+}
+
+// later returns the later of two times
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// lane runs functions in order on its own goroutine, through a bounded
+// queue: enqueueing blocks while the queue is full, slowing whoever feeds
+// the lane rather than letting the queue grow
+type lane struct {
+	// mu is held for reading while enqueueing, so closing waits for
+	// senders blocked on a full queue
+	mu     sync.RWMutex
+	closed bool
+	queue  chan func()
+	limit  *rateLimiter
+	done   chan struct{}
+}
+
+func newLane(size int, limit *rateLimiter) *lane {
+	l := &lane{queue: make(chan func(), size), limit: limit, done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		for fn := range l.queue {
+			l.limit.wait()
+			fn()
+		}
+	}()
+	return l
+}
+
+func (l *lane) enqueue(fn func()) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return errLaneClosed
+	}
+	l.queue <- fn
+	return nil
+}
+
+// shutdown stops taking functions and waits for the queued ones to run,
+// giving up when ctx is done
+func (l *lane) shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mu.Unlock()
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RateLimit hands the subscriber at most eventsPerSecond events a second.
+// Unless the subscription is also Buffered, publishing waits for it.
+func RateLimit(eventsPerSecond float64) SubscribeOption {
+	return func(s *subscription) {
+		s.limit = newRateLimiter(eventsPerSecond)
+	}
+}
+
+// Buffered hands events to the subscriber from a goroutine of its own,
+// through a queue of up to size events, so a slow subscriber such as a
+// webhook only holds up publishing once its queue is full, and never the
+// subscribers after it. Failures of a buffered subscriber can't fail the
+// publish: FailFast ones are logged, the other policies apply as usual.
+// Shutdown the bus to wait for the queues to drain.
+func Buffered(size int) SubscribeOption {
+	return func(s *subscription) {
+		s.buffer = size
+	}
+}
+
+// Lane publishes events to a slow publisher from a goroutine of its own,
+// through a bounded queue, so that recording only waits for the publisher
+// once the queue is full, rather than for every event, and memory stays
+// bounded. The publisher's errors are logged, not returned; use a
+// DeliveryWorker for delivery that must not miss events.
+type Lane struct {
+	publisher RecordedEventPublisher
+	lane      *lane
+	limit     *rateLimiter
+	logger    Logger
+}
+
+// NewLane returns a lane to publisher queueing up to size events.
+func NewLane(publisher RecordedEventPublisher, size int) *Lane {
+	limit := newRateLimiter(0)
+	return &Lane{
+		publisher: publisher,
+		lane:      newLane(size, limit),
+		limit:     limit,
+		logger:    slog.Default(),
+	}
+}
+
+// SetRateLimit publishes at most eventsPerSecond events a second; 0, the
+// default, is no limit.
+func (l *Lane) SetRateLimit(eventsPerSecond float64) {
+	l.limit.setRate(eventsPerSecond)
+}
+
+func (l *Lane) SetLogger(logger Logger) {
+	l.logger = logger
+}
+
+// Publish queues rec for the publisher, waiting while the queue is full.
+func (l *Lane) Publish(rec RecordedEvent, replay bool) error {
+	return l.lane.enqueue(func() {
+		if err := recovered(func() error { return l.publisher.Publish(rec, replay) }); err != nil {
+			l.logger.Error("evoke: lane publish failed", "event_type", rec.EventType, "sequence", rec.Sequence, "error", err)
+		}
+	})
+}
+
+// Shutdown stops taking events and waits for the queued ones to be
+// published, giving up when ctx is done.
+func (l *Lane) Shutdown(ctx context.Context) error {
+	return l.lane.shutdown(ctx)
+}
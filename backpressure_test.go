@@ -0,0 +1,156 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// blockingHandler handles events once release is closed, sending each on
+// handled
+type blockingHandler struct {
+	release chan struct{}
+	handled chan Event
+}
+
+func newBlockingHandler() blockingHandler {
+	return blockingHandler{release: make(chan struct{}), handled: make(chan Event, 10)}
+}
+
+func (h blockingHandler) Handle(e Event, replay bool) error {
+	<-h.release
+	h.handled <- e
+	return nil
+}
+
+func TestRateLimit(t *testing.T) {
+	bus := NewEventBus()
+	var log handlerLog
+	bus.Subscribe(itemAdded{}, log.handler("limited"), RateLimit(100))
+	start := time.Now()
+	for range 5 {
+		if err := bus.Publish(RecordedEvent{Event: itemAdded{}}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("published 5 events in %v, want at most 100 a second", elapsed)
+	}
+	if n := len(log.handled()); n != 5 {
+		t.Errorf("handled %d events", n)
+	}
+}
+
+func TestBuffered(t *testing.T) {
+	bus := NewEventBus()
+	slow := newBlockingHandler()
+	var log handlerLog
+	bus.Subscribe(itemAdded{}, slow, Buffered(1))
+	bus.Subscribe(itemAdded{}, log.handler("fast"))
+	publish := func() {
+		if err := bus.Publish(RecordedEvent{Event: itemAdded{}}, false); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// one event is being handled and one is queued
+	publish()
+	time.Sleep(10 * time.Millisecond)
+	publish()
+	if n := len(log.handled()); n != 2 {
+		t.Errorf("subscriber after the buffered one handled %d events, want it not held up", n)
+	}
+	done := make(chan struct{})
+	go func() {
+		publish()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("published past a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(slow.release)
+	<-done
+
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(slow.handled); n != 3 {
+		t.Errorf("buffered subscriber handled %d events by Shutdown, want all 3", n)
+	}
+	if err := bus.Publish(RecordedEvent{Event: itemAdded{}}, false); err == nil {
+		t.Error("published to a buffered subscriber after Shutdown")
+	}
+}
+
+func TestBufferedFailures(t *testing.T) {
+	bus := NewEventBus()
+	var logger recordingLogger
+	bus.SetLogger(&logger)
+	bus.Subscribe(itemAdded{}, failingHandler{err: errors.New("webhook down")}, Buffered(1))
+	if err := bus.Publish(RecordedEvent{Event: itemAdded{}}, false); err != nil {
+		t.Errorf("Publish returned %v, want a buffered subscriber's failure not to fail it", err)
+	}
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := logger.logged(); !slices.Contains(got, "error: evoke: buffered event handler failed") {
+		t.Errorf("logged %q, want the failure", got)
+	}
+}
+
+func TestBufferedShutdownTimeout(t *testing.T) {
+	bus := NewEventBus()
+	slow := newBlockingHandler()
+	defer close(slow.release)
+	bus.Subscribe(itemAdded{}, slow, Buffered(1))
+	if err := bus.Publish(RecordedEvent{Event: itemAdded{}}, false); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bus.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown returned %v, want it to give up", err)
+	}
+}
+
+// panickingPublisher panics on every publish
+type panickingPublisher struct{}
+
+func (panickingPublisher) Publish(RecordedEvent, bool) error { panic("broker gone") }
+
+func TestLane(t *testing.T) {
+	var pub recordingPublisher
+	l := NewLane(&pub, 2)
+	l.SetRateLimit(200)
+	for seq := range int64(4) {
+		if err := l.Publish(RecordedEvent{Sequence: seq + 1, Event: itemAdded{}}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(pub.published()); !slices.Equal(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("published %v, want the events in order", got)
+	}
+	if err := l.Publish(RecordedEvent{Event: itemAdded{}}, false); err == nil {
+		t.Error("published after Shutdown")
+	}
+
+	var logger recordingLogger
+	l = NewLane(panickingPublisher{}, 1)
+	l.SetLogger(&logger)
+	if err := l.Publish(RecordedEvent{Event: itemAdded{}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := logger.logged(); !slices.Equal(got, []string{"error: evoke: lane publish failed"}) {
+		t.Errorf("logged %q, want the publisher's panic", got)
+	}
+}
@@ -0,0 +1,169 @@
+package evoke
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// States of a CircuitBreaker.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+var errCircuitOpen = errors.New("circuit open")
+
+type heldEvent struct {
+	rec    RecordedEvent
+	replay bool
+}
+
+// CircuitBreaker wraps a publisher to an external system so a downstream
+// outage doesn't stall publishing. After SetThreshold failures in a row the
+// circuit opens: events are held back, without calling the publisher, and
+// publishing them succeeds. Once the cooldown has passed, the next event
+// probes the publisher with the held events, in order, closing the circuit
+// if they all go through and opening it again otherwise.
+//
+// Up to SetBufferSize events are held in memory; events beyond that go to
+// the dead letter queue if one is set and are dropped otherwise. Held
+// events are lost if the process stops; use a DeliveryWorker for delivery
+// that must not miss events.
+type CircuitBreaker struct {
+	publisher   RecordedEventPublisher
+	mu          sync.Mutex
+	state       string
+	failures    int
+	openedAt    time.Time
+	held        []heldEvent
+	threshold   int
+	cooldown    time.Duration
+	bufferSize  int
+	deadLetters DeadLetterQueue
+	logger      Logger
+}
+
+// NewCircuitBreaker returns a breaker around publisher, opening after 5
+// failures in a row for 30 seconds and holding up to 1000 events.
+func NewCircuitBreaker(publisher RecordedEventPublisher) *CircuitBreaker {
+	return &CircuitBreaker{
+		publisher:  publisher,
+		state:      CircuitClosed,
+		threshold:  5,
+		cooldown:   30 * time.Second,
+		bufferSize: 1000,
+		logger:     slog.Default(),
+	}
+}
+
+// SetThreshold sets how many failures in a row open the circuit. Failures
+// before that are returned to the caller.
+func (c *CircuitBreaker) SetThreshold(n int) {
+	c.threshold = n
+}
+
+// SetCooldown sets how long the circuit stays open before probing the
+// publisher again.
+func (c *CircuitBreaker) SetCooldown(d time.Duration) {
+	c.cooldown = d
+}
+
+// SetBufferSize sets how many events are held while the circuit is open.
+func (c *CircuitBreaker) SetBufferSize(n int) {
+	c.bufferSize = n
+}
+
+// SetDeadLetterQueue sets where events are sent that the circuit can't
+// hold.
+func (c *CircuitBreaker) SetDeadLetterQueue(q DeadLetterQueue) {
+	c.deadLetters = q
+}
+
+func (c *CircuitBreaker) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+// State returns CircuitClosed, CircuitOpen or CircuitHalfOpen.
+func (c *CircuitBreaker) State() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Held returns how many events are held back by the open circuit.
+func (c *CircuitBreaker) Held() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.held)
+}
+
+func (c *CircuitBreaker) Publish(rec RecordedEvent, replay bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitOpen {
+		if time.Since(c.openedAt) < c.cooldown {
+			return c.hold(rec, replay)
+		}
+		c.state = CircuitHalfOpen
+		if err := c.flush(); err != nil {
+			c.trip(err)
+			return c.hold(rec, replay)
+		}
+	}
+
+	err := recovered(func() error { return c.publisher.Publish(rec, replay) })
+	if err == nil {
+		if c.state != CircuitClosed {
+			c.logger.Info("evoke: circuit closed")
+		}
+		c.state, c.failures = CircuitClosed, 0
+		return nil
+	}
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= c.threshold {
+		c.trip(err)
+		return c.hold(rec, replay)
+	}
+	return err
+}
+
+// flush publishes the held events in order, keeping those it didn't get
+// to. Callers hold c.mu.
+func (c *CircuitBreaker) flush() error {
+	for len(c.held) > 0 {
+		h := c.held[0]
+		if err := recovered(func() error { return c.publisher.Publish(h.rec, h.replay) }); err != nil {
+			return fmt.Errorf("publish held event %d: %w", h.rec.Sequence, err)
+		}
+		c.held = c.held[1:]
+	}
+	c.held = nil
+	return nil
+}
+
+// trip opens the circuit. Callers hold c.mu.
+func (c *CircuitBreaker) trip(err error) {
+	c.logger.Warn("evoke: circuit open", "failures", c.failures, "cooldown", c.cooldown, "error", err)
+	c.state, c.openedAt = CircuitOpen, time.Now()
+}
+
+// hold keeps an event back while the circuit is open. Callers hold c.mu.
+func (c *CircuitBreaker) hold(rec RecordedEvent, replay bool) error {
+	if len(c.held) < c.bufferSize {
+		c.held = append(c.held, heldEvent{rec: rec, replay: replay})
+		return nil
+	}
+	if c.deadLetters != nil {
+		if err := c.deadLetters.DeadLetter(rec, errCircuitOpen); err != nil {
+			return fmt.Errorf("dead-letter event %d: %w", rec.Sequence, err)
+		}
+		return nil
+	}
+	c.logger.Error("evoke: circuit open and buffer full, dropping event", "event_type", rec.EventType, "sequence", rec.Sequence)
+	return nil
+}
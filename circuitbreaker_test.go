@@ -0,0 +1,125 @@
+package evoke
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// outagePublisher records events, failing while down
+type outagePublisher struct {
+	recordingPublisher
+	mu    sync.Mutex
+	down  bool
+	calls int
+}
+
+func (p *outagePublisher) setDown(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = down
+}
+
+func (p *outagePublisher) Publish(rec RecordedEvent, replay bool) error {
+	p.mu.Lock()
+	p.calls++
+	down := p.down
+	p.mu.Unlock()
+	if down {
+		return errors.New("webhook down")
+	}
+	return p.recordingPublisher.Publish(rec, replay)
+}
+
+func newTestBreaker(p RecordedEventPublisher) *CircuitBreaker {
+	c := NewCircuitBreaker(p)
+	c.SetThreshold(2)
+	c.SetCooldown(time.Hour)
+	c.SetLogger(&recordingLogger{})
+	return c
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	p := &outagePublisher{down: true}
+	c := newTestBreaker(p)
+	publish := func(seq int64) error {
+		return c.Publish(RecordedEvent{Sequence: seq, Event: itemAdded{}}, false)
+	}
+
+	if err := publish(1); err == nil || c.State() != CircuitClosed {
+		t.Fatalf("first failure returned %v in state %s, want the error with the circuit closed", err, c.State())
+	}
+	if err := publish(2); err != nil || c.State() != CircuitOpen {
+		t.Fatalf("failure at the threshold returned %v in state %s, want the circuit open", err, c.State())
+	}
+	if err := publish(3); err != nil || p.calls != 2 {
+		t.Errorf("publishing with the circuit open returned %v after %d calls, want the event held", err, p.calls)
+	}
+	if n := c.Held(); n != 2 {
+		t.Errorf("%d events held, want the two published since the circuit opened", n)
+	}
+
+	// a probe still failing opens the circuit again
+	c.SetCooldown(0)
+	if err := publish(4); err != nil || c.State() != CircuitOpen || c.Held() != 3 {
+		t.Errorf("failed probe returned %v in state %s with %d held", err, c.State(), c.Held())
+	}
+
+	p.setDown(false)
+	if err := publish(5); err != nil || c.State() != CircuitClosed || c.Held() != 0 {
+		t.Fatalf("probe returned %v in state %s with %d held, want the circuit closed", err, c.State(), c.Held())
+	}
+	if got := sequences(p.published()); !slices.Equal(got, []int64{2, 3, 4, 5}) {
+		t.Errorf("published %v, want the held events in order before the probe", got)
+	}
+}
+
+// A probe that gets the held events through but not its own event opens
+// the circuit again at once.
+func TestCircuitBreakerHalfOpenFailure(t *testing.T) {
+	p := &flakyPublisher{}
+	c := newTestBreaker(p)
+	c.SetThreshold(1)
+	c.SetCooldown(0)
+	for _, seq := range []int64{1, 2} {
+		p.failOn, p.failures = seq, 1
+		if err := c.Publish(RecordedEvent{Sequence: seq}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.State() != CircuitOpen || c.Held() != 1 {
+		t.Errorf("state %s with %d held, want the failed probe's event held", c.State(), c.Held())
+	}
+	if got := sequences(p.published()); !slices.Equal(got, []int64{1}) {
+		t.Errorf("published %v", got)
+	}
+}
+
+func TestCircuitBreakerBufferFull(t *testing.T) {
+	p := &outagePublisher{down: true}
+	c := newTestBreaker(p)
+	c.SetThreshold(1)
+	c.SetBufferSize(1)
+	var logger recordingLogger
+	c.SetLogger(&logger)
+	for _, seq := range []int64{1, 2} {
+		if err := c.Publish(RecordedEvent{Sequence: seq, Event: itemAdded{}}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Contains(logger.logged(), "error: evoke: circuit open and buffer full, dropping event") {
+		t.Errorf("logged %q, want the dropped event", logger.logged())
+	}
+
+	dlq := NewMemoryDeadLetterQueue()
+	c.SetDeadLetterQueue(dlq)
+	if err := c.Publish(RecordedEvent{Sequence: 3, Event: itemAdded{}}, false); err != nil {
+		t.Fatal(err)
+	}
+	entries := dlq.Entries()
+	if len(entries) != 1 || entries[0].Event.Sequence != 3 || c.Held() != 1 {
+		t.Errorf("dead-lettered %+v with %d held, want the event the circuit couldn't hold", entries, c.Held())
+	}
+}
@@ -0,0 +1,303 @@
+// Command evoke inspects and administers evoke file stores.
+//
+//	evoke -db events.db list [-from N] [-type T] [-aggregate ID] [-limit N]
+//	evoke -db events.db stream <aggregate-id>
+//	evoke -db events.db tail [-n N] [-f]
+//	evoke -db events.db stats
+//	evoke -db events.db export [-from N]
+//	evoke -db events.db import <file>
+//	evoke -db events.db backup <file>
+//	evoke -db events.db restore <file>
+//	evoke -db events.db tui [-n N]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+type command struct {
+	usage string
+	run   func(dbFile string, args []string) error
+}
+
+var commands = map[string]command{
+	"list":    {"list events, optionally filtered", runList},
+	"stream":  {"print every event of one aggregate", runStream},
+	"tail":    {"print the latest events, -f to follow", runTail},
+	"stats":   {"summarize the store", runStats},
+	"export":  {"write events as newline-delimited JSON", runExport},
+	"import":  {"append events exported from another store, - for stdin", runImport},
+	"backup":  {"snapshot the store to a file, - for stdout", runBackup},
+	"restore": {"replace the store's contents with a backup", runRestore},
+	"tui":     {"browse streams and follow new events interactively", runTUI},
+}
+
+func main() {
+	dbFile := flag.String("db", "events.db", "path to the sqlite store")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "evoke: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(*dbFile, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "evoke %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: evoke [-db file] <command> [args]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+type inspector interface {
+	ScanRaw(q evoke.RawQuery, fn func(evoke.RawEvent) error) error
+	Stats() (evoke.StoreStats, error)
+	Export(w io.Writer, fromSeq int64) error
+	Close() error
+}
+
+// openStore opens an existing store; it refuses to create a new empty one.
+func openStore(dbFile string) (inspector, error) {
+	if _, err := os.Stat(dbFile); err != nil {
+		return nil, err
+	}
+	return evoke.NewFileStore(dbFile)
+}
+
+func printEvent(e evoke.RawEvent) error {
+	ts := time.Unix(e.RecordedAt, 0).Format(time.RFC3339)
+	_, err := fmt.Printf("%d\t%s\t%s\t%s\t%s\n", e.Sequence, ts, e.AggregateID, e.EventType, e.Data)
+	return err
+}
+
+func runList(dbFile string, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	from := fs.Int64("from", 0, "start at this sequence")
+	eventType := fs.String("type", "", "only events of this type")
+	aggregate := fs.String("aggregate", "", "only events of this aggregate id")
+	limit := fs.Int("limit", 0, "maximum number of events")
+	fs.Parse(args)
+
+	q := evoke.RawQuery{FromSequence: *from, EventType: *eventType, Limit: *limit}
+	if *aggregate != "" {
+		id, err := uuid.Parse(*aggregate)
+		if err != nil {
+			return fmt.Errorf("aggregate id: %w", err)
+		}
+		q.AggregateID = id
+	}
+
+	store, err := openStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.ScanRaw(q, printEvent)
+}
+
+func runStream(dbFile string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: evoke stream <aggregate-id>")
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("aggregate id: %w", err)
+	}
+
+	store, err := openStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.ScanRaw(evoke.RawQuery{AggregateID: id}, printEvent)
+}
+
+func runTail(dbFile string, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	n := fs.Int64("n", 10, "number of events to show")
+	follow := fs.Bool("f", false, "keep polling for new events")
+	interval := fs.Duration("interval", 500*time.Millisecond, "poll interval with -f")
+	fs.Parse(args)
+
+	store, err := openStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	stats, err := store.Stats()
+	if err != nil {
+		return err
+	}
+	next := max(stats.LastSequence-*n+1, 0)
+
+	for {
+		err := store.ScanRaw(evoke.RawQuery{FromSequence: next}, func(e evoke.RawEvent) error {
+			next = e.Sequence + 1
+			return printEvent(e)
+		})
+		if err != nil {
+			return err
+		}
+		if !*follow {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
+
+func runStats(dbFile string, args []string) error {
+	store, err := openStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	stats, err := store.Stats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("events:        %d\n", stats.Events)
+	fmt.Printf("streams:       %d\n", stats.Streams)
+	fmt.Printf("last sequence: %d\n", stats.LastSequence)
+	if stats.Events > 0 {
+		fmt.Printf("first event:   %s\n", time.Unix(stats.FirstRecordedAt, 0).Format(time.RFC3339))
+		fmt.Printf("last event:    %s\n", time.Unix(stats.LastRecordedAt, 0).Format(time.RFC3339))
+	}
+
+	types := make([]string, 0, len(stats.EventTypes))
+	for t := range stats.EventTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Printf("  %-30s %d\n", t, stats.EventTypes[t])
+	}
+	return nil
+}
+
+func runExport(dbFile string, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	from := fs.Int64("from", 0, "start at this sequence")
+	fs.Parse(args)
+
+	store, err := openStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Export(os.Stdout, *from)
+}
+
+// runImport may create the store, so another store's events can seed a new
+// one
+func runImport(dbFile string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: evoke import <file>")
+	}
+
+	r := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	store, err := evoke.NewFileStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Import(r)
+}
+
+func runBackup(dbFile string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: evoke backup <file>")
+	}
+
+	if _, err := os.Stat(dbFile); err != nil {
+		return err
+	}
+	store, err := evoke.NewFileStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if args[0] == "-" {
+		return store.Backup(os.Stdout)
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if err := store.Backup(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runRestore may create the store, so a backup can be restored onto a fresh
+// machine
+func runRestore(dbFile string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: evoke restore <file>")
+	}
+
+	r := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	store, err := evoke.NewFileStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Restore(r)
+}
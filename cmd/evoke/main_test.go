@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+type itemAdded struct {
+	SKU string
+}
+
+// testDB writes a store with a cart of two events and an order of one,
+// returning its path and the cart's id
+func testDB(t *testing.T) (string, uuid.UUID) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := evoke.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evoke.RegisterEvent(s, &itemAdded{})
+	ctx := context.Background()
+	cartID := evoke.NewID()
+	if err := s.RecordAs(ctx, "Cart", cartID, []evoke.Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordAs(ctx, "Order", evoke.NewID(), []evoke.Event{itemAdded{SKU: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path, cartID
+}
+
+// capture returns what fn writes to stdout
+func capture(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	err = fn()
+	w.Close()
+	return <-out, err
+}
+
+// payloads returns the last column of each line printed by printEvent
+func payloads(out string) []string {
+	var data []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		data = append(data, fields[len(fields)-1])
+	}
+	return data
+}
+
+func TestList(t *testing.T) {
+	db, cartID := testDB(t)
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "everything", want: []string{`{"SKU":"a"}`, `{"SKU":"b"}`, `{"SKU":"c"}`}},
+		{name: "from", args: []string{"-from", "2"}, want: []string{`{"SKU":"b"}`, `{"SKU":"c"}`}},
+		{name: "aggregate", args: []string{"-aggregate", cartID.String()}, want: []string{`{"SKU":"a"}`, `{"SKU":"b"}`}},
+		{name: "type", args: []string{"-type", "itemSold"}},
+		{name: "limit", args: []string{"-limit", "1"}, want: []string{`{"SKU":"a"}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := capture(t, func() error { return runList(db, tt.args) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := payloads(out); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("listed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	db, cartID := testDB(t)
+	out, err := capture(t, func() error { return runStream(db, []string{cartID.String()}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("printed %q, want the cart's 2 events", out)
+	}
+	for i, line := range lines {
+		fields := strings.Split(line, "\t")
+		if fields[2] != cartID.String() || fields[3] != "itemAdded" {
+			t.Errorf("line %d is %q", i, line)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	db, _ := testDB(t)
+	out, err := capture(t, func() error { return runTail(db, []string{"-n", "2"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := payloads(out); strings.Join(got, " ") != `{"SKU":"b"} {"SKU":"c"}` {
+		t.Errorf("tailed %v, want the last 2 events", got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, _ := testDB(t)
+	out, err := capture(t, func() error { return runStats(db, nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"events:        3\n", "streams:       2\n", "last sequence: 3\n", "itemAdded"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stats %q don't include %q", out, want)
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	db, _ := testDB(t)
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{name: "stream without an id", run: func() error { return runStream(db, nil) }},
+		{name: "stream with a bad id", run: func() error { return runStream(db, []string{"nope"}) }},
+		{name: "list with a bad aggregate", run: func() error { return runList(db, []string{"-aggregate", "nope"}) }},
+		{name: "missing store", run: func() error { return runStats(missing, nil) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := capture(t, tt.run); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("inspecting a missing store created it")
+	}
+}
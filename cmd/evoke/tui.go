@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rivo/tview"
+)
+
+const tuiHelp = "[::b]tab[::-] switch pane  [::b]enter[::-] open  [::b]f[::-] follow  [::b]q[::-] quit"
+
+// browser is the state of the tui: the latest events of the log, the
+// streams they belong to, and which of them is shown
+type browser struct {
+	store inspector
+	app   *tview.Application
+
+	streamList *tview.List
+	eventTable *tview.Table
+	payload    *tview.TextView
+	status     *tview.TextView
+
+	mu sync.Mutex
+	// log are the events loaded from the log, oldest first
+	log  []evoke.RawEvent
+	next int64
+	// streams are the streams of the loaded events, in order of first
+	// appearance, with their latest type
+	streams     []uuid.UUID
+	streamTypes map[uuid.UUID]string
+	// shown are the events in the table: those of the log, or of one
+	// stream once selected
+	shown    []evoke.RawEvent
+	selected uuid.UUID
+	follow   bool
+}
+
+// runTUI browses the store interactively, following new events as other
+// processes record them
+func runTUI(dbFile string, args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	n := fs.Int64("n", 1000, "number of latest events to load")
+	interval := fs.Duration("interval", 500*time.Millisecond, "poll interval for new events")
+	fs.Parse(args)
+
+	store, err := openStore(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	stats, err := store.Stats()
+	if err != nil {
+		return err
+	}
+	b := &browser{
+		store:       store,
+		app:         tview.NewApplication(),
+		next:        max(stats.LastSequence-*n+1, 0),
+		streamTypes: map[uuid.UUID]string{},
+		follow:      true,
+	}
+	if err := b.poll(); err != nil {
+		return err
+	}
+	b.layout(dbFile)
+	b.showLog()
+
+	done := make(chan struct{})
+	defer close(done)
+	go b.tail(*interval, done)
+
+	return b.app.Run()
+}
+
+func (b *browser) layout(dbFile string) {
+	b.streamList = tview.NewList().ShowSecondaryText(false)
+	b.streamList.SetBorder(true).SetTitle(" Streams ")
+	b.streamList.SetChangedFunc(func(i int, _, _ string, _ rune) {
+		b.selectStream(i)
+	})
+
+	b.eventTable = tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	b.eventTable.SetBorder(true)
+	b.eventTable.SetSelectionChangedFunc(func(row, _ int) {
+		b.showPayload(row - 1)
+	})
+
+	b.payload = tview.NewTextView().SetDynamicColors(false).SetWrap(true)
+	b.payload.SetBorder(true).SetTitle(" Payload ")
+
+	b.status = tview.NewTextView().SetDynamicColors(true)
+	b.status.SetText(fmt.Sprintf("%s  %s", tview.Escape(dbFile), tuiHelp))
+
+	right := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(b.eventTable, 0, 3, false).
+		AddItem(b.payload, 0, 2, false)
+	main := tview.NewFlex().
+		AddItem(b.streamList, 40, 0, true).
+		AddItem(right, 0, 1, false)
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(main, 0, 1, true).
+		AddItem(b.status, 1, 0, false)
+
+	b.refreshStreams()
+	panes := []tview.Primitive{b.streamList, b.eventTable, b.payload}
+	b.app.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case ev.Key() == tcell.KeyTab:
+			for i, p := range panes {
+				if p.HasFocus() {
+					b.app.SetFocus(panes[(i+1)%len(panes)])
+					return nil
+				}
+			}
+		case ev.Key() == tcell.KeyEnter && b.streamList.HasFocus():
+			b.app.SetFocus(b.eventTable)
+			return nil
+		case ev.Rune() == 'f':
+			b.mu.Lock()
+			b.follow = !b.follow
+			b.mu.Unlock()
+			b.refreshTitle()
+			return nil
+		case ev.Rune() == 'q':
+			b.app.Stop()
+			return nil
+		}
+		return ev
+	})
+	b.app.SetRoot(root, true)
+}
+
+// tail polls for new events until done is closed
+func (b *browser) tail(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		b.mu.Lock()
+		before := len(b.log)
+		b.mu.Unlock()
+		if err := b.poll(); err != nil {
+			b.app.QueueUpdateDraw(func() { b.status.SetText("[red]" + tview.Escape(err.Error())) })
+			continue
+		}
+		b.mu.Lock()
+		added := b.log[before:]
+		b.mu.Unlock()
+		if len(added) > 0 {
+			b.app.QueueUpdateDraw(func() { b.appendEvents(added) })
+		}
+	}
+}
+
+// poll loads the events recorded since the last poll
+func (b *browser) poll() error {
+	var recs []evoke.RawEvent
+	b.mu.Lock()
+	from := b.next
+	b.mu.Unlock()
+	err := b.store.ScanRaw(evoke.RawQuery{FromSequence: from}, func(e evoke.RawEvent) error {
+		recs = append(recs, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range recs {
+		b.log = append(b.log, e)
+		b.next = e.Sequence + 1
+		if _, ok := b.streamTypes[e.AggregateID]; !ok {
+			b.streams = append(b.streams, e.AggregateID)
+		}
+		b.streamTypes[e.AggregateID] = e.AggregateType
+	}
+	return nil
+}
+
+// appendEvents adds new events to the streams and, if they belong there,
+// the table
+func (b *browser) appendEvents(added []evoke.RawEvent) {
+	b.refreshStreams()
+	b.mu.Lock()
+	follow := b.follow
+	var shown []evoke.RawEvent
+	for _, e := range added {
+		if b.selected == uuid.Nil || e.AggregateID == b.selected {
+			shown = append(shown, e)
+		}
+	}
+	b.shown = append(b.shown, shown...)
+	b.mu.Unlock()
+
+	for _, e := range shown {
+		b.addRow(e)
+	}
+	if follow && len(shown) > 0 {
+		b.eventTable.Select(b.eventTable.GetRowCount()-1, 0)
+	}
+	b.refreshTitle()
+}
+
+// refreshStreams lists the streams after the whole log, newest first.
+// Inserting above the selection moves it down, so it stays on its stream.
+func (b *browser) refreshStreams() {
+	if b.streamList.GetItemCount() == 0 {
+		b.streamList.AddItem("All events", "", 0, nil)
+	}
+	b.mu.Lock()
+	listed := b.streamList.GetItemCount() - 1
+	added := append([]uuid.UUID(nil), b.streams[listed:]...)
+	labels := make([]string, len(added))
+	for i, id := range added {
+		labels[i] = id.String()
+		if t := b.streamTypes[id]; t != "" {
+			labels[i] = t + " " + labels[i]
+		}
+	}
+	b.mu.Unlock()
+
+	for i, id := range added {
+		b.streamList.InsertItem(1, labels[i], id.String(), 0, nil)
+	}
+}
+
+func (b *browser) selectStream(i int) {
+	if i == 0 {
+		b.showLog()
+		return
+	}
+	_, secondary := b.streamList.GetItemText(i)
+	id, err := uuid.Parse(secondary)
+	if err != nil {
+		return
+	}
+	var recs []evoke.RawEvent
+	err = b.store.ScanRaw(evoke.RawQuery{AggregateID: id}, func(e evoke.RawEvent) error {
+		recs = append(recs, e)
+		return nil
+	})
+	if err != nil {
+		b.status.SetText("[red]" + tview.Escape(err.Error()))
+		return
+	}
+	b.mu.Lock()
+	b.selected = id
+	b.shown = recs
+	b.mu.Unlock()
+	b.fillTable()
+}
+
+func (b *browser) showLog() {
+	b.mu.Lock()
+	b.selected = uuid.Nil
+	b.shown = append([]evoke.RawEvent(nil), b.log...)
+	b.mu.Unlock()
+	b.fillTable()
+}
+
+func (b *browser) fillTable() {
+	b.eventTable.Clear()
+	for col, h := range []string{"Seq", "Recorded", "Stream", "Ver", "Type"} {
+		b.eventTable.SetCell(0, col, tview.NewTableCell(h).SetSelectable(false).SetAttributes(tcell.AttrBold))
+	}
+	b.mu.Lock()
+	shown := b.shown
+	b.mu.Unlock()
+	for _, e := range shown {
+		b.addRow(e)
+	}
+	if len(shown) > 0 {
+		b.eventTable.Select(len(shown), 0)
+	} else {
+		b.payload.Clear()
+	}
+	b.eventTable.ScrollToEnd()
+	b.refreshTitle()
+}
+
+func (b *browser) addRow(e evoke.RawEvent) {
+	row := b.eventTable.GetRowCount()
+	for col, text := range []string{
+		fmt.Sprint(e.Sequence),
+		time.Unix(e.RecordedAt, 0).Format(time.DateTime),
+		e.AggregateID.String()[:8],
+		fmt.Sprint(e.Version),
+		e.EventType,
+	} {
+		b.eventTable.SetCell(row, col, tview.NewTableCell(tview.Escape(text)))
+	}
+}
+
+func (b *browser) refreshTitle() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	title := " All events "
+	if b.selected != uuid.Nil {
+		title = fmt.Sprintf(" Stream %s ", b.selected)
+	}
+	if b.follow {
+		title += "(following) "
+	}
+	b.eventTable.SetTitle(title)
+}
+
+// showPayload pretty-prints the payload and metadata of the i-th event in
+// the table
+func (b *browser) showPayload(i int) {
+	b.mu.Lock()
+	if i < 0 || i >= len(b.shown) {
+		b.mu.Unlock()
+		return
+	}
+	e := b.shown[i]
+	b.mu.Unlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s #%d, version %d of %s", e.EventType, e.Sequence, e.Version, e.AggregateID)
+	if e.AggregateType != "" {
+		fmt.Fprintf(&sb, " (%s)", e.AggregateType)
+	}
+	fmt.Fprintf(&sb, "\nrecorded %s\n\n%s\n", time.Unix(e.RecordedAt, 0).Format(time.RFC3339), indent(e.Data))
+	if len(e.Metadata) > 0 {
+		md, _ := json.Marshal(e.Metadata)
+		fmt.Fprintf(&sb, "\nmetadata\n%s\n", indent(md))
+	}
+	b.payload.SetText(sb.String()).ScrollToBeginning()
+}
+
+// indent pretty-prints JSON, leaving anything else as it is
+func indent(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return buf.String()
+}
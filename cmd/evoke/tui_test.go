@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/rivo/tview"
+)
+
+// newTestBrowser opens the store at db as runTUI does, with its layout,
+// showing the whole log on a simulated screen
+func newTestBrowser(t *testing.T, db string) *browser {
+	t.Helper()
+	store, err := openStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(160, 40)
+	b := &browser{
+		store:       store,
+		app:         tview.NewApplication().SetScreen(screen),
+		streamTypes: map[uuid.UUID]string{},
+		follow:      true,
+	}
+	if err := b.poll(); err != nil {
+		t.Fatal(err)
+	}
+	b.layout(db)
+	b.showLog()
+	return b
+}
+
+// record appends events of skus to the stream id of the store at db, as
+// another process would
+func record(t *testing.T, db, aggregateType string, id uuid.UUID, skus ...string) {
+	t.Helper()
+	s, err := evoke.NewFileStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	evoke.RegisterEvent(s, &itemAdded{})
+	evs := make([]evoke.Event, len(skus))
+	for i, sku := range skus {
+		evs[i] = itemAdded{SKU: sku}
+	}
+	if err := s.RecordAs(context.Background(), aggregateType, id, evs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// streamLabels returns the items of the stream list, top first
+func streamLabels(b *browser) []string {
+	labels := make([]string, b.streamList.GetItemCount())
+	for i := range labels {
+		labels[i], _ = b.streamList.GetItemText(i)
+	}
+	return labels
+}
+
+func TestBrowserPoll(t *testing.T) {
+	db, cartID := testDB(t)
+	b := newTestBrowser(t, db)
+	if len(b.log) != 3 || b.next != 4 {
+		t.Fatalf("loaded %d events up to %d", len(b.log), b.next)
+	}
+	if len(b.streams) != 2 || b.streams[0] != cartID || b.streamTypes[cartID] != "Cart" {
+		t.Errorf("streams %v of types %v, want the cart first", b.streams, b.streamTypes)
+	}
+
+	record(t, db, "Cart", cartID, "d")
+	if err := b.poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.log) != 4 || b.log[3].Sequence != 4 || len(b.streams) != 2 {
+		t.Errorf("after a poll loaded %d events of %d streams, want the new one added", len(b.log), len(b.streams))
+	}
+}
+
+func TestBrowserSelectStream(t *testing.T) {
+	db, cartID := testDB(t)
+	b := newTestBrowser(t, db)
+	labels := streamLabels(b)
+	if len(labels) != 3 || labels[0] != "All events" || !strings.HasPrefix(labels[1], "Order ") || labels[2] != "Cart "+cartID.String() {
+		t.Fatalf("streams listed %q, want the newest first", labels)
+	}
+	if n := b.eventTable.GetRowCount(); n != 4 {
+		t.Errorf("table has %d rows, want a header and the log's 3 events", n)
+	}
+
+	b.selectStream(2)
+	if b.selected != cartID || len(b.shown) != 2 || b.eventTable.GetRowCount() != 3 {
+		t.Errorf("selecting the cart shows %d events of %v", len(b.shown), b.selected)
+	}
+	if title := b.eventTable.GetTitle(); title != " Stream "+cartID.String()+" (following) " {
+		t.Errorf("title %q", title)
+	}
+	if row, _ := b.eventTable.GetSelection(); row != 2 {
+		t.Errorf("selected row %d, want the stream's last event", row)
+	}
+
+	b.selectStream(0)
+	if b.selected != uuid.Nil || len(b.shown) != 3 || b.eventTable.GetTitle() != " All events (following) " {
+		t.Errorf("selecting all events shows %d events under %q", len(b.shown), b.eventTable.GetTitle())
+	}
+}
+
+// New events of other streams list their streams without moving the
+// selection off its stream or adding to its table.
+func TestBrowserAppendEvents(t *testing.T) {
+	db, cartID := testDB(t)
+	b := newTestBrowser(t, db)
+	b.streamList.SetCurrentItem(2)
+	if b.selected != cartID {
+		t.Fatalf("selected %v, want the cart", b.selected)
+	}
+
+	before := len(b.log)
+	record(t, db, "Cart", cartID, "d")
+	record(t, db, "Invoice", evoke.NewID(), "e")
+	if err := b.poll(); err != nil {
+		t.Fatal(err)
+	}
+	b.appendEvents(b.log[before:])
+
+	labels := streamLabels(b)
+	if len(labels) != 4 || !strings.HasPrefix(labels[1], "Invoice ") {
+		t.Errorf("streams listed %q, want the invoice on top", labels)
+	}
+	if got, _ := b.streamList.GetItemText(b.streamList.GetCurrentItem()); got != "Cart "+cartID.String() {
+		t.Errorf("selection moved to %q", got)
+	}
+	if len(b.shown) != 3 || b.eventTable.GetRowCount() != 4 {
+		t.Errorf("table shows %d events, want the cart's 3", len(b.shown))
+	}
+	if row, _ := b.eventTable.GetSelection(); row != 3 {
+		t.Errorf("selected row %d while following, want the new event", row)
+	}
+}
+
+func TestBrowserShowPayload(t *testing.T) {
+	db, cartID := testDB(t)
+	b := newTestBrowser(t, db)
+	b.showPayload(0)
+	text := b.payload.GetText(false)
+	for _, want := range []string{
+		"itemAdded #1, version 1 of " + cartID.String() + " (Cart)",
+		"{\n  \"SKU\": \"a\"\n}",
+		"metadata\n{\n  \"correlation_id\"",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("payload %q doesn't include %q", text, want)
+		}
+	}
+	b.showPayload(3)
+	if b.payload.GetText(false) != text {
+		t.Error("showing a row past the table changed the payload")
+	}
+	if got := indent([]byte("not json")); got != "not json" {
+		t.Errorf("indented %q", got)
+	}
+}
+
+// The running tui follows events recorded by other processes and toggles
+// following and quits on their keys.
+func TestTUI(t *testing.T) {
+	db, _ := testDB(t)
+	b := newTestBrowser(t, db)
+	run := make(chan error)
+	go func() { run <- b.app.Run() }()
+	done := make(chan struct{})
+	defer close(done)
+	go b.tail(10*time.Millisecond, done)
+
+	// rows returns the table's rows from the app's goroutine
+	rows := func() int {
+		var n int
+		b.app.QueueUpdate(func() { n = b.eventTable.GetRowCount() })
+		return n
+	}
+	record(t, db, "Cart", evoke.NewID(), "d")
+	for deadline := time.Now().Add(5 * time.Second); rows() != 5; {
+		if time.Now().After(deadline) {
+			t.Fatalf("table has %d rows, want the recorded event added", rows())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b.app.QueueEvent(tcell.NewEventKey(tcell.KeyRune, 'f', tcell.ModNone))
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		var title string
+		b.app.QueueUpdate(func() { title = b.eventTable.GetTitle() })
+		if title == " All events " {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("title %q after f, want following off", title)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b.app.QueueEvent(tcell.NewEventKey(tcell.KeyRune, 'q', tcell.ModNone))
+	select {
+	case err := <-run:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tui didn't quit on q")
+	}
+}
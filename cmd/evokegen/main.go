@@ -0,0 +1,391 @@
+// Command evokegen keeps a package's event and command registrations in
+// sync with its types. Run it from the package with go generate:
+//
+//	//go:generate go run github.com/rcy/evoke/cmd/evokegen -apply OrderState
+//
+// Events are the types marked with an //evoke:event comment. Commands are
+// the types marked //evoke:command, and the structs with an AggregateID
+// method, which satisfy evoke.Command. evokegen writes evoke_gen.go, with
+// registerEvents and registerCommands functions registering them all, and
+// evoke_gen_test.go, checking every type decodes under its name. Events
+// stored with a codec rather than as JSON need it registered too: a package
+// declaring a registerEventCodecs(evoke.EventRegisterer) function has
+// registerEvents call it once the events are registered.
+//
+// With -apply, it also writes a skeleton of the function folding the
+// package's events into the given state type, for evoke.NewAggregateBase,
+// with a case for each event. Once written the file is the package's to
+// fill in: evokegen doesn't overwrite it, but fails if its switch misses an
+// event, so adding an event without applying it is caught.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+const (
+	eventMarker   = "//evoke:event"
+	commandMarker = "//evoke:command"
+	// codecsFunc is the function registerEvents calls, if the package has
+	// it, to register the codecs of its events
+	codecsFunc = "registerEventCodecs"
+)
+
+func main() {
+	out := flag.String("o", "evoke_gen.go", "file to write the registrations to")
+	tests := flag.Bool("tests", true, "write tests of the registrations next to them")
+	apply := flag.String("apply", "", "state type to write an apply function skeleton for")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: evokegen [-o file] [-tests=false] [-apply StateType] [dir]\n\nflags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if err := run(dir, *out, *tests, *apply); err != nil {
+		fmt.Fprintf(os.Stderr, "evokegen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// pkg is what evokegen found in a package
+type pkg struct {
+	Name     string
+	Events   []string
+	Commands []string
+	// Codecs is whether the package declares codecsFunc
+	Codecs bool
+	// files are the parsed files, by name
+	files map[string]*ast.File
+}
+
+func run(dir, out string, tests bool, apply string) error {
+	p, err := scan(dir, out)
+	if err != nil {
+		return err
+	}
+	if len(p.Events) == 0 && len(p.Commands) == 0 {
+		return fmt.Errorf("no events or commands in %s (hint: mark event types with an %s comment)", dir, eventMarker)
+	}
+
+	if err := generate(filepath.Join(dir, out), registrations, p); err != nil {
+		return err
+	}
+	if tests {
+		name := strings.TrimSuffix(out, ".go") + "_test.go"
+		if err := generate(filepath.Join(dir, name), registrationTests, p); err != nil {
+			return err
+		}
+	}
+	if apply != "" {
+		return writeApply(dir, p, apply)
+	}
+	return nil
+}
+
+// scan parses the package in dir, leaving out its tests and the file
+// evokegen writes
+func scan(dir, out string) (*pkg, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	p := &pkg{files: map[string]*ast.File{}}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == out {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if p.Name == "" {
+			p.Name = f.Name.Name
+		}
+		p.files[name] = f
+	}
+
+	structs := map[string]bool{}
+	events := map[string]bool{}
+	commands := map[string]bool{}
+	// types with an AggregateID method, commands if they are structs
+	methods := map[string]bool{}
+	for _, f := range p.files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if ts.TypeParams != nil {
+						continue
+					}
+					if _, ok := ts.Type.(*ast.StructType); ok {
+						structs[ts.Name.Name] = true
+					}
+					doc := ts.Doc
+					if doc == nil && len(decl.Specs) == 1 {
+						doc = decl.Doc
+					}
+					switch {
+					case marked(doc, eventMarker):
+						events[ts.Name.Name] = true
+					case marked(doc, commandMarker):
+						commands[ts.Name.Name] = true
+					}
+				}
+			case *ast.FuncDecl:
+				if name, ok := aggregateIDMethod(decl); ok {
+					methods[name] = true
+				}
+			}
+		}
+	}
+	for name := range methods {
+		if structs[name] && !events[name] {
+			commands[name] = true
+		}
+	}
+
+	p.Events = sortedKeys(events)
+	p.Commands = sortedKeys(commands)
+	p.Codecs = findFunc(p, codecsFunc) != nil
+	return p, nil
+}
+
+// marked reports whether doc has a line starting with marker
+func marked(doc *ast.CommentGroup, marker string) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if c.Text == marker || strings.HasPrefix(c.Text, marker+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateIDMethod returns the receiver type of an AggregateID method
+// taking nothing and returning one value, as evoke.Command requires
+func aggregateIDMethod(fn *ast.FuncDecl) (string, bool) {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Name.Name != "AggregateID" {
+		return "", false
+	}
+	if len(fn.Type.Params.List) != 0 || fn.Type.Results == nil || len(fn.Type.Results.List) != 1 || len(fn.Type.Results.List[0].Names) > 1 {
+		return "", false
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	ident, ok := recv.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	return ident.Name, true
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+var registrations = template.Must(template.New("registrations").Parse(`// Code generated by evokegen; DO NOT EDIT.
+
+package {{.Name}}
+
+import "github.com/rcy/evoke"
+{{if .Events}}
+// registerEvents registers the package's events with er, such as a store.
+func registerEvents(er evoke.EventRegisterer) {
+{{- range .Events}}
+	evoke.RegisterEvent(er, &{{.}}{})
+{{- end}}
+{{- if .Codecs}}
+	registerEventCodecs(er)
+{{- end}}
+}
+{{end}}{{if .Commands}}
+// registerCommands registers the package's commands with cr, such as a
+// command bus.
+func registerCommands(cr evoke.CommandRegisterer) {
+{{- range .Commands}}
+	evoke.RegisterCommand(cr, &{{.}}{})
+{{- end}}
+}
+{{end}}`))
+
+var registrationTests = template.Must(template.New("tests").Parse(`// Code generated by evokegen; DO NOT EDIT.
+
+package {{.Name}}
+
+import (
+	"testing"
+
+	"github.com/rcy/evoke"
+)
+{{if .Events}}
+func TestRegisterEvents(t *testing.T) {
+	var r evoke.EventRegistry
+	registerEvents(&r)
+	// each zero value round trips, through its codec if it has one
+	for _, e := range []evoke.Event{
+{{- range .Events}}
+		*new({{.}}),
+{{- end}}
+	} {
+		name := evoke.TypeName(e)
+		data, err := r.MarshalEvent(e)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		decoded, err := r.UnmarshalEvent(r.EventName(e), data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := evoke.TypeName(decoded); got != name {
+			t.Errorf("%s decodes as %s", name, got)
+		}
+	}
+}
+{{end}}{{if .Commands}}
+func TestRegisterCommands(t *testing.T) {
+	var r evoke.CommandRegistry
+	registerCommands(&r)
+	for _, name := range []string{
+{{- range .Commands}}
+		"{{.}}",
+{{- end}}
+	} {
+		cmd, err := r.UnmarshalCommand(name, []byte("{}"))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := evoke.TypeName(cmd); got != name {
+			t.Errorf("%s decodes as %s", name, got)
+		}
+	}
+}
+{{end}}`))
+
+var applySkeleton = template.Must(template.New("apply").Parse(`package {{.Pkg.Name}}
+
+import (
+	"fmt"
+
+	"github.com/rcy/evoke"
+)
+
+// {{.Func}} folds an event into the state, for evoke.NewAggregateBase.
+func {{.Func}}(state *{{.State}}, e evoke.Event) error {
+	switch e := e.(type) {
+{{- range .Pkg.Events}}
+	case {{.}}:
+		_ = e // TODO
+{{- end}}
+	default:
+		return fmt.Errorf("{{.Func}}: unexpected event %T", e)
+	}
+	return nil
+}
+`))
+
+// generate writes the output of tmpl for data to path, formatted
+func generate(path string, tmpl *template.Template, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format %s: %w", path, err)
+	}
+	return os.WriteFile(path, src, 0o644)
+}
+
+// writeApply writes the skeleton of the apply function for state, or checks
+// the one written before handles every event
+func writeApply(dir string, p *pkg, state string) error {
+	funcName := "apply" + state
+	name := strings.ToLower(state) + "_apply.go"
+	if fn := findFunc(p, funcName); fn != nil {
+		missing := slices.DeleteFunc(slices.Clone(p.Events), func(event string) bool {
+			return slices.Contains(switchCases(fn), event)
+		})
+		if len(missing) > 0 {
+			return fmt.Errorf("%s doesn't handle %s", funcName, strings.Join(missing, ", "))
+		}
+		return nil
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s exists but doesn't declare %s", path, funcName)
+	}
+	return generate(path, applySkeleton, struct {
+		Pkg   *pkg
+		Func  string
+		State string
+	}{p, funcName, state})
+}
+
+func findFunc(p *pkg, name string) *ast.FuncDecl {
+	for _, f := range p.files {
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+				return fn
+			}
+		}
+	}
+	return nil
+}
+
+// switchCases returns the type names the type switches of fn have cases
+// for, by value or pointer
+func switchCases(fn *ast.FuncDecl) []string {
+	var names []string
+	ast.Inspect(fn, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSwitchStmt)
+		if !ok {
+			return true
+		}
+		for _, stmt := range ts.Body.List {
+			for _, expr := range stmt.(*ast.CaseClause).List {
+				if star, ok := expr.(*ast.StarExpr); ok {
+					expr = star.X
+				}
+				if ident, ok := expr.(*ast.Ident); ok {
+					names = append(names, ident.Name)
+				}
+			}
+		}
+		return true
+	})
+	return names
+}
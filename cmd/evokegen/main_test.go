@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with what evokegen generates")
+
+// fixture copies the testdata/cart package to a temporary directory,
+// returning it
+func fixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files, err := filepath.Glob(filepath.Join("testdata", "cart", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, dir, filepath.Base(file), string(src))
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, src string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestGenerate(t *testing.T) {
+	dir := fixture(t)
+	if err := run(dir, "evoke_gen.go", true, "CartState"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for _, name := range []string{"evoke_gen.go", "evoke_gen_test.go", "cartstate_apply.go"} {
+		t.Run(name, func(t *testing.T) {
+			got := readFile(t, filepath.Join(dir, name))
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if want := readFile(t, golden); !bytes.Equal(got, want) {
+				t.Errorf("generated %s differs from %s (rerun with -update if that's intended):\n%s", name, golden, got)
+			}
+		})
+	}
+}
+
+// Once written, the apply function is left as it is, but has to handle
+// every event.
+func TestApplyChecksCases(t *testing.T) {
+	tests := []struct {
+		name string
+		// before runs between the first run and the second
+		before  func(t *testing.T, dir string)
+		wantErr string
+	}{
+		{
+			name:   "every event handled",
+			before: func(t *testing.T, dir string) {},
+		},
+		{
+			name: "filled in",
+			before: func(t *testing.T, dir string) {
+				path := filepath.Join(dir, "cartstate_apply.go")
+				src := strings.Replace(string(readFile(t, path)), "case ItemAdded:", "case *ItemAdded:", 1)
+				writeFile(t, dir, "cartstate_apply.go", src)
+			},
+		},
+		{
+			name: "event added",
+			before: func(t *testing.T, dir string) {
+				writeFile(t, dir, "emptied.go", "package cart\n\n//evoke:event\ntype CartEmptied struct{}\n")
+			},
+			wantErr: "applyCartState doesn't handle CartEmptied",
+		},
+		{
+			name: "function removed",
+			before: func(t *testing.T, dir string) {
+				writeFile(t, dir, "cartstate_apply.go", "package cart\n")
+			},
+			wantErr: "doesn't declare applyCartState",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fixture(t)
+			if err := run(dir, "evoke_gen.go", false, "CartState"); err != nil {
+				t.Fatalf("first run: %v", err)
+			}
+			tt.before(t, dir)
+			before := readFile(t, filepath.Join(dir, "cartstate_apply.go"))
+
+			err := run(dir, "evoke_gen.go", false, "CartState")
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("second run: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("second run: %v, want an error containing %q", err, tt.wantErr)
+			}
+			if after := readFile(t, filepath.Join(dir, "cartstate_apply.go")); !bytes.Equal(after, before) {
+				t.Errorf("second run rewrote the apply file:\n%s", after)
+			}
+		})
+	}
+}
+
+func TestNoTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "empty.go", "package empty\n\ntype state struct{}\n")
+	err := run(dir, "evoke_gen.go", true, "")
+	if err == nil || !strings.Contains(err.Error(), "no events or commands") {
+		t.Fatalf("run: %v, want an error for a package with no events or commands", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evoke_gen.go")); !os.IsNotExist(err) {
+		t.Errorf("evoke_gen.go written for a package with nothing to register")
+	}
+}
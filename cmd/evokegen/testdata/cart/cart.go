@@ -0,0 +1,75 @@
+// Package cart is the package the evokegen tests generate code for.
+package cart
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+// ItemAdded is recorded when an item is put in the cart.
+//
+//evoke:event
+type ItemAdded struct {
+	SKU string
+	Qty int
+}
+
+//evoke:event
+type ItemRemoved struct {
+	SKU string
+}
+
+// Discounted is stored as its percentage, with a codec.
+//
+//evoke:event
+type Discounted struct {
+	percent int
+}
+
+func registerEventCodecs(er evoke.EventRegisterer) {
+	evoke.RegisterEventCodec(er, Discounted{},
+		func(d Discounted) ([]byte, error) {
+			return []byte(strconv.Itoa(d.percent)), nil
+		},
+		func(data []byte) (Discounted, error) {
+			n, err := strconv.Atoi(string(data))
+			if err != nil {
+				return Discounted{}, fmt.Errorf("bad discount %q: %w", data, err)
+			}
+			return Discounted{percent: n}, nil
+		})
+}
+
+// AddItem is a command for having a method AggregateID.
+type AddItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c AddItem) AggregateID() uuid.UUID { return c.ID }
+
+//evoke:command
+type Checkout struct {
+	ID uuid.UUID
+}
+
+func (c *Checkout) AggregateID() uuid.UUID { return c.ID }
+
+// cartID has an AggregateID method but isn't a struct, so isn't a command.
+type cartID uuid.UUID
+
+func (id cartID) AggregateID() uuid.UUID { return uuid.UUID(id) }
+
+// Page is generic, so never an event or command.
+//
+//evoke:event
+type Page[T any] struct {
+	Items []T
+}
+
+type CartState struct {
+	Items map[string]int
+}
@@ -0,0 +1,60 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// eventCodec encodes and decodes the payloads of one event type
+type eventCodec struct {
+	marshal   func(Event) ([]byte, error)
+	unmarshal func([]byte) (Event, error)
+}
+
+// RegisterEventCodec stores events of ctor's type as marshal encodes them
+// and decodes them with unmarshal, rather than as JSON: for types with
+// unexported fields, generated protobuf types, or types encrypting some of
+// their fields. The type must be registered too, with RegisterEvent or
+// RegisterEventNamed. Schemas registered for the type are checked against
+// the codec's output. Payloads other than JSON are only supported by the
+// file store, best kept WithBinaryPayloads, and can't be exported.
+func RegisterEventCodec[T Event](er EventRegisterer, ctor T, marshal func(T) ([]byte, error), unmarshal func([]byte) (T, error)) {
+	er.registerCodec(TypeName(ctor), eventCodec{
+		marshal: func(e Event) ([]byte, error) {
+			t, ok := asType[T](e)
+			if !ok {
+				return nil, fmt.Errorf("codec for %s given %T", TypeName(ctor), e)
+			}
+			return marshal(t)
+		},
+		unmarshal: func(data []byte) (Event, error) {
+			return unmarshal(data)
+		},
+	})
+}
+
+func (er *EventRegistry) registerCodec(goType string, codec eventCodec) {
+	if er.codecs == nil {
+		er.codecs = make(map[string]eventCodec)
+	}
+	er.codecs[goType] = codec
+}
+
+// MarshalEvent encodes e as stores record it: with the codec registered for
+// its type, or as JSON.
+func (er *EventRegistry) MarshalEvent(e Event) ([]byte, error) {
+	if codec, ok := er.codecs[TypeName(e)]; ok {
+		return codec.marshal(e)
+	}
+	return json.Marshal(e)
+}
+
+// underlying returns the value e points to, if it is a non-nil pointer
+func underlying(e Event) Event {
+	v := reflect.ValueOf(e)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return v.Elem().Interface().(Event)
+	}
+	return e
+}
@@ -0,0 +1,88 @@
+package evoke
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pipeEncoded is stored by a codec as "sku|qty" rather than as JSON
+type pipeEncoded struct {
+	SKU string
+	Qty int
+}
+
+func registerPipeCodec(s EventRegisterer) {
+	RegisterEvent(s, &pipeEncoded{})
+	RegisterEventCodec(s, pipeEncoded{},
+		func(e pipeEncoded) ([]byte, error) {
+			return []byte(e.SKU + "|" + strconv.Itoa(e.Qty)), nil
+		},
+		func(data []byte) (pipeEncoded, error) {
+			sku, qty, ok := strings.Cut(string(data), "|")
+			if !ok {
+				return pipeEncoded{}, fmt.Errorf("bad pipe encoding %q", data)
+			}
+			n, err := strconv.Atoi(qty)
+			return pipeEncoded{SKU: sku, Qty: n}, err
+		})
+}
+
+func TestEventCodec(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []FileStoreOption
+	}{
+		{"text", nil},
+		{"binary", []FileStoreOption{WithBinaryPayloads()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, tt.opts...)
+			tenant := s.ForTenant("acme")
+			registerPipeCodec(tenant)
+			id := NewID()
+			if err := tenant.Record(id, []Event{pipeEncoded{SKU: "a", Qty: 2}, itemAdded{SKU: "b"}}); err != nil {
+				t.Fatal(err)
+			}
+			if got := storedPayloads(t, s); got[0] != "a|2" || got[1] != `{"SKU":"b","Qty":0}` {
+				t.Errorf("stored %q, want the codec's encoding and JSON", got)
+			}
+			recs := mustLoad(t, tenant, id)
+			if recs[0].Event != (pipeEncoded{SKU: "a", Qty: 2}) || recs[1].Event != (itemAdded{SKU: "b"}) {
+				t.Errorf("loaded %+v", recs)
+			}
+		})
+	}
+}
+
+func TestEventCodecErrors(t *testing.T) {
+	s := newTestStore(t)
+	errEncode := errors.New("can't encode")
+	RegisterEvent(s, &pipeEncoded{})
+	RegisterEventCodec(s, pipeEncoded{},
+		func(pipeEncoded) ([]byte, error) { return nil, errEncode },
+		func([]byte) (pipeEncoded, error) { return pipeEncoded{}, errors.New("can't decode") })
+	if err := s.Record(NewID(), []Event{pipeEncoded{}}); !errors.Is(err, errEncode) {
+		t.Errorf("Record returned %v, want the codec's error", err)
+	}
+
+	registerPipeCodec(s)
+	if _, err := s.db.Exec(`insert into events(tenant_id, aggregate_id, recorded_at, event_json, event_type, version, encrypted) values('', ?, 0, 'no pipe', 'pipeEncoded', 1, 0)`, NewID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadAll(1, 0); err == nil || !strings.Contains(err.Error(), "bad pipe encoding") {
+		t.Errorf("ReadAll returned %v, want the codec's error", err)
+	}
+
+	var er EventRegistry
+	registerPipeCodec(&er)
+	if data, err := er.MarshalEvent(&pipeEncoded{SKU: "c", Qty: 1}); err != nil || string(data) != "c|1" {
+		t.Errorf("MarshalEvent of a pointer returned %q, %v", data, err)
+	}
+	if data, err := er.MarshalEvent(itemAdded{SKU: "d"}); err != nil || string(data) != `{"SKU":"d","Qty":0}` {
+		t.Errorf("MarshalEvent of a type without a codec returned %q, %v", data, err)
+	}
+}
@@ -1,18 +1,29 @@
 package evoke
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"sync"
 )
 
 type simpleCommandBus struct {
-	handlers map[string]CommandHandler
-	mu       sync.RWMutex
+	handlers    map[string]CommandHandler
+	fallback    CommandHandler
+	mu          sync.RWMutex
+	idempotency IdempotencyStore
+	keysMu      sync.Mutex
+	keyLocks    map[string]*keyLock
+	inst        Instrumentation
+	tracer      Tracer
+	commandLog  CommandLog
 }
 
 func NewCommandBus() *simpleCommandBus {
 	return &simpleCommandBus{
 		handlers: make(map[string]CommandHandler),
+		inst:     nopInstrumentation{},
+		tracer:   nopTracer{},
 	}
 }
 
@@ -26,14 +37,105 @@ func (b *simpleCommandBus) RegisterHandler(cmd Command, handler CommandHandler)
 	b.handlers[TypeName(cmd)] = handler
 }
 
+// RegisterDefaultHandler sets the handler of commands no handler was
+// registered for, such as one forwarding them to another service or
+// rejecting them with a clearer error. Without one, sending them returns
+// ErrNoHandler.
+func (b *simpleCommandBus) RegisterDefaultHandler(handler CommandHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fallback != nil {
+		panic("default handler already registered")
+	}
+	b.fallback = handler
+}
+
+// HandlerRegisterer is implemented by command buses.
+type HandlerRegisterer interface {
+	RegisterHandler(cmd Command, handler CommandHandler)
+}
+
+// CommandHandlerFunc adapts a function taking commands of type T to a
+// CommandHandler.
+type CommandHandlerFunc[T Command] func(T) error
+
+func (f CommandHandlerFunc[T]) Handle(cmd Command) error {
+	if c, ok := asType[T](cmd); ok {
+		return f(c)
+	}
+	var want T
+	return fmt.Errorf("CommandHandlerFunc: handler for %T got %T", want, cmd)
+}
+
+// asType returns v as a T. Commands and events are registered by type name,
+// so a T may arrive as a *T or the other way around.
+func asType[T any](v any) (T, bool) {
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case !rv.IsValid():
+	case rv.Kind() == reflect.Ptr:
+		if !rv.IsNil() {
+			if t, ok := rv.Elem().Interface().(T); ok {
+				return t, true
+			}
+		}
+	default:
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		if t, ok := p.Interface().(T); ok {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// RegisterHandlerFunc registers fn as the handler for commands of type T.
+func RegisterHandlerFunc[T Command](bus HandlerRegisterer, fn func(T) error) {
+	var cmd T
+	bus.RegisterHandler(cmd, CommandHandlerFunc[T](fn))
+}
+
 func (b *simpleCommandBus) Send(cmd Command) error {
+	return b.SendContext(context.Background(), cmd)
+}
+
+// SendContext sends a command as part of the trace in ctx. Commands
+// implementing CommandValidator are validated first.
+func (b *simpleCommandBus) SendContext(ctx context.Context, cmd Command) error {
+	if v, ok := cmd.(CommandValidator); ok {
+		if err := v.Validate(); err != nil {
+			return &ValidationError{Command: TypeName(cmd), Err: err}
+		}
+	}
+
 	b.mu.RLock()
 	h, ok := b.handlers[TypeName(cmd)]
+	if !ok && b.fallback != nil {
+		h, ok = b.fallback, true
+	}
+	idempotency := b.idempotency
+	inst, tracer, log := b.inst, b.tracer, b.commandLog
 	b.mu.RUnlock()
 	if !ok {
-		return fmt.Errorf("simpleCommandBus: command not registered: %s (hint: call RegisterHandler)", TypeName(cmd))
+		return fmt.Errorf("simpleCommandBus: %w: %s (hint: call RegisterHandler)", ErrNoHandler, TypeName(cmd))
+	}
+
+	send := func(ctx context.Context) error {
+		return traced(ctx, tracer, inst, "command", TypeName(cmd), func(ctx context.Context) error {
+			if ic, ok := cmd.(IdempotentCommand); ok && idempotency != nil && ic.IdempotencyKey() != "" {
+				return b.sendOnce(ctx, idempotency, ic.IdempotencyKey(), h, cmd)
+			}
+			return handleCommand(ctx, h, cmd)
+		})
+	}
+	if log != nil {
+		return sendLogged(ctx, log, cmd, send)
 	}
-	return h.Handle(cmd)
+	return send(ctx)
 }
 
 func (b *simpleCommandBus) MustSend(cmd Command) {
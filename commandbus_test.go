@@ -0,0 +1,67 @@
+package evoke
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterHandlerFunc(t *testing.T) {
+	bus := NewCommandBus()
+	var got []string
+	RegisterHandlerFunc(bus, func(cmd addItem) error {
+		got = append(got, cmd.SKU)
+		return nil
+	})
+	id := NewID()
+	if err := bus.Send(addItem{ID: id, SKU: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Send(&addItem{ID: id, SKU: "b"}); err != nil {
+		t.Errorf("sending a pointer to the handled type: %v", err)
+	}
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("handled %q", got)
+	}
+}
+
+func TestCommandHandlerFuncWrongType(t *testing.T) {
+	h := CommandHandlerFunc[addItem](func(addItem) error { return nil })
+	err := h.Handle(removeItem{ID: NewID(), SKU: "a"})
+	if err == nil || !strings.Contains(err.Error(), "got evoke.removeItem") {
+		t.Errorf("Handle of another type returned %v", err)
+	}
+	if err := h.Handle((*addItem)(nil)); err == nil {
+		t.Error("Handle of a nil pointer returned no error")
+	}
+}
+
+func TestSendWithoutHandler(t *testing.T) {
+	err := NewCommandBus().Send(addItem{ID: NewID()})
+	if !errors.Is(err, ErrNoHandler) || !strings.Contains(err.Error(), "addItem") {
+		t.Errorf("Send returned %v, want ErrNoHandler naming the command", err)
+	}
+}
+
+func TestRegisterDefaultHandler(t *testing.T) {
+	bus := NewCommandBus()
+	var specific, fallback countingHandler
+	bus.RegisterHandler(addItem{}, &specific)
+	bus.RegisterDefaultHandler(&fallback)
+	if err := bus.Send(addItem{ID: NewID()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Send(removeItem{ID: NewID(), SKU: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if specific.handled != 1 || fallback.handled != 1 {
+		t.Errorf("handled %d by the registered handler and %d by the default, want one each", specific.handled, fallback.handled)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registered a second default handler")
+		}
+	}()
+	bus.RegisterDefaultHandler(&fallback)
+}
@@ -0,0 +1,207 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommandLogEntry is a command sent through a bus and what became of it.
+type CommandLogEntry struct {
+	ID          uuid.UUID
+	CommandType string
+	AggregateID uuid.UUID
+	// Payload is the command as JSON
+	Payload json.RawMessage
+	// Issuer is who sent the command, see WithIssuer
+	Issuer string
+	// CorrelationID is the workflow the command and its events are part
+	// of, see WithCorrelationID
+	CorrelationID string
+	SentAt        time.Time
+	Duration      time.Duration
+	// Error is the error handling the command returned, empty if it
+	// succeeded
+	Error string
+	// Sequences are those of the events handling the command recorded
+	Sequences []int64
+}
+
+// CommandLog records the commands sent through a bus, for a complete
+// picture of who asked for what, not only of what happened.
+type CommandLog interface {
+	LogCommand(entry CommandLogEntry) error
+}
+
+// SetCommandLog records every command sent through the bus, whether its
+// handling succeeds or fails, in log. A command handled successfully that
+// can't be logged fails with the logging error.
+func (b *simpleCommandBus) SetCommandLog(log CommandLog) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.commandLog = log
+}
+
+type issuerKey struct{}
+
+// WithIssuer makes commands sent in ctx logged as sent by issuer, such as
+// the user or service making the request.
+func WithIssuer(ctx context.Context, issuer string) context.Context {
+	return context.WithValue(ctx, issuerKey{}, issuer)
+}
+
+// sendLogged handles cmd with send, logging it and the events it recorded
+func sendLogged(ctx context.Context, log CommandLog, cmd Command, send func(context.Context) error) error {
+	entry := CommandLogEntry{
+		ID:            NewID(),
+		CommandType:   TypeName(cmd),
+		AggregateID:   cmd.AggregateID(),
+		CorrelationID: correlationID(ctx),
+		SentAt:        time.Now(),
+	}
+	entry.Issuer, _ = ctx.Value(issuerKey{}).(string)
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("Marshal: %w", err)
+	}
+	entry.Payload = payload
+	// the command's events are part of its workflow, so they can be traced
+	// back to it
+	if entry.CorrelationID == "" {
+		entry.CorrelationID = NewID().String()
+		ctx = WithCorrelationID(ctx, entry.CorrelationID)
+	}
+
+	ctx, c := collect(ctx)
+	err = send(ctx)
+	entry.Duration = time.Since(entry.SentAt)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	c.mu.Lock()
+	for _, rec := range c.recs {
+		if rec.Sequence > 0 {
+			entry.Sequences = append(entry.Sequences, rec.Sequence)
+		}
+	}
+	c.mu.Unlock()
+
+	if logErr := log.LogCommand(entry); logErr != nil {
+		if err != nil {
+			return fmt.Errorf("%w (log command: %w)", err, logErr)
+		}
+		return fmt.Errorf("command %s handled but not logged: %w", entry.ID, logErr)
+	}
+	return err
+}
+
+type memoryCommandLog struct {
+	mu      sync.Mutex
+	entries []CommandLogEntry
+}
+
+func NewMemoryCommandLog() *memoryCommandLog {
+	return &memoryCommandLog{}
+}
+
+func (l *memoryCommandLog) LogCommand(entry CommandLogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// Entries returns the logged commands, oldest first.
+func (l *memoryCommandLog) Entries() []CommandLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]CommandLogEntry(nil), l.entries...)
+}
+
+func (s *fileStore) LogCommand(entry CommandLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`insert into command_log(id, command_type, aggregate_id, payload, issuer, correlation_id, sent_at, duration, error) values(?,?,?,?,?,?,?,?,?)`,
+		entry.ID.String(), entry.CommandType, entry.AggregateID.String(), string(entry.Payload), entry.Issuer, entry.CorrelationID,
+		entry.SentAt.UnixMilli(), int64(entry.Duration), entry.Error)
+	if err != nil {
+		return fmt.Errorf("insert into command_log: %w", err)
+	}
+	for _, seq := range entry.Sequences {
+		if _, err := tx.Exec(`insert or ignore into command_log_events(sequence, command_id) values(?,?)`, seq, entry.ID.String()); err != nil {
+			return fmt.Errorf("insert into command_log_events: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+type dbCommandLogEntry struct {
+	ID            string `db:"id"`
+	CommandType   string `db:"command_type"`
+	AggregateID   string `db:"aggregate_id"`
+	Payload       string `db:"payload"`
+	Issuer        string `db:"issuer"`
+	CorrelationID string `db:"correlation_id"`
+	SentAt        int64  `db:"sent_at"`
+	Duration      int64  `db:"duration"`
+	Error         string `db:"error"`
+}
+
+// CommandsFor returns the commands sent to an aggregate, oldest first.
+func (s *fileStore) CommandsFor(aggregateID uuid.UUID) ([]CommandLogEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.selectCommands(`select * from command_log where aggregate_id = ? order by sent_at asc, id asc`, aggregateID.String())
+}
+
+// CommandCausing returns the command whose handling recorded the event at
+// seq, and false if no logged command did.
+func (s *fileStore) CommandCausing(seq int64) (CommandLogEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.selectCommands(`select * from command_log where id = (select command_id from command_log_events where sequence = ?)`, seq)
+	if err != nil || len(entries) == 0 {
+		return CommandLogEntry{}, false, err
+	}
+	return entries[0], true, nil
+}
+
+// selectCommands runs a query for command log rows. Callers hold s.mu.
+func (s *fileStore) selectCommands(query string, args ...any) ([]CommandLogEntry, error) {
+	var rows []dbCommandLogEntry
+	if err := s.db.Select(&rows, query, args...); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("select from command_log: %w", err)
+	}
+	entries := make([]CommandLogEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := CommandLogEntry{
+			ID:            uuid.MustParse(row.ID),
+			CommandType:   row.CommandType,
+			AggregateID:   uuid.MustParse(row.AggregateID),
+			Payload:       json.RawMessage(row.Payload),
+			Issuer:        row.Issuer,
+			CorrelationID: row.CorrelationID,
+			SentAt:        time.UnixMilli(row.SentAt),
+			Duration:      time.Duration(row.Duration),
+			Error:         row.Error,
+		}
+		if err := s.db.Select(&entry.Sequences, `select sequence from command_log_events where command_id = ? order by sequence asc`, row.ID); err != nil {
+			return nil, fmt.Errorf("select from command_log_events: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,114 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// failingCommandLog fails to log every command
+type failingCommandLog struct{}
+
+func (failingCommandLog) LogCommand(CommandLogEntry) error { return errors.New("log full") }
+
+func TestCommandLog(t *testing.T) {
+	s := newTestStore(t)
+	bus := NewCommandBus()
+	bus.SetCommandLog(s)
+	bus.RegisterHandler(addItem{}, NewAggregateHandler(s, newCart))
+	errOutOfStock := errors.New("out of stock")
+	RegisterHandlerFunc(bus, func(removeItem) error { return errOutOfStock })
+
+	id := NewID()
+	ctx := WithIssuer(context.Background(), "alice")
+	if err := bus.SendContext(ctx, addItem{ID: id, SKU: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.SendContext(ctx, removeItem{ID: id, SKU: "a"}); !errors.Is(err, errOutOfStock) {
+		t.Fatalf("Send returned %v, want the handler's error", err)
+	}
+
+	entries, err := s.CommandsFor(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("logged %d commands, want both", len(entries))
+	}
+	slices.SortFunc(entries, func(a, b CommandLogEntry) int { return strings.Compare(a.CommandType, b.CommandType) })
+	added, removed := entries[0], entries[1]
+	if added.CommandType != "addItem" || added.Issuer != "alice" || added.Error != "" || !slices.Equal(added.Sequences, []int64{1}) {
+		t.Errorf("logged %+v for the command handled", added)
+	}
+	if !strings.Contains(string(added.Payload), `"SKU":"a"`) || added.SentAt.IsZero() || added.AggregateID != id {
+		t.Errorf("logged %+v, want the command's payload", added)
+	}
+	if removed.CommandType != "removeItem" || removed.Error != "out of stock" || len(removed.Sequences) != 0 {
+		t.Errorf("logged %+v for the command failed", removed)
+	}
+
+	// the command's events trace back to it
+	cmd, ok, err := s.CommandCausing(1)
+	if err != nil || !ok || cmd.ID != added.ID {
+		t.Errorf("command causing event 1: %+v, %v, %v", cmd, ok, err)
+	}
+	if rec := mustLoad(t, s, id)[0]; CorrelationID(rec) != added.CorrelationID || added.CorrelationID == "" {
+		t.Errorf("event in workflow %q, want the command's %q", CorrelationID(rec), added.CorrelationID)
+	}
+	if _, ok, err := s.CommandCausing(2); ok || err != nil {
+		t.Errorf("found a command causing an event never recorded, %v", err)
+	}
+}
+
+func TestCommandLogInWorkflow(t *testing.T) {
+	log := NewMemoryCommandLog()
+	bus := NewCommandBus()
+	bus.SetCommandLog(log)
+	var h countingHandler
+	bus.RegisterHandler(addItem{}, &h)
+	if err := bus.SendContext(WithCorrelationID(context.Background(), "req-1"), addItem{ID: NewID()}); err != nil {
+		t.Fatal(err)
+	}
+	if entries := log.Entries(); len(entries) != 1 || entries[0].CorrelationID != "req-1" {
+		t.Errorf("logged %+v, want the command in the workflow it was sent in", entries)
+	}
+}
+
+func TestCommandLogFails(t *testing.T) {
+	bus := NewCommandBus()
+	bus.SetCommandLog(failingCommandLog{})
+	var h countingHandler
+	bus.RegisterHandler(addItem{}, &h)
+	if err := bus.Send(addItem{ID: NewID()}); err == nil || !strings.Contains(err.Error(), "handled but not logged") {
+		t.Errorf("Send returned %v, want the logging error", err)
+	}
+	if h.handled != 1 {
+		t.Errorf("handled %d commands", h.handled)
+	}
+}
+
+// Commands sent within the same millisecond are still returned in the
+// order they were sent.
+func TestCommandsForOrder(t *testing.T) {
+	s := newTestStore(t)
+	bus := NewCommandBus()
+	bus.SetCommandLog(s)
+	bus.RegisterHandler(addItem{}, NewAggregateHandler(s, newCart))
+	id := NewID()
+	for range 20 {
+		if err := bus.Send(addItem{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := s.CommandsFor(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, entry := range entries {
+		if !slices.Equal(entry.Sequences, []int64{int64(i + 1)}) {
+			t.Fatalf("command %d recorded %v, want the commands in the order sent", i+1, entry.Sequences)
+		}
+	}
+}
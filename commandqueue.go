@@ -0,0 +1,296 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var errQueueClosed = errors.New("command queue shut down")
+
+// Statuses of a queued command.
+const (
+	CommandPending = "pending"
+	CommandDone    = "done"
+	CommandFailed  = "failed"
+)
+
+const (
+	// default number of times a queued command is sent before it fails
+	defaultQueueMaxAttempts = 10
+	// retries back off from queueBaseBackoff, doubling up to
+	// maxQueueBackoff
+	queueBaseBackoff = time.Second
+	maxQueueBackoff  = 5 * time.Minute
+	// commands RunPending reads at a time
+	queueBatchLimit = 100
+)
+
+// QueuedCommand is the state of a command accepted by SendAsync.
+type QueuedCommand struct {
+	ID          uuid.UUID
+	CommandType string
+	Status      string
+	Attempts    int
+	LastError   string
+	QueuedAt    time.Time
+}
+
+// CommandQueue accepts commands to be sent later and sends them through a
+// CommandSender in the background, retrying with backoff, so a frontend can
+// take commands while the handlers or their stores are briefly unavailable.
+// Queued commands survive restarts and are sent in the order they were
+// queued, apart from retries. Command types must be registered with
+// RegisterCommand.
+type CommandQueue struct {
+	CommandRegistry
+	mu sync.Mutex
+	// running is held by RunPending while it sends, so Shutdown can wait
+	// for it
+	running     sync.Mutex
+	closed      bool
+	db          *sqlx.DB
+	sender      CommandSender
+	logger      Logger
+	interval    time.Duration
+	maxAttempts int
+	wake        chan struct{}
+}
+
+// NewCommandQueue opens the queue database, which may be the same file as
+// a file store.
+func NewCommandQueue(dbFile string, sender CommandSender) (*CommandQueue, error) {
+	db, err := openSQLite(dbFile, defaultSQLiteConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(`
+		create table if not exists queued_commands (
+			seq          integer primary key autoincrement,
+			id           text not null unique,
+			queued_at    integer not null, -- unix milliseconds
+			next_at      integer not null, -- unix milliseconds
+			command_type text not null,
+			command_json text not null,
+			attempts     integer not null default 0,
+			last_error   text not null default '',
+			status       text not null default 'pending'
+		);
+		create index if not exists queued_commands_next on queued_commands(status, next_at);
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create queued_commands table: %w", err)
+	}
+
+	return &CommandQueue{
+		db:          sqlx.NewDb(db, "sqlite3"),
+		sender:      sender,
+		logger:      slog.Default(),
+		interval:    time.Second,
+		maxAttempts: defaultQueueMaxAttempts,
+		wake:        make(chan struct{}, 1),
+	}, nil
+}
+
+// SetInterval sets how often Run looks for commands due a retry. The
+// default is one second.
+func (q *CommandQueue) SetInterval(d time.Duration) {
+	q.interval = d
+}
+
+// SetMaxAttempts sets how many times a command is sent before it is marked
+// failed. The default is 10.
+func (q *CommandQueue) SetMaxAttempts(n int) {
+	q.maxAttempts = n
+}
+
+func (q *CommandQueue) SetLogger(logger Logger) {
+	q.logger = logger
+}
+
+// Close closes the queue database without waiting for RunPending; see
+// Shutdown.
+func (q *CommandQueue) Close() error {
+	return q.db.Close()
+}
+
+// Shutdown waits for RunPending to finish sending, giving up when ctx is
+// done, then closes the queue database. Commands still queued are sent
+// when the queue is next run.
+func (q *CommandQueue) Shutdown(ctx context.Context) error {
+	if err := lockContext(ctx, &q.running); err != nil {
+		return fmt.Errorf("wait for command queue: %w", err)
+	}
+	defer q.running.Unlock()
+	q.closed = true
+	return q.db.Close()
+}
+
+// SendAsync queues cmd to be sent and returns the ID to look up its
+// progress with Status. The command isn't validated or handled before
+// SendAsync returns.
+func (q *CommandQueue) SendAsync(cmd Command) (uuid.UUID, error) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("Marshal: %w", err)
+	}
+	id := NewID()
+	now := time.Now().UnixMilli()
+
+	q.mu.Lock()
+	_, err = q.db.Exec(`insert into queued_commands(id, queued_at, next_at, command_type, command_json) values(?,?,?,?,?)`,
+		id.String(), now, now, TypeName(cmd), string(data))
+	q.mu.Unlock()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("insert into queued_commands: %w", err)
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+type queuedCommand struct {
+	Seq         int64  `db:"seq"`
+	ID          string `db:"id"`
+	QueuedAt    int64  `db:"queued_at"`
+	NextAt      int64  `db:"next_at"`
+	CommandType string `db:"command_type"`
+	CommandJSON string `db:"command_json"`
+	Attempts    int    `db:"attempts"`
+	LastError   string `db:"last_error"`
+	Status      string `db:"status"`
+}
+
+// Status returns the state of a command queued by SendAsync.
+func (q *CommandQueue) Status(id uuid.UUID) (QueuedCommand, error) {
+	q.mu.Lock()
+	var row queuedCommand
+	err := q.db.Get(&row, `select * from queued_commands where id = ?`, id.String())
+	q.mu.Unlock()
+	if errors.Is(err, sql.ErrNoRows) {
+		return QueuedCommand{}, fmt.Errorf("no queued command %s", id)
+	}
+	if err != nil {
+		return QueuedCommand{}, fmt.Errorf("select from queued_commands: %w", err)
+	}
+	return QueuedCommand{
+		ID:          id,
+		CommandType: row.CommandType,
+		Status:      row.Status,
+		Attempts:    row.Attempts,
+		LastError:   row.LastError,
+		QueuedAt:    time.UnixMilli(row.QueuedAt),
+	}, nil
+}
+
+// Prune deletes the commands sent or failed that were queued before t,
+// returning how many were deleted.
+func (q *CommandQueue) Prune(t time.Time) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	res, err := q.db.Exec(`delete from queued_commands where status != 'pending' and queued_at < ?`, t.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("delete from queued_commands: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// RunPending sends the queued commands that are due, returning how many
+// were sent. A command that fails to send is retried with backoff, and
+// marked failed after SetMaxAttempts errors; a command whose type isn't
+// registered fails at once.
+func (q *CommandQueue) RunPending() (int, error) {
+	q.running.Lock()
+	defer q.running.Unlock()
+	if q.closed {
+		return 0, errQueueClosed
+	}
+	sent := 0
+	for {
+		now := time.Now()
+		q.mu.Lock()
+		var rows []queuedCommand
+		err := q.db.Select(&rows, `select * from queued_commands where status = 'pending' and next_at <= ? order by seq limit ?`, now.UnixMilli(), queueBatchLimit)
+		q.mu.Unlock()
+		if err != nil {
+			return sent, fmt.Errorf("select from queued_commands: %w", err)
+		}
+
+		for _, row := range rows {
+			cmd, err := q.UnmarshalCommand(row.CommandType, []byte(row.CommandJSON))
+			permanent := err != nil
+			if err == nil {
+				err = q.sender.Send(cmd)
+			}
+			if err == nil {
+				sent++
+			}
+			if err := q.settle(row, now, err, permanent); err != nil {
+				return sent, err
+			}
+		}
+		if len(rows) < queueBatchLimit {
+			return sent, nil
+		}
+	}
+}
+
+func (q *CommandQueue) settle(row queuedCommand, now time.Time, sendErr error, permanent bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if sendErr == nil {
+		_, err := q.db.Exec(`update queued_commands set attempts = attempts + 1, status = 'done', last_error = '' where seq = ?`, row.Seq)
+		if err != nil {
+			return fmt.Errorf("update queued_commands: %w", err)
+		}
+		return nil
+	}
+
+	attempts := row.Attempts + 1
+	status := CommandPending
+	if permanent || attempts >= q.maxAttempts {
+		status = CommandFailed
+		q.logger.Error("evoke: queued command failed", "id", row.ID, "command_type", row.CommandType, "attempts", attempts, "error", sendErr)
+	} else {
+		q.logger.Warn("evoke: queued command will be retried", "id", row.ID, "command_type", row.CommandType, "attempts", attempts, "error", sendErr)
+	}
+	backoff := min(queueBaseBackoff<<min(attempts-1, 16), maxQueueBackoff)
+	_, err := q.db.Exec(`update queued_commands set attempts = ?, last_error = ?, status = ?, next_at = ? where seq = ?`,
+		attempts, sendErr.Error(), status, now.Add(backoff).UnixMilli(), row.Seq)
+	if err != nil {
+		return fmt.Errorf("update queued_commands: %w", err)
+	}
+	return nil
+}
+
+// Run sends queued commands as they arrive, and retries every interval,
+// until ctx is done or the queue is shut down. Failures to read the queue
+// are logged and retried.
+func (q *CommandQueue) Run(ctx context.Context) error {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := q.RunPending(); errors.Is(err, errQueueClosed) {
+			return nil
+		} else if err != nil {
+			q.logger.Warn("evoke: command queue failed, will retry", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
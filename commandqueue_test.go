@@ -0,0 +1,178 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestQueue(t *testing.T, path string, sender CommandSender) *CommandQueue {
+	t.Helper()
+	q, err := NewCommandQueue(path, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterCommand(q, &addItem{})
+	q.SetLogger(&recordingLogger{})
+	t.Cleanup(func() { q.Shutdown(context.Background()) })
+	return q
+}
+
+// queueStatus returns the status of a queued command
+func queueStatus(t *testing.T, q *CommandQueue, id uuid.UUID) QueuedCommand {
+	t.Helper()
+	st, err := q.Status(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestCommandQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	var sender recordingSender
+	q := newTestQueue(t, path, &sender)
+	var ids []uuid.UUID
+	for _, sku := range []string{"a", "b"} {
+		id, err := q.SendAsync(addItem{ID: NewID(), SKU: sku})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if st := queueStatus(t, q, ids[0]); st.Status != CommandPending || st.CommandType != "addItem" || st.Attempts != 0 || st.QueuedAt.IsZero() {
+		t.Errorf("status %+v before running, want pending", st)
+	}
+	if len(sender.skus()) != 0 {
+		t.Error("sent a command before the queue was run")
+	}
+
+	// queued commands survive a restart
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	q = newTestQueue(t, path, &sender)
+	if n, err := q.RunPending(); n != 2 || err != nil {
+		t.Fatalf("RunPending sent %d, %v", n, err)
+	}
+	if got := sender.skus(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("sent %q, want the commands in the order they were queued", got)
+	}
+	if st := queueStatus(t, q, ids[1]); st.Status != CommandDone || st.Attempts != 1 {
+		t.Errorf("status %+v after sending, want done", st)
+	}
+	if n, err := q.RunPending(); n != 0 || err != nil {
+		t.Errorf("RunPending sent %d again, %v", n, err)
+	}
+	if _, err := q.Status(NewID()); err == nil {
+		t.Error("got the status of a command never queued")
+	}
+}
+
+func TestCommandQueueRetries(t *testing.T) {
+	sender := recordingSender{err: errors.New("store unavailable")}
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "queue.db"), &sender)
+	q.SetMaxAttempts(3)
+	id, err := q.SendAsync(addItem{ID: NewID(), SKU: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry := func() {
+		t.Helper()
+		if _, err := q.db.Exec(`update queued_commands set next_at = 0`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.RunPending(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := q.RunPending(); err != nil {
+		t.Fatal(err)
+	}
+	if st := queueStatus(t, q, id); st.Status != CommandPending || st.Attempts != 1 || st.LastError != "store unavailable" {
+		t.Errorf("status %+v after a failure, want it pending with the error", st)
+	}
+	// not due again until it has backed off
+	if _, err := q.RunPending(); err != nil {
+		t.Fatal(err)
+	}
+	if st := queueStatus(t, q, id); st.Attempts != 1 {
+		t.Errorf("sent %d times, want the retry to wait", st.Attempts)
+	}
+	retry()
+	retry()
+	if st := queueStatus(t, q, id); st.Status != CommandFailed || st.Attempts != 3 {
+		t.Errorf("status %+v after the last attempt, want failed", st)
+	}
+
+	// a command of a type not registered fails at once
+	sender.mu.Lock()
+	sender.err = nil
+	sender.mu.Unlock()
+	id, err = q.SendAsync(removeItem{ID: NewID(), SKU: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry()
+	if st := queueStatus(t, q, id); st.Status != CommandFailed || st.Attempts != 1 {
+		t.Errorf("status %+v of an unregistered command, want failed", st)
+	}
+}
+
+func TestCommandQueuePrune(t *testing.T) {
+	var sender recordingSender
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "queue.db"), &sender)
+	done, err := q.SendAsync(addItem{ID: NewID(), SKU: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.RunPending(); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := q.SendAsync(addItem{ID: NewID(), SKU: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := q.Prune(time.Now().Add(time.Second)); n != 1 || err != nil {
+		t.Fatalf("Prune deleted %d, %v, want the command sent", n, err)
+	}
+	if _, err := q.Status(done); err == nil {
+		t.Error("pruned command still has a status")
+	}
+	if st := queueStatus(t, q, pending); st.Status != CommandPending {
+		t.Errorf("status %+v of a pending command after pruning", st)
+	}
+}
+
+func TestCommandQueueRun(t *testing.T) {
+	var sender recordingSender
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "queue.db"), &sender)
+	q.SetInterval(time.Hour)
+	errc := make(chan error, 1)
+	go func() { errc <- q.Run(context.Background()) }()
+
+	// queuing a command wakes Run up
+	if _, err := q.SendAsync(addItem{ID: NewID(), SKU: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sender.skus()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Run didn't send the queued command")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	q.wake <- struct{}{}
+	if err := <-errc; err != nil {
+		t.Errorf("Run returned %v after Shutdown", err)
+	}
+}
@@ -0,0 +1,117 @@
+package evoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CommandReplay is what re-sending a logged command recorded, next to what
+// sending it did originally.
+type CommandReplay struct {
+	Entry CommandLogEntry
+	// Want are the events the command recorded originally
+	Want []Event
+	// Got are the events re-sending it recorded
+	Got []Event
+	// Err is the error re-sending it returned
+	Err error
+}
+
+// Diff describes how re-sending the command differed from sending it
+// originally, one difference per line, or is empty if it didn't.
+func (r CommandReplay) Diff() string {
+	var diffs []string
+	if got := errorString(r.Err); got != r.Entry.Error {
+		diffs = append(diffs, fmt.Sprintf("error: want %q, got %q", r.Entry.Error, got))
+	}
+	for i := 0; i < max(len(r.Want), len(r.Got)); i++ {
+		var want, got string
+		if i < len(r.Want) {
+			want = describeEvent(r.Want[i])
+		}
+		if i < len(r.Got) {
+			got = describeEvent(r.Got[i])
+		}
+		switch {
+		case want == got:
+		case got == "":
+			diffs = append(diffs, fmt.Sprintf("event %d: want %s, got none", i+1, want))
+		case want == "":
+			diffs = append(diffs, fmt.Sprintf("event %d: want none, got %s", i+1, got))
+		default:
+			diffs = append(diffs, fmt.Sprintf("event %d: want %s, got %s", i+1, want, got))
+		}
+	}
+	return strings.Join(diffs, "\n")
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// describeEvent returns the type and JSON of e, to compare events by
+func describeEvent(e Event) string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("%s (%v)", TypeName(e), err)
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) == nil {
+		data = buf.Bytes()
+	}
+	return TypeName(e) + " " + string(data)
+}
+
+// ReplayCommands re-sends logged commands, oldest first, to handlers setup
+// registers against a fresh simple store, and returns what each recorded
+// next to what it recorded originally, read from original by sequence. Run
+// it over a command log after refactoring aggregate logic to check the
+// commands still record the same events: a replay whose Diff isn't empty
+// changed behaviour.
+//
+// The commands are decoded from their payloads as registered with
+// commands.
+func ReplayCommands(entries []CommandLogEntry, original EventStore, commands CommandRegisterer, setup func(store EventStore, bus HandlerRegisterer)) ([]CommandReplay, error) {
+	store := NewSimpleStore(nil)
+	bus := NewCommandBus()
+	setup(store, bus)
+	// the events each command records are those appended while it's handled
+	next := int64(1)
+
+	replays := make([]CommandReplay, 0, len(entries))
+	for _, entry := range entries {
+		cmd, err := commands.UnmarshalCommand(entry.CommandType, entry.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("UnmarshalCommand %s: %w", entry.ID, err)
+		}
+		replay := CommandReplay{Entry: entry}
+		for _, seq := range entry.Sequences {
+			recs, err := original.ReadAll(seq, 1)
+			if err != nil {
+				return nil, fmt.Errorf("ReadAll: %w", err)
+			}
+			if len(recs) == 0 || recs[0].Sequence != seq {
+				return nil, fmt.Errorf("event %d of command %s not found", seq, entry.ID)
+			}
+			replay.Want = append(replay.Want, recs[0].Event)
+		}
+
+		replay.Err = bus.SendContext(context.Background(), cmd)
+		recs, err := store.ReadAll(next, 0)
+		if err != nil {
+			return nil, fmt.Errorf("ReadAll: %w", err)
+		}
+		for _, rec := range recs {
+			replay.Got = append(replay.Got, rec.Event)
+			next = rec.Sequence + 1
+		}
+		replays = append(replays, replay)
+	}
+	return replays, nil
+}
@@ -0,0 +1,87 @@
+package evoke
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// loggedCommands sends three carts' worth of commands through a logged bus
+// and returns the log, with the commands registered to decode it
+func loggedCommands(t *testing.T) (*fileStore, []CommandLogEntry, *CommandRegistry) {
+	t.Helper()
+	s := newTestStore(t)
+	bus := NewCommandBus()
+	bus.SetCommandLog(s)
+	bus.RegisterHandler(addItem{}, NewAggregateHandler(s, newCart))
+	id := NewID()
+	for _, sku := range []string{"a", "b"} {
+		if err := bus.Send(addItem{ID: id, SKU: sku}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := s.CommandsFor(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commands CommandRegistry
+	RegisterCommand(&commands, addItem{})
+	return s, entries, &commands
+}
+
+func TestReplayCommands(t *testing.T) {
+	s, entries, commands := loggedCommands(t)
+	replays, err := ReplayCommands(entries, s, commands, func(store EventStore, bus HandlerRegisterer) {
+		bus.RegisterHandler(addItem{}, NewAggregateHandler(store, newCart))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replays) != 2 {
+		t.Fatalf("%d replays, want one per command", len(replays))
+	}
+	for _, r := range replays {
+		if diff := r.Diff(); diff != "" || len(r.Got) != 1 {
+			t.Errorf("replay of %s recorded %v, diff:\n%s", r.Entry.CommandType, r.Got, diff)
+		}
+	}
+}
+
+func TestReplayCommandsChanged(t *testing.T) {
+	s, entries, commands := loggedCommands(t)
+	replays, err := ReplayCommands(entries, s, commands, func(store EventStore, bus HandlerRegisterer) {
+		RegisterHandlerFunc(bus, func(cmd addItem) error {
+			if cmd.SKU == "b" {
+				return errors.New("out of stock")
+			}
+			return store.Record(cmd.ID, []Event{itemAdded{SKU: cmd.SKU, Qty: 5}, itemRemoved{SKU: cmd.SKU}})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`event 1: want itemAdded {"SKU":"a","Qty":1}, got itemAdded {"SKU":"a","Qty":5}` + "\n" +
+			`event 2: want none, got itemRemoved {"SKU":"a"}`,
+		`error: want "", got "out of stock"` + "\n" +
+			`event 1: want itemAdded {"SKU":"b","Qty":2}, got none`,
+	}
+	for i, r := range replays {
+		if diff := r.Diff(); diff != want[i] {
+			t.Errorf("replay %d diff:\n%s\nwant:\n%s", i+1, diff, want[i])
+		}
+	}
+}
+
+func TestReplayCommandsErrors(t *testing.T) {
+	s, entries, commands := loggedCommands(t)
+	setup := func(store EventStore, bus HandlerRegisterer) {
+		bus.RegisterHandler(addItem{}, NewAggregateHandler(store, newCart))
+	}
+	if _, err := ReplayCommands(entries, s, &CommandRegistry{}, setup); !errors.Is(err, ErrCommandNotRegistered) {
+		t.Errorf("replaying unregistered commands returned %v", err)
+	}
+	if _, err := ReplayCommands(entries, newTestStore(t), commands, setup); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("replaying against a store without the events returned %v", err)
+	}
+}
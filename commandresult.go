@@ -0,0 +1,136 @@
+package evoke
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// CommandResult is what handling a command recorded, for callers that read
+// their own writes or answer an API request with the outcome of a command.
+type CommandResult struct {
+	AggregateID uuid.UUID
+	// Version is the version of the aggregate's stream after the command
+	Version int64
+	// Events are the events the command recorded, in order
+	Events []RecordedEvent
+	// Position is the global sequence of the last event, or 0 if the store
+	// doesn't report sequences or the command recorded nothing
+	Position int64
+}
+
+type resultKey struct{}
+
+// resultCollector gathers the events recorded while handling a command
+type resultCollector struct {
+	mu   sync.Mutex
+	recs []RecordedEvent
+	// prior is the result a duplicate idempotent command was first handled
+	// with
+	prior *CommandResult
+	// parent is the collector of the context the collector was added to,
+	// which gathers the same events
+	parent *resultCollector
+}
+
+// collect adds a collector to ctx, within the one ctx already has
+func collect(ctx context.Context) (context.Context, *resultCollector) {
+	parent, _ := ctx.Value(resultKey{}).(*resultCollector)
+	c := &resultCollector{parent: parent}
+	return context.WithValue(ctx, resultKey{}, c), c
+}
+
+// NoteRecorded tells the SendR call handling the command in ctx, if any,
+// and the bus's command log, which events were just recorded. Stores call
+// it after appending.
+func NoteRecorded(ctx context.Context, recs []RecordedEvent) {
+	c, _ := ctx.Value(resultKey{}).(*resultCollector)
+	for ; c != nil; c = c.parent {
+		c.mu.Lock()
+		c.recs = append(c.recs, recs...)
+		c.mu.Unlock()
+	}
+}
+
+// notePrior tells the SendR call handling the command in ctx, if any, the
+// result the command was first handled with
+func notePrior(ctx context.Context, res CommandResult) {
+	c, _ := ctx.Value(resultKey{}).(*resultCollector)
+	for ; c != nil; c = c.parent {
+		c.mu.Lock()
+		c.prior = &res
+		c.mu.Unlock()
+	}
+}
+
+// noteHandled reports the events an aggregate command recorded when the
+// store didn't, without their sequences
+func noteHandled(ctx context.Context, aggregateID uuid.UUID, version int64, evs []Event) {
+	c, ok := ctx.Value(resultKey{}).(*resultCollector)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	for _, rec := range c.recs {
+		if rec.AggregateID == aggregateID {
+			c.mu.Unlock()
+			return
+		}
+	}
+	c.mu.Unlock()
+
+	recs := make([]RecordedEvent, len(evs))
+	for i, e := range evs {
+		recs[i] = RecordedEvent{
+			AggregateID: aggregateID,
+			Version:     version + int64(i) + 1,
+			Event:       e,
+			EventType:   TypeName(e),
+		}
+	}
+	NoteRecorded(ctx, recs)
+}
+
+// ResultSender is implemented by command buses that report what handling a
+// command recorded.
+type ResultSender interface {
+	SendR(cmd Command) (CommandResult, error)
+}
+
+// SendR sends a command like Send, returning what it recorded. A command
+// skipped as a duplicate by the idempotency store returns the result it was
+// first handled with.
+func (b *simpleCommandBus) SendR(cmd Command) (CommandResult, error) {
+	return b.SendRContext(context.Background(), cmd)
+}
+
+// SendRContext sends a command like SendContext, returning what it
+// recorded.
+func (b *simpleCommandBus) SendRContext(ctx context.Context, cmd Command) (CommandResult, error) {
+	ctx, c := collect(ctx)
+	err := b.SendContext(ctx, cmd)
+	if err != nil {
+		return CommandResult{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prior != nil {
+		return *c.prior, nil
+	}
+	return resultOf(cmd.AggregateID(), c.recs), nil
+}
+
+// resultOf returns the result of a command for aggregateID that recorded
+// recs
+func resultOf(aggregateID uuid.UUID, recs []RecordedEvent) CommandResult {
+	res := CommandResult{AggregateID: aggregateID, Events: recs}
+	for _, rec := range recs {
+		if rec.AggregateID == res.AggregateID {
+			res.Version = max(res.Version, rec.Version)
+		}
+		res.Position = max(res.Position, rec.Sequence)
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+package evoke
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSendR(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			bus := NewCommandBus()
+			bus.RegisterHandler(addItem{}, NewAggregateHandler(s, newCart))
+			id := NewID()
+			if err := s.Record(NewID(), []Event{itemAdded{SKU: "other"}}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := bus.SendR(addItem{ID: id, SKU: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			res, err := bus.SendR(addItem{ID: id, SKU: "b"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.AggregateID != id || res.Version != 2 || len(res.Events) != 1 {
+				t.Fatalf("result %+v, want the second command's event at version 2", res)
+			}
+			if res.Events[0].Event != (itemAdded{SKU: "b", Qty: 2}) || res.Events[0].EventType != "itemAdded" {
+				t.Errorf("result event %+v", res.Events[0])
+			}
+			// the in-memory store doesn't report sequences
+			wantPosition := int64(3)
+			if name == "simple" {
+				wantPosition = 0
+			}
+			if res.Position != wantPosition || res.Events[0].Sequence != wantPosition {
+				t.Errorf("result at position %d, event at sequence %d, want %d", res.Position, res.Events[0].Sequence, wantPosition)
+			}
+		})
+	}
+}
+
+func TestSendRFailure(t *testing.T) {
+	bus := NewCommandBus()
+	if res, err := bus.SendR(addItem{ID: NewID()}); err == nil || res.AggregateID != (uuid.UUID{}) {
+		t.Errorf("SendR without a handler returned %+v, %v", res, err)
+	}
+}
+
+func TestResultOf(t *testing.T) {
+	a, b := NewID(), NewID()
+	res := resultOf(a, []RecordedEvent{
+		{AggregateID: a, Version: 4, Sequence: 10},
+		{AggregateID: b, Version: 9, Sequence: 11},
+		{AggregateID: a, Version: 5, Sequence: 12},
+	})
+	if res.Version != 5 || res.Position != 12 || len(res.Events) != 3 {
+		t.Errorf("result %+v, want the aggregate's last version and the last sequence", res)
+	}
+	if got := sequences(res.Events); !slices.Equal(got, []int64{10, 11, 12}) {
+		t.Errorf("result events %v", got)
+	}
+}
@@ -0,0 +1,336 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConsumerGroupStore coordinates the members of consumer groups: who is
+// alive, which member owns each partition, and how far each partition has
+// been consumed.
+type ConsumerGroupStore interface {
+	CheckpointStore
+	// JoinGroup records that member is alive for ttl and returns the live
+	// members of group, sorted
+	JoinGroup(group, member string, ttl time.Duration) ([]string, error)
+	LeaveGroup(group, member string) error
+	// ClaimPartition makes member the owner of a partition for ttl, unless
+	// another member owns it and its claim hasn't expired
+	ClaimPartition(group string, partition int, member string, ttl time.Duration) (bool, error)
+	ReleasePartition(group string, partition int, member string) error
+}
+
+func (s *fileStore) JoinGroup(group, member string, ttl time.Duration) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	_, err := s.db.Exec(`insert into consumer_members(group_name, member, expires_at) values(?,?,?)
+		on conflict(group_name, member) do update set expires_at = excluded.expires_at`, group, member, now.Add(ttl).UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("insert into consumer_members: %w", err)
+	}
+	var members []string
+	err = s.db.Select(&members, `select member from consumer_members where group_name = ? and expires_at >= ? order by member`, group, now.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("select from consumer_members: %w", err)
+	}
+	return members, nil
+}
+
+func (s *fileStore) LeaveGroup(group, member string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.db.Exec(`delete from consumer_partitions where group_name = ? and member = ?`, group, member); err != nil {
+		return fmt.Errorf("delete from consumer_partitions: %w", err)
+	}
+	if _, err := s.db.Exec(`delete from consumer_members where group_name = ? and member = ?`, group, member); err != nil {
+		return fmt.Errorf("delete from consumer_members: %w", err)
+	}
+	return nil
+}
+
+func (s *fileStore) ClaimPartition(group string, partition int, member string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	res, err := s.db.Exec(`insert into consumer_partitions(group_name, partition, member, expires_at) values(?,?,?,?)
+		on conflict(group_name, partition) do update set member = excluded.member, expires_at = excluded.expires_at
+		where consumer_partitions.member = excluded.member or consumer_partitions.expires_at < ?`,
+		group, partition, member, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("insert into consumer_partitions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *fileStore) ReleasePartition(group string, partition int, member string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`delete from consumer_partitions where group_name = ? and partition = ? and member = ?`, group, partition, member)
+	if err != nil {
+		return fmt.Errorf("delete from consumer_partitions: %w", err)
+	}
+	return nil
+}
+
+// partitionOf assigns aggregates to partitions, so all events of a stream
+// are consumed in order by the same member
+func partitionOf(aggregateID uuid.UUID, partitions int) int {
+	h := fnv.New32a()
+	h.Write(aggregateID[:])
+	return int(h.Sum32() % uint32(partitions))
+}
+
+// ConsumerGroup is one member of a named group of consumers sharing the
+// work of handling a store's log, typically one per instance of a service.
+// The log is split into partitions by aggregate; the live members divide
+// the partitions among themselves and each partition is owned by one member
+// at a time, so every event is handled by a single member of the group.
+// Members joining, leaving or dying move partitions between the rest.
+//
+// Progress is checkpointed per partition after each batch, so delivery is
+// at least once: a member taking over a partition may handle again events
+// its previous owner handled after its last checkpoint.
+type ConsumerGroup struct {
+	group      string
+	member     string
+	store      EventStore
+	groups     ConsumerGroupStore
+	partitions int
+	handler    RecordedEventHandlerFunc
+	filters    []EventFilter
+	interval   time.Duration
+	ttl        time.Duration
+	logger     Logger
+	wake       chan struct{}
+
+	mu    sync.Mutex // guards owned, which Partitions reads from any goroutine
+	owned map[int]bool
+}
+
+// NewConsumerGroup returns member of group, handling the events of store
+// passing the filters. Every member of a group must be created with the
+// same number of partitions, which bounds how many members can share the
+// work.
+func NewConsumerGroup(group, member string, store EventStore, groups ConsumerGroupStore, partitions int, handler RecordedEventHandlerFunc, filters ...EventFilter) *ConsumerGroup {
+	g := &ConsumerGroup{
+		group:      group,
+		member:     member,
+		store:      store,
+		groups:     groups,
+		partitions: max(partitions, 1),
+		handler:    handler,
+		filters:    filters,
+		interval:   time.Second,
+		ttl:        10 * time.Second,
+		logger:     slog.Default(),
+		wake:       make(chan struct{}, 1),
+		owned:      make(map[int]bool),
+	}
+	store.RegisterPublisher(deliveryWaker{g.wake})
+	return g
+}
+
+// SetInterval sets how often the member renews its membership, rebalances
+// partitions and polls for events recorded by other processes. The default
+// is one second.
+func (g *ConsumerGroup) SetInterval(d time.Duration) {
+	g.interval = d
+}
+
+// SetLeaseTTL sets how long a member's membership and partitions outlive
+// its last renewal, which is how long its partitions stall if it dies. It
+// must be well above the interval. The default is 10 seconds.
+func (g *ConsumerGroup) SetLeaseTTL(d time.Duration) {
+	g.ttl = d
+}
+
+func (g *ConsumerGroup) SetLogger(logger Logger) {
+	g.logger = logger
+}
+
+// Partitions returns the partitions the member currently owns.
+func (g *ConsumerGroup) Partitions() []int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var ps []int
+	for p := range g.owned {
+		ps = append(ps, p)
+	}
+	slices.Sort(ps)
+	return ps
+}
+
+// Rebalance renews the membership, claims the partitions assigned to this
+// member and releases the others.
+func (g *ConsumerGroup) Rebalance() error {
+	members, err := g.groups.JoinGroup(g.group, g.member, g.ttl)
+	if err != nil {
+		return fmt.Errorf("join group: %w", err)
+	}
+	self := slices.Index(members, g.member)
+	for p := range g.partitions {
+		if self >= 0 && p%len(members) == self {
+			ok, err := g.groups.ClaimPartition(g.group, p, g.member, g.ttl)
+			if err != nil {
+				return fmt.Errorf("claim partition %d: %w", p, err)
+			}
+			g.setOwned(p, ok)
+		} else if g.owns(p) {
+			if err := g.groups.ReleasePartition(g.group, p, g.member); err != nil {
+				return fmt.Errorf("release partition %d: %w", p, err)
+			}
+			g.setOwned(p, false)
+		}
+	}
+	return nil
+}
+
+func (g *ConsumerGroup) owns(partition int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.owned[partition]
+}
+
+func (g *ConsumerGroup) setOwned(partition int, owned bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if owned {
+		g.owned[partition] = true
+	} else {
+		delete(g.owned, partition)
+	}
+}
+
+func (g *ConsumerGroup) checkpointName(partition int) string {
+	return g.group + "/" + strconv.Itoa(partition)
+}
+
+// Consume handles the events recorded since the checkpoint of each owned
+// partition, reading the log once for all of them. A partition stops at its
+// first handler error, while the others carry on.
+func (g *ConsumerGroup) Consume() error {
+	parts := make(map[int]*partitionProgress)
+	from := int64(math.MaxInt64)
+	for _, p := range g.Partitions() {
+		seq, err := g.groups.LoadCheckpoint(g.checkpointName(p))
+		if err != nil {
+			return fmt.Errorf("partition %d: load checkpoint: %w", p, err)
+		}
+		parts[p] = &partitionProgress{seq: seq, saved: seq}
+		from = min(from, seq)
+	}
+
+	var errs []error
+	for len(parts) > 0 {
+		batch, err := g.store.ReadAll(from+1, deliveryBatch)
+		if err != nil {
+			return errors.Join(append(errs, fmt.Errorf("read store: %w", err))...)
+		}
+		for _, rec := range batch {
+			p := partitionOf(rec.AggregateID, g.partitions)
+			pp := parts[p]
+			if pp == nil || pp.failed || rec.Sequence <= pp.seq {
+				continue
+			}
+			if MatchesAll(g.filters, rec) {
+				if err := g.handler(rec, false); err != nil {
+					errs = append(errs, fmt.Errorf("partition %d: handle event %d: %w", p, rec.Sequence, err))
+					pp.failed = true
+					continue
+				}
+			}
+			pp.seq = rec.Sequence
+		}
+
+		from = math.MaxInt64
+		for p, pp := range parts {
+			// the rest of the batch is other partitions' events
+			if !pp.failed && len(batch) > 0 {
+				pp.seq = max(pp.seq, batch[len(batch)-1].Sequence)
+			}
+			if pp.seq > pp.saved {
+				if err := g.groups.SaveCheckpoint(g.checkpointName(p), pp.seq); err != nil {
+					return errors.Join(append(errs, fmt.Errorf("partition %d: save checkpoint: %w", p, err))...)
+				}
+				pp.saved = pp.seq
+			}
+			if pp.failed {
+				delete(parts, p)
+				continue
+			}
+			from = min(from, pp.seq)
+		}
+		if len(batch) < deliveryBatch {
+			break
+		}
+
+		// renew the claims between batches of a long catch up
+		for p := range parts {
+			ok, err := g.groups.ClaimPartition(g.group, p, g.member, g.ttl)
+			if err != nil {
+				return errors.Join(append(errs, fmt.Errorf("partition %d: claim partition: %w", p, err))...)
+			}
+			if !ok {
+				g.setOwned(p, false)
+				delete(parts, p)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// partitionProgress is how far Consume has got with a partition
+type partitionProgress struct {
+	seq    int64
+	saved  int64
+	failed bool
+}
+
+// Run takes part in the group until ctx is done, then leaves it so the
+// other members take over its partitions straight away. Handler and
+// rebalance errors are logged and retried at the next interval; until a
+// rebalance succeeds the member consumes nothing, as its claims may have
+// lapsed.
+func (g *ConsumerGroup) Run(ctx context.Context) error {
+	defer g.groups.LeaveGroup(g.group, g.member)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	rebalance := true
+	for {
+		if rebalance {
+			if err := g.Rebalance(); err != nil {
+				g.logger.Warn("evoke: consumer group rebalance failed, will retry", "group", g.group, "member", g.member, "error", err)
+			} else {
+				rebalance = false
+			}
+		}
+		if !rebalance {
+			if err := g.Consume(); err != nil {
+				g.logger.Warn("evoke: consumer group handler failed, will retry", "group", g.group, "member", g.member, "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-g.wake:
+		case <-ticker.C:
+			rebalance = true
+		}
+	}
+}
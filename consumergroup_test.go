@@ -0,0 +1,175 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// groupMember returns a member of group "g" over s with 4 partitions,
+// recording what it handles in handled
+func groupMember(s *fileStore, member string, handled *[]int64, handler RecordedEventHandlerFunc) *ConsumerGroup {
+	return NewConsumerGroup("g", member, s, s, 4, func(rec RecordedEvent, replay bool) error {
+		if handler != nil {
+			if err := handler(rec, replay); err != nil {
+				return err
+			}
+		}
+		*handled = append(*handled, rec.Sequence)
+		return nil
+	})
+}
+
+func rebalance(t *testing.T, members ...*ConsumerGroup) {
+	t.Helper()
+	for _, m := range members {
+		if err := m.Rebalance(); err != nil {
+			t.Fatalf("Rebalance %s: %v", m.member, err)
+		}
+	}
+}
+
+func TestConsumerGroupSharesPartitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		members int
+		leave   bool
+		want    [][]int
+	}{
+		{name: "one member", members: 1, want: [][]int{{0, 1, 2, 3}}},
+		{name: "two members", members: 2, want: [][]int{{0, 2}, {1, 3}}},
+		{name: "more members than partitions", members: 5, want: [][]int{{0}, {1}, {2}, {3}, nil}},
+		{name: "a member leaves", members: 2, leave: true, want: [][]int{{0, 1, 2, 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			var handled []int64
+			var members []*ConsumerGroup
+			for _, name := range []string{"a", "b", "c", "d", "e"}[:tt.members] {
+				members = append(members, groupMember(s, name, &handled, nil))
+			}
+			// the first rebalance of the early members claims what the
+			// later ones are assigned, so the second settles it
+			rebalance(t, members...)
+			rebalance(t, members...)
+			if tt.leave {
+				if err := s.LeaveGroup("g", members[1].member); err != nil {
+					t.Fatal(err)
+				}
+				rebalance(t, members[0])
+			}
+
+			for i, want := range tt.want {
+				m := members[i]
+				if got := m.Partitions(); !slices.Equal(got, want) {
+					t.Errorf("member %s owns %v, want %v", m.member, got, want)
+				}
+			}
+		})
+	}
+}
+
+// A member that dies keeps its partitions until its lease runs out.
+func TestConsumerGroupLeaseExpiry(t *testing.T) {
+	s := newTestStore(t)
+	var handled []int64
+	a, b := groupMember(s, "a", &handled, nil), groupMember(s, "b", &handled, nil)
+	for _, m := range []*ConsumerGroup{a, b} {
+		m.SetLeaseTTL(100 * time.Millisecond)
+	}
+	rebalance(t, a, b, a, b)
+	if got := b.Partitions(); !slices.Equal(got, []int{1, 3}) {
+		t.Fatalf("b owns %v, want [1 3]", got)
+	}
+
+	// b stops renewing; a is assigned everything once b's membership
+	// expires, but only gets b's partitions once their claims do too
+	time.Sleep(150 * time.Millisecond)
+	rebalance(t, a)
+	if got := a.Partitions(); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("a owns %v after b's lease expired, want all", got)
+	}
+}
+
+// Each event is handled by the one member owning its partition, and a
+// partition whose handler fails is retried from where it stopped.
+func TestConsumerGroupConsume(t *testing.T) {
+	s := newTestStore(t)
+	// streams in every partition, two events each
+	var ids []uuid.UUID
+	covered := make(map[int]bool)
+	for len(ids) < 8 || len(covered) < 4 {
+		id := NewID()
+		ids = append(ids, id)
+		covered[partitionOf(id, 4)] = true
+		if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a fails once on a stream of partition 0
+	failed := ids[slices.IndexFunc(ids, func(id uuid.UUID) bool { return partitionOf(id, 4) == 0 })]
+	var failedOnce bool
+	var handledA, handledB []int64
+	a := groupMember(s, "a", &handledA, func(rec RecordedEvent, _ bool) error {
+		if rec.AggregateID == failed && !failedOnce {
+			failedOnce = true
+			return errors.New("handler failed")
+		}
+		return nil
+	})
+	b := groupMember(s, "b", &handledB, nil)
+	rebalance(t, a, b, a, b)
+
+	if err := a.Consume(); err == nil {
+		t.Error("Consume returned no error for the failed handler")
+	}
+	for _, m := range []*ConsumerGroup{a, b} {
+		if err := m.Consume(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := slices.Concat(handledA, handledB)
+	slices.Sort(all)
+	if want := sequences(mustReadAll(t, s)); !slices.Equal(all, want) {
+		t.Errorf("handled %v, want every event once: %v", all, want)
+	}
+	for _, seq := range handledA {
+		rec := mustReadAll(t, s)[seq-1]
+		if p := partitionOf(rec.AggregateID, 4); p%2 != 0 {
+			t.Errorf("a handled event %d of partition %d, which b owns", seq, p)
+		}
+	}
+}
+
+// Partitions may be called while the member runs.
+func TestConsumerGroupPartitionsWhileRunning(t *testing.T) {
+	s := newTestStore(t)
+	var handled []int64
+	g := groupMember(s, "a", &handled, nil)
+	g.SetInterval(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- g.Run(ctx) }()
+	for ctx.Err() == nil {
+		g.Partitions()
+	}
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func mustReadAll(t *testing.T, s EventStore) []RecordedEvent {
+	t.Helper()
+	recs, err := s.ReadAll(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recs
+}
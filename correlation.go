@@ -0,0 +1,77 @@
+package evoke
+
+import (
+	"context"
+	"strconv"
+)
+
+// Metadata keys linking events into workflows. Every event recorded in a
+// context carries the correlation ID of the workflow it belongs to, and,
+// when it was recorded handling another event, that event's sequence as
+// its causation ID.
+const (
+	CorrelationIDKey = "correlation_id"
+	CausationIDKey   = "causation_id"
+)
+
+type correlationKey struct{}
+
+type correlation struct {
+	id        string
+	causation int64
+}
+
+// WithCorrelationID makes events recorded in ctx part of the workflow id,
+// such as the ID of the request that started it. Events recorded outside
+// of a workflow start one of their own.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	c.id = id
+	return context.WithValue(ctx, correlationKey{}, c)
+}
+
+// correlationID returns the workflow events recorded in ctx are part of,
+// if any
+func correlationID(ctx context.Context) string {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	return c.id
+}
+
+// CausedBy makes events recorded in ctx caused by rec, in its workflow.
+// Event buses hand handlers a context caused by the event handled, so what
+// a handler records, and the commands it sends with that context record,
+// trace back to it.
+func CausedBy(ctx context.Context, rec RecordedEvent) context.Context {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	if id := CorrelationID(rec); id != "" {
+		c.id = id
+	}
+	c.causation = rec.Sequence
+	return context.WithValue(ctx, correlationKey{}, c)
+}
+
+// CorrelationID returns the ID of the workflow rec is part of, empty for
+// events recorded before workflows were kept.
+func CorrelationID(rec RecordedEvent) string {
+	return rec.Metadata[CorrelationIDKey]
+}
+
+// CausationSequence returns the sequence of the event that caused rec, or
+// 0.
+func CausationSequence(rec RecordedEvent) int64 {
+	seq, _ := strconv.ParseInt(rec.Metadata[CausationIDKey], 10, 64)
+	return seq
+}
+
+// correlate adds the workflow of ctx to the metadata of events recorded in
+// it, starting a new workflow if ctx isn't part of one
+func correlate(ctx context.Context, md Metadata) {
+	c, _ := ctx.Value(correlationKey{}).(correlation)
+	if c.id == "" {
+		c.id = NewID().String()
+	}
+	md[CorrelationIDKey] = c.id
+	if c.causation > 0 {
+		md[CausationIDKey] = strconv.FormatInt(c.causation, 10)
+	}
+}
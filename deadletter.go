@@ -0,0 +1,127 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// PanicError is the error a panicking event handler is converted to.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it was an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recovered calls fn, converting a panic into a PanicError
+func recovered(fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// ErrorPolicy is what the event bus does when a subscriber fails or panics.
+type ErrorPolicy int
+
+const (
+	// FailFast stops the publish at the failing subscriber and returns its
+	// error, failing the Record that published the event. It is the
+	// default.
+	FailFast ErrorPolicy = iota
+	// SkipAndLog logs the error and carries on with the other subscribers.
+	SkipAndLog
+	// DeadLetter hands the event and error to the bus's dead-letter queue
+	// and carries on with the other subscribers.
+	DeadLetter
+)
+
+// OnError sets what the bus does when the subscriber fails.
+func OnError(policy ErrorPolicy) SubscribeOption {
+	return func(s *subscription) {
+		s.policy = policy
+	}
+}
+
+// DeadLetterQueue keeps events subscribers failed to handle, for inspection
+// and retry.
+type DeadLetterQueue interface {
+	DeadLetter(rec RecordedEvent, err error) error
+}
+
+// DeadLetterEntry is an event a subscriber failed to handle.
+type DeadLetterEntry struct {
+	Event    RecordedEvent
+	Err      error
+	FailedAt time.Time
+}
+
+type memoryDeadLetterQueue struct {
+	mu      sync.Mutex
+	entries []DeadLetterEntry
+}
+
+func NewMemoryDeadLetterQueue() *memoryDeadLetterQueue {
+	return &memoryDeadLetterQueue{}
+}
+
+func (q *memoryDeadLetterQueue) DeadLetter(rec RecordedEvent, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = append(q.entries, DeadLetterEntry{Event: rec, Err: err, FailedAt: time.Now()})
+	return nil
+}
+
+// Entries returns the dead-lettered events, oldest first.
+func (q *memoryDeadLetterQueue) Entries() []DeadLetterEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]DeadLetterEntry(nil), q.entries...)
+}
+
+// SetDeadLetterQueue sets where subscriptions with the DeadLetter policy
+// send the events they fail. Without one, their failures fail fast.
+func (b *simpleEventBus) SetDeadLetterQueue(q DeadLetterQueue) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deadLetters = q
+}
+
+// dispatch hands rec to sub, recovering panics and applying the
+// subscription's error policy
+func (b *simpleEventBus) dispatch(ctx context.Context, sub subscription, rec RecordedEvent, replay bool, tracer Tracer, inst Instrumentation, logger Logger, dlq DeadLetterQueue) error {
+	name := TypeName(rec.Event)
+	err := traced(ctx, tracer, inst, "event", name, func(ctx context.Context) error {
+		return recovered(func() error {
+			return handleEvent(ctx, sub.handler, rec, replay)
+		})
+	})
+	if err == nil {
+		return nil
+	}
+	switch {
+	case sub.policy == SkipAndLog:
+		logger.Error("evoke: event handler failed, skipping", "event_type", name, "sequence", rec.Sequence, "error", err)
+		return nil
+	case sub.policy == DeadLetter && dlq != nil:
+		if dlErr := dlq.DeadLetter(rec, err); dlErr != nil {
+			return fmt.Errorf("dead-letter event %d: %w (handler error: %w)", rec.Sequence, dlErr, err)
+		}
+		logger.Warn("evoke: event handler failed, dead-lettered", "event_type", name, "sequence", rec.Sequence, "error", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package evoke
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// failingHandler returns err, or panics with err if panics is set
+type failingHandler struct {
+	err    error
+	panics bool
+}
+
+func (h failingHandler) Handle(Event, bool) error {
+	if h.panics {
+		panic(h.err)
+	}
+	return h.err
+}
+
+func TestErrorPolicies(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name    string
+		opts    []SubscribeOption
+		dlq     bool
+		wantErr bool
+		// handled is whether the subscriber after the failing one got the
+		// event
+		handled    bool
+		deadLetter bool
+		logged     []string
+	}{
+		{name: "fail fast", wantErr: true},
+		{name: "skip and log", opts: []SubscribeOption{OnError(SkipAndLog)}, handled: true, logged: []string{"error: evoke: event handler failed, skipping"}},
+		{name: "dead letter", opts: []SubscribeOption{OnError(DeadLetter)}, dlq: true, handled: true, deadLetter: true, logged: []string{"warn: evoke: event handler failed, dead-lettered"}},
+		{name: "dead letter without a queue", opts: []SubscribeOption{OnError(DeadLetter)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		for _, panics := range []bool{false, true} {
+			name := tt.name
+			if panics {
+				name += " panicking"
+			}
+			t.Run(name, func(t *testing.T) {
+				var log handlerLog
+				var logger recordingLogger
+				dlq := NewMemoryDeadLetterQueue()
+				bus := NewEventBus()
+				bus.SetLogger(&logger)
+				if tt.dlq {
+					bus.SetDeadLetterQueue(dlq)
+				}
+				bus.Subscribe(itemAdded{}, failingHandler{err: boom, panics: panics}, tt.opts...)
+				bus.Subscribe(itemAdded{}, log.handler("next"))
+
+				err := bus.Publish(RecordedEvent{Sequence: 7, Event: itemAdded{}}, false)
+				if tt.wantErr != (err != nil) || err != nil && !errors.Is(err, boom) {
+					t.Errorf("Publish returned %v", err)
+				}
+				var perr *PanicError
+				if err != nil && panics != errors.As(err, &perr) {
+					t.Errorf("Publish returned %v, want a PanicError only for a panic", err)
+				}
+				if handled := len(log.handled()) == 1; handled != tt.handled {
+					t.Errorf("next subscriber handled the event: %v, want %v", handled, tt.handled)
+				}
+				entries := dlq.Entries()
+				if tt.deadLetter && (len(entries) != 1 || entries[0].Event.Sequence != 7 || !errors.Is(entries[0].Err, boom)) {
+					t.Errorf("dead-lettered %+v, want the event and its error", entries)
+				}
+				if !tt.deadLetter && len(entries) != 0 {
+					t.Errorf("dead-lettered %+v", entries)
+				}
+				if got := logger.logged(); !slices.Equal(got, tt.logged) {
+					t.Errorf("logged %q, want %q", got, tt.logged)
+				}
+			})
+		}
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	err := recovered(func() error { panic("not an error") })
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "not an error" || len(perr.Stack) == 0 {
+		t.Fatalf("recovered %v, want a PanicError with the value and stack", err)
+	}
+	if err.Error() != "handler panicked: not an error" || errors.Unwrap(err) != nil {
+		t.Errorf("PanicError %q unwraps to %v", err, errors.Unwrap(err))
+	}
+}
@@ -0,0 +1,151 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// StateStep is an aggregate's state after applying one event of its
+// stream.
+type StateStep struct {
+	Version int64
+	Event   RecordedEvent
+	// State is the aggregate as JSON once the event was applied
+	State json.RawMessage
+	// Changes are the fields the event changed, in path order
+	Changes []FieldChange
+}
+
+// FieldChange is a field of an aggregate's state an event changed.
+type FieldChange struct {
+	// Path names the field, such as State.Lines[2].Quantity
+	Path string
+	// Before is the field's value before the event, nil if it didn't exist
+	Before json.RawMessage
+	// After is the field's value after the event, nil if it no longer exists
+	After json.RawMessage
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, orNone(c.Before), orNone(c.After))
+}
+
+func orNone(v json.RawMessage) string {
+	if v == nil {
+		return "(none)"
+	}
+	return string(v)
+}
+
+// DebugHydrate loads the aggregate id from store as an AggregateHandler
+// would, returning its state, serialized as JSON, after each event of its
+// stream along with the fields the event changed, to see which event
+// changed what when tracking down bad state. Only state that serializes is
+// seen: exported fields, such as an AggregateBase's State.
+func DebugHydrate(store EventStore, factory func(id uuid.UUID) Aggregate, id uuid.UUID) ([]StateStep, error) {
+	agg := factory(id)
+	recs, err := loadStreamAfter(store, id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := stateOf(agg)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]StateStep, 0, len(recs))
+	var version int64
+	for _, rec := range recs {
+		if version, err = hydrate(agg, []RecordedEvent{rec}, version); err != nil {
+			return nil, err
+		}
+		after, err := stateOf(agg)
+		if err != nil {
+			return nil, err
+		}
+		var changes []FieldChange
+		diffJSON("", before, after, &changes)
+		steps = append(steps, StateStep{
+			Version: version,
+			Event:   rec,
+			State:   after,
+			Changes: changes,
+		})
+		before = after
+	}
+	return steps, nil
+}
+
+func stateOf(agg Aggregate) (json.RawMessage, error) {
+	data, err := json.Marshal(agg)
+	if err != nil {
+		return nil, fmt.Errorf("Marshal(%T): %w", agg, err)
+	}
+	return data, nil
+}
+
+// diffJSON appends the fields under path that differ between before and
+// after, comparing objects by key and arrays by index
+func diffJSON(path string, before, after json.RawMessage, changes *[]FieldChange) {
+	var b, a any
+	if before != nil {
+		json.Unmarshal(before, &b)
+	}
+	if after != nil {
+		json.Unmarshal(after, &a)
+	}
+	if before != nil && after != nil && reflect.DeepEqual(b, a) {
+		return
+	}
+
+	bObj, bIsObj := b.(map[string]any)
+	aObj, aIsObj := a.(map[string]any)
+	if bIsObj && aIsObj {
+		keys := make([]string, 0, len(bObj)+len(aObj))
+		for k := range bObj {
+			keys = append(keys, k)
+		}
+		for k := range aObj {
+			if _, ok := bObj[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			diffJSON(joinPath(path, k), field(bObj, k), field(aObj, k), changes)
+		}
+		return
+	}
+
+	bArr, bIsArr := b.([]any)
+	aArr, aIsArr := a.([]any)
+	if bIsArr && aIsArr {
+		for i := range max(len(bArr), len(aArr)) {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), element(bArr, i), element(aArr, i), changes)
+		}
+		return
+	}
+
+	*changes = append(*changes, FieldChange{Path: path, Before: before, After: after})
+}
+
+func field(obj map[string]any, key string) json.RawMessage {
+	v, ok := obj[key]
+	if !ok {
+		return nil
+	}
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func element(arr []any, i int) json.RawMessage {
+	if i >= len(arr) {
+		return nil
+	}
+	data, _ := json.Marshal(arr[i])
+	return data
+}
@@ -0,0 +1,112 @@
+package evoke
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type basketState struct {
+	Lines []string
+	Qty   map[string]int
+	Empty bool
+}
+
+// basket keeps a line per SKU added, and the quantity of each
+type basket struct {
+	AggregateBase[basketState]
+}
+
+func newBasket(uuid.UUID) Aggregate {
+	return &basket{NewAggregateBase(func(s *basketState, e Event) error {
+		switch e := e.(type) {
+		case itemAdded:
+			if s.Qty == nil {
+				s.Qty = make(map[string]int)
+			}
+			if _, ok := s.Qty[e.SKU]; !ok {
+				s.Lines = append(s.Lines, e.SKU)
+			}
+			s.Qty[e.SKU] += e.Qty
+		case itemRemoved:
+			delete(s.Qty, e.SKU)
+			s.Lines = s.Lines[:len(s.Lines)-1]
+		}
+		s.Empty = len(s.Lines) == 0
+		return nil
+	})}
+}
+
+func (b *basket) HandleCommand(Command) ([]Event, error) { return nil, nil }
+
+// changeStrings returns the changes of each step as strings
+func changeStrings(steps []StateStep) [][]string {
+	out := make([][]string, len(steps))
+	for i, step := range steps {
+		for _, c := range step.Changes {
+			out[i] = append(out[i], c.String())
+		}
+	}
+	return out
+}
+
+func TestDebugHydrate(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id := NewID()
+			evs := []Event{itemAdded{SKU: "a", Qty: 1}, itemAdded{SKU: "b", Qty: 2}, itemAdded{SKU: "a", Qty: 3}, itemRemoved{SKU: "b"}}
+			if err := s.Record(id, evs); err != nil {
+				t.Fatal(err)
+			}
+			steps, err := DebugHydrate(s, newBasket, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(steps) != 4 || steps[3].Version != 4 || steps[3].Event.Event != (itemRemoved{SKU: "b"}) {
+				t.Fatalf("steps %+v, want one per event", steps)
+			}
+			want := [][]string{
+				{`State.Lines: null -> ["a"]`, `State.Qty: null -> {"a":1}`},
+				{`State.Lines[1]: (none) -> "b"`, `State.Qty.b: (none) -> 2`},
+				{`State.Qty.a: 1 -> 4`},
+				{`State.Lines[1]: "b" -> (none)`, `State.Qty.b: 2 -> (none)`},
+			}
+			if got := changeStrings(steps); !reflect.DeepEqual(got, want) {
+				t.Errorf("changes %q, want %q", got, want)
+			}
+			var state struct{ State basketState }
+			if err := json.Unmarshal(steps[2].State, &state); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(state.State, basketState{Lines: []string{"a", "b"}, Qty: map[string]int{"a": 4, "b": 2}}) {
+				t.Errorf("state after the third event %+v", state.State)
+			}
+		})
+	}
+}
+
+// unserializable can't be serialized
+type unserializable struct {
+	AggregateBase[struct{ C chan int }]
+}
+
+func (unserializable) HandleCommand(Command) ([]Event, error) { return nil, nil }
+
+func TestDebugHydrateErrors(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := DebugHydrate(s, func(uuid.UUID) Aggregate {
+		return &unserializable{NewAggregateBase(func(*struct{ C chan int }, Event) error { return nil })}
+	}, id)
+	if err == nil {
+		t.Error("hydrated state that doesn't serialize")
+	}
+	if steps, err := DebugHydrate(s, newBasket, NewID()); err != nil || len(steps) != 0 {
+		t.Errorf("steps of an empty stream %+v, %v", steps, err)
+	}
+}
@@ -0,0 +1,154 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// events a DeliveryWorker reads at a time, saving its checkpoint after each
+// batch
+const deliveryBatch = 500
+
+// maxDeliveryBackoff caps the wait between retries of a failing publisher
+const maxDeliveryBackoff = time.Minute
+
+// DeliveryWorker delivers the log of a store to a publisher at least once.
+// Unlike a publisher registered with RegisterPublisher, which only hears of
+// events as they are recorded and misses them if it fails or the process
+// stops, a worker tracks its progress in a checkpoint and resumes from it:
+// after a publisher error it retries the failed event until it succeeds,
+// and after a restart it delivers everything recorded since its last saved
+// checkpoint, some events possibly for the second time.
+type DeliveryWorker struct {
+	name        string
+	store       EventStore
+	checkpoints CheckpointStore
+	publisher   RecordedEventPublisher
+	filters     []EventFilter
+	interval    time.Duration
+	backoff     time.Duration
+	logger      Logger
+	wake        chan struct{}
+}
+
+// NewDeliveryWorker returns a worker delivering the events of store passing
+// the filters to publisher, saving its progress in checkpoints under name.
+func NewDeliveryWorker(name string, store EventStore, checkpoints CheckpointStore, publisher RecordedEventPublisher, filters ...EventFilter) *DeliveryWorker {
+	w := &DeliveryWorker{
+		name:        name,
+		store:       store,
+		checkpoints: checkpoints,
+		publisher:   publisher,
+		filters:     filters,
+		interval:    time.Second,
+		backoff:     100 * time.Millisecond,
+		logger:      slog.Default(),
+		wake:        make(chan struct{}, 1),
+	}
+	store.RegisterPublisher(deliveryWaker{w.wake})
+	return w
+}
+
+// deliveryWaker wakes a worker as soon as its store records events, rather
+// than at its next poll
+type deliveryWaker struct {
+	wake chan struct{}
+}
+
+func (d deliveryWaker) Publish(RecordedEvent, bool) error {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetInterval sets how often Run polls the store for events recorded by
+// other processes. The default is one second.
+func (w *DeliveryWorker) SetInterval(d time.Duration) {
+	w.interval = d
+}
+
+// SetRetryBackoff sets the wait before the first retry of a failed
+// delivery. It doubles with each further failure, up to a minute. The
+// default is 100ms.
+func (w *DeliveryWorker) SetRetryBackoff(d time.Duration) {
+	w.backoff = d
+}
+
+func (w *DeliveryWorker) SetLogger(logger Logger) {
+	w.logger = logger
+}
+
+// Deliver publishes every event recorded since the checkpoint, stopping at
+// the first publisher error.
+func (w *DeliveryWorker) Deliver() error {
+	seq, err := w.checkpoints.LoadCheckpoint(w.name)
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
+	}
+
+	for {
+		batch, err := w.store.ReadAll(seq+1, deliveryBatch)
+		if err != nil {
+			return fmt.Errorf("read store: %w", err)
+		}
+		delivered := seq
+		for _, rec := range batch {
+			if MatchesAll(w.filters, rec) {
+				if err = w.publisher.Publish(rec, false); err != nil {
+					err = fmt.Errorf("publish event %d: %w", rec.Sequence, err)
+					break
+				}
+			}
+			delivered = rec.Sequence
+		}
+		if delivered > seq {
+			if err := w.checkpoints.SaveCheckpoint(w.name, delivered); err != nil {
+				return fmt.Errorf("save checkpoint: %w", err)
+			}
+			seq = delivered
+		}
+		if err != nil {
+			return err
+		}
+		if len(batch) < deliveryBatch {
+			return nil
+		}
+	}
+}
+
+// Run delivers events as they are recorded until ctx is done, retrying
+// failed deliveries with backoff.
+func (w *DeliveryWorker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	backoff := w.backoff
+	for {
+		var retry <-chan time.Time
+		if err := w.Deliver(); err != nil {
+			w.logger.Warn("evoke: delivery failed, will retry", "worker", w.name, "error", err, "backoff", backoff)
+			retry = time.After(backoff)
+			backoff = min(backoff*2, maxDeliveryBackoff)
+		} else {
+			backoff = w.backoff
+		}
+
+		if retry != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-retry:
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyPublisher records events, failing the first failures attempts to
+// publish the event at sequence failOn
+type flakyPublisher struct {
+	recordingPublisher
+	mu       sync.Mutex
+	failOn   int64
+	failures int
+}
+
+func (p *flakyPublisher) Publish(rec RecordedEvent, replay bool) error {
+	p.mu.Lock()
+	if rec.Sequence == p.failOn && p.failures > 0 {
+		p.failures--
+		p.mu.Unlock()
+		return errors.New("broker down")
+	}
+	p.mu.Unlock()
+	return p.recordingPublisher.Publish(rec, replay)
+}
+
+func TestDeliver(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemRemoved{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	p := &flakyPublisher{failOn: 3, failures: 1}
+	w := NewDeliveryWorker("outbox", s, s, p, OnlyEvents(itemAdded{}))
+
+	if err := w.Deliver(); err == nil {
+		t.Fatal("Deliver returned no error when the publisher failed")
+	}
+	if seq, err := s.LoadCheckpoint("outbox"); err != nil || seq != 2 {
+		t.Errorf("checkpoint %d, %v, want the event before the failed one", seq, err)
+	}
+	if err := w.Deliver(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(p.published()); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("delivered %v, want each matching event once", got)
+	}
+
+	// a new worker resumes from the saved checkpoint
+	if err := s.Record(id, []Event{itemAdded{SKU: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	next := &recordingPublisher{}
+	if err := NewDeliveryWorker("outbox", s, s, next).Deliver(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(next.published()); !slices.Equal(got, []int64{4}) {
+		t.Errorf("restarted worker delivered %v, want only the new event", got)
+	}
+}
+
+func TestDeliveryWorkerRun(t *testing.T) {
+	s := newTestStore(t)
+	p := &flakyPublisher{failOn: 1, failures: 2}
+	var logger recordingLogger
+	w := NewDeliveryWorker("outbox", s, NewMemoryCheckpointStore(), p)
+	w.SetInterval(time.Hour)
+	w.SetRetryBackoff(time.Millisecond)
+	w.SetLogger(&logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// recording wakes the worker long before its next poll
+	if err := s.Record(NewID(), []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(p.published()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event not delivered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v, want context.Canceled", err)
+	}
+	if got := logger.logged(); len(got) != 2 || got[0] != "warn: evoke: delivery failed, will retry" {
+		t.Errorf("logged %q, want a warning per failed attempt", got)
+	}
+}
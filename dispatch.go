@@ -0,0 +1,125 @@
+package evoke
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// events queued per partition before Handle blocks
+const dispatchQueue = 64
+
+var errDispatcherClosed = errors.New("dispatcher closed")
+
+type dispatchItem struct {
+	rec    RecordedEvent
+	replay bool
+}
+
+// ParallelDispatcher hands events to a handler from several goroutines at
+// once, one per partition, where the partition of an event is a hash of its
+// aggregate ID. Events of the same aggregate are handled one at a time in
+// the order they were dispatched; events of different aggregates may be
+// handled concurrently, so the handler must be safe for concurrent use
+// across aggregates. It mostly speeds up rebuilding read models whose
+// handlers wait on I/O.
+//
+// Once the handler fails, the remaining events are dropped and Handle
+// returns the error, which stops a replay.
+type ParallelDispatcher struct {
+	handler RecordedEventHandlerFunc
+	queues  []chan dispatchItem
+	wg      sync.WaitGroup
+
+	// mu guards closed; Handle holds it shared while queueing
+	mu     sync.RWMutex
+	closed bool
+
+	errMu sync.Mutex
+	err   error
+}
+
+// NewParallelDispatcher starts a dispatcher calling handler from partitions
+// goroutines. Wait must be called to stop them.
+func NewParallelDispatcher(partitions int, handler RecordedEventHandlerFunc) *ParallelDispatcher {
+	d := &ParallelDispatcher{
+		handler: handler,
+		queues:  make([]chan dispatchItem, max(partitions, 1)),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchItem, dispatchQueue)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+func (d *ParallelDispatcher) work(queue <-chan dispatchItem) {
+	defer d.wg.Done()
+	for item := range queue {
+		if d.failed() != nil {
+			continue
+		}
+		if err := d.handler(item.rec, item.replay); err != nil {
+			d.errMu.Lock()
+			if d.err == nil {
+				d.err = fmt.Errorf("handle event %d: %w", item.rec.Sequence, err)
+			}
+			d.errMu.Unlock()
+		}
+	}
+}
+
+func (d *ParallelDispatcher) failed() error {
+	d.errMu.Lock()
+	defer d.errMu.Unlock()
+	return d.err
+}
+
+// Handle queues rec on its aggregate's partition. It has the signature of a
+// RecordedEventHandlerFunc, for passing to ReplayFrom.
+func (d *ParallelDispatcher) Handle(rec RecordedEvent, replay bool) error {
+	if err := d.failed(); err != nil {
+		return err
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return errDispatcherClosed
+	}
+	d.queues[partitionOf(rec.AggregateID, len(d.queues))] <- dispatchItem{rec, replay}
+	return nil
+}
+
+// Publish queues rec like Handle, so a dispatcher can be registered as a
+// store's publisher.
+func (d *ParallelDispatcher) Publish(rec RecordedEvent, replay bool) error {
+	return d.Handle(rec, replay)
+}
+
+// Wait stops accepting events, waits for the queued ones to be handled and
+// returns the first handler error.
+func (d *ParallelDispatcher) Wait() error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, q := range d.queues {
+			close(q)
+		}
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+	return d.failed()
+}
+
+// ReplayParallel replays the events of store passing the filters from seq
+// on, handling them across partitions as a ParallelDispatcher does, and
+// returns once all of them are handled.
+func ReplayParallel(store EventStore, seq int64, partitions int, handler RecordedEventHandlerFunc, filters ...EventFilter) error {
+	d := NewParallelDispatcher(partitions, handler)
+	err := store.ReplayFrom(seq, d.Handle, filters...)
+	if werr := d.Wait(); werr != nil {
+		return werr
+	}
+	return err
+}
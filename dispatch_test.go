@@ -0,0 +1,86 @@
+package evoke
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReplayParallel(t *testing.T) {
+	s := newTestStore(t)
+	ids := make([]uuid.UUID, 5)
+	for i := range ids {
+		ids[i] = NewID()
+	}
+	for round := 0; round < 20; round++ {
+		for _, id := range ids {
+			if err := s.Record(id, []Event{itemAdded{Qty: round}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	versions := make(map[uuid.UUID][]int64)
+	err := ReplayParallel(s, 1, 3, func(rec RecordedEvent, replay bool) error {
+		if !replay {
+			t.Error("event handled as live during a replay")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		versions[rec.AggregateID] = append(versions[rec.AggregateID], rec.Version)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if got := versions[id]; len(got) != 20 || !slices.IsSorted(got) {
+			t.Errorf("handled versions %v of a stream, want all 20 in order", got)
+		}
+	}
+}
+
+func TestParallelDispatcherStopsOnError(t *testing.T) {
+	boom := errors.New("boom")
+	var mu sync.Mutex
+	var handled []int64
+	d := NewParallelDispatcher(1, func(rec RecordedEvent, replay bool) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, rec.Sequence)
+		if rec.Sequence == 2 {
+			return boom
+		}
+		return nil
+	})
+	id := NewID()
+	for seq := int64(1); seq <= 4; seq++ {
+		d.Handle(RecordedEvent{AggregateID: id, Sequence: seq}, true)
+	}
+	if err := d.Wait(); !errors.Is(err, boom) {
+		t.Errorf("Wait returned %v, want the handler's error", err)
+	}
+	if !slices.Equal(handled, []int64{1, 2}) {
+		t.Errorf("handled %v, want the events after the failure dropped", handled)
+	}
+	if err := d.Handle(RecordedEvent{AggregateID: id, Sequence: 5}, true); !errors.Is(err, boom) {
+		t.Errorf("Handle after the failure returned %v, want the handler's error", err)
+	}
+}
+
+func TestParallelDispatcherClosed(t *testing.T) {
+	d := NewParallelDispatcher(2, func(RecordedEvent, bool) error { return nil })
+	if err := d.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Publish(RecordedEvent{AggregateID: NewID()}, false); !errors.Is(err, errDispatcherClosed) {
+		t.Errorf("Publish after Wait returned %v, want errDispatcherClosed", err)
+	}
+	if err := d.Wait(); err != nil {
+		t.Errorf("second Wait returned %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// envelope is the JSON form of a RecordedEvent
+type envelope struct {
+	Sequence      int64           `json:"sequence"`
+	Version       int64           `json:"version"`
+	RecordedAt    int64           `json:"recordedAt"`
+	AggregateID   uuid.UUID       `json:"aggregateId"`
+	AggregateType string          `json:"aggregateType,omitempty"`
+	TenantID      string          `json:"tenantId,omitempty"`
+	EventType     string          `json:"eventType"`
+	SchemaVersion int             `json:"schemaVersion,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	Metadata      Metadata        `json:"metadata,omitempty"`
+}
+
+// MarshalJSON encodes rec as a stable envelope for shipping events over
+// HTTP or queues, with the event's JSON as its payload. Decode it with
+// UnmarshalRecordedEvent to get the event back as its registered type.
+func (rec RecordedEvent) MarshalJSON() ([]byte, error) {
+	payload, err := json.Marshal(rec.Event)
+	if err != nil {
+		return nil, fmt.Errorf("Marshal event %d: %w", rec.Sequence, err)
+	}
+	return json.Marshal(envelope{
+		Sequence:      rec.Sequence,
+		Version:       rec.Version,
+		RecordedAt:    rec.RecordedAt,
+		AggregateID:   rec.AggregateID,
+		AggregateType: rec.AggregateType,
+		TenantID:      rec.TenantID,
+		EventType:     rec.EventType,
+		SchemaVersion: rec.SchemaVersion,
+		Payload:       payload,
+		Metadata:      rec.Metadata,
+	})
+}
+
+// UnmarshalJSON decodes an envelope written by MarshalJSON. Without a
+// registry to decode it with, the event is left as its json.RawMessage
+// payload; use UnmarshalRecordedEvent to decode it.
+func (rec *RecordedEvent) UnmarshalJSON(data []byte) error {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	*rec = env.recordedEvent()
+	rec.Event = env.Payload
+	return nil
+}
+
+// UnmarshalRecordedEvent decodes an envelope written by
+// RecordedEvent.MarshalJSON, decoding the event as the type registered for
+// its event type with events, such as a store.
+func UnmarshalRecordedEvent(data []byte, events EventRegisterer) (RecordedEvent, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return RecordedEvent{}, fmt.Errorf("Unmarshal envelope: %w", err)
+	}
+	rec := env.recordedEvent()
+	e, err := events.UnmarshalEvent(env.EventType, env.Payload)
+	if err != nil {
+		return RecordedEvent{}, fmt.Errorf("UnmarshalEvent: %w", err)
+	}
+	rec.Event = e
+	return rec, nil
+}
+
+func (env envelope) recordedEvent() RecordedEvent {
+	return RecordedEvent{
+		Sequence:      env.Sequence,
+		Version:       env.Version,
+		RecordedAt:    env.RecordedAt,
+		AggregateID:   env.AggregateID,
+		AggregateType: env.AggregateType,
+		TenantID:      env.TenantID,
+		EventType:     env.EventType,
+		SchemaVersion: env.SchemaVersion,
+		Metadata:      env.Metadata,
+	}
+}
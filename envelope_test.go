@@ -0,0 +1,90 @@
+package evoke
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRecordedEventJSON(t *testing.T) {
+	rec := RecordedEvent{
+		Sequence:      7,
+		Version:       2,
+		RecordedAt:    1700000000,
+		AggregateID:   uuid.MustParse("01890a5d-ac96-774b-bcce-b302099a8057"),
+		AggregateType: "cart",
+		TenantID:      "acme",
+		EventType:     "itemAdded",
+		SchemaVersion: 1,
+		Event:         itemAdded{SKU: "a", Qty: 2},
+		Metadata:      Metadata{"user_id": "u1"},
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"sequence":7,"version":2,"recordedAt":1700000000,"aggregateId":"01890a5d-ac96-774b-bcce-b302099a8057","aggregateType":"cart","tenantId":"acme","eventType":"itemAdded","schemaVersion":1,"payload":{"SKU":"a","Qty":2},"metadata":{"user_id":"u1"}}`
+	if string(data) != want {
+		t.Errorf("marshaled\n%s\nwant\n%s", data, want)
+	}
+
+	var er EventRegistry
+	RegisterEvent(&er, &itemAdded{})
+	got, err := UnmarshalRecordedEvent(data, &er)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rec) {
+		t.Errorf("unmarshaled %+v, want %+v", got, rec)
+	}
+
+	// without a registry the payload is kept as it came, and marshals back
+	// the same
+	var raw RecordedEvent
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if payload, ok := raw.Event.(json.RawMessage); !ok || string(payload) != `{"SKU":"a","Qty":2}` {
+		t.Errorf("unmarshaled event %#v, want the raw payload", raw.Event)
+	}
+	if again, err := json.Marshal(raw); err != nil || string(again) != want {
+		t.Errorf("re-marshaled %s, %v", again, err)
+	}
+}
+
+func TestUnmarshalRecordedEventErrors(t *testing.T) {
+	var er EventRegistry
+	RegisterEvent(&er, &itemAdded{})
+	for _, data := range []string{
+		`{`,
+		`{"eventType":"itemRemoved","payload":{}}`,
+		`{"eventType":"itemAdded","payload":{"SKU":5}}`,
+	} {
+		if _, err := UnmarshalRecordedEvent([]byte(data), &er); err == nil {
+			t.Errorf("unmarshaled %s", data)
+		}
+	}
+}
+
+// Events loaded from a store go out and come back as they were.
+func TestRecordedEventJSONFromStore(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.RecordWithMetadata(id, []EventWithMeta{{Event: itemAdded{SKU: "a"}, Metadata: Metadata{"k": "v"}}}); err != nil {
+		t.Fatal(err)
+	}
+	rec := mustLoad(t, s, id)[0]
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnmarshalRecordedEvent(data, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rec) {
+		t.Errorf("round-tripped %+v, want %+v", got, rec)
+	}
+}
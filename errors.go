@@ -0,0 +1,46 @@
+package evoke
+
+import "errors"
+
+var (
+	// ErrStreamNotFound is returned when deleting or restoring a stream
+	// that has no events
+	ErrStreamNotFound = errors.New("stream not found")
+	// ErrStreamDeleted is returned when appending to a soft-deleted stream
+	ErrStreamDeleted = errors.New("stream deleted")
+	// ErrStreamTombstoned is returned when appending to a tombstoned stream
+	ErrStreamTombstoned = errors.New("stream tombstoned")
+	// ErrInvalidEvent is returned when appending an event that fails its
+	// Validate method or the schema registered for its type
+	ErrInvalidEvent = errors.New("invalid event")
+	// ErrEventNotRegistered is returned when decoding an event whose type
+	// was never registered
+	ErrEventNotRegistered = errors.New("event not registered")
+	// ErrConcurrencyConflict is returned when appending to a stream that
+	// has moved on from the version the writer expected
+	ErrConcurrencyConflict = errors.New("concurrency conflict")
+	// ErrNoHandler is returned when sending a command no handler was
+	// registered for
+	ErrNoHandler = errors.New("no handler for command")
+	// ErrDuplicateCommand is returned when appending for an idempotent
+	// command another process has already handled
+	ErrDuplicateCommand = errors.New("command already handled")
+	// ErrNoQueryHandler is returned when asking a query no handler was
+	// registered for
+	ErrNoQueryHandler = errors.New("no handler for query")
+	// ErrCommandNotRegistered is returned when decoding a command whose
+	// type was never registered
+	ErrCommandNotRegistered = errors.New("command not registered")
+	// ErrPayloadTooLarge is returned when appending an event whose payload
+	// is over the store's size limit
+	ErrPayloadTooLarge = errors.New("event payload too large")
+	// ErrIntegrity is returned when the hash chain of the event log doesn't
+	// verify
+	ErrIntegrity = errors.New("event log integrity check failed")
+	// ErrRebuildInProgress is returned when rebuilding a projection that
+	// is already being rebuilt blue/green
+	ErrRebuildInProgress = errors.New("projection rebuild in progress")
+	// ErrSequenceGap is returned when a subscriber is handed an event
+	// after missing some of those before it
+	ErrSequenceGap = errors.New("sequence gap")
+)
@@ -1,6 +1,8 @@
 package evoke
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 
 	"github.com/google/uuid"
@@ -36,17 +38,144 @@ type EventStore interface {
 	Record(aggregateID uuid.UUID, evs []Event) error
 	MustRecord(aggregateID uuid.UUID, evs []Event)
 	LoadStream(aggregateID uuid.UUID) ([]RecordedEvent, error)
-	ReplayFrom(seq int64, handler RecordedEventHandlerFunc) error
-	RegisterPublisher(publisher RecordedEventPublisher)
+	// ReplayFrom calls handler for every event from sequence seq onwards
+	// that passes the filters; see Replay to cancel, follow or throttle a
+	// long replay
+	ReplayFrom(seq int64, handler RecordedEventHandlerFunc, filters ...EventFilter) error
+	// ReadAll returns up to limit events of the global log, starting at
+	// sequence fromSeq. A limit <= 0 returns every event.
+	ReadAll(fromSeq int64, limit int) ([]RecordedEvent, error)
+	// RegisterPublisher publishes newly recorded events passing the filters
+	RegisterPublisher(publisher RecordedEventPublisher, filters ...EventFilter)
+}
+
+// VersionedRecorder is implemented by stores that support optimistic
+// concurrency: RecordAtVersion appends only if the stream is still at
+// expectedVersion, 0 for a new stream, and fails with an error wrapping
+// ErrConcurrencyConflict otherwise.
+type VersionedRecorder interface {
+	RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []Event) error
+}
+
+// StreamEvents are events to append to one stream, as part of an append to
+// several.
+type StreamEvents struct {
+	AggregateID uuid.UUID
+	// AggregateType, if set, categorizes the stream as for RecordAs
+	AggregateType string
+	Events        []Event
+}
+
+// MultiRecorder is implemented by stores that can append to several streams
+// atomically: either every stream gets its events or none does.
+type MultiRecorder interface {
+	RecordMulti(streams []StreamEvents) error
+}
+
+// MetadataRecorder is implemented by stores that can record metadata of
+// each event's own, which is stored with it and delivered to subscribers on
+// the RecordedEvent.
+type MetadataRecorder interface {
+	RecordWithMetadata(aggregateID uuid.UUID, evs []EventWithMeta) error
+}
+
+// anyVersion is the expected version of appends that don't check it
+const anyVersion int64 = -1
+
+// StreamPager is implemented by stores that can read part of a stream,
+// starting at a version and returning at most limit events (all of them if
+// limit <= 0)
+type StreamPager interface {
+	LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error)
+}
+
+// MultiStreamLoader is implemented by stores that can load the streams of
+// several aggregates at once. Each stream is in sequence order; aggregates
+// without events are left out.
+type MultiStreamLoader interface {
+	LoadStreams(ids []uuid.UUID) (map[uuid.UUID][]RecordedEvent, error)
+}
+
+// EventTypeReader is implemented by stores that can read the events of one
+// type from the global log without reading the others, such as every
+// OrderPlaced. Reading starts at sequence fromSeq; at most limit events are
+// returned, all of them if limit <= 0.
+type EventTypeReader interface {
+	ReadByType(eventType string, fromSeq int64, limit int) ([]RecordedEvent, error)
+}
+
+// LoadStreams loads the streams of several aggregates in one call if store
+// can, one by one if not.
+func LoadStreams(store EventStore, ids []uuid.UUID) (map[uuid.UUID][]RecordedEvent, error) {
+	if l, ok := store.(MultiStreamLoader); ok {
+		return l.LoadStreams(ids)
+	}
+	streams := make(map[uuid.UUID][]RecordedEvent, len(ids))
+	for _, id := range ids {
+		recs, err := store.LoadStream(id)
+		if err != nil {
+			return nil, fmt.Errorf("LoadStream(%s): %w", id, err)
+		}
+		if len(recs) > 0 {
+			streams[id] = recs
+		}
+	}
+	return streams, nil
+}
+
+// BackwardReader is implemented by stores that can read newest events
+// first. Reading starts at fromVersion (or fromSeq) and goes back; a start
+// <= 0 means from the end. At most limit events are returned, all of them if
+// limit <= 0.
+type BackwardReader interface {
+	LoadStreamBackward(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error)
+	ReadAllBackward(fromSeq int64, limit int) ([]RecordedEvent, error)
+}
+
+// AggregateRecorder is implemented by stores that record on behalf of an
+// aggregate: its type categorizes the stream, so streams can be read by
+// category, and ctx carries the trace the append belongs to.
+// AggregateHandler records through it when the store supports it.
+type AggregateRecorder interface {
+	RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []Event) error
+}
+
+// HealthChecker is implemented by stores that can tell whether they are
+// able to serve reads and appends, for health and readiness probes.
+type HealthChecker interface {
+	// Healthy returns nil if the store is usable, or why it isn't
+	Healthy(ctx context.Context) error
 }
 
 // Events are whatever you want them to be
 type Event interface{}
 
+// EventHandler handles events published by a bus. The bool is true when the
+// event is replayed, from ReplayFrom or a rebuild, rather than just recorded:
+// handlers with side effects outside the system should do nothing on
+// replays, which SubscribeReaction takes care of.
 type EventHandler interface {
 	Handle(Event, bool) error
 }
 
+// EventHandlerFunc adapts a function taking events of type T to an
+// EventHandler, so closures can be subscribed:
+//
+//	bus.Subscribe(OrderPlaced{}, evoke.EventHandlerFunc[OrderPlaced](func(e OrderPlaced, replay bool) error {
+//		...
+//	}))
+//
+// Use EventHandlerFunc[Event] for handlers of any event.
+type EventHandlerFunc[T Event] func(e T, replay bool) error
+
+func (f EventHandlerFunc[T]) Handle(e Event, replay bool) error {
+	if ev, ok := asType[T](e); ok {
+		return f(ev, replay)
+	}
+	var want T
+	return fmt.Errorf("EventHandlerFunc: handler for %T got %T", want, e)
+}
+
 type RecordedEventHandlerFunc func(rec RecordedEvent, replay bool) error
 
 type RecordedEventPublisher interface {
@@ -59,11 +188,41 @@ type EventBus interface {
 }
 
 type RecordedEvent struct {
-	Sequence    int64
+	Sequence int64
+	// Version is the position of the event within its aggregate's stream,
+	// starting at 1
+	Version     int64
 	RecordedAt  int64
 	AggregateID uuid.UUID
-	Event       Event
-	EventType   string
+	// AggregateType is the category of the stream, when the store knows it
+	AggregateType string
+	// Metadata is stored alongside the event, such as the trace context of
+	// the command that produced it
+	Metadata  Metadata
+	Event     Event
+	EventType string
+	// TenantID is empty unless the event was recorded through a
+	// tenant-scoped store
+	TenantID string
+	// SchemaVersion is the generation of the event's payload when it was
+	// recorded, see Versioned, or 0 if the store doesn't keep it
+	SchemaVersion int
+}
+
+// Versioned is implemented by events whose payload changed shape over time.
+// SchemaVersion returns the generation the type encodes, and is stored with
+// every event so upcasters and analytics can tell generations apart. Events
+// that don't implement it are generation 1.
+type Versioned interface {
+	SchemaVersion() int
+}
+
+// SchemaVersionOf returns the schema version e is recorded with.
+func SchemaVersionOf(e Event) int {
+	if v, ok := e.(Versioned); ok {
+		return v.SchemaVersion()
+	}
+	return 1
 }
 
 type Aggregate interface {
@@ -0,0 +1,272 @@
+package evoke
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Versions count each stream's events from 1, whatever else the log holds.
+func TestStreamVersions(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			a, b := NewID(), NewID()
+			for _, rec := range []struct {
+				id  uuid.UUID
+				evs []Event
+			}{
+				{a, []Event{itemAdded{SKU: "a"}}},
+				{b, []Event{itemAdded{SKU: "b"}, itemAdded{SKU: "c"}}},
+				{a, []Event{itemRemoved{SKU: "a"}, itemAdded{SKU: "d"}}},
+			} {
+				if err := s.Record(rec.id, rec.evs); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for id, want := range map[uuid.UUID][]int64{a: {1, 2, 3}, b: {1, 2}} {
+				var got []int64
+				for _, rec := range mustLoad(t, s, id) {
+					got = append(got, rec.Version)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("stream versions %v, want %v", got, want)
+				}
+			}
+			log, err := s.ReadAll(1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sequences(log); !slices.Equal(got, []int64{1, 2, 3, 4, 5}) {
+				t.Errorf("log sequences %v", got)
+			}
+		})
+	}
+}
+
+// recordInterleaved records three events to a and two to b, alternating
+// between the streams, so a holds sequences 1, 3, 4 and b 2, 5
+func recordInterleaved(t *testing.T, s EventStore) (a, b uuid.UUID) {
+	t.Helper()
+	a, b = NewID(), NewID()
+	for _, id := range []uuid.UUID{a, b, a, a, b} {
+		if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b
+}
+
+func TestLoadStreamFrom(t *testing.T) {
+	tests := []struct {
+		name        string
+		fromVersion int64
+		limit       int
+		want        []int64
+	}{
+		{"whole stream", 1, 0, []int64{1, 3, 4}},
+		{"from a version", 2, 0, []int64{3, 4}},
+		{"page", 1, 2, []int64{1, 3}},
+		{"next page", 3, 2, []int64{4}},
+		{"past the end", 4, 0, []int64{}},
+		{"before the start", 0, -1, []int64{1, 3, 4}},
+	}
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			a, _ := recordInterleaved(t, s)
+			for _, tt := range tests {
+				recs, err := s.(StreamPager).LoadStreamFrom(a, tt.fromVersion, tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sequences(recs); !slices.Equal(got, tt.want) {
+					t.Errorf("%s: loaded %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadStreams(t *testing.T) {
+	stores := eventStores(t)
+	// a store without LoadStreams of its own is loaded stream by stream
+	stores["fallback"] = eventsOnly{newTestStore(t)}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			a, b := recordInterleaved(t, s)
+			empty := NewID()
+			streams, err := LoadStreams(s, []uuid.UUID{b, empty, a, b})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(streams) != 2 {
+				t.Errorf("loaded %d streams, want a and b without the empty one", len(streams))
+			}
+			if got := sequences(streams[a]); !slices.Equal(got, []int64{1, 3, 4}) {
+				t.Errorf("a loaded %v", got)
+			}
+			if got := sequences(streams[b]); !slices.Equal(got, []int64{2, 5}) {
+				t.Errorf("b loaded %v, want it once though asked for twice", got)
+			}
+		})
+	}
+}
+
+// Streams beyond a query's worth are loaded in batches, deleted ones left
+// out.
+func TestLoadStreamsBatches(t *testing.T) {
+	s := newTestStore(t)
+	ids := make([]uuid.UUID, loadStreamsBatchSize+10)
+	for i := range ids {
+		ids[i] = NewID()
+		if err := s.Record(ids[i], []Event{itemAdded{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleted := ids[len(ids)-1]
+	if err := s.DeleteStream(deleted); err != nil {
+		t.Fatal(err)
+	}
+	streams, err := s.LoadStreams(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != len(ids)-1 {
+		t.Errorf("loaded %d streams, want %d", len(streams), len(ids)-1)
+	}
+	if _, ok := streams[deleted]; ok {
+		t.Error("loaded a deleted stream")
+	}
+	if recs := streams[ids[len(ids)-2]]; len(recs) != 1 || recs[0].Sequence != int64(len(ids)-1) {
+		t.Errorf("last stream of the second batch loaded %+v", recs)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		fromSeq int64
+		limit   int
+		want    []int64
+	}{
+		{"whole log", 1, 0, []int64{1, 2, 3, 4, 5}},
+		{"from a sequence", 3, 0, []int64{3, 4, 5}},
+		{"page", 1, 2, []int64{1, 2}},
+		{"next page", 3, 2, []int64{3, 4}},
+		{"last page", 5, 2, []int64{5}},
+		{"past the end", 6, 0, []int64{}},
+	}
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			recordInterleaved(t, s)
+			for _, tt := range tests {
+				recs, err := s.ReadAll(tt.fromSeq, tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sequences(recs); !slices.Equal(got, tt.want) {
+					t.Errorf("%s: read %v, want %v", tt.name, got, tt.want)
+				}
+				for _, rec := range recs {
+					if rec.Event != (itemAdded{SKU: "a"}) {
+						t.Errorf("%s: event %d read as %#v", tt.name, rec.Sequence, rec.Event)
+					}
+				}
+			}
+
+			if d, ok := s.(interface {
+				DebugEvents() ([]RecordedEvent, error)
+			}); ok {
+				recs, err := d.DebugEvents()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(recs, mustReadAll(t, s)) {
+					t.Errorf("DebugEvents returned %v, want the whole log as ReadAll reads it", recs)
+				}
+			}
+		})
+	}
+}
+
+func TestBackwardReads(t *testing.T) {
+	streamTests := []struct {
+		name        string
+		fromVersion int64
+		limit       int
+		want        []int64
+	}{
+		{"whole stream", 0, 0, []int64{4, 3, 1}},
+		{"from a version", 2, 0, []int64{3, 1}},
+		{"newest page", 0, 2, []int64{4, 3}},
+		{"next page", 1, 2, []int64{1}},
+		{"past the end", 9, 0, []int64{4, 3, 1}},
+	}
+	logTests := []struct {
+		name    string
+		fromSeq int64
+		limit   int
+		want    []int64
+	}{
+		{"whole log", 0, 0, []int64{5, 4, 3, 2, 1}},
+		{"from a sequence", 3, 0, []int64{3, 2, 1}},
+		{"newest page", 0, 2, []int64{5, 4}},
+		{"next page", 3, 2, []int64{3, 2}},
+	}
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			a, _ := recordInterleaved(t, s)
+			r := s.(BackwardReader)
+			for _, tt := range streamTests {
+				recs, err := r.LoadStreamBackward(a, tt.fromVersion, tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sequences(recs); !slices.Equal(got, tt.want) {
+					t.Errorf("stream %s: loaded %v, want %v", tt.name, got, tt.want)
+				}
+			}
+			for _, tt := range logTests {
+				recs, err := r.ReadAllBackward(tt.fromSeq, tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sequences(recs); !slices.Equal(got, tt.want) {
+					t.Errorf("log %s: read %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEventHandlerFunc(t *testing.T) {
+	bus := NewEventBus()
+	var got []string
+	bus.Subscribe(itemAdded{}, EventHandlerFunc[itemAdded](func(e itemAdded, replay bool) error {
+		if replay {
+			t.Error("handled a live event as a replay")
+		}
+		got = append(got, e.SKU)
+		return nil
+	}))
+	var all int
+	bus.Subscribe(itemRemoved{}, EventHandlerFunc[Event](func(Event, bool) error {
+		all++
+		return nil
+	}))
+	for _, e := range []Event{itemAdded{SKU: "a"}, &itemAdded{SKU: "b"}, itemRemoved{}} {
+		if err := bus.Publish(RecordedEvent{Event: e}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(got, []string{"a", "b"}) || all != 1 {
+		t.Errorf("handled %q and %d of any type", got, all)
+	}
+
+	h := EventHandlerFunc[itemAdded](func(itemAdded, bool) error { return nil })
+	if err := h.Handle(itemRemoved{}, false); err == nil {
+		t.Error("Handle of another type returned no error")
+	}
+}
@@ -0,0 +1,336 @@
+// Package evokeadmin serves a small web UI for operating an evoke store:
+// browsing events, inspecting streams, watching projections and rebuilding
+// them, and inspecting dead letters. Mount it under the application's mux:
+//
+//	admin := evokeadmin.Handler(store,
+//		evokeadmin.WithProjections(projections),
+//		evokeadmin.WithDeadLetters(deadLetters))
+//	mux.Handle("/admin/", http.StripPrefix("/admin", admin))
+//
+// The UI changes what it is given, so mount it behind the application's
+// authentication.
+package evokeadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+// defaultLimit is how many events a page lists unless asked for more
+const defaultLimit = 100
+
+// RawScanner is implemented by stores that can list events without
+// decoding them, such as the file store, which the UI then filters in the
+// database.
+type RawScanner interface {
+	ScanRaw(q evoke.RawQuery, fn func(evoke.RawEvent) error) error
+}
+
+// DeadLetterLister is implemented by dead letter queues that can list the
+// events they keep, such as the memory one.
+type DeadLetterLister interface {
+	Entries() []evoke.DeadLetterEntry
+}
+
+type handler struct {
+	store       evoke.EventStore
+	projections *evoke.ProjectionManager
+	deadLetters DeadLetterLister
+	mux         *http.ServeMux
+}
+
+type Option func(*handler)
+
+// WithProjections shows the projections of m, with their checkpoints, and
+// lets them be rebuilt.
+func WithProjections(m *evoke.ProjectionManager) Option {
+	return func(h *handler) {
+		h.projections = m
+	}
+}
+
+// WithDeadLetters shows the events kept by q.
+func WithDeadLetters(q DeadLetterLister) Option {
+	return func(h *handler) {
+		h.deadLetters = q
+	}
+}
+
+// Handler returns the admin UI of store.
+func Handler(store evoke.EventStore, opts ...Option) http.Handler {
+	h := &handler{store: store, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("GET /{$}", h.events)
+	h.mux.HandleFunc("GET /streams/{id}", h.stream)
+	h.mux.HandleFunc("GET /projections", h.listProjections)
+	h.mux.HandleFunc("POST /projections/{name}/rebuild", h.rebuild)
+	h.mux.HandleFunc("GET /deadletters", h.listDeadLetters)
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// eventRow is an event as the UI lists it
+type eventRow struct {
+	Sequence      int64
+	RecordedAt    int64
+	AggregateID   uuid.UUID
+	AggregateType string
+	Version       int64
+	EventType     string
+	Payload       string
+}
+
+func rowOf(rec evoke.RecordedEvent) eventRow {
+	payload, err := json.Marshal(rec.Event)
+	if err != nil {
+		payload = []byte(err.Error())
+	}
+	return eventRow{
+		Sequence:      rec.Sequence,
+		RecordedAt:    rec.RecordedAt,
+		AggregateID:   rec.AggregateID,
+		AggregateType: rec.AggregateType,
+		Version:       rec.Version,
+		EventType:     eventType(rec),
+		Payload:       string(payload),
+	}
+}
+
+func eventType(rec evoke.RecordedEvent) string {
+	if rec.EventType != "" {
+		return rec.EventType
+	}
+	return evoke.TypeName(rec.Event)
+}
+
+// eventQuery is the filter form of the events page
+type eventQuery struct {
+	From          int64
+	AggregateID   string
+	AggregateType string
+	EventType     string
+	Limit         int
+}
+
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	q := eventQuery{
+		AggregateID:   r.FormValue("aggregate"),
+		AggregateType: r.FormValue("aggregateType"),
+		EventType:     r.FormValue("type"),
+		Limit:         defaultLimit,
+	}
+	if v := r.FormValue("from"); v != "" {
+		from, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "bad from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.From = from
+	}
+	if v := r.FormValue("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "bad limit: "+v, http.StatusBadRequest)
+			return
+		}
+		q.Limit = limit
+	}
+	var aggID uuid.UUID
+	if q.AggregateID != "" {
+		id, err := uuid.Parse(q.AggregateID)
+		if err != nil {
+			http.Error(w, "bad aggregate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		aggID = id
+	}
+
+	rows, err := h.findEvents(q, aggID)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	var next int64
+	if len(rows) == q.Limit {
+		next = rows[len(rows)-1].Sequence + 1
+	}
+	h.render(w, r, eventsPage, map[string]any{"Query": q, "Events": rows, "Next": next})
+}
+
+// findEvents returns up to q.Limit events matching q, in the database if
+// the store is a RawScanner and by paging through the log otherwise
+func (h *handler) findEvents(q eventQuery, aggID uuid.UUID) ([]eventRow, error) {
+	rows := make([]eventRow, 0)
+	if rs, ok := h.store.(RawScanner); ok {
+		err := rs.ScanRaw(evoke.RawQuery{
+			FromSequence:  q.From,
+			AggregateID:   aggID,
+			AggregateType: q.AggregateType,
+			EventType:     q.EventType,
+			Limit:         q.Limit,
+		}, func(raw evoke.RawEvent) error {
+			rows = append(rows, eventRow{
+				Sequence:      raw.Sequence,
+				RecordedAt:    raw.RecordedAt,
+				AggregateID:   raw.AggregateID,
+				AggregateType: raw.AggregateType,
+				Version:       raw.Version,
+				EventType:     raw.EventType,
+				Payload:       string(raw.Data),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ScanRaw: %w", err)
+		}
+		return rows, nil
+	}
+
+	from := q.From
+	for len(rows) < q.Limit {
+		recs, err := h.store.ReadAll(from, defaultLimit)
+		if err != nil {
+			return nil, fmt.Errorf("ReadAll: %w", err)
+		}
+		for _, rec := range recs {
+			if len(rows) == q.Limit {
+				break
+			}
+			switch {
+			case aggID != uuid.Nil && rec.AggregateID != aggID:
+			case q.AggregateType != "" && rec.AggregateType != q.AggregateType:
+			case q.EventType != "" && eventType(rec) != q.EventType:
+			default:
+				rows = append(rows, rowOf(rec))
+			}
+		}
+		if len(recs) < defaultLimit {
+			break
+		}
+		from = recs[len(recs)-1].Sequence + 1
+	}
+	return rows, nil
+}
+
+func (h *handler) stream(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "bad stream id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	info, err := evoke.GetStreamInfo(h.store, id)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	recs, err := h.store.LoadStream(id)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	rows := make([]eventRow, len(recs))
+	for i, rec := range recs {
+		rows[i] = rowOf(rec)
+	}
+	h.render(w, r, streamPage, map[string]any{"Info": info, "Events": rows})
+}
+
+// projectionRow is a projection as the UI lists it
+type projectionRow struct {
+	Name       string
+	Checkpoint int64
+	Err        error
+}
+
+func (h *handler) listProjections(w http.ResponseWriter, r *http.Request) {
+	if h.projections == nil {
+		http.NotFound(w, r)
+		return
+	}
+	names := h.projections.Names()
+	rows := make([]projectionRow, len(names))
+	for i, name := range names {
+		rows[i].Name = name
+		rows[i].Checkpoint, rows[i].Err = h.projections.Checkpoint(name)
+	}
+	h.render(w, r, projectionsPage, map[string]any{
+		"Projections": rows,
+		"Rebuilt":     r.FormValue("rebuilt"),
+	})
+}
+
+func (h *handler) rebuild(w http.ResponseWriter, r *http.Request) {
+	if h.projections == nil {
+		http.NotFound(w, r)
+		return
+	}
+	name := r.PathValue("name")
+	if err := h.projections.Rebuild(name); err != nil {
+		serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, base(r)+"/projections?rebuilt="+url.QueryEscape(name), http.StatusSeeOther)
+}
+
+func (h *handler) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if h.deadLetters == nil {
+		http.NotFound(w, r)
+		return
+	}
+	entries := h.deadLetters.Entries()
+	// newest first, as the ones to look at usually are
+	rows := make([]map[string]any, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		rows = append(rows, map[string]any{
+			"Event":    rowOf(entries[i].Event),
+			"Err":      entries[i].Err,
+			"FailedAt": entries[i].FailedAt,
+		})
+	}
+	h.render(w, r, deadLettersPage, map[string]any{"Entries": rows})
+}
+
+func (h *handler) render(w http.ResponseWriter, r *http.Request, page *template.Template, data map[string]any) {
+	data["Base"] = base(r)
+	data["HasProjections"] = h.projections != nil
+	data["HasDeadLetters"] = h.deadLetters != nil
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, data); err != nil {
+		serverError(w, err)
+	}
+}
+
+// base returns the path the handler is mounted under, which
+// http.StripPrefix removed from the request's path
+func base(r *http.Request) string {
+	path := r.URL.EscapedPath()
+	requested := strings.SplitN(r.RequestURI, "?", 2)[0]
+	return strings.TrimSuffix(requested, path)
+}
+
+func serverError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+var funcs = template.FuncMap{
+	"time": func(unix int64) string {
+		if unix == 0 {
+			return ""
+		}
+		return time.Unix(unix, 0).UTC().Format(time.DateTime)
+	},
+}
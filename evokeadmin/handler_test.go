@@ -0,0 +1,203 @@
+package evokeadmin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+type itemAdded struct{ SKU string }
+
+type itemRemoved struct{ SKU string }
+
+// resettingProjection counts the events it handles and its resets
+type resettingProjection struct{ handled, resets int }
+
+func (p *resettingProjection) Handle(evoke.RecordedEvent, bool) error {
+	p.handled++
+	return nil
+}
+
+func (p *resettingProjection) Reset() error {
+	p.resets++
+	p.handled = 0
+	return nil
+}
+
+func newFileStore(t *testing.T) evoke.EventStore {
+	t.Helper()
+	s, err := evoke.NewFileStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	evoke.RegisterEvent(s, &itemAdded{})
+	evoke.RegisterEvent(s, &itemRemoved{})
+	return s
+}
+
+// get serves a GET of target from h mounted under /admin
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	http.StripPrefix("/admin", h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin"+target, nil))
+	return w
+}
+
+func TestEvents(t *testing.T) {
+	stores := map[string]func(*testing.T) evoke.EventStore{
+		// the file store is filtered by ScanRaw, the simple one by paging
+		"file":   newFileStore,
+		"simple": func(*testing.T) evoke.EventStore { return evoke.NewSimpleStore(nil) },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			a, b := uuid.New(), uuid.New()
+			if err := s.Record(a, []evoke.Event{itemAdded{SKU: "sku-1"}, itemRemoved{SKU: "sku-2"}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Record(b, []evoke.Event{itemAdded{SKU: "sku-3"}}); err != nil {
+				t.Fatal(err)
+			}
+			h := Handler(s)
+
+			tests := []struct {
+				target string
+				want   []string
+			}{
+				{"/", []string{"sku-1", "sku-2", "sku-3"}},
+				{"/?type=itemRemoved", []string{"sku-2"}},
+				{"/?aggregate=" + b.String(), []string{"sku-3"}},
+				{"/?from=2&limit=1", []string{"sku-2"}},
+			}
+			for _, tt := range tests {
+				w := get(t, h, tt.target)
+				if w.Code != http.StatusOK {
+					t.Fatalf("GET %s: %d %s", tt.target, w.Code, w.Body)
+				}
+				body := w.Body.String()
+				for _, sku := range []string{"sku-1", "sku-2", "sku-3"} {
+					want := strings.Contains(strings.Join(tt.want, " "), sku)
+					if strings.Contains(body, sku) != want {
+						t.Errorf("GET %s: listing %s is %v, want %v", tt.target, sku, !want, want)
+					}
+				}
+			}
+
+			body := get(t, h, "/?limit=1").Body.String()
+			if !strings.Contains(body, `href="/admin/?from=2&aggregate=`) {
+				t.Errorf("a full page links to %q, want the next under the mount path", body)
+			}
+			if !strings.Contains(body, `href="/admin/streams/`+a.String()+`"`) {
+				t.Error("listed event doesn't link to its stream")
+			}
+			if strings.Contains(get(t, h, "/").Body.String(), "Next page") {
+				t.Error("the last page links to a next one")
+			}
+		})
+	}
+}
+
+func TestEventsBadQuery(t *testing.T) {
+	h := Handler(newFileStore(t))
+	for _, target := range []string{"/?from=x", "/?limit=0", "/?aggregate=x", "/streams/x"} {
+		if w := get(t, h, target); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: %d, want 400", target, w.Code)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := newFileStore(t)
+	id := uuid.New()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "sku-1"}, itemRemoved{SKU: "sku-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(uuid.New(), []evoke.Event{itemAdded{SKU: "sku-other"}}); err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(s)
+
+	w := get(t, h, "/streams/"+id.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "version 2") || !strings.Contains(body, "sku-1") || strings.Contains(body, "sku-other") {
+		t.Errorf("stream page %s", body)
+	}
+	if body := get(t, h, "/streams/"+uuid.New().String()).Body.String(); !strings.Contains(body, "No such stream") {
+		t.Errorf("page of a missing stream %s", body)
+	}
+}
+
+func TestProjections(t *testing.T) {
+	s := newFileStore(t)
+	if err := s.Record(uuid.New(), []evoke.Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	m := evoke.NewProjectionManager(s, evoke.NewMemoryCheckpointStore())
+	p := &resettingProjection{}
+	m.Register("items", p)
+	if err := m.CatchUp("items"); err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(s, WithProjections(m))
+
+	body := get(t, h, "/projections").Body.String()
+	if !strings.Contains(body, "<td>items</td>\n<td>2</td>") {
+		t.Errorf("projections page %s, want items at checkpoint 2", body)
+	}
+	if !strings.Contains(get(t, h, "/").Body.String(), `href="/admin/projections"`) {
+		t.Error("events page doesn't link to the projections")
+	}
+
+	w := httptest.NewRecorder()
+	http.StripPrefix("/admin", h).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/projections/items/rebuild", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/projections?rebuilt=items" {
+		t.Fatalf("rebuild returned %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if p.resets != 1 || p.handled != 2 {
+		t.Errorf("rebuild reset %d times and handled %d events", p.resets, p.handled)
+	}
+	if body := get(t, h, "/projections?rebuilt=items").Body.String(); !strings.Contains(body, "Rebuilt items.") {
+		t.Errorf("projections page after the rebuild %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/projections/missing/rebuild", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("rebuild of a missing projection returned %d", w.Code)
+	}
+	if w := get(t, Handler(s), "/projections"); w.Code != http.StatusNotFound {
+		t.Errorf("projections page without projections returned %d", w.Code)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	s := newFileStore(t)
+	q := evoke.NewMemoryDeadLetterQueue()
+	q.DeadLetter(evoke.RecordedEvent{Sequence: 1, Event: itemAdded{SKU: "sku-1"}}, errors.New("first failure"))
+	q.DeadLetter(evoke.RecordedEvent{Sequence: 2, Event: itemAdded{SKU: "sku-2"}}, errors.New("second failure"))
+	h := Handler(s, WithDeadLetters(q))
+
+	body := get(t, h, "/deadletters").Body.String()
+	first, second := strings.Index(body, "first failure"), strings.Index(body, "second failure")
+	if first < 0 || second < 0 || second > first {
+		t.Errorf("dead letters page %s, want both failures, newest first", body)
+	}
+	if !strings.Contains(body, "itemAdded") || !strings.Contains(body, "sku-2") {
+		t.Errorf("dead letters page %s, want the events' types and payloads", body)
+	}
+	if w := get(t, Handler(s), "/deadletters"); w.Code != http.StatusNotFound {
+		t.Errorf("dead letters page without a queue returned %d", w.Code)
+	}
+}
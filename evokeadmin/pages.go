@@ -0,0 +1,118 @@
+package evokeadmin
+
+import "html/template"
+
+// layout is shared by every page, which defines its content
+var layout = template.Must(template.New("layout").Funcs(funcs).Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>evoke admin</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 1.5em; }
+nav a { margin-right: 1em; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { border-bottom: 1px solid #ddd; padding: .3em .6em; text-align: left; vertical-align: top; }
+td.payload { font-family: monospace; white-space: pre-wrap; word-break: break-all; max-width: 50em; }
+.error { color: #b00; }
+form.filters input { width: 12em; }
+</style>
+</head>
+<body>
+<nav>
+<a href="{{.Base}}/">Events</a>
+{{- if .HasProjections}}<a href="{{.Base}}/projections">Projections</a>{{end}}
+{{- if .HasDeadLetters}}<a href="{{.Base}}/deadletters">Dead letters</a>{{end}}
+</nav>
+{{template "content" .}}
+</body>
+</html>
+{{define "events"}}
+<table>
+<tr><th>Sequence</th><th>Recorded</th><th>Stream</th><th>Version</th><th>Type</th><th>Payload</th></tr>
+{{- range .Events}}
+<tr>
+<td>{{.Sequence}}</td>
+<td>{{time .RecordedAt}}</td>
+<td><a href="{{$.Base}}/streams/{{.AggregateID}}">{{.AggregateID}}</a>{{with .AggregateType}}<br>{{.}}{{end}}</td>
+<td>{{.Version}}</td>
+<td>{{.EventType}}</td>
+<td class="payload">{{.Payload}}</td>
+</tr>
+{{- else}}
+<tr><td colspan="6">No events</td></tr>
+{{- end}}
+</table>
+{{end}}
+`))
+
+func page(content string) *template.Template {
+	return template.Must(template.Must(layout.Clone()).Parse(`{{define "content"}}` + content + `{{end}}`))
+}
+
+// eventsTable lists the Events of a page
+const eventsTable = `{{template "events" .}}`
+
+var eventsPage = page(`
+<h1>Events</h1>
+<form class="filters" method="get" action="{{.Base}}/">
+<input name="from" placeholder="from sequence" value="{{with .Query.From}}{{.}}{{end}}">
+<input name="aggregate" placeholder="stream id" value="{{.Query.AggregateID}}">
+<input name="aggregateType" placeholder="aggregate type" value="{{.Query.AggregateType}}">
+<input name="type" placeholder="event type" value="{{.Query.EventType}}">
+<input name="limit" placeholder="limit" value="{{.Query.Limit}}">
+<button>Filter</button>
+</form>
+` + eventsTable + `
+{{with .Next}}<p><a href="{{$.Base}}/?from={{.}}&aggregate={{$.Query.AggregateID}}&aggregateType={{$.Query.AggregateType}}&type={{$.Query.EventType}}&limit={{$.Query.Limit}}">Next page</a></p>{{end}}
+`)
+
+var streamPage = page(`
+<h1>Stream {{.Info.AggregateID}}</h1>
+{{if .Info.Exists}}
+<p>
+{{with .Info.AggregateType}}Type {{.}}, {{end}}version {{.Info.Version}},
+recorded {{time .Info.FirstRecordedAt}} to {{time .Info.LastRecordedAt}}
+{{- if .Info.Deleted}}, <span class="error">deleted</span>{{end}}
+{{- if .Info.Tombstoned}}, <span class="error">tombstoned</span>{{end}}
+</p>
+{{else}}
+<p>No such stream.</p>
+{{end}}
+` + eventsTable)
+
+var projectionsPage = page(`
+<h1>Projections</h1>
+{{with .Rebuilt}}<p>Rebuilt {{.}}.</p>{{end}}
+<table>
+<tr><th>Name</th><th>Checkpoint</th><th></th></tr>
+{{- range .Projections}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{if .Err}}<span class="error">{{.Err}}</span>{{else}}{{.Checkpoint}}{{end}}</td>
+<td><form method="post" action="{{$.Base}}/projections/{{.Name}}/rebuild"><button>Rebuild</button></form></td>
+</tr>
+{{- else}}
+<tr><td colspan="3">No projections</td></tr>
+{{- end}}
+</table>
+`)
+
+var deadLettersPage = page(`
+<h1>Dead letters</h1>
+<table>
+<tr><th>Failed</th><th>Sequence</th><th>Stream</th><th>Type</th><th>Error</th><th>Payload</th></tr>
+{{- range .Entries}}
+<tr>
+<td>{{.FailedAt.UTC.Format "2006-01-02 15:04:05"}}</td>
+<td>{{.Event.Sequence}}</td>
+<td><a href="{{$.Base}}/streams/{{.Event.AggregateID}}">{{.Event.AggregateID}}</a></td>
+<td>{{.Event.EventType}}</td>
+<td class="error">{{.Err}}</td>
+<td class="payload">{{.Event.Payload}}</td>
+</tr>
+{{- else}}
+<tr><td colspan="6">No dead letters</td></tr>
+{{- end}}
+</table>
+`)
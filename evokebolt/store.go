@@ -0,0 +1,356 @@
+// Package evokebolt is an evoke.EventStore kept in a bbolt file, for
+// applications that want an embedded pure Go store without SQLite.
+//
+// Events live in one bucket keyed by their global sequence. Each stream has
+// a bucket of its own mapping stream versions to sequences, so stream reads
+// touch only the stream's events.
+package evokebolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	eventsBucket  = []byte("events")
+	streamsBucket = []byte("streams")
+	// typesBucket maps aggregate IDs to the aggregate type of their stream
+	typesBucket = []byte("types")
+)
+
+// ErrConflict is returned by RecordAtVersion when the stream is no longer at
+// the expected version. It wraps evoke.ErrConcurrencyConflict, so
+// evoke.AggregateHandler retries the command.
+var ErrConflict = fmt.Errorf("evokebolt: concurrent append to stream: %w", evoke.ErrConcurrencyConflict)
+
+type Store struct {
+	evoke.EventRegistry
+	db         *bolt.DB
+	mu         sync.Mutex
+	publishers []evoke.RecordedEventPublisher
+}
+
+var _ evoke.EventStore = (*Store)(nil)
+var _ evoke.StreamPager = (*Store)(nil)
+var _ evoke.AggregateRecorder = (*Store)(nil)
+var _ evoke.VersionedRecorder = (*Store)(nil)
+var _ evoke.HealthChecker = (*Store)(nil)
+
+// Open opens or creates the store kept in file.
+func Open(file string) (*Store, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{eventsBucket, streamsBucket, typesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Healthy checks the store's buckets can be read.
+func (s *Store) Healthy(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{eventsBucket, streamsBucket, typesBucket} {
+			if tx.Bucket(name) == nil {
+				return fmt.Errorf("missing bucket %s", name)
+			}
+		}
+		return nil
+	})
+}
+
+// record is how an event is kept in the events bucket
+type record struct {
+	Sequence      int64           `json:"sequence"`
+	Version       int64           `json:"version"`
+	RecordedAt    int64           `json:"recordedAt"`
+	AggregateID   uuid.UUID       `json:"aggregateId"`
+	AggregateType string          `json:"aggregateType,omitempty"`
+	EventType     string          `json:"eventType"`
+	Data          json.RawMessage `json:"data"`
+	Metadata      evoke.Metadata  `json:"metadata,omitempty"`
+}
+
+func (s *Store) decode(value []byte) (evoke.RecordedEvent, error) {
+	var r record
+	if err := json.Unmarshal(value, &r); err != nil {
+		return evoke.RecordedEvent{}, fmt.Errorf("Unmarshal record: %w", err)
+	}
+	event, err := s.UnmarshalEvent(r.EventType, r.Data)
+	if err != nil {
+		return evoke.RecordedEvent{}, fmt.Errorf("UnmarshalEvent: %w", err)
+	}
+	return evoke.RecordedEvent{
+		Sequence:      r.Sequence,
+		Version:       r.Version,
+		RecordedAt:    r.RecordedAt,
+		AggregateID:   r.AggregateID,
+		AggregateType: r.AggregateType,
+		Event:         event,
+		EventType:     r.EventType,
+		Metadata:      r.Metadata,
+	}, nil
+}
+
+func itob(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+func btoi(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// underlying returns the value e points to, if it is a non-nil pointer, so
+// recorded events hold values as the events read back do rather than the
+// caller's pointers
+func underlying(e evoke.Event) evoke.Event {
+	v := reflect.ValueOf(e)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return v.Elem().Interface().(evoke.Event)
+	}
+	return e
+}
+
+func (s *Store) RegisterPublisher(publisher evoke.RecordedEventPublisher, filters ...evoke.EventFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishers = append(s.publishers, evoke.FilterPublisher(publisher, filters...))
+}
+
+func (s *Store) Record(aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.RecordAs(context.Background(), "", aggregateID, evs)
+}
+
+// RecordAs records events to the stream of an aggregate of the given type.
+// An empty type keeps the type the stream already has.
+func (s *Store) RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, anyVersion, evs)
+}
+
+// RecordAtVersion records events like RecordAs, provided the stream is at
+// expectedVersion. The version is checked in the append's transaction,
+// which bbolt runs one at a time.
+func (s *Store) RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, expectedVersion, evs)
+}
+
+// anyVersion is the expected version of appends that don't check it
+const anyVersion = -1
+
+func (s *Store) record(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expected int64, evs []evoke.Event) error {
+	if len(evs) == 0 {
+		return errors.New("no events to append")
+	}
+
+	recs := make([]evoke.RecordedEvent, 0, len(evs))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		events := tx.Bucket(eventsBucket)
+		stream, err := tx.Bucket(streamsBucket).CreateBucketIfNotExists(aggregateID[:])
+		if err != nil {
+			return fmt.Errorf("create stream bucket: %w", err)
+		}
+
+		var version int64
+		if k, _ := stream.Cursor().Last(); k != nil {
+			version = btoi(k)
+		}
+		if expected != anyVersion && version != expected {
+			return fmt.Errorf("%w: stream %s is at version %d, not %d", ErrConflict, aggregateID, version, expected)
+		}
+		types := tx.Bucket(typesBucket)
+		if aggregateType == "" {
+			aggregateType = string(types.Get(aggregateID[:]))
+		} else if err := types.Put(aggregateID[:], []byte(aggregateType)); err != nil {
+			return err
+		}
+
+		recordedAt := time.Now().Unix()
+		for _, e := range evs {
+			e = underlying(e)
+			data, err := s.MarshalEvent(e)
+			if err != nil {
+				return fmt.Errorf("Marshal: %w", err)
+			}
+			if err := s.ValidateEvent(e, data); err != nil {
+				return err
+			}
+			seq, err := events.NextSequence()
+			if err != nil {
+				return err
+			}
+			version++
+			r := record{
+				Sequence:      int64(seq),
+				Version:       version,
+				RecordedAt:    recordedAt,
+				AggregateID:   aggregateID,
+				AggregateType: aggregateType,
+				EventType:     s.EventName(e),
+				Data:          data,
+			}
+			value, err := json.Marshal(r)
+			if err != nil {
+				return fmt.Errorf("Marshal record: %w", err)
+			}
+			if err := events.Put(itob(r.Sequence), value); err != nil {
+				return err
+			}
+			if err := stream.Put(itob(version), itob(r.Sequence)); err != nil {
+				return err
+			}
+			recs = append(recs, evoke.RecordedEvent{
+				Sequence:      r.Sequence,
+				Version:       r.Version,
+				RecordedAt:    r.RecordedAt,
+				AggregateID:   aggregateID,
+				AggregateType: aggregateType,
+				Event:         e,
+				EventType:     r.EventType,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("append events: %w", err)
+	}
+
+	evoke.NoteRecorded(ctx, recs)
+
+	s.mu.Lock()
+	publishers := s.publishers
+	s.mu.Unlock()
+	for _, rec := range recs {
+		for _, p := range publishers {
+			if err := p.Publish(rec, false); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) MustRecord(aggregateID uuid.UUID, evs []evoke.Event) {
+	err := s.Record(aggregateID, evs)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *Store) LoadStream(aggregateID uuid.UUID) ([]evoke.RecordedEvent, error) {
+	return s.LoadStreamFrom(aggregateID, 1, 0)
+}
+
+// LoadStreamFrom returns up to limit events of a stream starting at
+// fromVersion. A limit <= 0 means no limit.
+func (s *Store) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]evoke.RecordedEvent, error) {
+	out := make([]evoke.RecordedEvent, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stream := tx.Bucket(streamsBucket).Bucket(aggregateID[:])
+		if stream == nil {
+			return nil
+		}
+		events := tx.Bucket(eventsBucket)
+		c := stream.Cursor()
+		for k, v := c.Seek(itob(max(fromVersion, 1))); k != nil; k, v = c.Next() {
+			if limit > 0 && len(out) >= limit {
+				break
+			}
+			rec, err := s.decode(events.Get(v))
+			if err != nil {
+				return err
+			}
+			out = append(out, rec)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ReadAll returns up to limit events of the log starting at fromSeq. A
+// limit <= 0 means no limit.
+func (s *Store) ReadAll(fromSeq int64, limit int) ([]evoke.RecordedEvent, error) {
+	out := make([]evoke.RecordedEvent, 0)
+	err := s.scan(fromSeq, func(rec evoke.RecordedEvent) (bool, error) {
+		out = append(out, rec)
+		return limit <= 0 || len(out) < limit, nil
+	})
+	return out, err
+}
+
+func (s *Store) ReplayFrom(seq int64, handler evoke.RecordedEventHandlerFunc, filters ...evoke.EventFilter) error {
+	return s.scan(seq, func(rec evoke.RecordedEvent) (bool, error) {
+		if !evoke.MatchesAll(filters, rec) {
+			return true, nil
+		}
+		if err := handler(rec, true); err != nil {
+			return false, fmt.Errorf("callback error: %w", err)
+		}
+		return true, nil
+	})
+}
+
+// scanBatch is how many events scan reads per transaction
+const scanBatch = 500
+
+// scan calls fn on every event from seq on until it returns false or an
+// error. Events are read in batches and fn is called outside of any
+// transaction, so it may record events itself.
+func (s *Store) scan(seq int64, fn func(evoke.RecordedEvent) (bool, error)) error {
+	seq = max(seq, 0)
+	for {
+		batch := make([]evoke.RecordedEvent, 0, scanBatch)
+		err := s.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(eventsBucket).Cursor()
+			for k, v := c.Seek(itob(seq)); k != nil && len(batch) < scanBatch; k, v = c.Next() {
+				rec, err := s.decode(v)
+				if err != nil {
+					return err
+				}
+				batch = append(batch, rec)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, rec := range batch {
+			more, err := fn(rec)
+			if err != nil || !more {
+				return err
+			}
+		}
+		if len(batch) < scanBatch {
+			return nil
+		}
+		seq = batch[len(batch)-1].Sequence + 1
+	}
+}
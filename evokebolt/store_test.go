@@ -0,0 +1,369 @@
+package evokebolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+type itemAdded struct {
+	SKU string
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "events.bolt"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	evoke.RegisterEvent(s, &itemAdded{})
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestRecordAtVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing int
+		expected int64
+		conflict bool
+	}{
+		{name: "new stream", existing: 0, expected: 0},
+		{name: "at version", existing: 2, expected: 2},
+		{name: "behind", existing: 2, expected: 1, conflict: true},
+		{name: "ahead", existing: 2, expected: 3, conflict: true},
+		{name: "missing stream", existing: 0, expected: 1, conflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			ctx := context.Background()
+			id := evoke.NewID()
+			for i := 0; i < tt.existing; i++ {
+				if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := s.RecordAtVersion(ctx, "Cart", id, tt.expected, []evoke.Event{itemAdded{SKU: "b"}})
+			if tt.conflict != errors.Is(err, evoke.ErrConcurrencyConflict) {
+				t.Fatalf("RecordAtVersion(%d) on a stream at %d: %v", tt.expected, tt.existing, err)
+			}
+			if !tt.conflict && err != nil {
+				t.Fatal(err)
+			}
+
+			recs, err := s.LoadStream(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.existing
+			if !tt.conflict {
+				want++
+			}
+			if len(recs) != want {
+				t.Errorf("stream has %d events, want %d", len(recs), want)
+			}
+		})
+	}
+}
+
+func TestRecordAtVersionConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	id := evoke.NewID()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.RecordAtVersion(context.Background(), "", id, 1, []evoke.Event{itemAdded{SKU: "b"}})
+		}()
+	}
+	wg.Wait()
+
+	var ok int
+	for _, err := range errs {
+		if err == nil {
+			ok++
+		} else if !errors.Is(err, evoke.ErrConcurrencyConflict) {
+			t.Errorf("RecordAtVersion: %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d concurrent appends at version 1 succeeded, want 1", ok)
+	}
+}
+
+// recordingPublisher keeps the events published to it
+type recordingPublisher struct {
+	recs []evoke.RecordedEvent
+}
+
+func (p *recordingPublisher) Publish(rec evoke.RecordedEvent, replay bool) error {
+	p.recs = append(p.recs, rec)
+	return nil
+}
+
+// Events are published as values, as they are read back, whether recorded
+// as values or pointers.
+func TestPublishedEventsAreValues(t *testing.T) {
+	tests := []struct {
+		name string
+		e    evoke.Event
+	}{
+		{name: "value", e: itemAdded{SKU: "a"}},
+		{name: "pointer", e: &itemAdded{SKU: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			var p recordingPublisher
+			s.RegisterPublisher(&p)
+			id := evoke.NewID()
+			if err := s.RecordAs(context.Background(), "Cart", id, []evoke.Event{tt.e}); err != nil {
+				t.Fatal(err)
+			}
+			if e, ok := tt.e.(*itemAdded); ok {
+				e.SKU = "changed"
+			}
+
+			recs, err := s.LoadStream(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.recs) != 1 || len(recs) != 1 {
+				t.Fatalf("published %d and read back %d events, want 1", len(p.recs), len(recs))
+			}
+			if got, want := p.recs[0].Event, recs[0].Event; got != want {
+				t.Errorf("published %#v, read back %#v", got, want)
+			}
+		})
+	}
+}
+
+// sequences returns the sequences of recs, in order
+func sequences(recs []evoke.RecordedEvent) []int64 {
+	seqs := make([]int64, len(recs))
+	for i, rec := range recs {
+		seqs[i] = rec.Sequence
+	}
+	return seqs
+}
+
+func TestReads(t *testing.T) {
+	s := newTestStore(t)
+	a, b := evoke.NewID(), evoke.NewID()
+	for _, id := range []uuid.UUID{a, b, a, a, b} {
+		if err := s.Record(id, []evoke.Event{itemAdded{SKU: id.String()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recs, err := s.LoadStream(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(recs); !slices.Equal(got, []int64{1, 3, 4}) {
+		t.Errorf("loaded %v, want [1 3 4]", got)
+	}
+	for i, rec := range recs {
+		if rec.Version != int64(i+1) || rec.AggregateID != a || rec.Event != (itemAdded{SKU: a.String()}) {
+			t.Errorf("event %d loaded as %+v", i, rec)
+		}
+	}
+	if recs, err := s.LoadStreamFrom(a, 2, 1); err != nil || !slices.Equal(sequences(recs), []int64{3}) {
+		t.Errorf("LoadStreamFrom(2, 1) loaded %v, %v; want [3]", sequences(recs), err)
+	}
+	if recs, err := s.LoadStream(evoke.NewID()); err != nil || len(recs) != 0 {
+		t.Errorf("loading a missing stream: %v, %v", recs, err)
+	}
+	if recs, err := s.ReadAll(2, 2); err != nil || !slices.Equal(sequences(recs), []int64{2, 3}) {
+		t.Errorf("ReadAll(2, 2) read %v, %v; want [2 3]", sequences(recs), err)
+	}
+	if recs, err := s.ReadAll(1, 0); err != nil || len(recs) != 5 {
+		t.Errorf("ReadAll(1, 0) read %v, %v; want the whole log", sequences(recs), err)
+	}
+}
+
+func TestReplayFrom(t *testing.T) {
+	s := newTestStore(t)
+	a, b := evoke.NewID(), evoke.NewID()
+	if err := s.RecordAs(context.Background(), "Cart", a, []evoke.Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(b, []evoke.Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int64
+	err := s.ReplayFrom(2, func(rec evoke.RecordedEvent, replay bool) error {
+		if !replay {
+			t.Errorf("event %d replayed as new", rec.Sequence)
+		}
+		got = append(got, rec.Sequence)
+		return nil
+	}, evoke.InCategory("Cart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int64{2}) {
+		t.Errorf("replayed %v of the Cart category from 2, want [2]", got)
+	}
+
+	errStop := errors.New("stop")
+	err = s.ReplayFrom(1, func(evoke.RecordedEvent, bool) error { return errStop })
+	if !errors.Is(err, errStop) {
+		t.Errorf("ReplayFrom with a failing handler: %v, want its error", err)
+	}
+}
+
+// A replay longer than a batch reads every event once, and its handler may
+// record events itself.
+func TestReplayAcrossBatches(t *testing.T) {
+	s := newTestStore(t)
+	id := evoke.NewID()
+	evs := make([]evoke.Event, scanBatch+10)
+	for i := range evs {
+		evs[i] = itemAdded{}
+	}
+	if err := s.Record(id, evs); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int64
+	err := s.ReplayFrom(1, func(rec evoke.RecordedEvent, replay bool) error {
+		n++
+		if rec.Sequence != n {
+			return fmt.Errorf("replayed sequence %d as event %d", rec.Sequence, n)
+		}
+		if n == 1 {
+			return s.Record(evoke.NewID(), []evoke.Event{itemAdded{}})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(evs))+1 {
+		t.Errorf("replayed %d events, want %d", n, len(evs)+1)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.bolt")
+	id := evoke.NewID()
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	evoke.RegisterEvent(s, &itemAdded{})
+	if err := s.RecordAtVersion(context.Background(), "", id, 1, []evoke.Event{itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(recs); !slices.Equal(got, []int64{1, 2}) || recs[0].Event != (itemAdded{SKU: "a"}) {
+		t.Errorf("after reopening loaded %+v", recs)
+	}
+}
+
+// recordItem records an itemAdded to the command's aggregate, in the
+// command's context
+type recordItem struct{ store *Store }
+
+type addItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c addItem) AggregateID() uuid.UUID { return c.ID }
+
+func (h recordItem) Handle(cmd evoke.Command) error {
+	return h.HandleContext(context.Background(), cmd)
+}
+
+func (h recordItem) HandleContext(ctx context.Context, cmd evoke.Command) error {
+	add := cmd.(addItem)
+	return h.store.RecordAs(ctx, "cart", add.ID, []evoke.Event{itemAdded{SKU: add.SKU}})
+}
+
+func TestSendR(t *testing.T) {
+	s := newTestStore(t)
+	bus := evoke.NewCommandBus()
+	bus.RegisterHandler(addItem{}, recordItem{s})
+	id := uuid.New()
+	if err := s.Record(uuid.New(), []evoke.Event{itemAdded{SKU: "other"}}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := bus.SendR(addItem{ID: id, SKU: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version != 1 || res.Position != 2 || len(res.Events) != 1 || res.Events[0].Event != (itemAdded{SKU: "a"}) {
+		t.Errorf("result %+v, want the event at version 1 and sequence 2", res)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Healthy(context.Background()); err != nil {
+		t.Errorf("Healthy: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Healthy(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Healthy with a cancelled context returned %v", err)
+	}
+}
+
+// Events with a codec are stored as it encodes them.
+func TestEventCodec(t *testing.T) {
+	s := newTestStore(t)
+	evoke.RegisterEventCodec(s, itemAdded{},
+		func(e itemAdded) ([]byte, error) { return json.Marshal([]string{e.SKU}) },
+		func(data []byte) (itemAdded, error) {
+			var fields []string
+			if err := json.Unmarshal(data, &fields); err != nil || len(fields) != 1 {
+				return itemAdded{}, fmt.Errorf("bad item %s", data)
+			}
+			return itemAdded{SKU: fields[0]}, nil
+		})
+	id := evoke.NewID()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Event != (itemAdded{SKU: "a"}) {
+		t.Errorf("loaded %+v", recs)
+	}
+}
@@ -0,0 +1,446 @@
+// Package evokedynamo is an evoke.EventStore kept in a DynamoDB table, for
+// serverless deployments without a local disk.
+//
+// Each event is an item with the aggregate ID as partition key and the
+// stream version as sort key, so a conditional put on a version that already
+// exists detects concurrent appends. Global sequences come from a counter
+// item in the same table and the log is read in sequence order through the
+// by_sequence global secondary index. Sequences are reserved before the
+// append is written, so a failed append leaves a gap, and a reader tailing the
+// log may briefly see an event before one with a lower sequence.
+//
+// Both the counter and the index are single hot keys, which caps the write
+// throughput of the whole table whatever its capacity: DynamoDB serves one
+// partition at most 1,000 write units a second. Every append updates the
+// counter, in one write however many events it holds, so a table takes at
+// most about 1,000 appends a second across all streams. Every event is also
+// written to the by_sequence partition, taking a write unit per KB, so it
+// takes at most about 1,000 KB of events a second. Appending events in
+// batches raises the first ceiling but not the second; a deployment needing
+// more than that should use a store per service, or another backend.
+package evokedynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+// ErrConflict is returned when another writer appended to the stream
+// between reading its version and writing the new events. It wraps
+// evoke.ErrConcurrencyConflict, so evoke.AggregateHandler retries the command.
+var ErrConflict = fmt.Errorf("evokedynamo: concurrent append to stream: %w", evoke.ErrConcurrencyConflict)
+
+// maxAppend is the most events one append may hold, the number of items
+// in a DynamoDB transaction
+const maxAppend = 100
+
+const (
+	sequenceIndex = "by_sequence"
+	// logPartition is the by_sequence partition key shared by every event
+	logPartition = "all"
+	// counterID is the partition key of the global sequence counter item
+	counterID = "$sequence"
+)
+
+// API is the part of *dynamodb.Client the store uses.
+type API interface {
+	Query(ctx context.Context, in *dynamodb.QueryInput, opts ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, opts ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+type Store struct {
+	evoke.EventRegistry
+	client     API
+	table      string
+	mu         sync.Mutex
+	publishers []evoke.RecordedEventPublisher
+}
+
+var _ evoke.EventStore = (*Store)(nil)
+var _ evoke.StreamPager = (*Store)(nil)
+var _ evoke.AggregateRecorder = (*Store)(nil)
+var _ evoke.VersionedRecorder = (*Store)(nil)
+var _ evoke.HealthChecker = (*Store)(nil)
+
+// New returns a store kept in table, which must have been created as by
+// CreateTable.
+func New(client API, table string) *Store {
+	return &Store{client: client, table: table}
+}
+
+// Healthy checks the table can be queried, reading the sequence counter.
+func (s *Store) Healthy(ctx context.Context) error {
+	_, err := s.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		KeyConditionExpression: aws.String("aggregate_id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": str(counterID),
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("query table: %w", err)
+	}
+	return nil
+}
+
+// CreateTable creates an events table with the keys and index the store
+// expects, billed on demand.
+func CreateTable(ctx context.Context, client interface {
+	CreateTable(ctx context.Context, in *dynamodb.CreateTableInput, opts ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+}, table string) error {
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("aggregate_id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("version"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("log"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sequence"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("aggregate_id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("version"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String(sequenceIndex),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("log"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("sequence"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("create table: %w", err)
+	}
+	return nil
+}
+
+// underlying returns the value e points to, if it is a non-nil pointer, so
+// recorded events hold values as the events read back do rather than the
+// caller's pointers
+func underlying(e evoke.Event) evoke.Event {
+	v := reflect.ValueOf(e)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return v.Elem().Interface().(evoke.Event)
+	}
+	return e
+}
+
+func (s *Store) RegisterPublisher(publisher evoke.RecordedEventPublisher, filters ...evoke.EventFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishers = append(s.publishers, evoke.FilterPublisher(publisher, filters...))
+}
+
+func (s *Store) Record(aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.RecordAs(context.Background(), "", aggregateID, evs)
+}
+
+// RecordAs records events to the stream of an aggregate of the given type.
+// An empty type keeps the type the stream already has.
+func (s *Store) RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, anyVersion, evs)
+}
+
+// RecordAtVersion records events like RecordAs, provided the stream is at
+// expectedVersion. A writer that appends after the version is read makes
+// the conditional put of the first event fail, with ErrConflict.
+func (s *Store) RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, expectedVersion, evs)
+}
+
+// anyVersion is the expected version of appends that don't check it
+const anyVersion = -1
+
+func (s *Store) record(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expected int64, evs []evoke.Event) error {
+	if len(evs) == 0 {
+		return errors.New("no events to append")
+	}
+	if len(evs) > maxAppend {
+		return fmt.Errorf("evokedynamo: cannot append more than %d events at once", maxAppend)
+	}
+
+	head, err := s.query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("aggregate_id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": str(aggregateID.String()),
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+		ConsistentRead:   aws.Bool(true),
+	}, 1)
+	if err != nil {
+		return err
+	}
+	var version int64
+	if len(head) > 0 {
+		version = head[0].Version
+		if aggregateType == "" {
+			aggregateType = head[0].AggregateType
+		}
+	}
+	if expected != anyVersion && version != expected {
+		return fmt.Errorf("%w: stream %s is at version %d, not %d", ErrConflict, aggregateID, version, expected)
+	}
+
+	last, err := s.reserve(ctx, len(evs))
+	if err != nil {
+		return err
+	}
+	seq := last - int64(len(evs))
+
+	recordedAt := time.Now().Unix()
+	recs := make([]evoke.RecordedEvent, 0, len(evs))
+	items := make([]types.TransactWriteItem, 0, len(evs))
+	for _, e := range evs {
+		e = underlying(e)
+		data, err := s.MarshalEvent(e)
+		if err != nil {
+			return fmt.Errorf("Marshal: %w", err)
+		}
+		if err := s.ValidateEvent(e, data); err != nil {
+			return err
+		}
+		seq++
+		version++
+		rec := evoke.RecordedEvent{
+			Sequence:      seq,
+			Version:       version,
+			RecordedAt:    recordedAt,
+			AggregateID:   aggregateID,
+			AggregateType: aggregateType,
+			Event:         e,
+			EventType:     s.EventName(e),
+		}
+		item := map[string]types.AttributeValue{
+			"aggregate_id": str(aggregateID.String()),
+			"version":      num(version),
+			"log":          str(logPartition),
+			"sequence":     num(seq),
+			"recorded_at":  num(recordedAt),
+			"event_type":   str(rec.EventType),
+			"data":         str(string(data)),
+		}
+		if aggregateType != "" {
+			item["aggregate_type"] = str(aggregateType)
+		}
+		items = append(items, types.TransactWriteItem{Put: &types.Put{
+			TableName:                aws.String(s.table),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#v)"),
+			ExpressionAttributeNames: map[string]string{"#v": "version"},
+		}})
+		recs = append(recs, rec)
+	}
+
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return ErrConflict
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("append events: %w", err)
+	}
+
+	evoke.NoteRecorded(ctx, recs)
+
+	s.mu.Lock()
+	publishers := s.publishers
+	s.mu.Unlock()
+	for _, rec := range recs {
+		for _, p := range publishers {
+			if err := p.Publish(rec, false); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// reserve takes n sequences from the counter, returning the last of them
+func (s *Store) reserve(ctx context.Context, n int) (int64, error) {
+	out, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]types.AttributeValue{
+			"aggregate_id": str(counterID),
+			"version":      num(0),
+		},
+		UpdateExpression:          aws.String("add next_sequence :n"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":n": num(int64(n))},
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("reserve sequence: %w", err)
+	}
+	last, err := parseNum(out.Attributes["next_sequence"])
+	if err != nil {
+		return 0, fmt.Errorf("reserve sequence: %w", err)
+	}
+	return last, nil
+}
+
+func (s *Store) MustRecord(aggregateID uuid.UUID, evs []evoke.Event) {
+	err := s.Record(aggregateID, evs)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *Store) LoadStream(aggregateID uuid.UUID) ([]evoke.RecordedEvent, error) {
+	return s.LoadStreamFrom(aggregateID, 1, 0)
+}
+
+// LoadStreamFrom returns up to limit events of a stream starting at
+// fromVersion. A limit <= 0 means no limit.
+func (s *Store) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]evoke.RecordedEvent, error) {
+	return s.query(context.Background(), &dynamodb.QueryInput{
+		KeyConditionExpression:   aws.String("aggregate_id = :id and #v >= :v"),
+		ExpressionAttributeNames: map[string]string{"#v": "version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": str(aggregateID.String()),
+			":v":  num(max(fromVersion, 1)),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, limit)
+}
+
+// ReadAll returns up to limit events of the log starting at fromSeq. A
+// limit <= 0 means no limit. The log is read from an eventually consistent
+// index, so the latest appends may be missing.
+func (s *Store) ReadAll(fromSeq int64, limit int) ([]evoke.RecordedEvent, error) {
+	return s.query(context.Background(), s.logQuery(fromSeq), limit)
+}
+
+func (s *Store) ReplayFrom(seq int64, handler evoke.RecordedEventHandlerFunc, filters ...evoke.EventFilter) error {
+	in := s.logQuery(seq)
+	for {
+		page, err := s.client.Query(context.Background(), in)
+		if err != nil {
+			return fmt.Errorf("query events: %w", err)
+		}
+		for _, item := range page.Items {
+			rec, err := s.decode(item)
+			if err != nil {
+				return err
+			}
+			if !evoke.MatchesAll(filters, rec) {
+				continue
+			}
+			if err := handler(rec, true); err != nil {
+				return fmt.Errorf("callback error: %w", err)
+			}
+		}
+		if len(page.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		in.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+func (s *Store) logQuery(fromSeq int64) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		IndexName:              aws.String(sequenceIndex),
+		KeyConditionExpression: aws.String("#log = :log and #seq >= :seq"),
+		ExpressionAttributeNames: map[string]string{
+			"#log": "log",
+			"#seq": "sequence",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":log": str(logPartition),
+			":seq": num(max(fromSeq, 0)),
+		},
+	}
+}
+
+// query runs in on the store's table, following pages until limit events
+// have been read or there are no more. A limit <= 0 means no limit.
+func (s *Store) query(ctx context.Context, in *dynamodb.QueryInput, limit int) ([]evoke.RecordedEvent, error) {
+	in.TableName = aws.String(s.table)
+	out := make([]evoke.RecordedEvent, 0)
+	for {
+		page, err := s.client.Query(ctx, in)
+		if err != nil {
+			return nil, fmt.Errorf("query events: %w", err)
+		}
+		for _, item := range page.Items {
+			rec, err := s.decode(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, rec)
+			if limit > 0 && len(out) >= limit {
+				return out, nil
+			}
+		}
+		if len(page.LastEvaluatedKey) == 0 {
+			return out, nil
+		}
+		in.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+}
+
+func (s *Store) decode(item map[string]types.AttributeValue) (evoke.RecordedEvent, error) {
+	var rec evoke.RecordedEvent
+	var err error
+	if rec.AggregateID, err = uuid.Parse(parseStr(item["aggregate_id"])); err != nil {
+		return rec, fmt.Errorf("decode aggregate_id: %w", err)
+	}
+	if rec.Version, err = parseNum(item["version"]); err != nil {
+		return rec, fmt.Errorf("decode version: %w", err)
+	}
+	if rec.Sequence, err = parseNum(item["sequence"]); err != nil {
+		return rec, fmt.Errorf("decode sequence: %w", err)
+	}
+	if rec.RecordedAt, err = parseNum(item["recorded_at"]); err != nil {
+		return rec, fmt.Errorf("decode recorded_at: %w", err)
+	}
+	rec.EventType = parseStr(item["event_type"])
+	rec.AggregateType = parseStr(item["aggregate_type"])
+	rec.Event, err = s.UnmarshalEvent(rec.EventType, []byte(parseStr(item["data"])))
+	if err != nil {
+		return rec, fmt.Errorf("UnmarshalEvent: %w", err)
+	}
+	return rec, nil
+}
+
+func str(s string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: s}
+}
+
+func num(n int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+func parseStr(av types.AttributeValue) string {
+	if s, ok := av.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+func parseNum(av types.AttributeValue) (int64, error) {
+	n, ok := av.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("not a number: %T", av)
+	}
+	return strconv.ParseInt(n.Value, 10, 64)
+}
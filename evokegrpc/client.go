@@ -0,0 +1,63 @@
+package evokegrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) Append(ctx context.Context, aggregateID uuid.UUID, events []EventData) error {
+	req := &AppendRequest{AggregateID: aggregateID.String(), Events: events}
+	return c.cc.Invoke(ctx, "/"+serviceName+"/Append", req, new(AppendResponse), grpc.CallContentSubtype("json"))
+}
+
+func (c *Client) ReadStream(ctx context.Context, aggregateID uuid.UUID) ([]RecordedEvent, error) {
+	resp := new(ReadStreamResponse)
+	req := &ReadStreamRequest{AggregateID: aggregateID.String()}
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/ReadStream", req, resp, grpc.CallContentSubtype("json"))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+// SubscribeFrom calls fn for every event from seq onwards until ctx is done,
+// the server closes the stream, or fn returns an error.
+func (c *Client) SubscribeFrom(ctx context.Context, seq int64, fn func(RecordedEvent) error) error {
+	desc := &serviceDesc.Streams[0]
+	stream, err := c.cc.NewStream(ctx, desc, "/"+serviceName+"/SubscribeFrom", grpc.CallContentSubtype("json"))
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&SubscribeFromRequest{Sequence: seq}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		var rec RecordedEvent
+		err := stream.RecvMsg(&rec)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
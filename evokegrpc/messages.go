@@ -0,0 +1,56 @@
+// Package evokegrpc serves an evoke event store over gRPC.
+//
+// Messages are plain Go structs carried with a JSON codec (content subtype
+// "json"), so any gRPC client that can send application/grpc+json can talk
+// to the service without generated stubs.
+package evokegrpc
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+const serviceName = "evoke.EventStore"
+
+type EventData struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type RecordedEvent struct {
+	Sequence    int64           `json:"sequence"`
+	RecordedAt  int64           `json:"recordedAt"`
+	AggregateID string          `json:"aggregateId"`
+	EventType   string          `json:"eventType"`
+	Data        json.RawMessage `json:"data"`
+}
+
+type AppendRequest struct {
+	AggregateID string      `json:"aggregateId"`
+	Events      []EventData `json:"events"`
+}
+
+type AppendResponse struct{}
+
+type ReadStreamRequest struct {
+	AggregateID string `json:"aggregateId"`
+}
+
+type ReadStreamResponse struct {
+	Events []RecordedEvent `json:"events"`
+}
+
+type SubscribeFromRequest struct {
+	Sequence int64 `json:"sequence"`
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,164 @@
+package evokegrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Store is an event store that can also decode events by type name, which
+// the server needs to turn wire payloads back into events. The file store
+// satisfies it.
+type Store interface {
+	evoke.EventStore
+	evoke.EventRegisterer
+}
+
+type Server struct {
+	store Store
+	feed  *evoke.Feed
+}
+
+func NewServer(store Store) *Server {
+	return &Server{
+		store: store,
+		feed:  evoke.NewFeed(store),
+	}
+}
+
+// Register attaches the service to a grpc.Server.
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, s)
+}
+
+func (s *Server) Append(ctx context.Context, req *AppendRequest) (*AppendResponse, error) {
+	aggID, err := uuid.Parse(req.AggregateID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "aggregate id: %v", err)
+	}
+
+	evs := make([]evoke.Event, 0, len(req.Events))
+	for _, ed := range req.Events {
+		e, err := s.store.UnmarshalEvent(ed.Type, ed.Data)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "UnmarshalEvent: %v", err)
+		}
+		evs = append(evs, e)
+	}
+
+	if err := s.store.Record(aggID, evs); err != nil {
+		return nil, status.Errorf(codes.Internal, "Record: %v", err)
+	}
+
+	return &AppendResponse{}, nil
+}
+
+func (s *Server) ReadStream(ctx context.Context, req *ReadStreamRequest) (*ReadStreamResponse, error) {
+	aggID, err := uuid.Parse(req.AggregateID)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "aggregate id: %v", err)
+	}
+
+	recs, err := s.store.LoadStream(aggID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "LoadStream: %v", err)
+	}
+
+	resp := &ReadStreamResponse{Events: make([]RecordedEvent, 0, len(recs))}
+	for _, rec := range recs {
+		out, err := fromRecordedEvent(rec)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		resp.Events = append(resp.Events, out)
+	}
+
+	return resp, nil
+}
+
+func (s *Server) SubscribeFrom(req *SubscribeFromRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	err := s.feed.SubscribeFrom(ctx, req.Sequence, func(rec evoke.RecordedEvent) error {
+		out, err := fromRecordedEvent(rec)
+		if err != nil {
+			return err
+		}
+		return stream.SendMsg(&out)
+	})
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return err
+}
+
+func fromRecordedEvent(rec evoke.RecordedEvent) (RecordedEvent, error) {
+	data, err := json.Marshal(rec.Event)
+	if err != nil {
+		return RecordedEvent{}, fmt.Errorf("Marshal: %w", err)
+	}
+	return RecordedEvent{
+		Sequence:    rec.Sequence,
+		RecordedAt:  rec.RecordedAt,
+		AggregateID: rec.AggregateID.String(),
+		EventType:   rec.EventType,
+		Data:        data,
+	}, nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Append",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := new(AppendRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(*Server).Append(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Append"}
+				return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return srv.(*Server).Append(ctx, req.(*AppendRequest))
+				})
+			},
+		},
+		{
+			MethodName: "ReadStream",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := new(ReadStreamRequest)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(*Server).ReadStream(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/ReadStream"}
+				return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return srv.(*Server).ReadStream(ctx, req.(*ReadStreamRequest))
+				})
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeFrom",
+			ServerStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				req := new(SubscribeFromRequest)
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*Server).SubscribeFrom(req, stream)
+			},
+		},
+	},
+}
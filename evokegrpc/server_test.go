@@ -0,0 +1,159 @@
+package evokegrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/rcy/evoke"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type itemAdded struct {
+	SKU string
+}
+
+// newTestClient serves a file store over an in-memory connection, returning
+// the store and a client of it
+func newTestClient(t *testing.T) (evoke.EventStore, *Client) {
+	t.Helper()
+	store, err := evoke.NewFileStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	evoke.RegisterEvent(store, &itemAdded{})
+	t.Cleanup(func() { store.Shutdown(context.Background()) })
+
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	NewServer(store).Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return store, NewClient(cc)
+}
+
+func eventData(t *testing.T, skus ...string) []EventData {
+	t.Helper()
+	evs := make([]EventData, len(skus))
+	for i, sku := range skus {
+		data, err := json.Marshal(itemAdded{SKU: sku})
+		if err != nil {
+			t.Fatal(err)
+		}
+		evs[i] = EventData{Type: "itemAdded", Data: data}
+	}
+	return evs
+}
+
+func skus(t *testing.T, recs []RecordedEvent) []string {
+	t.Helper()
+	var out []string
+	for _, rec := range recs {
+		var e itemAdded
+		if err := json.Unmarshal(rec.Data, &e); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, e.SKU)
+	}
+	return out
+}
+
+func TestAppendAndReadStream(t *testing.T) {
+	store, client := newTestClient(t)
+	ctx := context.Background()
+	id := evoke.NewID()
+	if err := client.Append(ctx, id, eventData(t, "a", "b")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	recs, err := client.ReadStream(ctx, id)
+	if err != nil {
+		t.Fatalf("ReadStream: %v", err)
+	}
+	if got := skus(t, recs); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("read %v, want [a b]", got)
+	}
+	stored, err := store.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, rec := range recs {
+		if rec.AggregateID != id.String() || rec.EventType != "itemAdded" || rec.Sequence != stored[i].Sequence {
+			t.Errorf("event %d read as %+v, stored as %+v", i, rec, stored[i])
+		}
+	}
+}
+
+func TestAppendInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *AppendRequest
+	}{
+		{name: "bad aggregate id", req: &AppendRequest{AggregateID: "nope", Events: eventData(t, "a")}},
+		{name: "unregistered event", req: &AppendRequest{
+			AggregateID: evoke.NewID().String(),
+			Events:      []EventData{{Type: "itemSold", Data: json.RawMessage(`{}`)}},
+		}},
+		{name: "bad payload", req: &AppendRequest{
+			AggregateID: evoke.NewID().String(),
+			Events:      []EventData{{Type: "itemAdded", Data: json.RawMessage(`[]`)}},
+		}},
+	}
+	_, client := newTestClient(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.cc.Invoke(context.Background(), "/"+serviceName+"/Append", tt.req, new(AppendResponse), grpc.CallContentSubtype("json"))
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("Append: %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
+
+var errEnough = errors.New("enough events")
+
+// SubscribeFrom streams the events already recorded from the sequence on,
+// then the ones recorded after.
+func TestSubscribeFrom(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+	id := evoke.NewID()
+	if err := client.Append(ctx, id, eventData(t, "a", "b", "c")); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []RecordedEvent
+	err := client.SubscribeFrom(ctx, 2, func(rec RecordedEvent) error {
+		got = append(got, rec)
+		switch len(got) {
+		case 2:
+			// caught up; record an event for the live part of the stream
+			return client.Append(ctx, id, eventData(t, "d"))
+		case 3:
+			return errEnough
+		}
+		return nil
+	})
+	if !errors.Is(err, errEnough) {
+		t.Fatalf("SubscribeFrom: %v", err)
+	}
+	if names := skus(t, got); !slices.Equal(names, []string{"b", "c", "d"}) {
+		t.Errorf("streamed %v, want [b c d]", names)
+	}
+}
@@ -0,0 +1,125 @@
+// Package evokemetrics exports evoke instrumentation as Prometheus metrics.
+//
+//	metrics := evokemetrics.New("myapp")
+//	prometheus.MustRegister(metrics)
+//	store, err := evoke.NewFileStore(dbFile, evoke.WithInstrumentation(metrics))
+//	commands.SetInstrumentation(metrics)
+//	events.SetInstrumentation(metrics)
+package evokemetrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rcy/evoke"
+)
+
+// Collector is both an evoke.Instrumentation and a prometheus.Collector.
+// Register it once and pass it to every store and bus to be measured.
+type Collector struct {
+	appendDuration  prometheus.Histogram
+	appendErrors    prometheus.Counter
+	eventsAppended  prometheus.Counter
+	handlerDuration *prometheus.HistogramVec
+	handlerErrors   *prometheus.CounterVec
+	eventsReplayed  prometheus.Counter
+	replaySequence  prometheus.Gauge
+}
+
+var _ evoke.Instrumentation = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
+
+// New returns a collector whose metric names are prefixed with namespace.
+func New(namespace string) *Collector {
+	return &Collector{
+		appendDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "evoke",
+			Name:      "append_duration_seconds",
+			Help:      "Time taken to append a batch of events to the store.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}),
+		appendErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "evoke",
+			Name:      "append_errors_total",
+			Help:      "Appends to the store that failed.",
+		}),
+		eventsAppended: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "evoke",
+			Name:      "events_appended_total",
+			Help:      "Events appended to the store.",
+		}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "evoke",
+			Name:      "handler_duration_seconds",
+			Help:      "Time taken by command and event handlers.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"kind", "type"}),
+		handlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "evoke",
+			Name:      "handler_errors_total",
+			Help:      "Command and event handlers that returned an error.",
+		}, []string{"kind", "type"}),
+		eventsReplayed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "evoke",
+			Name:      "events_replayed_total",
+			Help:      "Events handed to replays.",
+		}),
+		replaySequence: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "evoke",
+			Name:      "replay_sequence",
+			Help:      "Sequence of the last event handed to a replay.",
+		}),
+	}
+}
+
+func (c *Collector) EventsAppended(n int, elapsed time.Duration, err error) {
+	c.appendDuration.Observe(elapsed.Seconds())
+	if err != nil {
+		c.appendErrors.Inc()
+		return
+	}
+	c.eventsAppended.Add(float64(n))
+}
+
+func (c *Collector) HandlerDone(kind, name string, elapsed time.Duration, err error) {
+	c.handlerDuration.WithLabelValues(kind, name).Observe(elapsed.Seconds())
+	if err != nil {
+		c.handlerErrors.WithLabelValues(kind, name).Inc()
+	}
+}
+
+func (c *Collector) EventReplayed(seq int64) {
+	c.eventsReplayed.Inc()
+	c.replaySequence.Set(float64(seq))
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics() {
+		m.Describe(ch)
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.metrics() {
+		m.Collect(ch)
+	}
+}
+
+func (c *Collector) metrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.appendDuration,
+		c.appendErrors,
+		c.eventsAppended,
+		c.handlerDuration,
+		c.handlerErrors,
+		c.eventsReplayed,
+		c.replaySequence,
+	}
+}
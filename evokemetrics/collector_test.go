@@ -0,0 +1,96 @@
+package evokemetrics
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rcy/evoke"
+)
+
+type itemAdded struct{ SKU string }
+
+type addItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c addItem) AggregateID() uuid.UUID { return c.ID }
+
+// recordItem records the item of each command, failing on an empty SKU
+type recordItem struct{ store evoke.EventStore }
+
+func (h recordItem) Handle(cmd evoke.Command) error {
+	add := cmd.(addItem)
+	if add.SKU == "" {
+		return errors.New("no sku")
+	}
+	return h.store.Record(add.ID, []evoke.Event{itemAdded{SKU: add.SKU}})
+}
+
+func TestCollector(t *testing.T) {
+	c := New("test")
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := evoke.NewFileStore(filepath.Join(t.TempDir(), "events.db"), evoke.WithInstrumentation(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Shutdown(context.Background()) })
+	evoke.RegisterEvent(store, &itemAdded{})
+	bus := evoke.NewCommandBus()
+	bus.SetInstrumentation(c)
+	bus.RegisterHandler(addItem{}, recordItem{store})
+
+	id := uuid.New()
+	for _, sku := range []string{"a", "b", ""} {
+		bus.Send(addItem{ID: id, SKU: sku})
+	}
+	if err := store.Record(id, nil); err == nil {
+		t.Fatal("recording no events returned no error")
+	}
+	err = store.ReplayFrom(1, func(evoke.RecordedEvent, bool) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		got, want float64
+	}{
+		"events appended": {testutil.ToFloat64(c.eventsAppended), 2},
+		"append errors":   {testutil.ToFloat64(c.appendErrors), 1},
+		"handler errors":  {testutil.ToFloat64(c.handlerErrors.WithLabelValues("command", "addItem")), 1},
+		"events replayed": {testutil.ToFloat64(c.eventsReplayed), 2},
+		"replay sequence": {testutil.ToFloat64(c.replaySequence), 2},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s: %v, want %v", name, tt.got, tt.want)
+		}
+	}
+	if n := testutil.CollectAndCount(c, "test_evoke_handler_duration_seconds"); n != 1 {
+		t.Errorf("%d handler duration series, want one for addItem", n)
+	}
+	if n := testutil.CollectAndCount(reg, "test_evoke_append_duration_seconds"); n != 1 {
+		t.Errorf("%d append duration series gathered, want 1", n)
+	}
+}
+
+func TestEventsAppended(t *testing.T) {
+	c := New("test")
+	c.EventsAppended(3, time.Millisecond, nil)
+	c.EventsAppended(5, time.Millisecond, errors.New("disk full"))
+	if got := testutil.ToFloat64(c.eventsAppended); got != 3 {
+		t.Errorf("%v events appended, want the 3 of the append that succeeded", got)
+	}
+	if got := testutil.ToFloat64(c.appendErrors); got != 1 {
+		t.Errorf("%v append errors, want 1", got)
+	}
+}
@@ -0,0 +1,337 @@
+// Package evokemysql is an evoke.EventStore kept in MySQL or MariaDB.
+//
+// Events get their global sequence from an auto-increment primary key, and a
+// unique (aggregate_id, version) key makes concurrent appends to one stream
+// fail with ErrConflict instead of interleaving. Sequences are assigned when
+// an event is inserted but become visible when its transaction commits, so a
+// reader tailing the log can see a sequence before a lower one.
+package evokemysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/rcy/evoke"
+)
+
+// ErrConflict is returned when another writer appended to the stream
+// between reading its version and writing the new events. It wraps
+// evoke.ErrConcurrencyConflict, so evoke.AggregateHandler retries the command.
+var ErrConflict = fmt.Errorf("evokemysql: concurrent append to stream: %w", evoke.ErrConcurrencyConflict)
+
+// mysql error number for a duplicate key
+const errDuplicateEntry = 1062
+
+type Store struct {
+	evoke.EventRegistry
+	db         *sqlx.DB
+	mu         sync.Mutex
+	publishers []evoke.RecordedEventPublisher
+}
+
+var _ evoke.EventStore = (*Store)(nil)
+var _ evoke.StreamPager = (*Store)(nil)
+var _ evoke.AggregateRecorder = (*Store)(nil)
+var _ evoke.VersionedRecorder = (*Store)(nil)
+var _ evoke.HealthChecker = (*Store)(nil)
+var _ evoke.StreamInspector = (*Store)(nil)
+
+// Open connects to the database named in dsn, as understood by
+// github.com/go-sql-driver/mysql, and creates the events table if needed.
+func Open(dsn string) (*Store, error) {
+	db, err := sqlx.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := db.Exec(`
+		create table if not exists events (
+			sequence       bigint not null auto_increment primary key,
+			recorded_at    bigint not null,
+			aggregate_id   char(36) not null,
+			aggregate_type varchar(255) not null default '',
+			version        bigint not null,
+			event_type     varchar(255) not null,
+			event_json     longtext not null,
+			unique key events_stream_version (aggregate_id, version)
+		) engine=InnoDB
+	`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create events table: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Healthy pings the database and checks the events table can be read.
+func (s *Store) Healthy(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+	var seq int64
+	if err := s.db.GetContext(ctx, &seq, `select coalesce(max(sequence), 0) from events`); err != nil {
+		return fmt.Errorf("read events table: %w", err)
+	}
+	return nil
+}
+
+type dbEvent struct {
+	Sequence      int64     `db:"sequence"`
+	RecordedAt    int64     `db:"recorded_at"`
+	AggregateID   uuid.UUID `db:"aggregate_id"`
+	AggregateType string    `db:"aggregate_type"`
+	Version       int64     `db:"version"`
+	EventType     string    `db:"event_type"`
+	EventJSON     string    `db:"event_json"`
+}
+
+func (s *Store) decode(row dbEvent) (evoke.RecordedEvent, error) {
+	event, err := s.UnmarshalEvent(row.EventType, []byte(row.EventJSON))
+	if err != nil {
+		return evoke.RecordedEvent{}, fmt.Errorf("UnmarshalEvent: %w", err)
+	}
+	return evoke.RecordedEvent{
+		Sequence:      row.Sequence,
+		Version:       row.Version,
+		RecordedAt:    row.RecordedAt,
+		AggregateID:   row.AggregateID,
+		AggregateType: row.AggregateType,
+		Event:         event,
+		EventType:     row.EventType,
+	}, nil
+}
+
+// underlying returns the value e points to, if it is a non-nil pointer, so
+// recorded events hold values as the events read back do rather than the
+// caller's pointers
+func underlying(e evoke.Event) evoke.Event {
+	v := reflect.ValueOf(e)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return v.Elem().Interface().(evoke.Event)
+	}
+	return e
+}
+
+func (s *Store) RegisterPublisher(publisher evoke.RecordedEventPublisher, filters ...evoke.EventFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishers = append(s.publishers, evoke.FilterPublisher(publisher, filters...))
+}
+
+func (s *Store) Record(aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.RecordAs(context.Background(), "", aggregateID, evs)
+}
+
+// RecordAs records events to the stream of an aggregate of the given type.
+// An empty type keeps the type the stream already has.
+func (s *Store) RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, anyVersion, evs)
+}
+
+// RecordAtVersion records events like RecordAs, provided the stream is at
+// expectedVersion. A writer that appends first makes it fail with
+// ErrConflict, on the version check or else on the events_stream_version key.
+func (s *Store) RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, expectedVersion, evs)
+}
+
+// anyVersion is the expected version of appends that don't check it
+const anyVersion = -1
+
+func (s *Store) record(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expected int64, evs []evoke.Event) error {
+	if len(evs) == 0 {
+		return errors.New("no events to append")
+	}
+
+	recs, err := s.appendEvents(ctx, aggregateType, aggregateID, expected, evs)
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == errDuplicateEntry {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	evoke.NoteRecorded(ctx, recs)
+
+	s.mu.Lock()
+	publishers := s.publishers
+	s.mu.Unlock()
+	for _, rec := range recs {
+		for _, p := range publishers {
+			if err := p.Publish(rec, false); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) appendEvents(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expected int64, evs []evoke.Event) ([]evoke.RecordedEvent, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	var head struct {
+		Version       int64  `db:"version"`
+		AggregateType string `db:"aggregate_type"`
+	}
+	err = tx.GetContext(ctx, &head, `select coalesce(max(version), 0) as version, coalesce(max(aggregate_type), '') as aggregate_type
+		from events where aggregate_id = ?`, aggregateID.String())
+	if err != nil {
+		return nil, fmt.Errorf("select stream version: %w", err)
+	}
+	if expected != anyVersion && head.Version != expected {
+		return nil, fmt.Errorf("%w: stream %s is at version %d, not %d", ErrConflict, aggregateID, head.Version, expected)
+	}
+	version := head.Version
+	if aggregateType == "" {
+		aggregateType = head.AggregateType
+	}
+
+	recordedAt := time.Now().Unix()
+	recs := make([]evoke.RecordedEvent, 0, len(evs))
+	for _, e := range evs {
+		e = underlying(e)
+		data, err := s.MarshalEvent(e)
+		if err != nil {
+			return nil, fmt.Errorf("Marshal: %w", err)
+		}
+		if err := s.ValidateEvent(e, data); err != nil {
+			return nil, err
+		}
+		version++
+		res, err := tx.ExecContext(ctx, `insert into events(recorded_at, aggregate_id, aggregate_type, version, event_type, event_json) values(?,?,?,?,?,?)`,
+			recordedAt, aggregateID.String(), aggregateType, version, s.EventName(e), string(data))
+		if err != nil {
+			return nil, fmt.Errorf("insert into events: %w", err)
+		}
+		seq, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, evoke.RecordedEvent{
+			Sequence:      seq,
+			Version:       version,
+			RecordedAt:    recordedAt,
+			AggregateID:   aggregateID,
+			AggregateType: aggregateType,
+			Event:         e,
+			EventType:     s.EventName(e),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return recs, nil
+}
+
+func (s *Store) MustRecord(aggregateID uuid.UUID, evs []evoke.Event) {
+	err := s.Record(aggregateID, evs)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *Store) LoadStream(aggregateID uuid.UUID) ([]evoke.RecordedEvent, error) {
+	return s.LoadStreamFrom(aggregateID, 1, 0)
+}
+
+// LoadStreamFrom returns up to limit events of a stream starting at
+// fromVersion. A limit <= 0 means no limit.
+func (s *Store) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]evoke.RecordedEvent, error) {
+	return s.selectRecords(`select * from events where aggregate_id = ? and version >= ? order by version asc limit ?`,
+		aggregateID.String(), fromVersion, rowLimit(limit))
+}
+
+// StreamInfo describes a stream from the events_stream_version index.
+func (s *Store) StreamInfo(aggregateID uuid.UUID) (evoke.StreamInfo, error) {
+	info := evoke.StreamInfo{AggregateID: aggregateID}
+	var row struct {
+		Version       int64  `db:"version"`
+		AggregateType string `db:"aggregate_type"`
+		First         int64  `db:"first"`
+		Last          int64  `db:"last"`
+	}
+	err := s.db.Get(&row, `select coalesce(max(version), 0) as version, coalesce(max(aggregate_type), '') as aggregate_type,
+		coalesce(min(recorded_at), 0) as first, coalesce(max(recorded_at), 0) as last
+		from events where aggregate_id = ?`, aggregateID.String())
+	if err != nil {
+		return info, fmt.Errorf("select stream info: %w", err)
+	}
+	if row.Version == 0 {
+		return info, nil
+	}
+	info.Exists = true
+	info.AggregateType = row.AggregateType
+	info.Version = row.Version
+	info.Events = row.Version
+	info.FirstRecordedAt, info.LastRecordedAt = row.First, row.Last
+	return info, nil
+}
+
+// ReadAll returns up to limit events of the log starting at fromSeq. A
+// limit <= 0 means no limit.
+func (s *Store) ReadAll(fromSeq int64, limit int) ([]evoke.RecordedEvent, error) {
+	return s.selectRecords(`select * from events where sequence >= ? order by sequence asc limit ?`, fromSeq, rowLimit(limit))
+}
+
+// replayBatch is how many events ReplayFrom reads per query
+const replayBatch = 500
+
+func (s *Store) ReplayFrom(seq int64, handler evoke.RecordedEventHandlerFunc, filters ...evoke.EventFilter) error {
+	for {
+		recs, err := s.ReadAll(seq, replayBatch)
+		if err != nil {
+			return err
+		}
+		for _, rec := range recs {
+			if !evoke.MatchesAll(filters, rec) {
+				continue
+			}
+			if err := handler(rec, true); err != nil {
+				return fmt.Errorf("callback error: %w", err)
+			}
+		}
+		if len(recs) < replayBatch {
+			return nil
+		}
+		seq = recs[len(recs)-1].Sequence + 1
+	}
+}
+
+// rowLimit maps limit to mysql, which has no way to spell no limit
+func rowLimit(limit int) uint64 {
+	if limit <= 0 {
+		return 1<<63 - 1
+	}
+	return uint64(limit)
+}
+
+func (s *Store) selectRecords(query string, args ...any) ([]evoke.RecordedEvent, error) {
+	var rows []dbEvent
+	if err := s.db.Select(&rows, query, args...); err != nil {
+		return nil, fmt.Errorf("select from events: %w", err)
+	}
+	recs := make([]evoke.RecordedEvent, len(rows))
+	for i, row := range rows {
+		rec, err := s.decode(row)
+		if err != nil {
+			return nil, err
+		}
+		recs[i] = rec
+	}
+	return recs, nil
+}
@@ -0,0 +1,284 @@
+package evokemysql
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+type itemAdded struct {
+	SKU string
+}
+
+// newTestStore opens the database named by EVOKE_TEST_MYSQL_DSN, skipping
+// the test if it isn't set. Tests use fresh aggregate IDs, so they can share
+// the database.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("EVOKE_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("EVOKE_TEST_MYSQL_DSN not set")
+	}
+	s, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	evoke.RegisterEvent(s, &itemAdded{})
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestRecordAtVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing int
+		expected int64
+		conflict bool
+	}{
+		{name: "new stream", existing: 0, expected: 0},
+		{name: "at version", existing: 2, expected: 2},
+		{name: "behind", existing: 2, expected: 1, conflict: true},
+		{name: "ahead", existing: 2, expected: 3, conflict: true},
+		{name: "missing stream", existing: 0, expected: 1, conflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			ctx := context.Background()
+			id := evoke.NewID()
+			for i := 0; i < tt.existing; i++ {
+				if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := s.RecordAtVersion(ctx, "Cart", id, tt.expected, []evoke.Event{itemAdded{SKU: "b"}})
+			if tt.conflict != errors.Is(err, evoke.ErrConcurrencyConflict) {
+				t.Fatalf("RecordAtVersion(%d) on a stream at %d: %v", tt.expected, tt.existing, err)
+			}
+			if !tt.conflict && err != nil {
+				t.Fatal(err)
+			}
+
+			recs, err := s.LoadStream(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.existing
+			if !tt.conflict {
+				want++
+			}
+			if len(recs) != want {
+				t.Errorf("stream has %d events, want %d", len(recs), want)
+			}
+		})
+	}
+}
+
+func TestRecordAtVersionConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	id := evoke.NewID()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.RecordAtVersion(context.Background(), "", id, 1, []evoke.Event{itemAdded{SKU: "b"}})
+		}()
+	}
+	wg.Wait()
+
+	var ok int
+	for _, err := range errs {
+		if err == nil {
+			ok++
+		} else if !errors.Is(err, evoke.ErrConcurrencyConflict) {
+			t.Errorf("RecordAtVersion: %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d concurrent appends at version 1 succeeded, want 1", ok)
+	}
+}
+
+// sequences returns the sequences of recs, in order
+func sequences(recs []evoke.RecordedEvent) []int64 {
+	seqs := make([]int64, len(recs))
+	for i, rec := range recs {
+		seqs[i] = rec.Sequence
+	}
+	return seqs
+}
+
+func TestReads(t *testing.T) {
+	s := newTestStore(t)
+	a, b := evoke.NewID(), evoke.NewID()
+	for _, id := range []uuid.UUID{a, b, a, a, b} {
+		if err := s.Record(id, []evoke.Event{itemAdded{SKU: id.String()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recs, err := s.LoadStream(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 || !slices.IsSorted(sequences(recs)) {
+		t.Fatalf("loaded %v, want 3 events in sequence order", sequences(recs))
+	}
+	for i, rec := range recs {
+		if rec.Version != int64(i+1) || rec.AggregateID != a || rec.Event != (itemAdded{SKU: a.String()}) {
+			t.Errorf("event %d loaded as %+v", i, rec)
+		}
+	}
+	if page, err := s.LoadStreamFrom(a, 2, 1); err != nil || len(page) != 1 || page[0].Sequence != recs[1].Sequence {
+		t.Errorf("LoadStreamFrom(2, 1) loaded %v, %v; want [%d]", sequences(page), err, recs[1].Sequence)
+	}
+
+	// other tests may be appending to the database too
+	log, err := s.ReadAll(recs[0].Sequence, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ours []uuid.UUID
+	for _, rec := range log {
+		if rec.AggregateID == a || rec.AggregateID == b {
+			ours = append(ours, rec.AggregateID)
+		}
+	}
+	if !slices.Equal(ours, []uuid.UUID{a, b, a, a, b}) {
+		t.Errorf("ReadAll read the streams' events in the order %v", ours)
+	}
+	if page, err := s.ReadAll(recs[0].Sequence, 2); err != nil || len(page) != 2 || page[0].Sequence != recs[0].Sequence {
+		t.Errorf("ReadAll(%d, 2) read %v, %v", recs[0].Sequence, sequences(page), err)
+	}
+}
+
+func TestReplayFrom(t *testing.T) {
+	s := newTestStore(t)
+	category := "Cart-" + evoke.NewID().String()
+	a, b := evoke.NewID(), evoke.NewID()
+	if err := s.RecordAs(context.Background(), category, a, []evoke.Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(b, []evoke.Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.LoadStream(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int64
+	err = s.ReplayFrom(recs[1].Sequence, func(rec evoke.RecordedEvent, replay bool) error {
+		got = append(got, rec.Sequence)
+		return nil
+	}, evoke.InCategory(category))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int64{recs[1].Sequence}) {
+		t.Errorf("replayed %v of the category from %d, want [%d]", got, recs[1].Sequence, recs[1].Sequence)
+	}
+}
+
+// recordingPublisher keeps the events published to it
+type recordingPublisher struct {
+	recs []evoke.RecordedEvent
+}
+
+func (p *recordingPublisher) Publish(rec evoke.RecordedEvent, replay bool) error {
+	p.recs = append(p.recs, rec)
+	return nil
+}
+
+func TestPublishes(t *testing.T) {
+	s := newTestStore(t)
+	var p recordingPublisher
+	s.RegisterPublisher(&p, evoke.OnlyEvents(itemAdded{}))
+	id := evoke.NewID()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}, &itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.recs, recs) {
+		t.Errorf("published %+v, want the events as read back %+v", p.recs, recs)
+	}
+}
+
+// recordItem records an itemAdded to the command's aggregate, in the
+// command's context
+type recordItem struct{ store *Store }
+
+type addItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c addItem) AggregateID() uuid.UUID { return c.ID }
+
+func (h recordItem) Handle(cmd evoke.Command) error {
+	return h.HandleContext(context.Background(), cmd)
+}
+
+func (h recordItem) HandleContext(ctx context.Context, cmd evoke.Command) error {
+	add := cmd.(addItem)
+	return h.store.RecordAs(ctx, "cart", add.ID, []evoke.Event{itemAdded{SKU: add.SKU}})
+}
+
+func TestSendR(t *testing.T) {
+	s := newTestStore(t)
+	bus := evoke.NewCommandBus()
+	bus.RegisterHandler(addItem{}, recordItem{s})
+	id := uuid.New()
+	res, err := bus.SendR(addItem{ID: id, SKU: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version != 1 || len(res.Events) != 1 || res.Events[0].Event != (itemAdded{SKU: "a"}) {
+		t.Fatalf("result %+v, want the event at version 1", res)
+	}
+	if res.Position == 0 || res.Position != res.Events[0].Sequence {
+		t.Errorf("result at position %d, event at sequence %d, want the event's sequence", res.Position, res.Events[0].Sequence)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Healthy(context.Background()); err != nil {
+		t.Errorf("Healthy: %v", err)
+	}
+}
+
+func TestStreamInfo(t *testing.T) {
+	s := newTestStore(t)
+	id := uuid.New()
+	if info, err := s.StreamInfo(id); err != nil || info.Exists {
+		t.Errorf("info of a missing stream %+v, %v", info, err)
+	}
+	if err := s.RecordAs(context.Background(), "cart", id, []evoke.Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.StreamInfo(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Exists || info.AggregateType != "cart" || info.Version != 2 || info.Events != 2 || info.FirstRecordedAt == 0 || info.LastRecordedAt < info.FirstRecordedAt {
+		t.Errorf("info %+v", info)
+	}
+}
@@ -0,0 +1,180 @@
+package evokenats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rcy/evoke"
+)
+
+// the queue group handlers subscribe in, so each command is handled once
+const queueGroup = "evoke"
+
+// errors that keep their identity across the wire, so errors.Is works on
+// the sending node
+var sentinels = []error{
+	evoke.ErrNoHandler,
+	evoke.ErrCommandNotRegistered,
+	evoke.ErrConcurrencyConflict,
+	evoke.ErrInvalidEvent,
+	evoke.ErrStreamDeleted,
+	evoke.ErrStreamTombstoned,
+	evoke.ErrPayloadTooLarge,
+}
+
+// reply is the response to a command, empty when it was handled
+type reply struct {
+	Error string `json:"error,omitempty"`
+	// Sentinel is the text of the evoke error the handler's error wraps
+	Sentinel string `json:"sentinel,omitempty"`
+}
+
+// CommandBus sends commands over NATS request/reply to whichever node
+// registered a handler for their type, so any node can send any command.
+// Commands are sent as JSON on the subject prefix + "." + the command's
+// type name, and each is handled by one of the nodes handling its type.
+type CommandBus struct {
+	evoke.CommandRegistry
+	conn    *nats.Conn
+	prefix  string
+	timeout time.Duration
+	mu      sync.Mutex
+	subs    []*nats.Subscription
+}
+
+// NewCommandBus returns a bus sending and handling commands on conn under
+// subjects starting with prefix, such as "evoke.commands".
+func NewCommandBus(conn *nats.Conn, prefix string) *CommandBus {
+	return &CommandBus{conn: conn, prefix: prefix, timeout: 5 * time.Second}
+}
+
+// SetTimeout sets how long Send waits for a command to be handled. The
+// default is five seconds.
+func (b *CommandBus) SetTimeout(d time.Duration) {
+	b.timeout = d
+}
+
+func (b *CommandBus) subject(commandType string) string {
+	return b.prefix + "." + commandType
+}
+
+// RegisterHandler handles commands of cmd's type sent from any node on
+// this one. The command type is registered for decoding too.
+func (b *CommandBus) RegisterHandler(cmd evoke.Command, handler evoke.CommandHandler) {
+	evoke.RegisterCommand(b, cmd)
+	commandType := evoke.TypeName(cmd)
+	sub, err := b.conn.QueueSubscribe(b.subject(commandType), queueGroup, func(msg *nats.Msg) {
+		b.handle(commandType, handler, msg)
+	})
+	if err != nil {
+		panic(fmt.Sprintf("evokenats: subscribe to %s: %v", commandType, err))
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, sub)
+}
+
+func (b *CommandBus) handle(commandType string, handler evoke.CommandHandler, msg *nats.Msg) {
+	var r reply
+	cmd, err := b.UnmarshalCommand(commandType, msg.Data)
+	if err == nil {
+		err = handler.Handle(cmd)
+	}
+	if err != nil {
+		r.Error = err.Error()
+		for _, sentinel := range sentinels {
+			if errors.Is(err, sentinel) {
+				r.Sentinel = sentinel.Error()
+				break
+			}
+		}
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	// a failed reply times out on the sender
+	_ = msg.Respond(data)
+}
+
+func (b *CommandBus) Send(cmd evoke.Command) error {
+	return b.SendContext(context.Background(), cmd)
+}
+
+// SendContext sends cmd to a node handling its type and waits for it to be
+// handled, until the bus timeout or ctx is done. Errors returned by the
+// handler come back with their message, wrapping the evoke error they
+// wrapped, if any.
+func (b *CommandBus) SendContext(ctx context.Context, cmd evoke.Command) error {
+	if v, ok := cmd.(evoke.CommandValidator); ok {
+		if err := v.Validate(); err != nil {
+			return &evoke.ValidationError{Command: evoke.TypeName(cmd), Err: err}
+		}
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("Marshal: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	msg, err := b.conn.RequestWithContext(ctx, b.subject(evoke.TypeName(cmd)), data)
+	if errors.Is(err, nats.ErrNoResponders) {
+		return fmt.Errorf("evokenats: %w: %s (hint: call RegisterHandler on a node)", evoke.ErrNoHandler, evoke.TypeName(cmd))
+	}
+	if err != nil {
+		return fmt.Errorf("evokenats: send %s: %w", evoke.TypeName(cmd), err)
+	}
+	var r reply
+	if err := json.Unmarshal(msg.Data, &r); err != nil {
+		return fmt.Errorf("evokenats: decode reply: %w", err)
+	}
+	if r.Error == "" {
+		return nil
+	}
+	for _, sentinel := range sentinels {
+		if sentinel.Error() == r.Sentinel {
+			return &remoteError{msg: r.Error, sentinel: sentinel}
+		}
+	}
+	return &remoteError{msg: r.Error}
+}
+
+func (b *CommandBus) MustSend(cmd evoke.Command) {
+	if err := b.Send(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// Close stops handling commands on this node, letting those being handled
+// finish.
+func (b *CommandBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var first error
+	for _, sub := range b.subs {
+		if err := sub.Drain(); err != nil && first == nil {
+			first = err
+		}
+	}
+	b.subs = nil
+	return first
+}
+
+// remoteError is an error returned by a handler on another node
+type remoteError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *remoteError) Error() string {
+	return e.msg
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.sentinel
+}
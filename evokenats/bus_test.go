@@ -0,0 +1,169 @@
+package evokenats
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/rcy/evoke"
+)
+
+type addItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c addItem) AggregateID() uuid.UUID { return c.ID }
+
+func (c addItem) Validate() error {
+	if c.SKU == "" {
+		return errors.New("sku is required")
+	}
+	return nil
+}
+
+// removeItem has no handler on any node
+type removeItem struct{ ID uuid.UUID }
+
+func (c removeItem) AggregateID() uuid.UUID { return c.ID }
+
+// recordingHandler records the commands it handles, failing with err
+type recordingHandler struct {
+	mu      sync.Mutex
+	handled []evoke.Command
+	err     error
+	delay   time.Duration
+}
+
+func (h *recordingHandler) Handle(cmd evoke.Command) error {
+	time.Sleep(h.delay)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handled = append(h.handled, cmd)
+	return h.err
+}
+
+func (h *recordingHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.handled)
+}
+
+// testConn connects to the server at EVOKE_TEST_NATS_URL, skipping the test
+// if it isn't set
+func testConn(t *testing.T) *nats.Conn {
+	t.Helper()
+	url := os.Getenv("EVOKE_TEST_NATS_URL")
+	if url == "" {
+		t.Skip("EVOKE_TEST_NATS_URL not set")
+	}
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+// newTestBuses returns buses for n nodes, each with a connection of its
+// own, under a prefix of their own
+func newTestBuses(t *testing.T, n int) []*CommandBus {
+	t.Helper()
+	prefix := "evoke-test-" + evoke.NewID().String()
+	buses := make([]*CommandBus, n)
+	for i := range buses {
+		buses[i] = NewCommandBus(testConn(t), prefix)
+		t.Cleanup(func() { buses[i].Close() })
+	}
+	return buses
+}
+
+func TestSendToAnotherNode(t *testing.T) {
+	buses := newTestBuses(t, 3)
+	var h recordingHandler
+	buses[1].RegisterHandler(addItem{}, &h)
+	buses[2].RegisterHandler(addItem{}, &h)
+	if err := buses[1].conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := buses[2].conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	id := evoke.NewID()
+	for range 4 {
+		if err := buses[0].Send(addItem{ID: id, SKU: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := h.count(); n != 4 {
+		t.Fatalf("handled %d commands, want each of the 4 handled once", n)
+	}
+	if h.handled[0] != (addItem{ID: id, SKU: "a"}) {
+		t.Errorf("handled %#v", h.handled[0])
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	buses := newTestBuses(t, 2)
+	h := recordingHandler{err: fmt.Errorf("cart is busy: %w", evoke.ErrConcurrencyConflict)}
+	buses[1].RegisterHandler(addItem{}, &h)
+	if err := buses[1].conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := buses[0].Send(addItem{ID: evoke.NewID(), SKU: "a"})
+	if !errors.Is(err, evoke.ErrConcurrencyConflict) || err.Error() != h.err.Error() {
+		t.Errorf("Send returned %v, want the handler's error wrapping ErrConcurrencyConflict", err)
+	}
+	h.mu.Lock()
+	h.err = errors.New("out of stock")
+	h.mu.Unlock()
+	if err := buses[0].Send(addItem{ID: evoke.NewID(), SKU: "a"}); err == nil || err.Error() != "out of stock" {
+		t.Errorf("Send returned %v, want the handler's error", err)
+	}
+
+	var verr *evoke.ValidationError
+	if err := buses[0].Send(addItem{ID: evoke.NewID()}); !errors.As(err, &verr) {
+		t.Errorf("Send of an invalid command returned %v, want a ValidationError", err)
+	}
+	if err := buses[0].Send(removeItem{ID: evoke.NewID()}); !errors.Is(err, evoke.ErrNoHandler) {
+		t.Errorf("Send without a handler returned %v, want ErrNoHandler", err)
+	}
+	if n := h.count(); n != 2 {
+		t.Errorf("handled %d commands, want only the two valid ones", n)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	buses := newTestBuses(t, 2)
+	h := recordingHandler{delay: 200 * time.Millisecond}
+	buses[1].RegisterHandler(addItem{}, &h)
+	if err := buses[1].conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	buses[0].SetTimeout(20 * time.Millisecond)
+	if err := buses[0].Send(addItem{ID: evoke.NewID(), SKU: "a"}); err == nil {
+		t.Error("Send returned before the handler without an error")
+	}
+}
+
+func TestClose(t *testing.T) {
+	buses := newTestBuses(t, 2)
+	var h recordingHandler
+	buses[1].RegisterHandler(addItem{}, &h)
+	if err := buses[1].Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := buses[1].conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := buses[0].Send(addItem{ID: evoke.NewID(), SKU: "a"}); !errors.Is(err, evoke.ErrNoHandler) {
+		t.Errorf("Send to a closed node returned %v, want ErrNoHandler", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package evokeotel traces evoke stores, buses and aggregate handlers with
+// OpenTelemetry. Give the same tracer to everything involved so a command,
+// the events it records and the handlers of those events end up in one
+// trace:
+//
+//	tracer := evokeotel.New()
+//	store, err := evoke.NewFileStore(dbFile, evoke.WithTracer(tracer))
+//	commands.SetTracer(tracer)
+//	events.SetTracer(tracer)
+package evokeotel
+
+import (
+	"context"
+
+	"github.com/rcy/evoke"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/rcy/evoke"
+
+type Option func(*tracer)
+
+// WithTracerProvider creates spans with tp instead of the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *tracer) {
+		t.provider = tp
+	}
+}
+
+// WithPropagator writes trace context into event metadata with p instead of
+// the global propagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *tracer) {
+		t.propagator = p
+	}
+}
+
+type tracer struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+	tracer     trace.Tracer
+}
+
+// New returns an evoke.Tracer backed by OpenTelemetry.
+func New(opts ...Option) evoke.Tracer {
+	t := &tracer{
+		provider:   otel.GetTracerProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.tracer = t.provider.Tracer(instrumentationName)
+	return t
+}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, func(error)) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (t *tracer) Inject(ctx context.Context, md evoke.Metadata) {
+	t.propagator.Inject(ctx, propagation.MapCarrier(md))
+}
+
+func (t *tracer) Extract(ctx context.Context, md evoke.Metadata) context.Context {
+	if md == nil {
+		return ctx
+	}
+	return t.propagator.Extract(ctx, propagation.MapCarrier(md))
+}
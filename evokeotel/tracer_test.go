@@ -0,0 +1,119 @@
+package evokeotel
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type itemAdded struct{ SKU string }
+
+type addItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c addItem) AggregateID() uuid.UUID { return c.ID }
+
+type cart struct{}
+
+func (c *cart) Apply(evoke.Event) error { return nil }
+
+func (c *cart) HandleCommand(cmd evoke.Command) ([]evoke.Event, error) {
+	return []evoke.Event{itemAdded{SKU: cmd.(addItem).SKU}}, nil
+}
+
+// newTracer returns a tracer whose ended spans are kept by the recorder
+func newTracer() (evoke.Tracer, *tracetest.SpanRecorder) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	return New(WithTracerProvider(tp), WithPropagator(propagation.TraceContext{})), sr
+}
+
+func TestStartRecordsErrors(t *testing.T) {
+	tracer, sr := newTracer()
+	_, end := tracer.Start(context.Background(), "ok")
+	end(nil)
+	_, end = tracer.Start(context.Background(), "failed")
+	end(errors.New("boom"))
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended, want 2", len(spans))
+	}
+	if spans[0].Name() != "ok" || spans[0].Status().Code != codes.Unset {
+		t.Errorf("span %q ended with status %v", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Name() != "failed" || spans[1].Status().Code != codes.Error || spans[1].Status().Description != "boom" {
+		t.Errorf("span %q ended with status %v, want the error", spans[1].Name(), spans[1].Status())
+	}
+	if len(spans[1].Events()) != 1 {
+		t.Errorf("failed span has %d events, want the recorded error", len(spans[1].Events()))
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	tracer, _ := newTracer()
+	ctx, end := tracer.Start(context.Background(), "command")
+	defer end(nil)
+	md := evoke.Metadata{}
+	tracer.Inject(ctx, md)
+	if md["traceparent"] == "" {
+		t.Fatalf("injected %v, want a traceparent", md)
+	}
+
+	_, child := tracer.Start(tracer.Extract(context.Background(), md), "handler")
+	child(nil)
+	if got := tracer.Extract(context.Background(), nil); got != context.Background() {
+		t.Error("extracting from no metadata changed the context")
+	}
+}
+
+// A command, the append of its events and the handlers of those events end
+// up in one trace.
+func TestCommandTrace(t *testing.T) {
+	tracer, sr := newTracer()
+	store, err := evoke.NewFileStore(filepath.Join(t.TempDir(), "events.db"), evoke.WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Shutdown(context.Background()) })
+	evoke.RegisterEvent(store, &itemAdded{})
+
+	events := evoke.NewEventBus()
+	events.SetTracer(tracer)
+	events.Subscribe(itemAdded{}, evoke.EventHandlerFunc[itemAdded](func(itemAdded, bool) error { return nil }))
+	store.RegisterPublisher(events)
+
+	commands := evoke.NewCommandBus()
+	commands.SetTracer(tracer)
+	handler := evoke.NewAggregateHandler(store, func(uuid.UUID) evoke.Aggregate { return &cart{} })
+	handler.SetTracer(tracer)
+	commands.RegisterHandler(addItem{}, handler)
+	if err := commands.Send(addItem{ID: uuid.New(), SKU: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := sr.Ended()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+		if span.SpanContext().TraceID() != spans[0].SpanContext().TraceID() {
+			t.Errorf("span %q is in another trace", span.Name())
+		}
+	}
+	for _, want := range []string{"evoke.command addItem", "cart.HandleCommand addItem", "evoke.append", "evoke.event itemAdded"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("spans %q, want one named %q", names, want)
+		}
+	}
+}
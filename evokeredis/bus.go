@@ -0,0 +1,179 @@
+package evokeredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcy/evoke"
+	"github.com/redis/go-redis/v9"
+)
+
+// Bus delivers the log of a Store to event handlers as a member of a Redis
+// consumer group. Every group sees every event once, shared among its
+// consumers; an event is acknowledged only after all of its handlers
+// succeed, so one whose handler fails is delivered again when the consumer
+// restarts. Events left pending by a consumer that never comes back are
+// claimed by another once they have been idle for the claim timeout.
+type Bus struct {
+	store       *Store
+	group       string
+	consumer    string
+	claimIdle   time.Duration
+	mu          sync.RWMutex
+	subscribers map[string][]evoke.EventHandler
+}
+
+// NewBus returns a bus reading store's log as consumer within group. A
+// group that doesn't exist yet is created to start at the beginning of the
+// log.
+func NewBus(store *Store, group, consumer string) *Bus {
+	return &Bus{
+		store:       store,
+		group:       group,
+		consumer:    consumer,
+		claimIdle:   defaultClaimIdle,
+		subscribers: make(map[string][]evoke.EventHandler),
+	}
+}
+
+// defaultClaimIdle is how long an event stays pending before another
+// consumer claims it, unless SetClaimIdle says otherwise
+const defaultClaimIdle = time.Minute
+
+// SetClaimIdle sets how long an event delivered to another consumer of the
+// group may go unacknowledged before this one claims and handles it. It
+// should be well above the time handlers take; 0 stops claiming. Call it
+// before Run.
+func (b *Bus) SetClaimIdle(d time.Duration) {
+	b.claimIdle = d
+}
+
+// Subscribe hands handler the events of evt's type, so it can be used with
+// evoke.On. Subscribe options are of the in-process bus and panic here.
+func (b *Bus) Subscribe(evt evoke.Event, handler evoke.EventHandler, opts ...evoke.SubscribeOption) {
+	if len(opts) > 0 {
+		panic("evokeredis: subscribe options are not supported")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[evoke.TypeName(evt)] = append(b.subscribers[evoke.TypeName(evt)], handler)
+}
+
+// Run delivers events until ctx is done. It first redelivers events this
+// consumer read earlier but never acknowledged, then waits for new ones,
+// every claim timeout also claiming the events other consumers have left
+// pending that long.
+func (b *Bus) Run(ctx context.Context) error {
+	client, stream := b.store.client, b.store.logKey()
+	err := client.XGroupCreateMkStream(ctx, stream, b.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("XGROUP CREATE: %w", err)
+	}
+
+	// "0" reads this consumer's pending entries, ">" entries never delivered
+	start := "0"
+	var claimed time.Time
+	for {
+		if start == ">" && b.claimIdle > 0 && time.Since(claimed) >= b.claimIdle {
+			if err := b.claim(ctx); err != nil {
+				return err
+			}
+			claimed = time.Now()
+		}
+		streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    b.group,
+			Consumer: b.consumer,
+			Streams:  []string{stream, start},
+			Count:    pageSize,
+			Block:    time.Second,
+		}).Result()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, redis.Nil) {
+			start = ">"
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("XREADGROUP: %w", err)
+		}
+		msgs := streams[0].Messages
+		if start == "0" && len(msgs) == 0 {
+			start = ">"
+			continue
+		}
+		if err := b.handle(ctx, msgs); err != nil {
+			return err
+		}
+	}
+}
+
+// claim takes over and handles the events pending on any consumer of the
+// group for longer than the claim timeout
+func (b *Bus) claim(ctx context.Context) error {
+	start := "0-0"
+	for {
+		msgs, next, err := b.store.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   b.store.logKey(),
+			Group:    b.group,
+			Consumer: b.consumer,
+			MinIdle:  b.claimIdle,
+			Start:    start,
+			Count:    pageSize,
+		}).Result()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("XAUTOCLAIM: %w", err)
+		}
+		if err := b.handle(ctx, msgs); err != nil {
+			return err
+		}
+		// the scan is done when it wraps around to the start
+		if next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// handle delivers msgs in order, acknowledging each once its handlers
+// succeed
+func (b *Bus) handle(ctx context.Context, msgs []redis.XMessage) error {
+	for _, msg := range msgs {
+		if err := b.deliver(msg); err != nil {
+			return err
+		}
+		if err := b.store.client.XAck(ctx, b.store.logKey(), b.group, msg.ID).Err(); err != nil {
+			return fmt.Errorf("XACK: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *Bus) deliver(msg redis.XMessage) error {
+	rec, err := b.store.decode(msg)
+	if err != nil {
+		return err
+	}
+	b.mu.RLock()
+	handlers := b.subscribers[evoke.TypeName(rec.Event)]
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		var err error
+		if rh, ok := h.(evoke.RecordedHandler); ok {
+			err = rh.HandleRecorded(context.Background(), rec, false)
+		} else {
+			err = h.Handle(rec.Event, false)
+		}
+		if err != nil {
+			return fmt.Errorf("handle %s %d: %w", rec.EventType, rec.Sequence, err)
+		}
+	}
+	return nil
+}
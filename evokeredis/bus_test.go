@@ -0,0 +1,133 @@
+package evokeredis
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcy/evoke"
+)
+
+var errHandler = errors.New("handler failed")
+
+// runBus runs b until handler has been called n times or timeout passes,
+// returning the SKUs handled; a handler error stops the run and is
+// returned
+func runBus(t *testing.T, b *Bus, n int, timeout time.Duration, fail func(itemAdded) bool) ([]string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var mu sync.Mutex
+	var skus []string
+	b.Subscribe(itemAdded{}, evoke.EventHandlerFunc[itemAdded](func(e itemAdded, _ bool) error {
+		if fail != nil && fail(e) {
+			return errHandler
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		skus = append(skus, e.SKU)
+		if len(skus) == n {
+			cancel()
+		}
+		return nil
+	}))
+	err := b.Run(ctx)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		err = nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return skus, err
+}
+
+func recordSKUs(t *testing.T, s *Store, skus ...string) {
+	t.Helper()
+	for _, sku := range skus {
+		if err := s.Record(evoke.NewID(), []evoke.Event{itemAdded{SKU: sku}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// An event whose handler failed is delivered again when the consumer
+// restarts, before the events after it.
+func TestBusRedeliversPending(t *testing.T) {
+	s := newTestStore(t)
+	recordSKUs(t, s, "a", "b")
+
+	_, err := runBus(t, NewBus(s, "g", "c1"), 2, 5*time.Second, func(e itemAdded) bool { return e.SKU == "b" })
+	if !errors.Is(err, errHandler) {
+		t.Fatalf("Run: %v, want the handler's error", err)
+	}
+	recordSKUs(t, s, "c")
+
+	got, err := runBus(t, NewBus(s, "g", "c1"), 2, 5*time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("restarted consumer handled %v, want %v", got, want)
+	}
+}
+
+// Events left pending by a consumer that went away are claimed by another
+// once they have been idle for the claim timeout.
+func TestBusClaimsIdleEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		idle time.Duration
+		want []string
+	}{
+		{name: "claimed", idle: 100 * time.Millisecond, want: []string{"a"}},
+		{name: "claiming off", idle: 0, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			recordSKUs(t, s, "a")
+			_, err := runBus(t, NewBus(s, "g", "gone"), 1, 5*time.Second, func(itemAdded) bool { return true })
+			if !errors.Is(err, errHandler) {
+				t.Fatalf("Run: %v, want the handler's error", err)
+			}
+			time.Sleep(200 * time.Millisecond)
+
+			b := NewBus(s, "g", "live")
+			b.SetClaimIdle(tt.idle)
+			got, err := runBus(t, b, 1, 2*time.Second, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("live consumer handled %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Handlers subscribed with evoke.On get the recorded event sent on the
+// stream.
+func TestBusOn(t *testing.T) {
+	s := newTestStore(t)
+	id := evoke.NewID()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b := NewBus(s, "g", "c1")
+	var got evoke.RecordedEvent
+	evoke.On(b, func(e itemAdded, rec evoke.RecordedEvent, _ bool) error {
+		got = rec
+		cancel()
+		return nil
+	})
+	if err := b.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run: %v", err)
+	}
+	if got.AggregateID != id || got.Version != 1 || got.Event != (itemAdded{SKU: "a"}) {
+		t.Errorf("handled %+v, want the recorded event", got)
+	}
+}
@@ -0,0 +1,305 @@
+// Package evokeredis keeps events in Redis Streams: a Store implementing
+// evoke.EventStore and a Bus delivering the log to event handlers through a
+// consumer group, so small distributed deployments get durable appends and
+// at-least-once delivery without running a broker.
+//
+// The global log and every aggregate stream are Redis streams whose entry
+// IDs are 0-<sequence> and 0-<version>, so range reads map directly onto
+// XRANGE. Appends run as one Lua script and are atomic. All keys share a hash
+// tag and so live in one cluster slot.
+package evokeredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/redis/go-redis/v9"
+)
+
+// pageSize is how many entries reads fetch per XRANGE
+const pageSize = 500
+
+// ErrConflict is returned by RecordAtVersion when the stream is no longer at
+// the expected version. It wraps evoke.ErrConcurrencyConflict, so
+// evoke.AggregateHandler retries the command.
+var ErrConflict = fmt.Errorf("evokeredis: concurrent append to stream: %w", evoke.ErrConcurrencyConflict)
+
+type Store struct {
+	evoke.EventRegistry
+	client     redis.UniversalClient
+	prefix     string
+	mu         sync.Mutex
+	publishers []evoke.RecordedEventPublisher
+}
+
+var _ evoke.EventStore = (*Store)(nil)
+var _ evoke.StreamPager = (*Store)(nil)
+var _ evoke.AggregateRecorder = (*Store)(nil)
+var _ evoke.VersionedRecorder = (*Store)(nil)
+var _ evoke.HealthChecker = (*Store)(nil)
+
+// New returns a store keeping its keys under prefix, e.g. "evoke". Stores
+// with different prefixes can share a Redis database.
+func New(client redis.UniversalClient, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+func (s *Store) key(name string) string {
+	return "{" + s.prefix + "}:" + name
+}
+
+func (s *Store) logKey() string {
+	return s.key("log")
+}
+
+func (s *Store) streamKey(aggregateID uuid.UUID) string {
+	return s.key("stream:" + aggregateID.String())
+}
+
+// Healthy pings the Redis server.
+func (s *Store) Healthy(ctx context.Context) error {
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("ping redis: %w", err)
+	}
+	return nil
+}
+
+// appendScript appends events to an aggregate stream and the global log,
+// numbering them from the stream's last version and the sequence counter.
+// Unless the expected version is -1 the stream has to be at it, or nothing is
+// appended and the script returns {0, version}.
+//
+// KEYS: log, stream, sequence counter, aggregate types hash
+// ARGV: aggregate id, aggregate type, recorded at, expected version, then
+// event type and data for each event
+var appendScript = redis.NewScript(`
+local version = 0
+local last = redis.call('XREVRANGE', KEYS[2], '+', '-', 'COUNT', 1)
+if #last > 0 then
+	version = tonumber(string.match(last[1][1], '-(%d+)$'))
+end
+local expected = tonumber(ARGV[4])
+if expected ~= -1 and expected ~= version then
+	return {0, version}
+end
+local atype = ARGV[2]
+if atype == '' then
+	atype = redis.call('HGET', KEYS[4], ARGV[1]) or ''
+else
+	redis.call('HSET', KEYS[4], ARGV[1], atype)
+end
+local n = (#ARGV - 4) / 2
+local seq = redis.call('INCRBY', KEYS[3], n) - n
+local first = seq + 1
+for i = 0, n - 1 do
+	seq = seq + 1
+	version = version + 1
+	local fields = {
+		'aggregate_id', ARGV[1], 'aggregate_type', atype,
+		'version', version, 'sequence', seq, 'recorded_at', ARGV[3],
+		'event_type', ARGV[5 + 2 * i], 'data', ARGV[6 + 2 * i],
+	}
+	redis.call('XADD', KEYS[1], '0-' .. seq, unpack(fields))
+	redis.call('XADD', KEYS[2], '0-' .. version, unpack(fields))
+end
+return {first, version - n + 1, atype}
+`)
+
+// underlying returns the value e points to, if it is a non-nil pointer, so
+// recorded events hold values as the events read back do rather than the
+// caller's pointers
+func underlying(e evoke.Event) evoke.Event {
+	v := reflect.ValueOf(e)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return v.Elem().Interface().(evoke.Event)
+	}
+	return e
+}
+
+func (s *Store) RegisterPublisher(publisher evoke.RecordedEventPublisher, filters ...evoke.EventFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishers = append(s.publishers, evoke.FilterPublisher(publisher, filters...))
+}
+
+func (s *Store) Record(aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.RecordAs(context.Background(), "", aggregateID, evs)
+}
+
+// RecordAs records events to the stream of an aggregate of the given type.
+// An empty type keeps the type the stream already has.
+func (s *Store) RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, anyVersion, evs)
+}
+
+// RecordAtVersion records events like RecordAs, provided the stream is at
+// expectedVersion. The append script checks the version before it writes,
+// and scripts run one at a time.
+func (s *Store) RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []evoke.Event) error {
+	return s.record(ctx, aggregateType, aggregateID, expectedVersion, evs)
+}
+
+// anyVersion is the expected version of appends that don't check it
+const anyVersion = -1
+
+func (s *Store) record(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expected int64, evs []evoke.Event) error {
+	if len(evs) == 0 {
+		return errors.New("no events to append")
+	}
+
+	recordedAt := time.Now().Unix()
+	args := []any{aggregateID.String(), aggregateType, recordedAt, expected}
+	for _, e := range evs {
+		data, err := s.MarshalEvent(e)
+		if err != nil {
+			return fmt.Errorf("Marshal: %w", err)
+		}
+		if err := s.ValidateEvent(e, data); err != nil {
+			return err
+		}
+		args = append(args, s.EventName(e), string(data))
+	}
+
+	keys := []string{s.logKey(), s.streamKey(aggregateID), s.key("sequence"), s.key("aggregate_types")}
+	res, err := appendScript.Run(ctx, s.client, keys, args...).Slice()
+	if err != nil {
+		return fmt.Errorf("append events: %w", err)
+	}
+	seq, version := res[0].(int64), res[1].(int64)
+	if seq == 0 {
+		return fmt.Errorf("%w: stream %s is at version %d, not %d", ErrConflict, aggregateID, version, expected)
+	}
+	aggregateType, _ = res[2].(string)
+
+	recs := make([]evoke.RecordedEvent, len(evs))
+	for i, e := range evs {
+		recs[i] = evoke.RecordedEvent{
+			Sequence:      seq + int64(i),
+			Version:       version + int64(i),
+			RecordedAt:    recordedAt,
+			AggregateID:   aggregateID,
+			AggregateType: aggregateType,
+			Event:         underlying(e),
+			EventType:     s.EventName(e),
+		}
+	}
+
+	evoke.NoteRecorded(ctx, recs)
+
+	s.mu.Lock()
+	publishers := s.publishers
+	s.mu.Unlock()
+	for _, rec := range recs {
+		for _, p := range publishers {
+			if err := p.Publish(rec, false); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) MustRecord(aggregateID uuid.UUID, evs []evoke.Event) {
+	err := s.Record(aggregateID, evs)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *Store) LoadStream(aggregateID uuid.UUID) ([]evoke.RecordedEvent, error) {
+	return s.LoadStreamFrom(aggregateID, 1, 0)
+}
+
+// LoadStreamFrom returns up to limit events of a stream starting at
+// fromVersion. A limit <= 0 means no limit.
+func (s *Store) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]evoke.RecordedEvent, error) {
+	return s.readRange(s.streamKey(aggregateID), fromVersion, limit)
+}
+
+// ReadAll returns up to limit events of the log starting at fromSeq. A
+// limit <= 0 means no limit.
+func (s *Store) ReadAll(fromSeq int64, limit int) ([]evoke.RecordedEvent, error) {
+	return s.readRange(s.logKey(), fromSeq, limit)
+}
+
+func (s *Store) readRange(key string, from int64, limit int) ([]evoke.RecordedEvent, error) {
+	out := make([]evoke.RecordedEvent, 0)
+	err := s.scan(key, from, func(rec evoke.RecordedEvent) (bool, error) {
+		out = append(out, rec)
+		return limit <= 0 || len(out) < limit, nil
+	})
+	return out, err
+}
+
+func (s *Store) ReplayFrom(seq int64, handler evoke.RecordedEventHandlerFunc, filters ...evoke.EventFilter) error {
+	return s.scan(s.logKey(), seq, func(rec evoke.RecordedEvent) (bool, error) {
+		if !evoke.MatchesAll(filters, rec) {
+			return true, nil
+		}
+		if err := handler(rec, true); err != nil {
+			return false, fmt.Errorf("callback error: %w", err)
+		}
+		return true, nil
+	})
+}
+
+// scan calls fn on the entries of a stream from entry 0-from on, a page at
+// a time, until it returns false or an error
+func (s *Store) scan(key string, from int64, fn func(evoke.RecordedEvent) (bool, error)) error {
+	start := "0-" + strconv.FormatInt(max(from, 1), 10)
+	for {
+		msgs, err := s.client.XRangeN(context.Background(), key, start, "+", pageSize).Result()
+		if err != nil {
+			return fmt.Errorf("XRANGE %s: %w", key, err)
+		}
+		for _, msg := range msgs {
+			rec, err := s.decode(msg)
+			if err != nil {
+				return err
+			}
+			more, err := fn(rec)
+			if err != nil || !more {
+				return err
+			}
+		}
+		if len(msgs) < pageSize {
+			return nil
+		}
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+}
+
+func (s *Store) decode(msg redis.XMessage) (evoke.RecordedEvent, error) {
+	field := func(name string) string {
+		v, _ := msg.Values[name].(string)
+		return v
+	}
+	var rec evoke.RecordedEvent
+	var err error
+	if rec.AggregateID, err = uuid.Parse(field("aggregate_id")); err != nil {
+		return rec, fmt.Errorf("decode %s aggregate_id: %w", msg.ID, err)
+	}
+	if rec.Sequence, err = strconv.ParseInt(field("sequence"), 10, 64); err != nil {
+		return rec, fmt.Errorf("decode %s sequence: %w", msg.ID, err)
+	}
+	if rec.Version, err = strconv.ParseInt(field("version"), 10, 64); err != nil {
+		return rec, fmt.Errorf("decode %s version: %w", msg.ID, err)
+	}
+	if rec.RecordedAt, err = strconv.ParseInt(field("recorded_at"), 10, 64); err != nil {
+		return rec, fmt.Errorf("decode %s recorded_at: %w", msg.ID, err)
+	}
+	rec.AggregateType = field("aggregate_type")
+	rec.EventType = field("event_type")
+	rec.Event, err = s.UnmarshalEvent(rec.EventType, []byte(field("data")))
+	if err != nil {
+		return rec, fmt.Errorf("UnmarshalEvent: %w", err)
+	}
+	return rec, nil
+}
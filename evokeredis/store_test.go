@@ -0,0 +1,259 @@
+package evokeredis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+	"github.com/redis/go-redis/v9"
+)
+
+type itemAdded struct {
+	SKU string
+}
+
+// testClient connects to the server at EVOKE_TEST_REDIS_ADDR, skipping the
+// test if it isn't set
+func testClient(t *testing.T) *redis.Client {
+	t.Helper()
+	addr := os.Getenv("EVOKE_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("EVOKE_TEST_REDIS_ADDR not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// newTestStore returns a store under a prefix of its own, deleting its keys
+// when the test ends
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	client := testClient(t)
+	s := New(client, "evoke-test-"+evoke.NewID().String())
+	evoke.RegisterEvent(s, &itemAdded{})
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys, _ := client.Keys(ctx, "{"+s.prefix+"}:*").Result()
+		if len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+	})
+	return s
+}
+
+func TestRecordAtVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing int
+		expected int64
+		conflict bool
+	}{
+		{name: "new stream", existing: 0, expected: 0},
+		{name: "at version", existing: 2, expected: 2},
+		{name: "behind", existing: 2, expected: 1, conflict: true},
+		{name: "ahead", existing: 2, expected: 3, conflict: true},
+		{name: "missing stream", existing: 0, expected: 1, conflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			ctx := context.Background()
+			id := evoke.NewID()
+			for i := 0; i < tt.existing; i++ {
+				if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := s.RecordAtVersion(ctx, "Cart", id, tt.expected, []evoke.Event{itemAdded{SKU: "b"}})
+			if tt.conflict != errors.Is(err, evoke.ErrConcurrencyConflict) {
+				t.Fatalf("RecordAtVersion(%d) on a stream at %d: %v", tt.expected, tt.existing, err)
+			}
+			if !tt.conflict && err != nil {
+				t.Fatal(err)
+			}
+
+			recs, err := s.LoadStream(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.existing
+			if !tt.conflict {
+				want++
+			}
+			if len(recs) != want {
+				t.Errorf("stream has %d events, want %d", len(recs), want)
+			}
+		})
+	}
+}
+
+func TestRecordAtVersionConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	id := evoke.NewID()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.RecordAtVersion(context.Background(), "", id, 1, []evoke.Event{itemAdded{SKU: "b"}})
+		}()
+	}
+	wg.Wait()
+
+	var ok int
+	for _, err := range errs {
+		if err == nil {
+			ok++
+		} else if !errors.Is(err, evoke.ErrConcurrencyConflict) {
+			t.Errorf("RecordAtVersion: %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d concurrent appends at version 1 succeeded, want 1", ok)
+	}
+}
+
+// sequences returns the sequences of recs, in order
+func sequences(recs []evoke.RecordedEvent) []int64 {
+	seqs := make([]int64, len(recs))
+	for i, rec := range recs {
+		seqs[i] = rec.Sequence
+	}
+	return seqs
+}
+
+func TestReads(t *testing.T) {
+	s := newTestStore(t)
+	a, b := evoke.NewID(), evoke.NewID()
+	for _, id := range []uuid.UUID{a, b, a, a, b} {
+		if err := s.Record(id, []evoke.Event{itemAdded{SKU: id.String()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recs, err := s.LoadStream(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(recs); !slices.Equal(got, []int64{1, 3, 4}) {
+		t.Errorf("loaded %v, want [1 3 4]", got)
+	}
+	for i, rec := range recs {
+		if rec.Version != int64(i+1) || rec.AggregateID != a || rec.Event != (itemAdded{SKU: a.String()}) {
+			t.Errorf("event %d loaded as %+v", i, rec)
+		}
+	}
+	if recs, err := s.LoadStreamFrom(a, 2, 1); err != nil || !slices.Equal(sequences(recs), []int64{3}) {
+		t.Errorf("LoadStreamFrom(2, 1) loaded %v, %v; want [3]", sequences(recs), err)
+	}
+	if recs, err := s.ReadAll(2, 2); err != nil || !slices.Equal(sequences(recs), []int64{2, 3}) {
+		t.Errorf("ReadAll(2, 2) read %v, %v; want [2 3]", sequences(recs), err)
+	}
+}
+
+func TestReplayFrom(t *testing.T) {
+	s := newTestStore(t)
+	a, b := evoke.NewID(), evoke.NewID()
+	if err := s.RecordAs(context.Background(), "Cart", a, []evoke.Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(b, []evoke.Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int64
+	err := s.ReplayFrom(2, func(rec evoke.RecordedEvent, replay bool) error {
+		got = append(got, rec.Sequence)
+		return nil
+	}, evoke.InCategory("Cart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int64{2}) {
+		t.Errorf("replayed %v of the Cart category from 2, want [2]", got)
+	}
+}
+
+// recordingPublisher keeps the events published to it
+type recordingPublisher struct {
+	recs []evoke.RecordedEvent
+}
+
+func (p *recordingPublisher) Publish(rec evoke.RecordedEvent, replay bool) error {
+	p.recs = append(p.recs, rec)
+	return nil
+}
+
+func TestPublishes(t *testing.T) {
+	s := newTestStore(t)
+	var p recordingPublisher
+	s.RegisterPublisher(&p, evoke.OnlyEvents(itemAdded{}))
+	id := evoke.NewID()
+	if err := s.Record(id, []evoke.Event{itemAdded{SKU: "a"}, &itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.recs, recs) {
+		t.Errorf("published %+v, want the events as read back %+v", p.recs, recs)
+	}
+}
+
+// recordItem records an itemAdded to the command's aggregate, in the
+// command's context
+type recordItem struct{ store *Store }
+
+type addItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c addItem) AggregateID() uuid.UUID { return c.ID }
+
+func (h recordItem) Handle(cmd evoke.Command) error {
+	return h.HandleContext(context.Background(), cmd)
+}
+
+func (h recordItem) HandleContext(ctx context.Context, cmd evoke.Command) error {
+	add := cmd.(addItem)
+	return h.store.RecordAs(ctx, "cart", add.ID, []evoke.Event{itemAdded{SKU: add.SKU}})
+}
+
+func TestSendR(t *testing.T) {
+	s := newTestStore(t)
+	bus := evoke.NewCommandBus()
+	bus.RegisterHandler(addItem{}, recordItem{s})
+	id := uuid.New()
+	res, err := bus.SendR(addItem{ID: id, SKU: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version != 1 || len(res.Events) != 1 || res.Events[0].Event != (itemAdded{SKU: "a"}) {
+		t.Fatalf("result %+v, want the event at version 1", res)
+	}
+	if res.Position == 0 || res.Position != res.Events[0].Sequence {
+		t.Errorf("result at position %d, event at sequence %d, want the event's sequence", res.Position, res.Events[0].Sequence)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Healthy(context.Background()); err != nil {
+		t.Errorf("Healthy: %v", err)
+	}
+}
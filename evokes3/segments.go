@@ -0,0 +1,64 @@
+// Package evokes3 keeps the segments of a tiered evoke store in S3 or an S3
+// compatible object store:
+//
+//	segments := evokes3.New(s3.NewFromConfig(cfg), "my-bucket", "events/")
+//	store, err := evoke.NewFileStore(dbFile, evoke.WithSegmentTier(segments))
+package evokes3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rcy/evoke"
+)
+
+// API is the part of *s3.Client the segment store uses.
+type API interface {
+	PutObject(ctx context.Context, in *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+type segments struct {
+	client API
+	bucket string
+	prefix string
+}
+
+// New returns a segment store keeping segments in bucket, under keys
+// starting with prefix.
+func New(client API, bucket, prefix string) evoke.SegmentStore {
+	return &segments{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *segments) PutSegment(ctx context.Context, name string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.prefix + name),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/gzip"),
+	})
+	if err != nil {
+		return fmt.Errorf("PutObject: %w", err)
+	}
+	return nil
+}
+
+func (s *segments) GetSegment(ctx context.Context, name string) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetObject: %w", err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read object: %w", err)
+	}
+	return data, nil
+}
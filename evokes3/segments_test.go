@@ -0,0 +1,149 @@
+package evokes3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rcy/evoke"
+)
+
+type itemAdded struct{ SKU string }
+
+// memAPI keeps objects in memory by bucket and key
+type memAPI struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (m *memAPI) PutObject(ctx context.Context, in *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[*in.Bucket+"/"+*in.Key] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *memAPI) GetObject(ctx context.Context, in *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	data, ok := m.objects[*in.Bucket+"/"+*in.Key]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func TestSegments(t *testing.T) {
+	api := &memAPI{objects: map[string][]byte{}}
+	segments := New(api, "bucket", "events/")
+	ctx := context.Background()
+	if err := segments.PutSegment(ctx, "seg-1", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := api.objects["bucket/events/seg-1"]; !ok {
+		t.Errorf("put objects %v, want the segment under the prefix", api.objects)
+	}
+	if data, err := segments.GetSegment(ctx, "seg-1"); err != nil || string(data) != "data" {
+		t.Errorf("GetSegment returned %q, %v", data, err)
+	}
+	if _, err := segments.GetSegment(ctx, "seg-2"); err == nil {
+		t.Error("got a segment that was never put")
+	}
+
+	api.err = errors.New("unavailable")
+	if err := segments.PutSegment(ctx, "seg-3", nil); !errors.Is(err, api.err) {
+		t.Errorf("PutSegment returned %v, want the client's error", err)
+	}
+}
+
+// newTestSegments creates a bucket of its own at the S3 compatible endpoint
+// in EVOKE_TEST_S3_ENDPOINT, such as MinIO, skipping the test if it isn't
+// set. The credentials are in EVOKE_TEST_S3_ACCESS_KEY and
+// EVOKE_TEST_S3_SECRET_KEY. The bucket is emptied and deleted when the test
+// ends.
+func newTestSegments(t *testing.T) evoke.SegmentStore {
+	t.Helper()
+	endpoint := os.Getenv("EVOKE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("EVOKE_TEST_S3_ENDPOINT not set")
+	}
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		UsePathStyle: true,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{
+				AccessKeyID:     os.Getenv("EVOKE_TEST_S3_ACCESS_KEY"),
+				SecretAccessKey: os.Getenv("EVOKE_TEST_S3_SECRET_KEY"),
+			}, nil
+		}),
+	})
+	ctx := context.Background()
+	bucket := "evoke-test-" + evoke.NewID().String()
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	t.Cleanup(func() {
+		out, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+		if err == nil {
+			for _, obj := range out.Contents {
+				client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: obj.Key})
+			}
+		}
+		client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+	})
+	return New(client, bucket, "events/")
+}
+
+func TestSegmentsOnServer(t *testing.T) {
+	segments := newTestSegments(t)
+	ctx := context.Background()
+	if err := segments.PutSegment(ctx, "seg-1", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := segments.GetSegment(ctx, "seg-1"); err != nil || string(data) != "data" {
+		t.Errorf("GetSegment returned %q, %v", data, err)
+	}
+	if _, err := segments.GetSegment(ctx, "seg-2"); err == nil {
+		t.Error("got a segment that was never put")
+	}
+}
+
+// A store tiering into the bucket reads its tiered events back from it.
+func TestTieredStore(t *testing.T) {
+	segments := newTestSegments(t)
+	store, err := evoke.NewFileStore(filepath.Join(t.TempDir(), "events.db"), evoke.WithSegmentTier(segments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Shutdown(context.Background()) })
+	evoke.RegisterEvent(store, &itemAdded{})
+	id := evoke.NewID()
+	if err := store.Record(id, []evoke.Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}, itemAdded{SKU: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := store.TierBefore(context.Background(), 3)
+	if err != nil || n != 2 {
+		t.Fatalf("TierBefore tiered %d events, %v", n, err)
+	}
+	recs, err := store.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 || recs[0].Event != (itemAdded{SKU: "a"}) || recs[2].Event != (itemAdded{SKU: "c"}) {
+		t.Errorf("loaded %+v, want the tiered events read back", recs)
+	}
+}
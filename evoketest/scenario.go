@@ -0,0 +1,121 @@
+// Package evoketest has helpers for testing code built on evoke.
+package evoketest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rcy/evoke"
+)
+
+// Scenario hydrates an aggregate from past events, runs a command against it
+// and checks what came out:
+//
+//	evoketest.For(&Order{}).
+//		Given(OrderPlaced{ID: id}).
+//		When(CancelOrder{ID: id}).
+//		ThenEvents(t, OrderCancelled{ID: id})
+type Scenario struct {
+	agg    evoke.Aggregate
+	given  []evoke.Event
+	cmd    evoke.Command
+	events []evoke.Event
+	err    error
+	ran    bool
+}
+
+// For starts a scenario against a fresh aggregate.
+func For(agg evoke.Aggregate) *Scenario {
+	return &Scenario{agg: agg}
+}
+
+// Given sets the history the aggregate is hydrated from.
+func (s *Scenario) Given(events ...evoke.Event) *Scenario {
+	s.given = append(s.given, events...)
+	return s
+}
+
+// When sets the command under test.
+func (s *Scenario) When(cmd evoke.Command) *Scenario {
+	s.cmd = cmd
+	return s
+}
+
+func (s *Scenario) run(t testing.TB) {
+	t.Helper()
+	if s.ran {
+		return
+	}
+	s.ran = true
+
+	for i, e := range s.given {
+		if err := s.agg.Apply(e); err != nil {
+			t.Fatalf("Given: Apply(%T) event %d: %v", e, i, err)
+		}
+	}
+	if s.cmd == nil {
+		t.Fatalf("When: no command set")
+	}
+	s.events, s.err = s.agg.HandleCommand(s.cmd)
+}
+
+// ThenEvents fails the test unless the command succeeded and produced
+// exactly the expected events, in order.
+func (s *Scenario) ThenEvents(t testing.TB, expected ...evoke.Event) {
+	t.Helper()
+	s.run(t)
+
+	if s.err != nil {
+		t.Fatalf("%T.HandleCommand(%T): unexpected error: %v", s.agg, s.cmd, s.err)
+	}
+	if diff := diffEvents(expected, s.events); diff != "" {
+		t.Fatalf("%T.HandleCommand(%T): events mismatch:\n%s", s.agg, s.cmd, diff)
+	}
+}
+
+// ThenNoEvents fails the test unless the command succeeded without
+// producing any events.
+func (s *Scenario) ThenNoEvents(t testing.TB) {
+	t.Helper()
+	s.ThenEvents(t)
+}
+
+// ThenError fails the test unless the command failed with an error matching
+// expected according to errors.Is. A nil expected accepts any error.
+func (s *Scenario) ThenError(t testing.TB, expected error) {
+	t.Helper()
+	s.run(t)
+
+	if s.err == nil {
+		t.Fatalf("%T.HandleCommand(%T): expected error, got events %s", s.agg, s.cmd, formatEvents(s.events))
+	}
+	if expected != nil && !errors.Is(s.err, expected) {
+		t.Fatalf("%T.HandleCommand(%T): expected error %q, got %q", s.agg, s.cmd, expected, s.err)
+	}
+}
+
+func diffEvents(expected, actual []evoke.Event) string {
+	var b strings.Builder
+	for i := 0; i < max(len(expected), len(actual)); i++ {
+		switch {
+		case i >= len(actual):
+			fmt.Fprintf(&b, "  [%d] missing: %#v\n", i, expected[i])
+		case i >= len(expected):
+			fmt.Fprintf(&b, "  [%d] unexpected: %#v\n", i, actual[i])
+		case !reflect.DeepEqual(expected[i], actual[i]):
+			fmt.Fprintf(&b, "  [%d] expected: %#v\n  [%d]      got: %#v\n", i, expected[i], i, actual[i])
+		}
+	}
+	return b.String()
+}
+
+func formatEvents(evs []evoke.Event) string {
+	parts := make([]string, len(evs))
+	for i, e := range evs {
+		parts[i] = fmt.Sprintf("%#v", e)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,201 @@
+package evoketest
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+type accountOpened struct{ ID uuid.UUID }
+
+type deposited struct {
+	ID     uuid.UUID
+	Amount int
+}
+
+type deposit struct {
+	ID     uuid.UUID
+	Amount int
+}
+
+func (c deposit) AggregateID() uuid.UUID { return c.ID }
+
+var errNotOpen = errors.New("account not open")
+
+// account takes deposits once opened, ignoring deposits of nothing
+type account struct {
+	open    bool
+	balance int
+}
+
+func (a *account) Apply(e evoke.Event) error {
+	switch e := e.(type) {
+	case accountOpened:
+		a.open = true
+	case deposited:
+		a.balance += e.Amount
+	default:
+		return fmt.Errorf("unexpected event %T", e)
+	}
+	return nil
+}
+
+func (a *account) HandleCommand(cmd evoke.Command) ([]evoke.Event, error) {
+	d := cmd.(deposit)
+	if !a.open {
+		return nil, fmt.Errorf("deposit: %w", errNotOpen)
+	}
+	if d.Amount == 0 {
+		return nil, nil
+	}
+	return []evoke.Event{deposited{ID: d.ID, Amount: d.Amount}}, nil
+}
+
+// fakeT records the failure a scenario reports instead of failing the test
+// running it
+type fakeT struct {
+	testing.TB
+	failure string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Fatalf(format string, args ...any) {
+	t.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// check runs fn against a fakeT, returning the failure it reported
+func check(t *testing.T, fn func(testing.TB)) string {
+	ft := &fakeT{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ft)
+	}()
+	<-done
+	return ft.failure
+}
+
+func TestScenario(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name string
+		then func(testing.TB)
+		// fails is part of the failure expected, or empty if the
+		// scenario should pass
+		fails string
+	}{
+		{
+			name: "events",
+			then: func(t testing.TB) {
+				For(&account{}).Given(accountOpened{ID: id}).When(deposit{ID: id, Amount: 5}).
+					ThenEvents(t, deposited{ID: id, Amount: 5})
+			},
+		},
+		{
+			name: "other events",
+			then: func(t testing.TB) {
+				For(&account{}).Given(accountOpened{ID: id}).When(deposit{ID: id, Amount: 5}).
+					ThenEvents(t, deposited{ID: id, Amount: 6})
+			},
+			fails: "events mismatch",
+		},
+		{
+			name: "missing event",
+			then: func(t testing.TB) {
+				For(&account{}).Given(accountOpened{ID: id}).When(deposit{ID: id, Amount: 5}).
+					ThenEvents(t, deposited{ID: id, Amount: 5}, deposited{ID: id, Amount: 1})
+			},
+			fails: "[1] missing",
+		},
+		{
+			name: "no events",
+			then: func(t testing.TB) {
+				For(&account{}).Given(accountOpened{ID: id}).When(deposit{ID: id}).ThenNoEvents(t)
+			},
+		},
+		{
+			name: "unexpected events",
+			then: func(t testing.TB) {
+				For(&account{}).Given(accountOpened{ID: id}).When(deposit{ID: id, Amount: 1}).ThenNoEvents(t)
+			},
+			fails: "[0] unexpected",
+		},
+		{
+			name: "error",
+			then: func(t testing.TB) {
+				For(&account{}).When(deposit{ID: id, Amount: 5}).ThenError(t, errNotOpen)
+			},
+		},
+		{
+			name: "any error",
+			then: func(t testing.TB) {
+				For(&account{}).When(deposit{ID: id, Amount: 5}).ThenError(t, nil)
+			},
+		},
+		{
+			name: "other error",
+			then: func(t testing.TB) {
+				For(&account{}).When(deposit{ID: id, Amount: 5}).ThenError(t, errors.New("other"))
+			},
+			fails: "expected error",
+		},
+		{
+			name: "error expected",
+			then: func(t testing.TB) {
+				For(&account{}).Given(accountOpened{ID: id}).When(deposit{ID: id, Amount: 5}).ThenError(t, errNotOpen)
+			},
+			fails: "expected error, got events",
+		},
+		{
+			name: "unexpected error",
+			then: func(t testing.TB) {
+				For(&account{}).When(deposit{ID: id, Amount: 5}).ThenEvents(t, deposited{ID: id, Amount: 5})
+			},
+			fails: "unexpected error",
+		},
+		{
+			name: "history that doesn't apply",
+			then: func(t testing.TB) {
+				For(&account{}).Given(deposit{ID: id}).When(deposit{ID: id, Amount: 5}).ThenNoEvents(t)
+			},
+			fails: "Given",
+		},
+		{
+			name: "no command",
+			then: func(t testing.TB) {
+				For(&account{}).Given(accountOpened{ID: id}).ThenNoEvents(t)
+			},
+			fails: "no command",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := check(t, tt.then)
+			if tt.fails == "" && failure != "" {
+				t.Errorf("scenario failed: %s", failure)
+			}
+			if tt.fails != "" && !strings.Contains(failure, tt.fails) {
+				t.Errorf("scenario failure %q, want one containing %q", failure, tt.fails)
+			}
+		})
+	}
+}
+
+// Checking a scenario twice runs the command once.
+func TestScenarioRunsOnce(t *testing.T) {
+	id := uuid.New()
+	a := &account{}
+	s := For(a).Given(accountOpened{ID: id}, deposited{ID: id, Amount: 2}).When(deposit{ID: id, Amount: 5})
+	s.ThenEvents(t, deposited{ID: id, Amount: 5})
+	s.ThenEvents(t, deposited{ID: id, Amount: 5})
+	if a.balance != 2 {
+		t.Errorf("balance %d, want the 2 given", a.balance)
+	}
+}
@@ -0,0 +1,356 @@
+package evoketest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+// TestStore is an in-memory evoke.EventStore for tests. Sequences start at 1
+// and timestamps come from a replaceable clock, so recorded events are fully
+// deterministic. Appends can be made to fail on demand.
+type TestStore struct {
+	mu           sync.Mutex
+	events       []evoke.RecordedEvent
+	streams      map[uuid.UUID][]evoke.RecordedEvent
+	nextSequence int64
+	publishers   []evoke.RecordedEventPublisher
+	now          func() time.Time
+	appends      int
+	failures     map[int]error
+}
+
+func NewTestStore() *TestStore {
+	return &TestStore{
+		streams:      make(map[uuid.UUID][]evoke.RecordedEvent),
+		nextSequence: 1,
+		now:          time.Now,
+		failures:     make(map[int]error),
+	}
+}
+
+// SetClock replaces the source of RecordedAt timestamps.
+func (s *TestStore) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// FailAppend makes the nth call to Record (counting from 1 since the store
+// was created) fail with err without recording anything.
+func (s *TestStore) FailAppend(n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[n] = err
+}
+
+// FailNextAppend makes the next call to Record fail with err.
+func (s *TestStore) FailNextAppend(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[s.appends+1] = err
+}
+
+// Events returns every recorded event in sequence order.
+func (s *TestStore) Events() []evoke.RecordedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cpy := make([]evoke.RecordedEvent, len(s.events))
+	copy(cpy, s.events)
+	return cpy
+}
+
+// RecordedEvents returns just the event payloads, handy for comparing
+// against expected values.
+func (s *TestStore) RecordedEvents() []evoke.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	evs := make([]evoke.Event, len(s.events))
+	for i, rec := range s.events {
+		evs[i] = rec.Event
+	}
+	return evs
+}
+
+func (s *TestStore) RegisterPublisher(publisher evoke.RecordedEventPublisher, filters ...evoke.EventFilter) {
+	s.publishers = append(s.publishers, evoke.FilterPublisher(publisher, filters...))
+}
+
+// anyVersion is the expected version of appends that don't check it
+const anyVersion = -1
+
+func (s *TestStore) appendEvents(aggregateType string, aggregateID uuid.UUID, expected int64, evs []evoke.Event, md []evoke.Metadata) ([]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.appends++
+	if err, ok := s.failures[s.appends]; ok {
+		delete(s.failures, s.appends)
+		return nil, err
+	}
+
+	if len(evs) == 0 {
+		return nil, errors.New("no events to append")
+	}
+	for _, e := range evs {
+		if v, ok := e.(evoke.EventValidator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", evoke.ErrInvalidEvent, evoke.TypeName(e), err)
+			}
+		}
+	}
+
+	if version := int64(len(s.streams[aggregateID])); expected != anyVersion && version != expected {
+		return nil, fmt.Errorf("%w: stream %s is at version %d, not %d", evoke.ErrConcurrencyConflict, aggregateID, version, expected)
+	}
+
+	if stream := s.streams[aggregateID]; aggregateType == "" && len(stream) > 0 {
+		aggregateType = stream[len(stream)-1].AggregateType
+	}
+
+	out := make([]evoke.RecordedEvent, 0, len(evs))
+	for i, e := range evs {
+		rec := evoke.RecordedEvent{
+			Sequence:      s.nextSequence,
+			Version:       int64(len(s.streams[aggregateID]) + 1),
+			RecordedAt:    s.now().Unix(),
+			AggregateID:   aggregateID,
+			AggregateType: aggregateType,
+			Event:         e,
+			EventType:     evoke.TypeName(e),
+			SchemaVersion: evoke.SchemaVersionOf(e),
+		}
+		if md != nil {
+			rec.Metadata = md[i]
+		}
+		s.nextSequence++
+
+		s.events = append(s.events, rec)
+		s.streams[aggregateID] = append(s.streams[aggregateID], rec)
+
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *TestStore) Record(aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.RecordAs(context.Background(), "", aggregateID, evs)
+}
+
+// RecordAs records events to the stream of an aggregate of the given type.
+func (s *TestStore) RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []evoke.Event) error {
+	return s.RecordAtVersion(ctx, aggregateType, aggregateID, anyVersion, evs)
+}
+
+// RecordAtVersion records events like RecordAs, provided the stream is at
+// expectedVersion.
+func (s *TestStore) RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []evoke.Event) error {
+	recs, err := s.appendEvents(aggregateType, aggregateID, expectedVersion, evs, nil)
+	if err != nil {
+		return err
+	}
+	evoke.NoteRecorded(ctx, recs)
+	return s.publish(recs)
+}
+
+// RecordWithMetadata records events along with metadata of their own.
+func (s *TestStore) RecordWithMetadata(aggregateID uuid.UUID, evs []evoke.EventWithMeta) error {
+	events := make([]evoke.Event, len(evs))
+	md := make([]evoke.Metadata, len(evs))
+	for i, e := range evs {
+		events[i], md[i] = e.Event, e.Metadata
+	}
+	recs, err := s.appendEvents("", aggregateID, anyVersion, events, md)
+	if err != nil {
+		return err
+	}
+	return s.publish(recs)
+}
+
+func (s *TestStore) publish(recs []evoke.RecordedEvent) error {
+	for _, rec := range recs {
+		for _, p := range s.publishers {
+			err := p.Publish(rec, false)
+			if err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *TestStore) MustRecord(aggregateID uuid.UUID, evs []evoke.Event) {
+	err := s.Record(aggregateID, evs)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *TestStore) LoadStream(aggregateID uuid.UUID) ([]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream := s.streams[aggregateID]
+	cpy := make([]evoke.RecordedEvent, len(stream))
+	copy(cpy, stream)
+	return cpy, nil
+}
+
+// StreamInfo describes a stream without copying it.
+func (s *TestStore) StreamInfo(aggregateID uuid.UUID) (evoke.StreamInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := evoke.StreamInfo{AggregateID: aggregateID}
+	stream := s.streams[aggregateID]
+	if len(stream) == 0 {
+		return info, nil
+	}
+	first, last := stream[0], stream[len(stream)-1]
+	info.Exists = true
+	info.AggregateType = last.AggregateType
+	info.Version = last.Version
+	info.Events = last.Version
+	info.FirstRecordedAt = first.RecordedAt
+	info.LastRecordedAt = last.RecordedAt
+	return info, nil
+}
+
+func (s *TestStore) LoadStreams(ids []uuid.UUID) (map[uuid.UUID][]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	streams := make(map[uuid.UUID][]evoke.RecordedEvent, len(ids))
+	for _, id := range ids {
+		if stream := s.streams[id]; len(stream) > 0 {
+			streams[id] = append([]evoke.RecordedEvent(nil), stream...)
+		}
+	}
+	return streams, nil
+}
+
+func (s *TestStore) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]evoke.RecordedEvent, 0)
+	for _, rec := range s.streams[aggregateID] {
+		if rec.Version < fromVersion {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *TestStore) ReadAll(fromSeq int64, limit int) ([]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]evoke.RecordedEvent, 0)
+	for _, rec := range s.events {
+		if rec.Sequence < fromSeq {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *TestStore) LoadStreamBackward(aggregateID uuid.UUID, fromVersion int64, limit int) ([]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return backward(s.streams[aggregateID], func(rec evoke.RecordedEvent) bool {
+		return fromVersion <= 0 || rec.Version <= fromVersion
+	}, limit), nil
+}
+
+func (s *TestStore) ReadByType(eventType string, fromSeq int64, limit int) ([]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filter := evoke.EventFilter{EventTypes: []string{eventType}}
+	out := make([]evoke.RecordedEvent, 0)
+	for _, rec := range s.events {
+		if rec.Sequence < fromSeq || !filter.Matches(rec) {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *TestStore) ReadAllBackward(fromSeq int64, limit int) ([]evoke.RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return backward(s.events, func(rec evoke.RecordedEvent) bool {
+		return fromSeq <= 0 || rec.Sequence <= fromSeq
+	}, limit), nil
+}
+
+func backward(recs []evoke.RecordedEvent, from func(evoke.RecordedEvent) bool, limit int) []evoke.RecordedEvent {
+	out := make([]evoke.RecordedEvent, 0)
+	for i := len(recs) - 1; i >= 0; i-- {
+		if !from(recs[i]) {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, recs[i])
+	}
+	return out
+}
+
+func (s *TestStore) ReplayFrom(seq int64, handler evoke.RecordedEventHandlerFunc, filters ...evoke.EventFilter) error {
+	s.mu.Lock()
+	recs := make([]evoke.RecordedEvent, 0)
+	for _, rec := range s.events {
+		if rec.Sequence >= seq && evoke.MatchesAll(filters, rec) {
+			recs = append(recs, rec)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, rec := range recs {
+		err := handler(rec, true)
+		if err != nil {
+			return fmt.Errorf("callback error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Clock is a manually advanced clock for use with TestStore.SetClock.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+var _ evoke.EventStore = (*TestStore)(nil)
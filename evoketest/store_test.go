@@ -0,0 +1,331 @@
+package evoketest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rcy/evoke"
+)
+
+// publisherFunc adapts a function to evoke.RecordedEventPublisher
+type publisherFunc func(rec evoke.RecordedEvent, replay bool) error
+
+func (f publisherFunc) Publish(rec evoke.RecordedEvent, replay bool) error { return f(rec, replay) }
+
+func TestTestStoreIsDeterministic(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	record := func() []evoke.RecordedEvent {
+		s := NewTestStore()
+		clock := NewClock(start)
+		s.SetClock(clock.Now)
+		a, b := uuid.MustParse("00000000-0000-0000-0000-00000000000a"), uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+		s.MustRecord(a, []evoke.Event{accountOpened{ID: a}})
+		clock.Advance(time.Minute)
+		s.MustRecord(b, []evoke.Event{accountOpened{ID: b}, deposited{ID: b, Amount: 1}})
+		clock.Advance(time.Hour)
+		s.MustRecord(a, []evoke.Event{deposited{ID: a, Amount: 2}})
+		return s.Events()
+	}
+
+	first := record()
+	if !reflect.DeepEqual(first, record()) {
+		t.Fatalf("the same appends recorded differently")
+	}
+	wantSeqs := []int64{1, 2, 3, 4}
+	wantTimes := []int64{start.Unix(), start.Unix() + 60, start.Unix() + 60, start.Unix() + 3660}
+	for i, rec := range first {
+		if rec.Sequence != wantSeqs[i] || rec.RecordedAt != wantTimes[i] {
+			t.Errorf("event %d recorded at sequence %d, time %d; want %d, %d", i, rec.Sequence, rec.RecordedAt, wantSeqs[i], wantTimes[i])
+		}
+	}
+}
+
+func TestTestStoreFailAppend(t *testing.T) {
+	errDisk := errors.New("disk full")
+	s := NewTestStore()
+	id := uuid.New()
+	s.FailAppend(2, errDisk)
+	ev := []evoke.Event{accountOpened{ID: id}}
+
+	if err := s.Record(id, ev); err != nil {
+		t.Fatalf("append 1: %v", err)
+	}
+	if err := s.Record(id, ev); !errors.Is(err, errDisk) {
+		t.Fatalf("append 2: %v, want the failure set", err)
+	}
+	if err := s.Record(id, ev); err != nil {
+		t.Fatalf("append 3: %v", err)
+	}
+	s.FailNextAppend(errDisk)
+	if err := s.Record(id, ev); !errors.Is(err, errDisk) {
+		t.Fatalf("append 4: %v, want the failure set", err)
+	}
+
+	recs := s.Events()
+	if len(recs) != 2 || recs[1].Sequence != 2 {
+		t.Errorf("recorded %d events, the last at %d; failed appends should record nothing", len(recs), recs[len(recs)-1].Sequence)
+	}
+}
+
+func TestTestStorePublishes(t *testing.T) {
+	s := NewTestStore()
+	var published []evoke.Event
+	s.RegisterPublisher(publisherFunc(func(rec evoke.RecordedEvent, replay bool) error {
+		published = append(published, rec.Event)
+		return nil
+	}))
+	id := uuid.New()
+	s.MustRecord(id, []evoke.Event{accountOpened{ID: id}, deposited{ID: id, Amount: 3}})
+
+	if want := s.RecordedEvents(); !reflect.DeepEqual(published, want) {
+		t.Errorf("published %v, want %v", published, want)
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+	c.Advance(90 * time.Second)
+	if got := c.Now(); !got.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("Now %v after advancing 90s from %v", got, start)
+	}
+}
+
+// sequences returns the sequences of recs, in order
+func sequences(recs []evoke.RecordedEvent) []int64 {
+	seqs := make([]int64, len(recs))
+	for i, rec := range recs {
+		seqs[i] = rec.Sequence
+	}
+	return seqs
+}
+
+// interleaved returns a store with three events recorded to a and two to
+// b, alternating between the streams, so a holds sequences 1, 3, 4 and b 2, 5
+func interleaved() (s *TestStore, a, b uuid.UUID) {
+	s = NewTestStore()
+	a, b = uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{a, b, a, a, b} {
+		s.MustRecord(id, []evoke.Event{deposited{ID: id, Amount: 1}})
+	}
+	return s, a, b
+}
+
+func TestTestStoreLoadStreamFrom(t *testing.T) {
+	s, a, _ := interleaved()
+	for _, tt := range []struct {
+		fromVersion int64
+		limit       int
+		want        []int64
+	}{
+		{1, 0, []int64{1, 3, 4}},
+		{2, 0, []int64{3, 4}},
+		{1, 2, []int64{1, 3}},
+		{4, 0, []int64{}},
+	} {
+		recs, err := s.LoadStreamFrom(a, tt.fromVersion, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sequences(recs); !slices.Equal(got, tt.want) {
+			t.Errorf("LoadStreamFrom(%d, %d) loaded %v, want %v", tt.fromVersion, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTestStoreReadAll(t *testing.T) {
+	s, _, _ := interleaved()
+	for _, tt := range []struct {
+		fromSeq int64
+		limit   int
+		want    []int64
+	}{
+		{1, 0, []int64{1, 2, 3, 4, 5}},
+		{3, 2, []int64{3, 4}},
+		{5, 2, []int64{5}},
+		{6, 0, []int64{}},
+	} {
+		recs, err := s.ReadAll(tt.fromSeq, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sequences(recs); !slices.Equal(got, tt.want) {
+			t.Errorf("ReadAll(%d, %d) read %v, want %v", tt.fromSeq, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTestStoreBackwardReads(t *testing.T) {
+	s, a, _ := interleaved()
+	recs, err := s.LoadStreamBackward(a, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(recs); !slices.Equal(got, []int64{3, 1}) {
+		t.Errorf("LoadStreamBackward(2, 0) loaded %v, want [3 1]", got)
+	}
+	recs, err = s.ReadAllBackward(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(recs); !slices.Equal(got, []int64{5, 4}) {
+		t.Errorf("ReadAllBackward(0, 2) read %v, want [5 4]", got)
+	}
+}
+
+func TestTestStoreCategories(t *testing.T) {
+	s := NewTestStore()
+	a, b := uuid.New(), uuid.New()
+	ctx := context.Background()
+	if err := s.RecordAs(ctx, "account", a, []evoke.Event{accountOpened{ID: a}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordAs(ctx, "ledger", b, []evoke.Event{deposited{ID: b}}); err != nil {
+		t.Fatal(err)
+	}
+	s.MustRecord(a, []evoke.Event{deposited{ID: a, Amount: 1}})
+
+	var got []int64
+	err := s.ReplayFrom(1, func(rec evoke.RecordedEvent, replay bool) error {
+		if rec.AggregateType != "account" {
+			t.Errorf("event %d replayed from a %q", rec.Sequence, rec.AggregateType)
+		}
+		got = append(got, rec.Sequence)
+		return nil
+	}, evoke.InCategory("account"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("replayed %v of the account category, want [1 3]", got)
+	}
+}
+
+// overdraft is never valid
+type overdraft struct{ ID uuid.UUID }
+
+func (overdraft) Validate() error { return errors.New("overdrawn") }
+
+func TestTestStoreValidates(t *testing.T) {
+	s := NewTestStore()
+	id := uuid.New()
+	if err := s.Record(id, []evoke.Event{accountOpened{ID: id}, overdraft{ID: id}}); !errors.Is(err, evoke.ErrInvalidEvent) {
+		t.Fatalf("recording an invalid event: %v, want ErrInvalidEvent", err)
+	}
+	if recs := s.Events(); len(recs) != 0 {
+		t.Errorf("recorded %d events of an invalid append", len(recs))
+	}
+}
+
+func TestTestStoreRecordAtVersion(t *testing.T) {
+	s := NewTestStore()
+	id := uuid.New()
+	ctx := context.Background()
+	if err := s.RecordAtVersion(ctx, "", id, 0, []evoke.Event{accountOpened{ID: id}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordAtVersion(ctx, "", id, 0, []evoke.Event{deposited{ID: id, Amount: 1}}); !errors.Is(err, evoke.ErrConcurrencyConflict) {
+		t.Errorf("a stale append returned %v, want ErrConcurrencyConflict", err)
+	}
+	if recs, err := s.LoadStream(id); err != nil || len(recs) != 1 {
+		t.Errorf("loaded %d events, %v, want the stale append left out", len(recs), err)
+	}
+}
+
+func TestTestStoreStreamInfo(t *testing.T) {
+	s := NewTestStore()
+	clock := NewClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	s.SetClock(clock.Now)
+	id := uuid.New()
+	if info, err := s.StreamInfo(id); err != nil || info.Exists {
+		t.Errorf("info of a missing stream %+v, %v", info, err)
+	}
+	first := clock.Now().Unix()
+	s.MustRecord(id, []evoke.Event{accountOpened{ID: id}})
+	clock.Advance(time.Hour)
+	s.MustRecord(id, []evoke.Event{deposited{ID: id, Amount: 1}})
+	info, err := s.StreamInfo(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Exists || info.Version != 2 || info.Events != 2 || info.FirstRecordedAt != first || info.LastRecordedAt != first+3600 {
+		t.Errorf("info %+v", info)
+	}
+}
+
+// withdrawn is in its second generation
+type withdrawn struct {
+	ID     uuid.UUID
+	Amount int
+}
+
+func (withdrawn) SchemaVersion() int { return 2 }
+
+func TestTestStoreSchemaVersions(t *testing.T) {
+	s := NewTestStore()
+	id := uuid.New()
+	s.MustRecord(id, []evoke.Event{accountOpened{ID: id}, withdrawn{ID: id, Amount: 1}})
+	recs, err := s.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs[0].SchemaVersion != 1 || recs[1].SchemaVersion != 2 {
+		t.Errorf("loaded schema versions %d and %d, want 1 and 2", recs[0].SchemaVersion, recs[1].SchemaVersion)
+	}
+}
+
+func TestTestStoreRecordWithMetadata(t *testing.T) {
+	s := NewTestStore()
+	var published []evoke.RecordedEvent
+	s.RegisterPublisher(publisherFunc(func(rec evoke.RecordedEvent, _ bool) error {
+		published = append(published, rec)
+		return nil
+	}))
+	id := uuid.New()
+	err := s.RecordWithMetadata(id, []evoke.EventWithMeta{
+		{Event: accountOpened{ID: id}, Metadata: evoke.Metadata{"user_id": "u1"}},
+		{Event: deposited{ID: id, Amount: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.LoadStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Metadata["user_id"] != "u1" || recs[1].Metadata != nil {
+		t.Errorf("loaded %+v, want the first event's metadata", recs)
+	}
+	if len(published) != 2 || published[0].Metadata["user_id"] != "u1" {
+		t.Errorf("published %+v, want the metadata delivered", published)
+	}
+}
+
+func TestTestStoreLoadStreams(t *testing.T) {
+	s, a, b := interleaved()
+	streams, err := s.LoadStreams([]uuid.UUID{a, b, uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 || !slices.Equal(sequences(streams[a]), []int64{1, 3, 4}) || !slices.Equal(sequences(streams[b]), []int64{2, 5}) {
+		t.Errorf("loaded %v", streams)
+	}
+}
+
+func TestTestStoreReadByType(t *testing.T) {
+	s := NewTestStore()
+	id := uuid.New()
+	s.MustRecord(id, []evoke.Event{accountOpened{ID: id}, deposited{ID: id, Amount: 1}, deposited{ID: id, Amount: 2}})
+	recs, err := s.ReadByType("deposited", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(recs); !slices.Equal(got, []int64{3}) {
+		t.Errorf("read %v, want the deposit from sequence 3", got)
+	}
+}
@@ -0,0 +1,130 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Feed fans recorded events out to live subscribers. It registers itself as
+// a publisher on the store, and lets each subscriber start from any sequence
+// by replaying history before switching over to live events.
+type Feed struct {
+	store EventStore
+	mu    sync.Mutex
+	subs  map[*feedSub]struct{}
+}
+
+func NewFeed(store EventStore) *Feed {
+	f := &Feed{
+		store: store,
+		subs:  make(map[*feedSub]struct{}),
+	}
+	store.RegisterPublisher(f)
+	return f
+}
+
+type feedSub struct {
+	mu    sync.Mutex
+	queue []RecordedEvent
+	ready chan struct{}
+}
+
+func (s *feedSub) push(rec RecordedEvent) {
+	s.mu.Lock()
+	s.queue = append(s.queue, rec)
+	s.mu.Unlock()
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *feedSub) take() []RecordedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue
+	s.queue = nil
+	return q
+}
+
+func (f *Feed) Publish(rec RecordedEvent, replay bool) error {
+	if replay {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		sub.push(rec)
+	}
+	return nil
+}
+
+// SubscribeFrom calls fn for every event with a sequence >= seq, in order,
+// first from the store a page at a time and then live as events are
+// recorded. It returns when ctx is done or fn returns an error.
+func (f *Feed) SubscribeFrom(ctx context.Context, seq int64, fn func(RecordedEvent) error) error {
+	sub := &feedSub{ready: make(chan struct{}, 1)}
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.subs, sub)
+		f.mu.Unlock()
+	}()
+
+	// the store isn't held while fn writes to a slow client
+	last := seq - 1
+	catchUp := func() error {
+		for {
+			batch, err := f.store.ReadAll(last+1, deliveryBatch)
+			if err != nil {
+				return fmt.Errorf("read store: %w", err)
+			}
+			for _, rec := range batch {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				last = rec.Sequence
+				if err := fn(rec); err != nil {
+					return err
+				}
+			}
+			if len(batch) < deliveryBatch {
+				return nil
+			}
+		}
+	}
+
+	if err := catchUp(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sub.ready:
+		}
+		for _, rec := range sub.take() {
+			if rec.Sequence <= last {
+				continue
+			}
+			// concurrent Record calls can publish out of order, so
+			// fill any gap from the store before moving past it
+			if rec.Sequence > last+1 {
+				if err := catchUp(); err != nil {
+					return err
+				}
+				if rec.Sequence <= last {
+					continue
+				}
+			}
+			last = rec.Sequence
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+	}
+}
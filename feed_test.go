@@ -0,0 +1,63 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFeedDone = errors.New("feed done")
+
+func TestFeedSubscribeFrom(t *testing.T) {
+	const history = deliveryBatch*2 + 10
+	tests := []struct {
+		name string
+		from int64
+	}{
+		{name: "from the start", from: 1},
+		{name: "within a page", from: 300},
+		{name: "at the head", from: history},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			f := NewFeed(s)
+			id := NewID()
+			evs := make([]Event, history)
+			for i := range evs {
+				evs[i] = itemAdded{SKU: "a", Qty: i}
+			}
+			if err := s.Record(id, evs); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			var got []int64
+			err := f.SubscribeFrom(ctx, tt.from, func(rec RecordedEvent) error {
+				got = append(got, rec.Sequence)
+				if rec.Sequence == history+1 {
+					return errFeedDone
+				}
+				// appending from fn deadlocks unless the feed lets go of
+				// the store while it calls fn
+				if len(got) == 1 {
+					return s.Record(id, []Event{itemAdded{SKU: "live"}})
+				}
+				return nil
+			})
+			if !errors.Is(err, errFeedDone) {
+				t.Fatalf("SubscribeFrom: %v", err)
+			}
+			if want := history + 2 - tt.from; int64(len(got)) != want || got[0] != tt.from {
+				t.Fatalf("fed %d events from %d, want %d from %d", len(got), got[0], want, tt.from)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i] != got[i-1]+1 {
+					t.Fatalf("event %d followed %d", got[i], got[i-1])
+				}
+			}
+		})
+	}
+}
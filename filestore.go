@@ -1,12 +1,16 @@
 package evoke
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,10 +23,260 @@ type fileStore struct {
 	EventRegistry
 	mu         sync.Mutex
 	db         *sqlx.DB
-	publishers []RecordedEventPublisher
+	publishers map[string][]RecordedEventPublisher
+	encrypt    bool
+	compress   bool
+	// maxPayloadSize is the longest event JSON accepted, 0 for no limit
+	maxPayloadSize int
+	payloadBlobs   SegmentStore
+	binary         bool
+	hashChain      bool
+	keyProvider    KeyProvider
+
+	// table is the events table, "events" unless WithTableName is used
+	table  string
+	sqlite sqliteConfig
+
+	archiveFile    string
+	segments       SegmentStore
+	archiveColumns string
+	// retentionArchive receives the events ReapExpired archives, and reaped
+	// is whether it has removed any, so appends look up their versions
+	retentionArchive SegmentStore
+	reaped           bool
+	// eventsSource is what reads select from: the events table, or the
+	// archive stitched together with it
+	eventsSource string
+
+	stmts map[string]*sqlx.Stmt
+
+	watchInterval time.Duration
+	maxWALSize    int64
+	// group batches appends into shared commits, if WithGroupCommit is used
+	group *groupCommit
+	// readConns is the size of the read pool of WithReadPool, reader the
+	// pool once open
+	readConns int
+	reader    *sqlx.DB
+
+	inst   Instrumentation
+	tracer Tracer
+	logger Logger
+}
+
+func NewFileStore(dbFile string, opts ...FileStoreOption) (*fileStore, error) {
+	s := &fileStore{
+		publishers:    make(map[string][]RecordedEventPublisher),
+		table:         "events",
+		sqlite:        defaultSQLiteConfig(),
+		stmts:         make(map[string]*sqlx.Stmt),
+		watchInterval: 200 * time.Millisecond,
+		maxWALSize:    64 << 20,
+		inst:          nopInstrumentation{},
+		tracer:        nopTracer{},
+		logger:        slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.checkOptions(); err != nil {
+		return nil, err
+	}
+	// reads alias the table as events, so their clauses don't depend on its name
+	s.eventsSource = "events"
+	if s.table != "events" {
+		s.eventsSource = s.table + " as events"
+	}
+
+	db, err := openSQLite(dbFile, s.sqlite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.createTables(db); err != nil {
+		return nil, err
+	}
+
+	s.db = sqlx.NewDb(db, "sqlite3")
+
+	if s.archiveFile != "" {
+		if err := s.attachArchive(); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.segments != nil {
+		if err := s.createTierTables(); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.readConns > 0 && s.archiveFile == "" && dbFile != ":memory:" {
+		if s.reader, err = openReadPool(dbFile, s.sqlite, s.readConns); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// createTables creates the store's tables, migrating those of older stores
+func (s *fileStore) createTables(db *sql.DB) error {
+	if _, err := db.Exec(`
+		create table if not exists ` + s.table + ` (
+			sequence     integer primary key autoincrement,
+                        recorded_at  integer not null,
+                        aggregate_id text not null,
+                        event_type   text not null,
+                        event_json   text not null,
+                        version      integer not null default 0,
+                        tenant_id    text not null default '',
+                        aggregate_type text not null default '',
+                        metadata     text not null default ''
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create events table: %w", err)
+	}
+
+	if err := migrateVersionColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateTenantColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := createKeyTable(db, s.table); err != nil {
+		return err
+	}
+
+	if err := createStreamStateTable(db); err != nil {
+		return err
+	}
+
+	if err := createSnapshotTable(db); err != nil {
+		return err
+	}
+
+	if err := createTruncationTable(db); err != nil {
+		return err
+	}
+
+	if err := s.createRetentionTables(db); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`create index if not exists ` + s.table + `_stream_sequence on ` + s.table + `(tenant_id, aggregate_id, sequence)`); err != nil {
+		return fmt.Errorf("failed to create stream index: %w", err)
+	}
+
+	if err := migrateAggregateTypeColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := createEventTypeIndex(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateMetadataColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateSchemaVersionColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateCompressedColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migratePayloadRefColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateEventDataColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateHashColumns(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateKeyIDColumn(db, s.table); err != nil {
+		return err
+	}
+
+	if err := migrateCorrelationColumns(db, s.table); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`
+		create table if not exists idempotency_keys (
+			key          text primary key,
+			processed_at integer not null
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create idempotency_keys table: %w", err)
+	}
+
+	if err := migrateIdempotencyTables(db); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`
+		create table if not exists command_log (
+			id             text primary key,
+			command_type   text not null,
+			aggregate_id   text not null,
+			payload        text not null,
+			issuer         text not null,
+			correlation_id text not null,
+			sent_at        integer not null, -- unix milliseconds
+			duration       integer not null, -- nanoseconds
+			error          text not null
+		);
+		create index if not exists command_log_aggregate on command_log(aggregate_id, sent_at);
+		create table if not exists command_log_events (
+			sequence   integer primary key,
+			command_id text not null
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create command_log tables: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		create table if not exists checkpoints (
+			name     text primary key,
+			sequence integer not null,
+			saved_at integer not null
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create checkpoints table: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		create table if not exists consumer_members (
+			group_name text not null,
+			member     text not null,
+			expires_at integer not null, -- unix milliseconds
+			primary key (group_name, member)
+		);
+		create table if not exists consumer_partitions (
+			group_name text not null,
+			partition  integer not null,
+			member     text not null,
+			expires_at integer not null, -- unix milliseconds
+			primary key (group_name, partition)
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create consumer group tables: %w", err)
+	}
+	return nil
 }
 
-func NewFileStore(dbFile string) (*fileStore, error) {
+// openSQLite opens (creating if needed) a sqlite database tuned for a single
+// writer
+func openSQLite(dbFile string, cfg sqliteConfig) (*sql.DB, error) {
 	err := os.MkdirAll(filepath.Dir(dbFile), 0755)
 	if err != nil {
 		return nil, err
@@ -37,60 +291,205 @@ func NewFileStore(dbFile string) (*fileStore, error) {
 	db.SetMaxOpenConns(1) // SQLite supports one writer, so cap to 1
 	db.SetMaxIdleConns(1)
 
-	// Enable WAL mode for better concurrency and durability
-	if _, err := db.Exec(`PRAGMA journal_mode = WAL;`); err != nil {
-		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	// WAL by default, for better concurrency and durability
+	if _, err := db.Exec(`PRAGMA journal_mode = ` + string(cfg.journalMode) + `;`); err != nil {
+		return nil, fmt.Errorf("failed to set journal_mode: %w", err)
 	}
 
-	// Optional performance pragmas (tweak based on needs):
-	if _, err := db.Exec(`PRAGMA synchronous = NORMAL;`); err != nil {
+	if _, err := db.Exec(`PRAGMA synchronous = ` + string(cfg.synchronous) + `;`); err != nil {
 		return nil, fmt.Errorf("failed to set synchronous: %w", err)
 	}
 	if _, err := db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
 		return nil, fmt.Errorf("failed to enable foreign_keys: %w", err)
 	}
 
-	if _, err := db.Exec(`
-		create table if not exists events (
-			sequence     integer primary key autoincrement,
-                        recorded_at  integer not null,
-                        aggregate_id text not null,
-                        event_type   text not null,
-                        event_json   text not null
-		);
-	`); err != nil {
-		return nil, fmt.Errorf("failed to create events table: %w", err)
+	// Wait for other connections (e.g. a Scheduler sharing the file)
+	// rather than failing immediately with SQLITE_BUSY
+	if _, err := db.Exec(fmt.Sprintf(`PRAGMA busy_timeout = %d;`, cfg.busyTimeout.Milliseconds())); err != nil {
+		return nil, fmt.Errorf("failed to set busy_timeout: %w", err)
 	}
 
-	sqlxDB := sqlx.NewDb(db, "sqlite3")
+	return db, nil
+}
 
-	return &fileStore{
-		db:         sqlxDB,
-		publishers: []RecordedEventPublisher{},
-	}, nil
+// hasColumn reports whether table already has the named column
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(`select name from pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// migrateVersionColumn adds per-stream versions to stores created before
+// they existed, numbering each stream's events in sequence order
+func migrateVersionColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "version")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column version integer not null default 0`); err != nil {
+			return fmt.Errorf("failed to add version column: %w", err)
+		}
+		if _, err := db.Exec(`
+			update ` + table + ` set version = (
+				select count(*) from ` + table + ` e
+				where e.aggregate_id = ` + table + `.aggregate_id and e.sequence <= ` + table + `.sequence
+			)`); err != nil {
+			return fmt.Errorf("failed to number stream versions: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateAggregateTypeColumn adds stream categories to stores created
+// before they existed; their streams are uncategorized
+func migrateAggregateTypeColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "aggregate_type")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column aggregate_type text not null default ''`); err != nil {
+			return fmt.Errorf("failed to add aggregate_type column: %w", err)
+		}
+	}
+	if _, err := db.Exec(`create index if not exists ` + table + `_category_sequence on ` + table + `(tenant_id, aggregate_type, sequence)`); err != nil {
+		return fmt.Errorf("failed to create category index: %w", err)
+	}
+	return nil
+}
+
+// migrateMetadataColumn adds event metadata to stores created before it
+// existed
+func migrateMetadataColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "metadata")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column metadata text not null default ''`); err != nil {
+			return fmt.Errorf("failed to add metadata column: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSchemaVersionColumn adds payload schema versions to stores created
+// before them. Their events are all generation 1.
+func migrateSchemaVersionColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "schema_version")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column schema_version integer not null default 1`); err != nil {
+			return fmt.Errorf("failed to add schema_version column: %w", err)
+		}
+	}
+	return nil
 }
 
+// Close waits for the append or read in progress and closes the store; see
+// Shutdown.
 func (s *fileStore) Close() error {
-	return s.db.Close()
+	return s.Shutdown(context.Background())
+}
+
+// RegisterPublisher registers a publisher for events of the default tenant.
+// Use ForTenant to publish another tenant's events.
+func (s *fileStore) RegisterPublisher(publisher RecordedEventPublisher, filters ...EventFilter) {
+	s.registerPublisher("", FilterPublisher(publisher, filters...))
 }
 
-func (s *fileStore) RegisterPublisher(publisher RecordedEventPublisher) {
-	s.publishers = append(s.publishers, publisher)
+func (s *fileStore) registerPublisher(tenantID string, publisher RecordedEventPublisher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishers[tenantID] = append(s.publishers[tenantID], publisher)
 }
 
-// Return all events, not really a long term method here
+// Return all events
+//
+// Deprecated: use ReadAll, which pages
 func (s *fileStore) DebugEvents() ([]RecordedEvent, error) {
-	var events []RecordedEvent
-	s.db.Select(&events, `select * from events order by sequence asc`)
-	return events, nil
+	return s.ReadAll(0, 0)
+}
+
+func (s *fileStore) ReadAll(fromSeq int64, limit int) ([]RecordedEvent, error) {
+	return s.readAll("", fromSeq, limit)
+}
+
+func (s *fileStore) readAll(tenantID string, fromSeq int64, limit int) ([]RecordedEvent, error) {
+	// the database is read before the segments, so events tiered in
+	// between are found in the latter
+	dbLimit := limit
+	if dbLimit <= 0 {
+		dbLimit = -1 // sqlite for no limit
+	}
+	recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and sequence >= ? and `+visibleStreams+` order by sequence asc limit ?`,
+		tenantID, fromSeq, dbLimit)
+	if err != nil {
+		return nil, err
+	}
+	tiered, err := s.segmentPage(tenantID, fromSeq, limit, nil)
+	if err != nil {
+		return nil, err
+	}
+	return stitchTiered(tiered, recs, limit), nil
+}
+
+// selectRecords runs a cached query over events and decodes the rows it
+// returns. Callers hold s.mu.
+func (s *fileStore) selectRecords(query string, args ...any) ([]RecordedEvent, error) {
+	stmt, err := s.prepared(nil, query)
+	if err != nil {
+		return nil, err
+	}
+	var rows []dbEvent
+	err = stmt.Select(&rows, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select from events: %w", err)
+	}
+	return s.decodeRows(s.db, rows)
 }
 
 type dbEvent struct {
-	Sequence    int64     `db:"sequence"`
-	RecordedAt  int64     `db:"recorded_at"`
-	AggregateID uuid.UUID `db:"aggregate_id"`
-	EventJSON   string    `db:"event_json"`
-	EventType   string    `db:"event_type"`
+	Sequence      int64     `db:"sequence"`
+	RecordedAt    int64     `db:"recorded_at"`
+	AggregateID   uuid.UUID `db:"aggregate_id"`
+	EventJSON     string    `db:"event_json"`
+	EventType     string    `db:"event_type"`
+	Version       int64     `db:"version"`
+	TenantID      string    `db:"tenant_id"`
+	Encrypted     bool      `db:"encrypted"`
+	AggregateType string    `db:"aggregate_type"`
+	Metadata      string    `db:"metadata"`
+	SchemaVersion int       `db:"schema_version"`
+	Compressed    bool      `db:"compressed"`
+	PayloadRef    string    `db:"payload_ref"`
+	EventData     []byte    `db:"event_data"`
+	PrevHash      string    `db:"prev_hash"`
+	Hash          string    `db:"hash"`
+	KeyID         string    `db:"key_id"`
+	CorrelationID string    `db:"correlation_id"`
+	CausationSeq  int64     `db:"causation_seq"`
+}
+
+// schemaVersion is the row's schema version; rows tiered before the column
+// existed have none
+func (e *dbEvent) schemaVersion() int {
+	return max(e.SchemaVersion, 1)
 }
 
 func (e *dbEvent) UnmarshalFromRegistry(s EventRegisterer) (RecordedEvent, error) {
@@ -98,59 +497,284 @@ func (e *dbEvent) UnmarshalFromRegistry(s EventRegisterer) (RecordedEvent, error
 	if err != nil {
 		return RecordedEvent{}, fmt.Errorf("UnmarshalEvent: %w", err)
 	}
+	md, err := decodeMetadata(e.Metadata)
+	if err != nil {
+		return RecordedEvent{}, err
+	}
 
 	return RecordedEvent{
-		Sequence:    e.Sequence,
-		RecordedAt:  e.RecordedAt,
-		AggregateID: e.AggregateID,
-		EventType:   e.EventType,
-		Event:       event,
+		Sequence:      e.Sequence,
+		RecordedAt:    e.RecordedAt,
+		AggregateID:   e.AggregateID,
+		EventType:     e.EventType,
+		Event:         event,
+		Version:       e.Version,
+		TenantID:      e.TenantID,
+		AggregateType: e.AggregateType,
+		Metadata:      md,
+		SchemaVersion: e.schemaVersion(),
 	}, nil
 }
 
-func (s *fileStore) appendEvents(aggregateID uuid.UUID, evs []Event) ([]RecordedEvent, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// appendEvents appends events to a stream in a transaction of their own,
+// saving claim with them if it isn't nil
+func (s *fileStore) appendEvents(tenantID, aggregateType string, aggregateID uuid.UUID, expected int64, evs []Event, md Metadata, eventMD []Metadata, claim *idempotencyClaim) (recs []RecordedEvent, err error) {
+	start := time.Now()
+	defer func() {
+		s.inst.EventsAppended(len(evs), time.Since(start), err)
+	}()
 
 	if len(evs) == 0 {
 		return nil, errors.New("no events to append")
 	}
+	if s.group != nil {
+		return s.appendGrouped(tenantID, aggregateType, aggregateID, expected, evs, md, eventMD, claim)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.prepareAppend(len(evs)); err != nil {
+		return nil, err
+	}
+
+	// all or nothing: a crash mid-batch must not leave half a command applied
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	recs, err = s.insertEvents(tx, tenantID, aggregateType, aggregateID, expected, evs, md, eventMD)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveClaim(tx, claim, recs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return recs, nil
+}
 
-	out := make([]RecordedEvent, 0, len(evs))
+// rows per insert statement, keeping well under sqlite's bound parameter limit
+const insertBatchSize = 500
+
+// insertEvents appends evs to a stream within tx, returning them as
+// recorded, in sequence order. The events are those given rather than
+// decoded back from their rows, as values where given pointers. An empty
+// aggregateType keeps the type the stream already has; md is stored with
+// every event, merged with the event's own metadata from eventMD if given.
+// Unless expected is anyVersion the stream must be at that version. Callers
+// hold s.mu.
+func (s *fileStore) insertEvents(tx *sqlx.Tx, tenantID, aggregateType string, aggregateID uuid.UUID, expected int64, evs []Event, md Metadata, eventMD []Metadata) ([]RecordedEvent, error) {
+	// the events aren't decoded back, so one that couldn't be must not be
+	// recorded
 	for _, e := range evs {
-		eventBytes, err := json.Marshal(e)
+		if !s.registered(e) {
+			return nil, fmt.Errorf("%w %q (hint call evoke.RegisterEvent(...)", ErrEventNotRegistered, TypeName(e))
+		}
+	}
+
+	if err := s.checkStreamWritable(tx, tenantID, aggregateID); err != nil {
+		return nil, err
+	}
+
+	metadata, err := encodeMetadata(md)
+	if err != nil {
+		return nil, err
+	}
+
+	versionStmt, err := s.prepared(tx, s.streamVersionQuery())
+	if err != nil {
+		return nil, err
+	}
+	var head struct {
+		Version       int64  `db:"version"`
+		AggregateType string `db:"aggregate_type"`
+	}
+	err = versionStmt.Get(&head, tenantID, aggregateID.String())
+	if err != nil {
+		return nil, fmt.Errorf("select stream version: %w", err)
+	}
+	if expected != anyVersion && head.Version != expected {
+		return nil, fmt.Errorf("%w: stream %s is at version %d, not %d", ErrConcurrencyConflict, aggregateID, head.Version, expected)
+	}
+	version := head.Version
+	if aggregateType == "" {
+		aggregateType = head.AggregateType
+	}
+
+	var key []byte
+	var keyID string
+	if s.encrypt {
+		key, err = s.aggregateKey(tx, tenantID, aggregateID, version+1)
 		if err != nil {
-			return nil, fmt.Errorf("Marshal: %w", err)
+			return nil, err
 		}
+		keyID, key, err = s.currentPayloadKey(key)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		var row dbEvent
-		err = s.db.Get(&row, `insert into events(aggregate_id, recorded_at, event_json, event_type) values(?,?,?,?) returning *`,
-			aggregateID,
-			time.Now().Unix(),
-			string(eventBytes),
-			TypeName(e))
+	recordedAt := time.Now().Unix()
+	rows := make([]dbEvent, len(evs))
+	recs := make([]RecordedEvent, len(evs))
+	for start := 0; start < len(evs); start += insertBatchSize {
+		batch := evs[start:min(start+insertBatchSize, len(evs))]
+
+		args := make([]any, 0, len(batch)*16)
+		for i, e := range batch {
+			eventBytes, err := s.MarshalEvent(e)
+			if err != nil {
+				return nil, fmt.Errorf("Marshal: %w", err)
+			}
+			if err := s.ValidateEvent(e, eventBytes); err != nil {
+				return nil, err
+			}
+
+			payload, err := s.storePayload(key, aggregateID, s.EventName(e), eventBytes)
+			if err != nil {
+				return nil, err
+			}
+
+			own, ownMetadata := md, metadata
+			if eventMD != nil && len(eventMD[start+i]) > 0 {
+				own = mergeMetadata(md, eventMD[start+i])
+				ownMetadata, err = encodeMetadata(own)
+				if err != nil {
+					return nil, err
+				}
+			}
+			correlationID, causationSeq := correlationColumns(own)
+
+			var recMD Metadata
+			if len(own) > 0 {
+				recMD = maps.Clone(own)
+			}
+
+			version++
+			row := &rows[start+i]
+			*row = dbEvent{
+				TenantID:      tenantID,
+				AggregateID:   aggregateID,
+				RecordedAt:    recordedAt,
+				EventJSON:     payload.text,
+				EventType:     s.EventName(e),
+				Version:       version,
+				Encrypted:     s.encrypt,
+				AggregateType: aggregateType,
+				Metadata:      ownMetadata,
+				SchemaVersion: SchemaVersionOf(e),
+				Compressed:    payload.compressed,
+				PayloadRef:    payload.ref,
+				EventData:     payload.data,
+				KeyID:         keyID,
+				CorrelationID: correlationID,
+				CausationSeq:  causationSeq,
+			}
+			args = append(args, row.TenantID, row.AggregateID, row.RecordedAt, row.EventJSON, row.EventType, row.Version, row.Encrypted, row.AggregateType, row.Metadata, row.SchemaVersion, row.Compressed, row.PayloadRef, row.EventData, row.KeyID, row.CorrelationID, row.CausationSeq)
+			recs[start+i] = RecordedEvent{
+				Version:       version,
+				RecordedAt:    recordedAt,
+				AggregateID:   aggregateID,
+				AggregateType: aggregateType,
+				TenantID:      tenantID,
+				EventType:     row.EventType,
+				Event:         underlying(e),
+				Metadata:      recMD,
+				SchemaVersion: row.schemaVersion(),
+			}
+		}
+
+		query := s.insertEventsQuery(len(batch))
+		var res sql.Result
+		if len(batch) <= maxCachedInsertRows {
+			var stmt *sqlx.Stmt
+			stmt, err = s.prepared(tx, query)
+			if err != nil {
+				return nil, err
+			}
+			res, err = stmt.Exec(args...)
+		} else {
+			res, err = tx.Exec(query, args...)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("insert into events: %w", err)
 		}
-
-		rec, err := row.UnmarshalFromRegistry(s)
+		// a multi-row insert numbers its rows in order, ending at the last
+		// insert id
+		last, err := res.LastInsertId()
 		if err != nil {
-			return nil, fmt.Errorf("getRecordedEvent: %w", err)
+			return nil, fmt.Errorf("insert into events: %w", err)
+		}
+		first := last - int64(len(batch)) + 1
+		for i := range batch {
+			rows[start+i].Sequence = first + int64(i)
+			recs[start+i].Sequence = first + int64(i)
 		}
+	}
 
-		out = append(out, rec)
+	if err := s.chainRows(tx, rows); err != nil {
+		return nil, err
 	}
-	return out, nil
+	return recs, nil
 }
 
 func (s *fileStore) Record(aggregateID uuid.UUID, evs []Event) error {
-	recs, err := s.appendEvents(aggregateID, evs)
+	return s.record(context.Background(), "", "", aggregateID, anyVersion, evs, nil)
+}
+
+// RecordWithMetadata records events along with metadata of their own.
+func (s *fileStore) RecordWithMetadata(aggregateID uuid.UUID, evs []EventWithMeta) error {
+	events, md := splitMetadata(evs)
+	return s.record(context.Background(), "", "", aggregateID, anyVersion, events, md)
+}
+
+// RecordAs records events to the stream of an aggregate of the given type,
+// as part of the trace in ctx.
+func (s *fileStore) RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []Event) error {
+	return s.record(ctx, "", aggregateType, aggregateID, anyVersion, evs, nil)
+}
+
+// RecordAtVersion records events like RecordAs, provided the stream is at
+// expectedVersion.
+func (s *fileStore) RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []Event) error {
+	return s.record(ctx, "", aggregateType, aggregateID, expectedVersion, evs, nil)
+}
+
+func (s *fileStore) record(ctx context.Context, tenantID, aggregateType string, aggregateID uuid.UUID, expected int64, evs []Event, eventMD []Metadata) (err error) {
+	ctx, end := s.tracer.Start(ctx, "evoke.append")
+	defer func() { end(err) }()
+
+	md := Metadata{}
+	s.tracer.Inject(ctx, md)
+	correlate(ctx, md)
+
+	claim := claimIn(ctx, s)
+	recs, err := s.appendEvents(tenantID, aggregateType, aggregateID, expected, evs, md, eventMD, claim)
 	if err != nil {
 		return err
 	}
+	claim.markSaved()
+	s.logger.Debug("evoke: recorded events", "tenant", tenantID, "aggregate_id", aggregateID, "events", len(recs),
+		"first_sequence", recs[0].Sequence, "last_sequence", recs[len(recs)-1].Sequence)
+	NoteRecorded(ctx, recs)
+
+	return s.publish(tenantID, recs)
+}
+
+// publish hands just recorded events to the tenant's publishers
+func (s *fileStore) publish(tenantID string, recs []RecordedEvent) error {
+	s.mu.Lock()
+	publishers := s.publishers[tenantID]
+	s.mu.Unlock()
 
 	for _, rec := range recs {
-		for _, p := range s.publishers {
+		for _, p := range publishers {
 			err := p.Publish(rec, false)
 			if err != nil {
 				return fmt.Errorf("publish: %w", err)
@@ -169,50 +793,251 @@ func (s *fileStore) MustRecord(aggregateID uuid.UUID, evs []Event) {
 }
 
 func (s *fileStore) LoadStream(aggregateID uuid.UUID) ([]RecordedEvent, error) {
-	fmt.Println("DEBUGX 3zqn 6 LoadStream", aggregateID)
+	return s.loadStream("", aggregateID)
+}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	var rows []dbEvent
-	err := s.db.Select(&rows, `select * from events where aggregate_id = ? order by sequence asc`, aggregateID.String())
+func (s *fileStore) loadStream(tenantID string, aggregateID uuid.UUID) ([]RecordedEvent, error) {
+	recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and aggregate_id = ? and `+visibleStreams+` order by sequence asc`,
+		tenantID, aggregateID.String())
 	if err != nil {
-		return nil, fmt.Errorf("select from events: %w", err)
+		return nil, err
+	}
+	recs, err = s.withSegmentStream(tenantID, aggregateID, 1, 0, recs)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug("evoke: loaded stream", "tenant", tenantID, "aggregate_id", aggregateID, "events", len(recs))
+
+	return recs, nil
+}
+
+// streams per query of LoadStreams, keeping under sqlite's bound parameter
+// limit
+const loadStreamsBatchSize = 500
+
+// LoadStreams loads the streams of several aggregates with a query per
+// batch of them, rather than one per aggregate.
+func (s *fileStore) LoadStreams(ids []uuid.UUID) (map[uuid.UUID][]RecordedEvent, error) {
+	return s.loadStreams("", ids)
+}
+
+func (s *fileStore) loadStreams(tenantID string, ids []uuid.UUID) (map[uuid.UUID][]RecordedEvent, error) {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	ids = unique
+
+	streams := make(map[uuid.UUID][]RecordedEvent, len(ids))
+	for start := 0; start < len(ids); start += loadStreamsBatchSize {
+		batch := ids[start:min(start+loadStreamsBatchSize, len(ids))]
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, tenantID)
+		for _, id := range batch {
+			args = append(args, id.String())
+		}
+		recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and aggregate_id in (?`+strings.Repeat(",?", len(batch)-1)+`) and `+visibleStreams+` order by sequence asc`,
+			args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range recs {
+			streams[rec.AggregateID] = append(streams[rec.AggregateID], rec)
+		}
 	}
 
-	recs := make([]RecordedEvent, len(rows))
-	for i, row := range rows {
-		rec, err := row.UnmarshalFromRegistry(s)
+	for _, id := range ids {
+		recs, err := s.withSegmentStream(tenantID, id, 1, 0, streams[id])
 		if err != nil {
-			return nil, fmt.Errorf("getRecordedEvent: %w", err)
+			return nil, err
+		}
+		if len(recs) > 0 {
+			streams[id] = recs
 		}
-		recs[i] = rec
 	}
 
-	fmt.Println("DEBUGX xWax 7 LoadStream", len(recs))
+	s.logger.Debug("evoke: loaded streams", "tenant", tenantID, "streams", len(ids))
+
+	return streams, nil
+}
+
+func (s *fileStore) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	return s.loadStreamFrom("", aggregateID, fromVersion, limit)
+}
+
+func (s *fileStore) loadStreamFrom(tenantID string, aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	if limit <= 0 {
+		limit = -1 // sqlite for no limit
+	}
+
+	recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and aggregate_id = ? and version >= ? and `+visibleStreams+` order by sequence asc limit ?`,
+		tenantID, aggregateID.String(), fromVersion, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.withSegmentStream(tenantID, aggregateID, fromVersion, limit, recs)
+}
+
+// LoadStreamAsOf returns the events of a stream recorded by asOf.
+func (s *fileStore) LoadStreamAsOf(aggregateID uuid.UUID, asOf AsOf) ([]RecordedEvent, error) {
+	return s.loadStreamAsOf("", aggregateID, asOf)
+}
+
+func (s *fileStore) loadStreamAsOf(tenantID string, aggregateID uuid.UUID, asOf AsOf) ([]RecordedEvent, error) {
+	toSeq, toTime := int64(math.MaxInt64), int64(math.MaxInt64)
+	if asOf.Sequence > 0 {
+		toSeq = asOf.Sequence
+	}
+	if !asOf.Time.IsZero() {
+		toTime = asOf.Time.Unix()
+	}
+
+	recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and aggregate_id = ? and sequence <= ? and recorded_at <= ? and `+visibleStreams+` order by sequence asc`,
+		tenantID, aggregateID.String(), toSeq, toTime)
+	if err != nil {
+		return nil, err
+	}
+	recs, err = s.withSegmentStream(tenantID, aggregateID, 1, 0, recs)
+	if err != nil {
+		return nil, err
+	}
+	// tiered events are all older than stored ones, though maybe not by asOf
+	return asOf.filter(recs), nil
+}
+
+func (s *fileStore) LoadStreamBackward(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	return s.loadStreamBackward("", aggregateID, fromVersion, limit)
+}
+
+func (s *fileStore) loadStreamBackward(tenantID string, aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	if fromVersion <= 0 {
+		fromVersion = math.MaxInt64
+	}
+	if limit <= 0 {
+		limit = -1 // sqlite for no limit
+	}
+
+	recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and aggregate_id = ? and version <= ? and `+visibleStreams+` order by sequence desc limit ?`,
+		tenantID, aggregateID.String(), fromVersion, limit)
+	if err != nil || (limit > 0 && len(recs) == limit) {
+		return recs, err
+	}
 
+	// tiered events are all older than stored ones; any read above that
+	// have since been tiered are only taken once
+	tiered, err := s.segmentStream(tenantID, aggregateID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) > 0 {
+		fromVersion = recs[len(recs)-1].Version - 1
+	}
+	for i := len(tiered) - 1; i >= 0 && (limit < 0 || len(recs) < limit); i-- {
+		if tiered[i].Version <= fromVersion {
+			recs = append(recs, tiered[i])
+		}
+	}
 	return recs, nil
 }
 
-func (s *fileStore) ReplayFrom(seq int64, handler RecordedEventHandlerFunc) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *fileStore) ReadAllBackward(fromSeq int64, limit int) ([]RecordedEvent, error) {
+	return s.readAllBackward("", fromSeq, limit)
+}
+
+func (s *fileStore) readAllBackward(tenantID string, fromSeq int64, limit int) ([]RecordedEvent, error) {
+	if fromSeq <= 0 {
+		fromSeq = math.MaxInt64
+	}
+	if limit <= 0 {
+		limit = -1 // sqlite for no limit
+	}
+
+	recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and sequence <= ? and `+visibleStreams+` order by sequence desc limit ?`,
+		tenantID, fromSeq, limit)
+	if err != nil || (limit > 0 && len(recs) == limit) {
+		return recs, err
+	}
+
+	// tiered events are all older than stored ones
+	if len(recs) > 0 {
+		fromSeq = recs[len(recs)-1].Sequence - 1
+	}
+	tiered, err := s.segmentsBackward(tenantID, fromSeq, limit-len(recs))
+	if err != nil {
+		return nil, err
+	}
+	return append(recs, tiered...), nil
+}
+
+func (s *fileStore) ReplayFrom(seq int64, handler RecordedEventHandlerFunc, filters ...EventFilter) error {
+	return s.replayFrom("", seq, handler, filters...)
+}
+
+func (s *fileStore) replayFrom(tenantID string, seq int64, handler RecordedEventHandlerFunc, filters ...EventFilter) error {
+	for {
+		next, err := s.replaySegments(tenantID, seq, handler, filters)
+		if err != nil {
+			return err
+		}
+		seq = next
+		replayed, err := s.replayDatabase(tenantID, seq, handler, filters)
+		if err != nil || replayed {
+			return err
+		}
+	}
+}
+
+// replayDatabase hands the events in the database from seq on to handler,
+// unless some of them were tiered since the segments were replayed up to
+// seq, reporting whether it did
+func (s *fileStore) replayDatabase(tenantID string, seq int64, handler RecordedEventHandlerFunc, filters []EventFilter) (bool, error) {
+	db := s.reader
+	if db == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		db = s.db
+	}
+
+	typeClause, typeArgs := filterSQL(filters, s.storedEventTypes)
+	args := append([]any{tenantID, seq}, typeArgs...)
 
 	var rows []dbEvent
-	err := s.db.Select(&rows, `select * from events where sequence >= ? order by sequence asc`, seq)
+	err := db.Select(&rows, `select * from `+s.eventsSource+` where tenant_id = ? and sequence >= ?`+typeClause+` and `+visibleStreams+` order by sequence asc`, args...)
 	if err != nil {
-		return fmt.Errorf("select from events: %w", err)
+		return false, fmt.Errorf("select from events: %w", err)
+	}
+	// tiering moves the oldest events, so a segment from seq on written
+	// before the select may hold events it missed
+	if s.segments != nil {
+		var tiered bool
+		if err := db.Get(&tiered, `select exists(select 1 from tier_segments where last_sequence >= ?)`, seq); err != nil {
+			return false, fmt.Errorf("select from tier_segments: %w", err)
+		}
+		if tiered {
+			return false, nil
+		}
 	}
 
+	keys := newKeyring(db)
 	for _, row := range rows {
-		rec, err := row.UnmarshalFromRegistry(s)
+		rec, err := s.decodeRow(row, keys)
 		if err != nil {
-			return fmt.Errorf("recordedEvent: %w", err)
+			return false, fmt.Errorf("recordedEvent: %w", err)
 		}
+		if !MatchesAll(filters, rec) {
+			continue
+		}
+		s.inst.EventReplayed(rec.Sequence)
 		err = handler(rec, true)
 		if err != nil {
-			return fmt.Errorf("callback error: %w", err)
+			return false, fmt.Errorf("callback error: %w", err)
 		}
 	}
 
-	return nil
+	return true, nil
 }
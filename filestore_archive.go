@@ -0,0 +1,128 @@
+package evoke
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WithArchive attaches an archive database holding events moved out of the
+// hot store by ArchiveBefore or ArchiveOlderThan. Reads stitch the archive
+// and the hot store together, so LoadStream and ReplayFrom see the whole log
+// no matter where its events live.
+func WithArchive(archiveFile string) FileStoreOption {
+	return func(s *fileStore) {
+		s.archiveFile = archiveFile
+	}
+}
+
+// attachArchive attaches the archive file to the store's connection and
+// brings its events table in line with the hot one
+func (s *fileStore) attachArchive() error {
+	if _, err := s.db.Exec(`attach database ? as archive`, s.archiveFile); err != nil {
+		return fmt.Errorf("failed to attach archive: %w", err)
+	}
+	if _, err := s.db.Exec(`create table if not exists archive.` + s.table + ` as select * from main.` + s.table + ` where 0`); err != nil {
+		return fmt.Errorf("failed to create archive events table: %w", err)
+	}
+
+	var mainCols, archiveCols []struct {
+		Name    string         `db:"name"`
+		Type    string         `db:"type"`
+		Default sql.NullString `db:"dflt_value"`
+	}
+	if err := s.db.Select(&mainCols, `select name, type, dflt_value from pragma_table_info(?, 'main')`, s.table); err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if err := s.db.Select(&archiveCols, `select name, type, dflt_value from pragma_table_info(?, 'archive')`, s.table); err != nil {
+		return fmt.Errorf("failed to inspect archive events table: %w", err)
+	}
+	have := make(map[string]bool, len(archiveCols))
+	for _, c := range archiveCols {
+		have[c.Name] = true
+	}
+	names := make([]string, 0, len(mainCols))
+	for _, c := range mainCols {
+		names = append(names, c.Name)
+		if have[c.Name] {
+			continue
+		}
+		// archived rows take the column's default, as hot rows did
+		def := ""
+		if c.Default.Valid {
+			def = " not null default " + c.Default.String
+		}
+		_, err := s.db.Exec(fmt.Sprintf(`alter table archive.%s add column %s %s%s`, s.table, c.Name, c.Type, def))
+		if err != nil {
+			return fmt.Errorf("failed to add archive column %s: %w", c.Name, err)
+		}
+	}
+
+	if _, err := s.db.Exec(`create unique index if not exists archive.archive_` + s.table + `_sequence on ` + s.table + `(sequence)`); err != nil {
+		return fmt.Errorf("failed to create archive index: %w", err)
+	}
+	if _, err := s.db.Exec(`create index if not exists archive.archive_` + s.table + `_stream on ` + s.table + `(tenant_id, aggregate_id, sequence)`); err != nil {
+		return fmt.Errorf("failed to create archive index: %w", err)
+	}
+
+	cols := strings.Join(names, ", ")
+	s.archiveColumns = cols
+	// The archive is always a prefix of the log. Only read archived rows
+	// below the first hot row, so a move interrupted between its insert
+	// and its delete never shows an event twice.
+	s.eventsSource = `(select ` + cols + ` from archive.` + s.table + `
+		where sequence < (select coalesce(min(sequence), 9223372036854775807) from main.` + s.table + `)
+		union all
+		select ` + cols + ` from main.` + s.table + `) as events`
+	return nil
+}
+
+// ArchiveBefore moves every event with a sequence lower than seq from the
+// hot store into the archive, returning how many were moved.
+func (s *fileStore) ArchiveBefore(seq int64) (int64, error) {
+	if s.archiveColumns == "" {
+		return 0, fmt.Errorf("no archive attached (hint: use WithArchive)")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cols := s.archiveColumns
+	_, err = tx.Exec(`insert or ignore into archive.`+s.table+`(`+cols+`) select `+cols+` from main.`+s.table+` where sequence < ?`, seq)
+	if err != nil {
+		return 0, fmt.Errorf("insert into archive: %w", err)
+	}
+	res, err := tx.Exec(`delete from main.`+s.table+` where sequence < ?`, seq)
+	if err != nil {
+		return 0, fmt.Errorf("delete from events: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// ArchiveOlderThan moves events recorded longer ago than the retention
+// window into the archive.
+func (s *fileStore) ArchiveOlderThan(retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention).Unix()
+
+	s.mu.Lock()
+	var seq int64
+	err := s.db.Get(&seq, `select coalesce(max(sequence), 0) + 1 from main.`+s.table+` where recorded_at < ?`, cutoff)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("select from events: %w", err)
+	}
+
+	return s.ArchiveBefore(seq)
+}
@@ -0,0 +1,139 @@
+package evoke
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// countRows returns how many events a table of the store's database holds
+func countRows(t *testing.T, s *fileStore, schema string) int {
+	t.Helper()
+	var n int
+	if err := s.db.Get(&n, `select count(*) from `+schema+`.`+s.table); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestArchiveBefore(t *testing.T) {
+	s := newTestStore(t, WithArchive(filepath.Join(t.TempDir(), "archive.db")))
+	id, other := NewID(), NewID()
+	for _, id := range []uuid.UUID{id, other, id, id} {
+		if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := s.ArchiveBefore(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || countRows(t, s, "archive") != 2 || countRows(t, s, "main") != 2 {
+		t.Fatalf("archived %d events, leaving %d archived and %d hot; want 2 of each", n, countRows(t, s, "archive"), countRows(t, s, "main"))
+	}
+
+	recs := mustLoad(t, s, id)
+	if got := sequences(recs); !slices.Equal(got, []int64{1, 3, 4}) {
+		t.Errorf("loaded %v across the archive, want [1 3 4]", got)
+	}
+	for i, rec := range recs {
+		if rec.Version != int64(i+1) || rec.Event != (itemAdded{SKU: "a"}) {
+			t.Errorf("event %d loaded as %+v", i, rec)
+		}
+	}
+	if got := replayed(t, s); !slices.Equal(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("replayed %v, want the whole log", got)
+	}
+
+	// the stream's version counts archived events
+	if err := s.RecordAtVersion(context.Background(), "", other, 1, []Event{itemAdded{SKU: "b"}}); err != nil {
+		t.Errorf("appending at the archived version: %v", err)
+	}
+}
+
+// Archiving again moves only what is still hot, and nothing is read twice.
+func TestArchiveIsIncremental(t *testing.T) {
+	s := newTestStore(t, WithArchive(filepath.Join(t.TempDir(), "archive.db")))
+	id := NewID()
+	for i := 0; i < 4; i++ {
+		if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, before := range []int64{2, 2, 4} {
+		if _, err := s.ArchiveBefore(before); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := sequences(mustLoad(t, s, id)); !slices.Equal(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("loaded %v, want [1 2 3 4]", got)
+	}
+	if n := countRows(t, s, "archive"); n != 3 {
+		t.Errorf("archive holds %d events, want 3", n)
+	}
+}
+
+func TestArchiveOlderThan(t *testing.T) {
+	s := newTestStore(t, WithArchive(filepath.Join(t.TempDir(), "archive.db")))
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "old"}, itemAdded{SKU: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec(`update ` + s.table + ` set recorded_at = recorded_at - 7200 where sequence = 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.ArchiveOlderThan(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("archived %d events, want the one older than an hour", n)
+	}
+	if got := sequences(mustLoad(t, s, id)); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("loaded %v, want [1 2]", got)
+	}
+}
+
+// A store reopened with its archive still reads archived events.
+func TestArchivePersists(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *fileStore {
+		s, err := NewFileStore(filepath.Join(dir, "events.db"), WithArchive(filepath.Join(dir, "archive.db")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterEvent(s, &itemAdded{})
+		return s
+	}
+	id := NewID()
+
+	s := open()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ArchiveBefore(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s = open()
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	if got := sequences(mustLoad(t, s, id)); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("loaded %v after reopening, want [1 2]", got)
+	}
+}
+
+func TestArchiveWithoutArchive(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.ArchiveBefore(1); err == nil {
+		t.Error("ArchiveBefore without an archive attached returned no error")
+	}
+}
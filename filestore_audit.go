@@ -0,0 +1,116 @@
+package evoke
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// AuditSignature is the detached signature of an audit export. It signs
+// the head of a hash chain over the export's lines, so the export can't be
+// changed, reordered or cut short without the signature failing to verify.
+type AuditSignature struct {
+	FromSequence int64 `json:"fromSequence"`
+	LastSequence int64 `json:"lastSequence"`
+	Events       int64 `json:"events"`
+	// Hash is the hex SHA-256 chain head: each line is hashed with the
+	// hash of the lines before it
+	Hash      string `json:"hash"`
+	Signature []byte `json:"signature"`
+}
+
+// message is what the signature signs
+func (a *AuditSignature) message() []byte {
+	msg := []byte("evoke audit export v1\n")
+	msg = binary.BigEndian.AppendUint64(msg, uint64(a.FromSequence))
+	msg = binary.BigEndian.AppendUint64(msg, uint64(a.LastSequence))
+	msg = binary.BigEndian.AppendUint64(msg, uint64(a.Events))
+	return append(msg, a.Hash...)
+}
+
+// auditChain hashes the lines of an audit export in order
+type auditChain struct {
+	head   [sha256.Size]byte
+	events int64
+}
+
+func (c *auditChain) add(line []byte) {
+	h := sha256.New()
+	h.Write(c.head[:])
+	h.Write(line)
+	h.Sum(c.head[:0])
+	c.events++
+}
+
+// AuditExport writes the log from fromSeq on to w like Export, which Import
+// reads, and returns a signature of what was written made with key, to hand
+// to auditors with it. Payloads are written decrypted.
+func (s *fileStore) AuditExport(w io.Writer, fromSeq int64, key ed25519.PrivateKey) (*AuditSignature, error) {
+	sig := &AuditSignature{FromSequence: fromSeq}
+	var chain auditChain
+	var line bytes.Buffer
+	enc := json.NewEncoder(&line)
+	err := s.ScanRaw(RawQuery{FromSequence: fromSeq}, func(e RawEvent) error {
+		line.Reset()
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		chain.add(line.Bytes())
+		sig.LastSequence = e.Sequence
+		_, err := w.Write(line.Bytes())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sig.Events = chain.events
+	sig.Hash = hex.EncodeToString(chain.head[:])
+	sig.Signature = ed25519.Sign(key, sig.message())
+	return sig, nil
+}
+
+// VerifyAuditExport checks an audit export read from r against its
+// signature and the public key of whoever made it, returning an error
+// wrapping ErrIntegrity if they don't match. Verify an export before
+// importing it.
+func VerifyAuditExport(r io.Reader, sig *AuditSignature, pub ed25519.PublicKey) error {
+	if !ed25519.Verify(pub, sig.message(), sig.Signature) {
+		return fmt.Errorf("%w: audit signature is not valid", ErrIntegrity)
+	}
+	var chain auditChain
+	var last int64
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var e RawEvent
+			if err := json.Unmarshal(line, &e); err != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrIntegrity, chain.events+1, err)
+			}
+			chain.add(line)
+			last = e.Sequence
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read export: %w", err)
+		}
+	}
+	switch {
+	case chain.events != sig.Events:
+		return fmt.Errorf("%w: export has %d events, signed for %d", ErrIntegrity, chain.events, sig.Events)
+	case last != sig.LastSequence:
+		return fmt.Errorf("%w: export ends at event %d, signed to %d", ErrIntegrity, last, sig.LastSequence)
+	case hex.EncodeToString(chain.head[:]) != sig.Hash:
+		return fmt.Errorf("%w: export doesn't match its signed hash", ErrIntegrity)
+	}
+	return nil
+}
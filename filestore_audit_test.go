@@ -0,0 +1,94 @@
+package evoke
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// auditExport returns a signed export of a store of three events
+func auditExport(t *testing.T, key ed25519.PrivateKey) (*fileStore, []byte, *AuditSignature) {
+	t.Helper()
+	s := newTestStore(t, WithPayloadEncryption())
+	if err := s.Record(NewID(), []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}, itemRemoved{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	var export bytes.Buffer
+	sig, err := s.AuditExport(&export, 1, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, export.Bytes(), sig
+}
+
+func TestAuditExport(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, export, sig := auditExport(t, key)
+	if sig.FromSequence != 1 || sig.LastSequence != 3 || sig.Events != 3 {
+		t.Errorf("signed %+v, want the three events", sig)
+	}
+	if !strings.Contains(string(export), `"SKU":"b"`) {
+		t.Errorf("exported %s, want payloads decrypted", export)
+	}
+	if err := VerifyAuditExport(bytes.NewReader(export), sig, pub); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := newTestStore(t)
+	if err := imported.Import(bytes.NewReader(export)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rawLog(t, imported)), len(rawLog(t, s)); got != want {
+		t.Errorf("imported %d events, want %d", got, want)
+	}
+}
+
+func TestVerifyAuditExportRejectsChanges(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, export, sig := auditExport(t, key)
+	lines := strings.SplitAfter(string(export), "\n")[:3]
+
+	tests := []struct {
+		name   string
+		export string
+		sig    func(AuditSignature) AuditSignature
+		pub    ed25519.PublicKey
+	}{
+		{name: "changed event", export: strings.Replace(string(export), `"SKU":"b"`, `"SKU":"c"`, 1)},
+		{name: "reordered", export: lines[1] + lines[0] + lines[2]},
+		{name: "cut short", export: lines[0] + lines[1]},
+		{name: "appended", export: string(export) + lines[2]},
+		{name: "malformed", export: string(export) + "{\n"},
+		{name: "other key", export: string(export), pub: otherPub},
+		{name: "changed signature", export: lines[0] + lines[1], sig: func(s AuditSignature) AuditSignature {
+			s.Events, s.LastSequence = 2, 2
+			return s
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, p := *sig, pub
+			if tt.sig != nil {
+				s = tt.sig(s)
+			}
+			if tt.pub != nil {
+				p = tt.pub
+			}
+			if err := VerifyAuditExport(strings.NewReader(tt.export), &s, p); !errors.Is(err, ErrIntegrity) {
+				t.Errorf("VerifyAuditExport returned %v, want ErrIntegrity", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"modernc.org/sqlite"
+)
+
+// Backup writes a consistent snapshot of the store's database to w. It can
+// run while the store is in use; appends through this store wait for it, but
+// other connections to the file keep writing in WAL mode. An attached
+// archive and tiered segments are not included.
+func (s *fileStore) Backup(w io.Writer) error {
+	dir, err := os.MkdirTemp("", "evoke-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "backup.db")
+	if _, err := s.db.Exec(`vacuum main into ?`, file); err != nil {
+		return fmt.Errorf("vacuum into: %w", err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("write backup: %w", err)
+	}
+	return nil
+}
+
+// restorer is the part of the sqlite driver's connection Restore uses
+type restorer interface {
+	NewRestore(srcUri string) (*sqlite.Backup, error)
+}
+
+// Restore replaces the contents of the store's database with a backup read
+// from r, as written by Backup. Projections and caches built from the old
+// contents have to be rebuilt.
+func (s *fileStore) Restore(r io.Reader) error {
+	dir, err := os.MkdirTemp("", "evoke-restore")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "restore.db")
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	if err := checkBackup(file, s.table); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// statements prepared against the old schema must not outlive it
+	s.closeStmts()
+
+	conn, err := s.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	err = conn.Raw(func(dc any) error {
+		rc, ok := dc.(restorer)
+		if !ok {
+			return fmt.Errorf("sqlite driver does not support restore")
+		}
+		b, err := rc.NewRestore(file)
+		if err != nil {
+			return err
+		}
+		if _, err := b.Step(-1); err != nil {
+			b.Finish()
+			return err
+		}
+		return b.Finish()
+	})
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	// the backup may come from an older version of the store
+	if err := s.createTables(s.db.DB); err != nil {
+		return err
+	}
+	if s.segments != nil {
+		return s.createTierTables()
+	}
+	return nil
+}
+
+// checkBackup refuses files that are not sqlite databases holding the
+// store's events table, before they overwrite anything
+func checkBackup(file, table string) error {
+	db, err := openSQLite(file, sqliteConfig{journalMode: JournalModeDelete, synchronous: SynchronousOff})
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	defer db.Close()
+	ok, err := hasColumn(db, table, "sequence")
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("backup has no %s table", table)
+	}
+	return nil
+}
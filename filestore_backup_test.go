@@ -0,0 +1,72 @@
+package evoke
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	var backup bytes.Buffer
+	if err := s.Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(id, []Event{itemRemoved{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// into a fresh store
+	other := newTestStore(t)
+	if err := other.Restore(bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(mustLoad(t, other, id)); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("restored store loaded %v, want the two events backed up", got)
+	}
+	if err := other.Record(id, []Event{itemRemoved{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// over the store it came from, dropping what was recorded since
+	if err := s.Restore(bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	recs := mustLoad(t, s, id)
+	if len(recs) != 2 || recs[1].Event != (itemAdded{SKU: "b"}) {
+		t.Errorf("loaded %+v after restoring, want the events backed up", recs)
+	}
+	if err := s.RecordAtVersion(context.Background(), "", id, 2, []Event{itemRemoved{SKU: "b"}}); err != nil {
+		t.Errorf("appending at the restored version: %v", err)
+	}
+}
+
+func TestRestoreRejectsOtherFiles(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var backup bytes.Buffer
+	if err := newTestStore(t, WithTableName("orders")).Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"not a database":   "just some text, longer than a sqlite header would be",
+		"of another table": backup.String(),
+	} {
+		if err := s.Restore(strings.NewReader(data)); err == nil {
+			t.Errorf("restored a backup %s", name)
+		}
+	}
+	if n := len(mustLoad(t, s, id)); n != 1 {
+		t.Errorf("loaded %d events after failed restores, want the store untouched", n)
+	}
+}
@@ -0,0 +1,104 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+)
+
+// external payloads stored as bytes rather than text have names ending in
+// binaryBlobSuffix
+const binaryBlobSuffix = ".bin"
+
+// WithBinaryPayloads stores event payloads as bytes in the event_data blob
+// column rather than as text in event_json, so compressed and encrypted
+// payloads are kept without the third base64 adds. Stores read both kinds
+// of rows, so the option can be switched on at any time; use
+// ConvertPayloadsToBinary to convert the rows recorded before.
+func WithBinaryPayloads() FileStoreOption {
+	return func(s *fileStore) {
+		s.binary = true
+	}
+}
+
+// migrateEventDataColumn adds binary payloads to stores created before them
+func migrateEventDataColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "event_data")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column event_data blob`); err != nil {
+			return fmt.Errorf("failed to add event_data column: %w", err)
+		}
+	}
+	return nil
+}
+
+// textPayload is the event_json of a payload; encoded payloads, those
+// compressed or encrypted, are base64 encoded
+func textPayload(raw []byte, encoded bool) string {
+	if encoded {
+		return base64.StdEncoding.EncodeToString(raw)
+	}
+	return string(raw)
+}
+
+// rawPayload returns the bytes of a row's payload, from whichever column
+// holds it
+func (e *dbEvent) rawPayload() ([]byte, error) {
+	if e.EventData != nil {
+		return e.EventData, nil
+	}
+	if e.Encrypted || e.Compressed {
+		return base64.StdEncoding.DecodeString(e.EventJSON)
+	}
+	return []byte(e.EventJSON), nil
+}
+
+// ConvertPayloadsToBinary moves the payloads of rows recorded as text into
+// the event_data column, a batch per transaction, returning how many rows
+// were converted. It can be stopped with ctx and run again later. Rows with
+// external payloads keep them as they are.
+func (s *fileStore) ConvertPayloadsToBinary(ctx context.Context) (int64, error) {
+	var n int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		converted, err := s.convertPayloadBatch()
+		n += converted
+		if err != nil || converted < deliveryBatch {
+			return n, err
+		}
+	}
+}
+
+// convertPayloadBatch converts the next batch of text payloads
+func (s *fileStore) convertPayloadBatch() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var rows []dbEvent
+	err = tx.Select(&rows, `select * from `+s.table+` where event_data is null and payload_ref = '' order by sequence limit ?`, deliveryBatch)
+	if err != nil {
+		return 0, fmt.Errorf("select from events: %w", err)
+	}
+	for _, row := range rows {
+		data, err := row.rawPayload()
+		if err != nil {
+			return 0, fmt.Errorf("decode event %d: %w", row.Sequence, err)
+		}
+		if _, err := tx.Exec(`update `+s.table+` set event_data = ?, event_json = '' where sequence = ?`, data, row.Sequence); err != nil {
+			return 0, fmt.Errorf("update events: %w", err)
+		}
+	}
+	return int64(len(rows)), tx.Commit()
+}
@@ -0,0 +1,91 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// payloadColumns counts the rows keeping their payloads as text and as
+// bytes
+func payloadColumns(t *testing.T, s *fileStore) (text, binary int) {
+	t.Helper()
+	if err := s.db.Get(&text, `select count(*) from `+s.table+` where event_json != ''`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.Get(&binary, `select count(*) from `+s.table+` where event_data is not null`); err != nil {
+		t.Fatal(err)
+	}
+	return text, binary
+}
+
+func TestBinaryPayloads(t *testing.T) {
+	s := newTestStore(t, WithBinaryPayloads(), WithPayloadEncryption(), WithPayloadCompression())
+	id := NewID()
+	evs := []Event{itemAdded{SKU: "a"}, itemAdded{SKU: strings.Repeat("b", 500)}}
+	if err := s.Record(id, evs); err != nil {
+		t.Fatal(err)
+	}
+	if text, binary := payloadColumns(t, s); text != 0 || binary != 2 {
+		t.Errorf("%d text and %d binary payloads, want all binary", text, binary)
+	}
+	recs := mustLoad(t, s, id)
+	if len(recs) != 2 || recs[0].Event != evs[0] || recs[1].Event != evs[1] {
+		t.Errorf("loaded %+v", recs)
+	}
+}
+
+func TestConvertPayloadsToBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	text, err := NewFileStore(path, WithPayloadEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterEvent(text, &itemAdded{})
+	id := NewID()
+	evs := make([]Event, deliveryBatch+10)
+	for i := range evs {
+		evs[i] = itemAdded{SKU: "a", Qty: i}
+	}
+	if err := text.Record(id, evs); err != nil {
+		t.Fatal(err)
+	}
+	want := mustLoad(t, text, id)
+	if err := text.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(path, WithPayloadEncryption(), WithBinaryPayloads())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	RegisterEvent(s, &itemAdded{})
+	if err := s.Record(id, []Event{itemAdded{SKU: "binary"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustLoad(t, s, id); !reflect.DeepEqual(got[:len(want)], want) {
+		t.Error("a binary store read its text rows differently")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.ConvertPayloadsToBinary(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("converting with a cancelled context returned %v", err)
+	}
+	if n, err := s.ConvertPayloadsToBinary(context.Background()); err != nil || n != int64(len(evs)) {
+		t.Fatalf("converted %d rows, %v, want %d", n, err, len(evs))
+	}
+	if text, binary := payloadColumns(t, s); text != 0 || binary != len(evs)+1 {
+		t.Errorf("%d text and %d binary payloads after converting", text, binary)
+	}
+	if got := mustLoad(t, s, id); !reflect.DeepEqual(got[:len(want)], want) {
+		t.Error("converted rows read back differently")
+	}
+	if n, err := s.ConvertPayloadsToBinary(context.Background()); err != nil || n != 0 {
+		t.Errorf("converting again converted %d, %v", n, err)
+	}
+}
@@ -0,0 +1,85 @@
+package evoke
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// payloads shorter than this are stored as they are, the gzip header and
+// base64 costing more than compression saves
+const compressMinSize = 256
+
+// WithPayloadCompression gzips event payloads before storing them, which
+// typically shrinks verbose JSON events several times over. Small payloads,
+// and any that don't get smaller, are stored uncompressed. Each row records
+// whether it is compressed, so stores can switch compression on and off and
+// still read all their events.
+func WithPayloadCompression() FileStoreOption {
+	return func(s *fileStore) {
+		s.compress = true
+	}
+}
+
+// migrateCompressedColumn adds the compression flag to stores created
+// before it
+func migrateCompressedColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "compressed")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column compressed integer not null default 0`); err != nil {
+			return fmt.Errorf("failed to add compressed column: %w", err)
+		}
+	}
+	return nil
+}
+
+// encodePayload turns an event's JSON into the bytes of its payload,
+// compressing it as the store is configured to and encrypting it with key
+// unless key is nil. It reports whether the payload was compressed.
+func (s *fileStore) encodePayload(key []byte, aggregateID uuid.UUID, data []byte) ([]byte, bool, error) {
+	compressed := false
+	if s.compress && len(data) >= compressMinSize {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, false, fmt.Errorf("compress: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, false, fmt.Errorf("compress: %w", err)
+		}
+		size := buf.Len()
+		if !s.binary {
+			// base64 grows the compressed payload by a third
+			size = base64.StdEncoding.EncodedLen(size)
+		}
+		if size < len(data) {
+			data, compressed = buf.Bytes(), true
+		}
+	}
+	if key != nil {
+		sealed, err := encryptPayload(key, aggregateID, data)
+		if err != nil {
+			return nil, false, fmt.Errorf("encrypt: %w", err)
+		}
+		return sealed, compressed, nil
+	}
+	return data, compressed, nil
+}
+
+// decompressPayload undoes the compression of encodePayload
+func decompressPayload(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
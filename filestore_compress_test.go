@@ -0,0 +1,88 @@
+package evoke
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// compressedRows returns the compressed flag of every row
+func compressedRows(t *testing.T, s *fileStore) []bool {
+	t.Helper()
+	var flags []bool
+	if err := s.db.Select(&flags, `select compressed from `+s.table+` order by sequence`); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestPayloadCompression(t *testing.T) {
+	for name, opts := range map[string][]FileStoreOption{
+		"plain":     {WithPayloadCompression()},
+		"encrypted": {WithPayloadCompression(), WithPayloadEncryption()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestStore(t, opts...)
+			long := itemAdded{SKU: strings.Repeat("sku-", 200)}
+			id := NewID()
+			if err := s.Record(id, []Event{long, itemAdded{SKU: "short"}}); err != nil {
+				t.Fatal(err)
+			}
+			if got := compressedRows(t, s); len(got) != 2 || !got[0] || got[1] {
+				t.Errorf("compressed flags %v, want only the long payload compressed", got)
+			}
+			if p := storedPayloads(t, s)[0]; len(p) >= len(long.SKU) {
+				t.Errorf("stored %d bytes for a %d byte SKU", len(p), len(long.SKU))
+			}
+			recs := mustLoad(t, s, id)
+			if recs[0].Event != long || recs[1].Event != (itemAdded{SKU: "short"}) {
+				t.Errorf("loaded %+v", recs)
+			}
+			var export bytes.Buffer
+			if err := s.Export(&export, 1); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(export.String(), long.SKU) {
+				t.Error("exported the payload compressed")
+			}
+		})
+	}
+}
+
+// A store reads back its events after compression is switched on or off.
+func TestPayloadCompressionSwitched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	id := NewID()
+	long := itemAdded{SKU: strings.Repeat("sku-", 200)}
+	for _, opts := range [][]FileStoreOption{nil, {WithPayloadCompression()}, nil} {
+		s, err := NewFileStore(path, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterEvent(s, &itemAdded{})
+		if err := s.Record(id, []Event{long}); err != nil {
+			t.Fatal(err)
+		}
+		recs := mustLoad(t, s, id)
+		for _, rec := range recs {
+			if rec.Event != long {
+				t.Errorf("loaded %+v", rec)
+			}
+		}
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	if got := compressedRows(t, s); !slices.Equal(got, []bool{false, true, false}) {
+		t.Errorf("compressed flags %v, want only the event recorded with compression on", got)
+	}
+}
@@ -0,0 +1,81 @@
+package evoke
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// CorrelationQuerier is implemented by stores that can trace workflows
+// through the correlation and causation IDs of their events.
+type CorrelationQuerier interface {
+	// EventsByCorrelationID returns the events of a workflow in sequence
+	// order
+	EventsByCorrelationID(correlationID string) ([]RecordedEvent, error)
+	// EventsCausedBy returns the events recorded handling the event at
+	// seq, those recorded handling them, and so on, in sequence order
+	EventsCausedBy(seq int64) ([]RecordedEvent, error)
+}
+
+// migrateCorrelationColumns adds the indexed workflow columns to stores
+// created before them. Events recorded before have neither.
+func migrateCorrelationColumns(db *sql.DB, table string) error {
+	for _, col := range []struct{ name, def string }{
+		{"correlation_id", "text not null default ''"},
+		{"causation_seq", "integer not null default 0"},
+	} {
+		ok, err := hasColumn(db, table, col.name)
+		if err != nil {
+			return fmt.Errorf("failed to inspect events table: %w", err)
+		}
+		if !ok {
+			if _, err := db.Exec(`alter table ` + table + ` add column ` + col.name + ` ` + col.def); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", col.name, err)
+			}
+		}
+		if _, err := db.Exec(`create index if not exists ` + table + `_` + col.name + ` on ` + table + `(` + col.name + `)`); err != nil {
+			return fmt.Errorf("failed to create %s index: %w", col.name, err)
+		}
+	}
+	return nil
+}
+
+// correlationColumns returns the values of the workflow columns of an
+// event recorded with md
+func correlationColumns(md Metadata) (string, int64) {
+	return md[CorrelationIDKey], CausationSequence(RecordedEvent{Metadata: md})
+}
+
+func (s *fileStore) EventsByCorrelationID(correlationID string) ([]RecordedEvent, error) {
+	return s.eventsByCorrelationID("", correlationID)
+}
+
+func (t *tenantStore) EventsByCorrelationID(correlationID string) ([]RecordedEvent, error) {
+	return t.store.eventsByCorrelationID(t.tenantID, correlationID)
+}
+
+func (s *fileStore) eventsByCorrelationID(tenantID, correlationID string) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.selectRecords(`select * from `+s.eventsSource+` where tenant_id = ? and correlation_id = ? and `+visibleStreams+` order by sequence asc`,
+		tenantID, correlationID)
+}
+
+func (s *fileStore) EventsCausedBy(seq int64) ([]RecordedEvent, error) {
+	return s.eventsCausedBy("", seq)
+}
+
+func (t *tenantStore) EventsCausedBy(seq int64) ([]RecordedEvent, error) {
+	return t.store.eventsCausedBy(t.tenantID, seq)
+}
+
+func (s *fileStore) eventsCausedBy(tenantID string, seq int64) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.selectRecords(`with recursive caused(sequence) as (
+			select sequence from `+s.eventsSource+` where tenant_id = ?1 and causation_seq = ?2
+			union
+			select events.sequence from `+s.eventsSource+` join caused on events.causation_seq = caused.sequence where events.tenant_id = ?1
+		)
+		select * from `+s.eventsSource+` where sequence in (select sequence from caused) and `+visibleStreams+` order by sequence asc`,
+		tenantID, seq)
+}
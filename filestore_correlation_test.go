@@ -0,0 +1,105 @@
+package evoke
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// reactingHandler records an itemRemoved for each empty itemAdded it
+// handles, and an itemAdded for each itemRemoved, in the context it was
+// handed
+type reactingHandler struct{ store EventStore }
+
+func (h reactingHandler) Handle(e Event, replay bool) error {
+	return h.HandleRecorded(context.Background(), RecordedEvent{Event: e}, replay)
+}
+
+func (h reactingHandler) HandleRecorded(ctx context.Context, rec RecordedEvent, replay bool) error {
+	var next Event
+	switch e := rec.Event.(type) {
+	case itemAdded:
+		if e.Qty > 0 {
+			return nil
+		}
+		next = itemRemoved{SKU: e.SKU}
+	case itemRemoved:
+		next = itemAdded{SKU: e.SKU, Qty: 1}
+	}
+	return h.store.(AggregateRecorder).RecordAs(ctx, "", NewID(), []Event{next})
+}
+
+// recordWorkflow records an empty itemAdded in the workflow id, and the
+// events it causes through a reactingHandler
+func recordWorkflow(t *testing.T, s EventStore, id string) {
+	t.Helper()
+	ctx := WithCorrelationID(context.Background(), id)
+	if err := s.(AggregateRecorder).RecordAs(ctx, "", NewID(), []Event{itemAdded{SKU: id}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCorrelationQueries(t *testing.T) {
+	s := newTestStore(t)
+	for name, store := range map[string]EventStore{"file": s, "tenant": s.ForTenant("acme")} {
+		t.Run(name, func(t *testing.T) {
+			bus := NewEventBus()
+			bus.Subscribe(itemAdded{}, reactingHandler{store})
+			bus.Subscribe(itemRemoved{}, reactingHandler{store})
+			store.RegisterPublisher(bus)
+			recordWorkflow(t, store, name+"-1")
+			recordWorkflow(t, store, name+"-2")
+
+			q := store.(CorrelationQuerier)
+			workflow, err := q.EventsByCorrelationID(name + "-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(workflow) != 3 {
+				t.Fatalf("workflow of %d events, want the event and the two it caused", len(workflow))
+			}
+			root := workflow[0].Sequence
+			if CausationSequence(workflow[0]) != 0 || CausationSequence(workflow[1]) != root || CausationSequence(workflow[2]) != workflow[1].Sequence {
+				t.Errorf("workflow %+v, want each event caused by the one before", workflow)
+			}
+
+			caused, err := q.EventsCausedBy(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := sequences(caused), sequences(workflow[1:]); !slices.Equal(got, want) {
+				t.Errorf("events caused by %d: %v, want %v", root, got, want)
+			}
+			if caused, err := q.EventsCausedBy(workflow[2].Sequence); err != nil || len(caused) != 0 {
+				t.Errorf("events caused by the last event %v, %v", sequences(caused), err)
+			}
+		})
+	}
+}
+
+// Appends outside of a workflow start one of their own.
+func TestCorrelationIDOfAppend(t *testing.T) {
+	s := newTestStore(t)
+	a, b := NewID(), NewID()
+	if err := s.Record(a, []Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(b, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.ReadAll(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := CorrelationID(recs[0]), CorrelationID(recs[2])
+	if first == "" || CorrelationID(recs[1]) != first || second == first {
+		t.Errorf("correlation IDs %q, %q and %q, want one per append", first, CorrelationID(recs[1]), second)
+	}
+	workflow, err := s.EventsByCorrelationID(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(workflow); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("workflow %v, want the first append", got)
+	}
+}
@@ -0,0 +1,243 @@
+package evoke
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ShreddedEvent stands in for an event whose aggregate key has been deleted
+// with ShredAggregate. The event keeps its place in the log, but its payload
+// can no longer be read.
+type ShreddedEvent struct {
+	EventType string
+}
+
+type FileStoreOption func(*fileStore)
+
+// WithPayloadEncryption encrypts every event payload with a key unique to
+// its aggregate. Deleting the key with ShredAggregate makes that aggregate's
+// events permanently unreadable, which erases personal data without
+// rewriting the log.
+func WithPayloadEncryption() FileStoreOption {
+	return func(s *fileStore) {
+		s.encrypt = true
+	}
+}
+
+// ShredAggregate deletes the encryption key of an aggregate in the default
+// tenant. Its events are afterwards loaded as ShreddedEvent. Events recorded
+// after shredding get a fresh key, which never applies to the events before
+// them.
+func (s *fileStore) ShredAggregate(aggregateID uuid.UUID) error {
+	return s.shredAggregate("", aggregateID)
+}
+
+func (t *tenantStore) ShredAggregate(aggregateID uuid.UUID) error {
+	return t.store.shredAggregate(t.tenantID, aggregateID)
+}
+
+func (s *fileStore) shredAggregate(tenantID string, aggregateID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`delete from aggregate_keys where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if err != nil {
+		return fmt.Errorf("delete from aggregate_keys: %w", err)
+	}
+	return s.deleteSnapshot(s.db, tenantID, aggregateID)
+}
+
+func createKeyTable(db *sql.DB, table string) error {
+	if _, err := db.Exec(`
+		create table if not exists aggregate_keys (
+			tenant_id    text not null,
+			aggregate_id text not null,
+			key          blob not null,
+			since        integer not null default 0,
+			primary key (tenant_id, aggregate_id)
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create aggregate_keys table: %w", err)
+	}
+	ok, err := hasColumn(db, "aggregate_keys", "since")
+	if err != nil {
+		return fmt.Errorf("failed to inspect aggregate_keys table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table aggregate_keys add column since integer not null default 0`); err != nil {
+			return fmt.Errorf("failed to add since column: %w", err)
+		}
+	}
+	ok, err = hasColumn(db, table, "encrypted")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column encrypted integer not null default 0`); err != nil {
+			return fmt.Errorf("failed to add encrypted column: %w", err)
+		}
+	}
+	return nil
+}
+
+// aggregateKey returns the key of an aggregate, creating one for the events
+// from version since on if needed. Callers hold s.mu.
+func (s *fileStore) aggregateKey(ext sqlx.Ext, tenantID string, aggregateID uuid.UUID, since int64) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	_, err := ext.Exec(`insert or ignore into aggregate_keys(tenant_id, aggregate_id, key, since) values(?,?,?,?)`, tenantID, aggregateID.String(), key, since)
+	if err != nil {
+		return nil, fmt.Errorf("insert into aggregate_keys: %w", err)
+	}
+	err = sqlx.Get(ext, &key, `select key from aggregate_keys where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if err != nil {
+		return nil, fmt.Errorf("select from aggregate_keys: %w", err)
+	}
+	return key, nil
+}
+
+// keyRow is a row of aggregate_keys: the key of an aggregate and the
+// first version it encrypts
+type keyRow struct {
+	Key   []byte `db:"key"`
+	Since int64  `db:"since"`
+}
+
+// keyring caches aggregate keys for the duration of one read; a nil key
+// means the key has been shredded
+type keyring struct {
+	q    sqlx.Queryer
+	keys map[string]keyRow
+}
+
+func newKeyring(q sqlx.Queryer) *keyring {
+	return &keyring{q: q, keys: make(map[string]keyRow)}
+}
+
+// lookup returns the key of the event at version of an aggregate, or nil if
+// it was shredded. Events older than the aggregate's key were encrypted
+// with a key shredded since.
+func (kr *keyring) lookup(tenantID string, aggregateID uuid.UUID, version int64) ([]byte, error) {
+	k := tenantID + "/" + aggregateID.String()
+	key, ok := kr.keys[k]
+	if !ok {
+		err := sqlx.Get(kr.q, &key, `select key, since from aggregate_keys where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+		if errors.Is(err, sql.ErrNoRows) {
+			key = keyRow{}
+		} else if err != nil {
+			return nil, fmt.Errorf("select from aggregate_keys: %w", err)
+		}
+		kr.keys[k] = key
+	}
+	if version < key.Since {
+		return nil, nil
+	}
+	return key.Key, nil
+}
+
+func encryptPayload(key []byte, aggregateID uuid.UUID, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aggregateID[:]), nil
+}
+
+func decryptPayload(key []byte, aggregateID uuid.UUID, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, aggregateID[:])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// payload returns the plaintext JSON of a row, or nil if it was shredded.
+// Callers hold s.mu.
+func (s *fileStore) payload(row *dbEvent, keys *keyring) ([]byte, error) {
+	if row.PayloadRef != "" {
+		if err := s.externalPayload(row); err != nil {
+			return nil, err
+		}
+	}
+	data, err := row.rawPayload()
+	if err != nil {
+		return nil, fmt.Errorf("decode event %d: %w", row.Sequence, err)
+	}
+	if row.Encrypted {
+		key, err := keys.lookup(row.TenantID, row.AggregateID, row.Version)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, nil
+		}
+		key, err = s.rowPayloadKey(row.KeyID, key)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", row.Sequence, err)
+		}
+		data, err = decryptPayload(key, row.AggregateID, data)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt event %d: %w", row.Sequence, err)
+		}
+	}
+	if row.Compressed {
+		var err error
+		data, err = decompressPayload(data)
+		if err != nil {
+			return nil, fmt.Errorf("decompress event %d: %w", row.Sequence, err)
+		}
+	}
+	return data, nil
+}
+
+// decodeRow turns a stored row into a RecordedEvent. Callers hold s.mu.
+func (s *fileStore) decodeRow(row dbEvent, keys *keyring) (RecordedEvent, error) {
+	data, err := s.payload(&row, keys)
+	if err != nil {
+		return RecordedEvent{}, err
+	}
+	if data == nil {
+		md, err := decodeMetadata(row.Metadata)
+		if err != nil {
+			return RecordedEvent{}, err
+		}
+		return RecordedEvent{
+			Sequence:      row.Sequence,
+			Version:       row.Version,
+			RecordedAt:    row.RecordedAt,
+			AggregateID:   row.AggregateID,
+			AggregateType: row.AggregateType,
+			EventType:     row.EventType,
+			Event:         ShreddedEvent{EventType: row.EventType},
+			TenantID:      row.TenantID,
+			Metadata:      md,
+			SchemaVersion: row.schemaVersion(),
+		}, nil
+	}
+	row.EventJSON = string(data)
+	return row.UnmarshalFromRegistry(s)
+}
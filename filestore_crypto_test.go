@@ -0,0 +1,106 @@
+package evoke
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// storedPayloads returns the payload columns of every row, as stored
+func storedPayloads(t *testing.T, s *fileStore) []string {
+	t.Helper()
+	var rows []struct {
+		JSON string `db:"event_json"`
+		Data []byte `db:"event_data"`
+	}
+	if err := s.db.Select(&rows, `select event_json, event_data from `+s.table+` order by sequence`); err != nil {
+		t.Fatal(err)
+	}
+	payloads := make([]string, len(rows))
+	for i, row := range rows {
+		payloads[i] = row.JSON + string(row.Data)
+	}
+	return payloads
+}
+
+func TestPayloadEncryption(t *testing.T) {
+	s := newTestStore(t, WithPayloadEncryption())
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "secret-sku", Qty: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range storedPayloads(t, s) {
+		if strings.Contains(p, "secret-sku") {
+			t.Errorf("payload stored in the clear: %q", p)
+		}
+	}
+	if got := mustLoad(t, s, id)[0].Event; got != (itemAdded{SKU: "secret-sku", Qty: 1}) {
+		t.Errorf("loaded %#v", got)
+	}
+}
+
+func TestShredAggregate(t *testing.T) {
+	s := newTestStore(t, WithPayloadEncryption())
+	shredded, kept := NewID(), NewID()
+	for _, id := range []uuid.UUID{shredded, kept} {
+		if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemRemoved{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.ShredAggregate(shredded); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := mustLoad(t, s, shredded)
+	if len(recs) != 2 {
+		t.Fatalf("shredded stream has %d events, want both kept in place", len(recs))
+	}
+	for i, want := range []string{"itemAdded", "itemRemoved"} {
+		if recs[i].Event != (ShreddedEvent{EventType: want}) || recs[i].Version != int64(i+1) {
+			t.Errorf("shredded event %d loaded as %+v", i, recs[i])
+		}
+	}
+	if got := mustLoad(t, s, kept)[0].Event; got != (itemAdded{SKU: "a"}) {
+		t.Errorf("another aggregate's event loaded as %#v", got)
+	}
+	err := s.ScanRaw(RawQuery{AggregateID: shredded}, func(e RawEvent) error {
+		if e.Data != nil {
+			t.Errorf("ScanRaw returned shredded payload %s", e.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stream carries on under a new key
+	if err := s.Record(shredded, []Event{itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	recs = mustLoad(t, s, shredded)
+	if got := recs[2].Event; got != (itemAdded{SKU: "b"}) || recs[2].Version != 3 {
+		t.Errorf("event recorded after shredding loaded as %+v", recs[2])
+	}
+}
+
+func TestShredAggregateOfTenant(t *testing.T) {
+	s := newTestStore(t, WithPayloadEncryption())
+	id := NewID()
+	acme, globex := s.ForTenant("acme"), s.ForTenant("globex")
+	for _, store := range []EventStore{acme, globex} {
+		if err := store.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := acme.ShredAggregate(id); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustLoad(t, acme, id)[0].Event; got != (ShreddedEvent{EventType: "itemAdded"}) {
+		t.Errorf("acme's event loaded as %#v after shredding", got)
+	}
+	if got := mustLoad(t, globex, id)[0].Event; got != (itemAdded{SKU: "a"}) {
+		t.Errorf("globex's event loaded as %#v after acme shredded its aggregate", got)
+	}
+}
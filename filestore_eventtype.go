@@ -0,0 +1,54 @@
+package evoke
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// createEventTypeIndex indexes the log by event type, so the events of a
+// type are read without scanning the others. It is kept by sqlite in the
+// transaction of every append, so it can't fall behind the log.
+func createEventTypeIndex(db *sql.DB, table string) error {
+	if _, err := db.Exec(`create index if not exists ` + table + `_type_sequence on ` + table + `(tenant_id, event_type, sequence)`); err != nil {
+		return fmt.Errorf("failed to create event type index: %w", err)
+	}
+	return nil
+}
+
+// ReadByType returns up to limit events of one type from the global log,
+// starting at sequence fromSeq, whatever names they are stored under.
+// All of them are returned if limit <= 0.
+func (s *fileStore) ReadByType(eventType string, fromSeq int64, limit int) ([]RecordedEvent, error) {
+	return s.readByType("", eventType, fromSeq, limit)
+}
+
+func (t *tenantStore) ReadByType(eventType string, fromSeq int64, limit int) ([]RecordedEvent, error) {
+	return t.store.readByType(t.tenantID, eventType, fromSeq, limit)
+}
+
+func (s *fileStore) readByType(tenantID, eventType string, fromSeq int64, limit int) ([]RecordedEvent, error) {
+	names := s.storedEventTypes(eventType)
+	dbLimit := limit
+	if dbLimit <= 0 {
+		dbLimit = -1 // sqlite for no limit
+	}
+
+	// the database is read before the segments, as by readAll
+	args := make([]any, 0, len(names)+3)
+	args = append(args, tenantID)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	args = append(args, fromSeq, dbLimit)
+	recs, err := s.readRecords(`select * from `+s.eventsSource+` where tenant_id = ? and event_type in (?`+strings.Repeat(",?", len(names)-1)+`) and sequence >= ? and `+visibleStreams+` order by sequence asc limit ?`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	tiered, err := s.segmentPage(tenantID, fromSeq, limit, []EventFilter{{EventTypes: names}})
+	if err != nil {
+		return nil, err
+	}
+	return stitchTiered(tiered, recs, limit), nil
+}
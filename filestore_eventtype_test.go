@@ -0,0 +1,123 @@
+package evoke
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReadByType(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		fromSeq   int64
+		limit     int
+		want      []int64
+	}{
+		{"all", "itemRemoved", 1, 0, []int64{2, 4, 5}},
+		{"from a sequence", "itemRemoved", 3, 0, []int64{4, 5}},
+		{"page", "itemRemoved", 1, 2, []int64{2, 4}},
+		{"other type", "itemAdded", 1, 0, []int64{1, 3}},
+		{"unknown type", "itemMoved", 1, 0, []int64{}},
+	}
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			a, b := NewID(), NewID()
+			for _, rec := range []struct {
+				id uuid.UUID
+				e  Event
+			}{
+				{a, itemAdded{}}, {a, itemRemoved{}}, {b, itemAdded{}}, {b, itemRemoved{}}, {a, itemRemoved{}},
+			} {
+				if err := s.Record(rec.id, []Event{rec.e}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, tt := range tests {
+				recs, err := s.(EventTypeReader).ReadByType(tt.eventType, tt.fromSeq, tt.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := sequences(recs); !slices.Equal(got, tt.want) {
+					t.Errorf("%s: read %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// A type renamed with RegisterEventNamed is read under its old name and
+// its new one.
+func TestReadByTypeRenamed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	old, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterEvent(old, &itemAdded{})
+	if err := old.Record(NewID(), []Event{itemAdded{SKU: "old"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	RegisterEventNamed(s, "cart.item_added", &itemAdded{})
+	RegisterEventAlias(s, "itemAdded", &itemAdded{})
+	if err := s.Record(NewID(), []Event{itemAdded{SKU: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.ReadByType("itemAdded", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Event != (itemAdded{SKU: "old"}) || recs[1].Event != (itemAdded{SKU: "new"}) {
+		t.Errorf("read %+v, want the events under both names", recs)
+	}
+}
+
+func TestReadByTypeOfTenant(t *testing.T) {
+	s := newTestStore(t)
+	acme := s.ForTenant("acme")
+	if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.Record(NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := acme.ReadByType("itemAdded", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(recs); !slices.Equal(got, []int64{2}) {
+		t.Errorf("tenant read %v, want only its own event", got)
+	}
+}
+
+func TestEventTypeIndex(t *testing.T) {
+	s := newTestStore(t)
+	var plan []struct {
+		ID, Parent, NotUsed int
+		Detail              string
+	}
+	err := s.db.Select(&plan, `explain query plan select * from `+s.table+` where tenant_id = ? and event_type in (?) and sequence >= ? order by sequence asc`, "", "itemAdded", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var details []string
+	for _, step := range plan {
+		details = append(details, step.Detail)
+	}
+	if !strings.Contains(strings.Join(details, "\n"), s.table+"_type_sequence") {
+		t.Errorf("query plan %q doesn't use the event type index", details)
+	}
+}
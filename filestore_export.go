@@ -0,0 +1,152 @@
+package evoke
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Export writes the log from fromSeq on to w as newline-delimited JSON, one
+// RawEvent per line. Payloads are written decrypted.
+func (s *fileStore) Export(w io.Writer, fromSeq int64) error {
+	enc := json.NewEncoder(w)
+	return s.ScanRaw(RawQuery{FromSequence: fromSeq}, func(e RawEvent) error {
+		return enc.Encode(e)
+	})
+}
+
+// events Import appends per transaction
+const importBatchSize = 500
+
+// Import appends the events of an export read from r, in order, keeping
+// their tenants, aggregates, types, payloads, metadata, schema versions and
+// recording times.
+// They get new sequences. An event whose version doesn't follow on from its
+// stream, such as one imported twice, fails the import; batches before it
+// stay imported. Imported events are not validated or published.
+func (s *fileStore) Import(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	batch := make([]RawEvent, 0, importBatchSize)
+	for {
+		var e RawEvent
+		err := dec.Decode(&e)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("decode event %d: %w", e.Sequence, err)
+		}
+		if err == nil {
+			batch = append(batch, e)
+		}
+		if len(batch) == importBatchSize || errors.Is(err, io.EOF) && len(batch) > 0 {
+			if err := s.importBatch(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+func (s *fileStore) importBatch(batch []RawEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.prepare(s.streamVersionQuery(), s.insertEventsQuery(1)); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	versionStmt, err := s.prepared(tx, s.streamVersionQuery())
+	if err != nil {
+		return err
+	}
+	insertStmt, err := s.prepared(tx, s.insertEventsQuery(1))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range batch {
+		if err := s.checkStreamWritable(tx, e.TenantID, e.AggregateID); err != nil {
+			return fmt.Errorf("import event %d: %w", e.Sequence, err)
+		}
+		var head struct {
+			Version       int64  `db:"version"`
+			AggregateType string `db:"aggregate_type"`
+		}
+		if err := versionStmt.Get(&head, e.TenantID, e.AggregateID.String()); err != nil {
+			return fmt.Errorf("select stream version: %w", err)
+		}
+		// exports from before versions were exported leave them out
+		if e.Version == 0 {
+			e.Version = head.Version + 1
+		}
+		if e.Version != head.Version+1 {
+			return fmt.Errorf("import event %d: version %d does not follow stream %s at version %d", e.Sequence, e.Version, e.AggregateID, head.Version)
+		}
+		if err := s.importEvent(tx, insertStmt, e); err != nil {
+			return fmt.Errorf("import event %d: %w", e.Sequence, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// importEvent inserts one event of an export. Callers hold s.mu.
+func (s *fileStore) importEvent(tx *sqlx.Tx, insert *sqlx.Stmt, e RawEvent) error {
+	metadata, err := encodeMetadata(e.Metadata)
+	if err != nil {
+		return err
+	}
+	var key []byte
+	var keyID string
+	if s.encrypt {
+		key, err = s.aggregateKey(tx, e.TenantID, e.AggregateID, e.Version)
+		if err != nil {
+			return err
+		}
+		keyID, key, err = s.currentPayloadKey(key)
+		if err != nil {
+			return err
+		}
+	}
+	payload, err := s.storePayload(key, e.AggregateID, e.EventType, e.Data)
+	if err != nil {
+		return err
+	}
+	correlationID, causationSeq := correlationColumns(e.Metadata)
+	row := dbEvent{
+		TenantID:      e.TenantID,
+		AggregateID:   e.AggregateID,
+		RecordedAt:    e.RecordedAt,
+		EventJSON:     payload.text,
+		EventType:     e.EventType,
+		Version:       e.Version,
+		Encrypted:     s.encrypt,
+		AggregateType: e.AggregateType,
+		Metadata:      metadata,
+		SchemaVersion: max(e.SchemaVersion, 1),
+		Compressed:    payload.compressed,
+		PayloadRef:    payload.ref,
+		EventData:     payload.data,
+		KeyID:         keyID,
+		CorrelationID: correlationID,
+		CausationSeq:  causationSeq,
+	}
+	res, err := insert.Exec(row.TenantID, row.AggregateID, row.RecordedAt, row.EventJSON, row.EventType, row.Version, row.Encrypted, row.AggregateType, row.Metadata, row.SchemaVersion, row.Compressed, row.PayloadRef, row.EventData, row.KeyID, row.CorrelationID, row.CausationSeq)
+	if err != nil {
+		return fmt.Errorf("insert into events: %w", err)
+	}
+	if row.Sequence, err = res.LastInsertId(); err != nil {
+		return fmt.Errorf("insert into events: %w", err)
+	}
+	return s.chainRows(tx, []dbEvent{row})
+}
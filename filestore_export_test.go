@@ -0,0 +1,105 @@
+package evoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// rawLog returns every event of s as ScanRaw sees it, without sequences
+func rawLog(t *testing.T, s *fileStore) []RawEvent {
+	t.Helper()
+	var evs []RawEvent
+	err := s.ScanRaw(RawQuery{}, func(e RawEvent) error {
+		e.Sequence = 0
+		evs = append(evs, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return evs
+}
+
+func TestExportImport(t *testing.T) {
+	s := newTestStore(t, WithPayloadEncryption())
+	id, other := NewID(), NewID()
+	if err := s.RecordAs(context.Background(), "cart", id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ForTenant("acme").Record(other, []Event{itemRemoved{SKU: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var export bytes.Buffer
+	if err := s.Export(&export, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(export.String(), "\n"); n != 3 {
+		t.Fatalf("exported %d lines, want one per event", n)
+	}
+	if !strings.Contains(export.String(), `"SKU":"a"`) {
+		t.Errorf("exported %s, want payloads decrypted", export.String())
+	}
+
+	imported := newTestStore(t)
+	if err := imported.Record(NewID(), []Event{itemAdded{SKU: "already there"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := imported.Import(bytes.NewReader(export.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	want, got := rawLog(t, s), rawLog(t, imported)[1:]
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if !bytes.Equal(wantJSON, gotJSON) {
+		t.Errorf("imported\n%s\nwant\n%s", gotJSON, wantJSON)
+	}
+	recs := mustLoad(t, imported.ForTenant("acme"), other)
+	if len(recs) != 1 || recs[0].Sequence != 4 || recs[0].Event != (itemRemoved{SKU: "c"}) {
+		t.Errorf("tenant loaded %+v, want its event at a new sequence", recs)
+	}
+
+	if err := imported.Import(bytes.NewReader(export.Bytes())); err == nil {
+		t.Error("imported the same events twice")
+	}
+	if n := len(rawLog(t, imported)); n != 4 {
+		t.Errorf("%d events after importing twice, want the first import's", n)
+	}
+}
+
+func TestExportFromSequence(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}, itemRemoved{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	var export bytes.Buffer
+	if err := s.Export(&export, 2); err != nil {
+		t.Fatal(err)
+	}
+	var seqs []int64
+	dec := json.NewDecoder(&export)
+	for dec.More() {
+		var e RawEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, e.Sequence)
+	}
+	if len(seqs) != 2 || seqs[0] != 2 || seqs[1] != 3 {
+		t.Errorf("exported sequences %v, want 2 and 3", seqs)
+	}
+}
+
+func TestImportMalformed(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Import(strings.NewReader(`{"eventType": "itemAdded", "data": `)); err == nil {
+		t.Error("imported a truncated export")
+	}
+	if err := s.Import(strings.NewReader("")); err != nil {
+		t.Errorf("importing an empty export: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package evoke
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// groupCommitMaxEvents is how many events a group gathers before it
+// commits without waiting out the latency
+const groupCommitMaxEvents = 2000
+
+// WithGroupCommit batches appends by concurrent callers into one
+// transaction, so they share a commit and its fsync: an append waits up to
+// maxLatency for others to join it. Under many concurrent writers this
+// trades a few milliseconds of latency for much higher throughput; a lone
+// writer only gains the latency. Appends still succeed or fail on their
+// own, a concurrency conflict failing only the append it is about.
+func WithGroupCommit(maxLatency time.Duration) FileStoreOption {
+	return func(s *fileStore) {
+		s.group = &groupCommit{maxLatency: maxLatency, full: make(chan struct{}, 1)}
+	}
+}
+
+// groupCommit gathers appends to commit together. The first append of a
+// group leads it: it waits for the others, then commits them all.
+type groupCommit struct {
+	maxLatency time.Duration
+	mu         sync.Mutex
+	pending    []*groupAppend
+	events     int
+	leading    bool
+	// full wakes the leader once the group has groupCommitMaxEvents
+	full chan struct{}
+}
+
+// groupAppend is an append waiting in a group
+type groupAppend struct {
+	tenantID      string
+	aggregateType string
+	aggregateID   uuid.UUID
+	expected      int64
+	evs           []Event
+	md            Metadata
+	eventMD       []Metadata
+	claim         *idempotencyClaim
+
+	recs []RecordedEvent
+	err  error
+	done chan struct{}
+}
+
+// appendGrouped appends events as part of a group, returning once the
+// group has committed
+func (s *fileStore) appendGrouped(tenantID, aggregateType string, aggregateID uuid.UUID, expected int64, evs []Event, md Metadata, eventMD []Metadata, claim *idempotencyClaim) ([]RecordedEvent, error) {
+	g := s.group
+	a := &groupAppend{
+		tenantID:      tenantID,
+		aggregateType: aggregateType,
+		aggregateID:   aggregateID,
+		expected:      expected,
+		evs:           evs,
+		md:            md,
+		eventMD:       eventMD,
+		claim:         claim,
+		done:          make(chan struct{}),
+	}
+
+	g.mu.Lock()
+	g.pending = append(g.pending, a)
+	g.events += len(evs)
+	if g.events >= groupCommitMaxEvents {
+		select {
+		case g.full <- struct{}{}:
+		default:
+		}
+	}
+	if g.leading {
+		g.mu.Unlock()
+		<-a.done
+		return a.recs, a.err
+	}
+	g.leading = true
+	g.mu.Unlock()
+
+	timer := time.NewTimer(g.maxLatency)
+	select {
+	case <-timer.C:
+	case <-g.full:
+		timer.Stop()
+	}
+
+	g.mu.Lock()
+	batch := g.pending
+	g.pending, g.events, g.leading = nil, 0, false
+	// a wake for this group must not cut the next one short
+	select {
+	case <-g.full:
+	default:
+	}
+	g.mu.Unlock()
+
+	s.commitGroup(batch)
+	return a.recs, a.err
+}
+
+// commitGroup appends every append of a group in one transaction, each in
+// a savepoint of its own so one failing doesn't fail the others
+func (s *fileStore) commitGroup(batch []*groupAppend) {
+	defer func() {
+		for _, a := range batch {
+			close(a.done)
+		}
+	}()
+	fail := func(err error) {
+		for _, a := range batch {
+			if a.err == nil {
+				a.recs, a.err = nil, err
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range batch {
+		if err := s.prepareAppend(len(a.evs)); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		fail(fmt.Errorf("begin: %w", err))
+		return
+	}
+	defer tx.Rollback()
+
+	for _, a := range batch {
+		if _, err := tx.Exec(`savepoint group_append`); err != nil {
+			fail(fmt.Errorf("savepoint: %w", err))
+			return
+		}
+		a.recs, a.err = s.insertEvents(tx, a.tenantID, a.aggregateType, a.aggregateID, a.expected, a.evs, a.md, a.eventMD)
+		if a.err == nil {
+			a.err = s.saveClaim(tx, a.claim, a.recs)
+		}
+		if a.err != nil {
+			a.recs = nil
+			if _, err := tx.Exec(`rollback to group_append`); err != nil {
+				fail(fmt.Errorf("rollback to savepoint: %w", err))
+				return
+			}
+		}
+		if _, err := tx.Exec(`release group_append`); err != nil {
+			fail(fmt.Errorf("release savepoint: %w", err))
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		fail(fmt.Errorf("commit: %w", err))
+	}
+}
@@ -0,0 +1,133 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Concurrent appends commit together, each recorded and published once
+// with sequences that don't interleave.
+func TestGroupCommit(t *testing.T) {
+	const latency = 100 * time.Millisecond
+	s := newTestStore(t, WithGroupCommit(latency))
+	var pub recordingPublisher
+	s.RegisterPublisher(&pub)
+
+	start := time.Now()
+	ids := make([]uuid.UUID, 20)
+	var wg sync.WaitGroup
+	for i := range ids {
+		ids[i] = NewID()
+		wg.Add(1)
+		go func(id uuid.UUID) {
+			defer wg.Done()
+			if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+				t.Error(err)
+			}
+		}(ids[i])
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed > 10*latency {
+		t.Errorf("20 concurrent appends took %v, want them to share groups", elapsed)
+	}
+
+	for _, id := range ids {
+		recs := mustLoad(t, s, id)
+		if len(recs) != 2 || recs[1].Sequence != recs[0].Sequence+1 || recs[1].Version != 2 {
+			t.Errorf("stream recorded %+v, want its two events in a row", recs)
+		}
+	}
+	if n := len(pub.published()); n != 40 {
+		t.Errorf("published %d events, want 40", n)
+	}
+}
+
+// A lone append waits out the latency for others to join it, unless it
+// fills a group on its own.
+func TestGroupCommitLatency(t *testing.T) {
+	s := newTestStore(t, WithGroupCommit(50*time.Millisecond))
+	start := time.Now()
+	if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("lone append committed after %v, before the latency", elapsed)
+	}
+
+	s = newTestStore(t, WithGroupCommit(time.Minute))
+	evs := make([]Event, groupCommitMaxEvents)
+	for i := range evs {
+		evs[i] = itemAdded{}
+	}
+	done := make(chan error)
+	go func() { done <- s.Record(NewID(), evs) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("a full group waited out the latency")
+	}
+}
+
+// A conflicting append fails alone, the rest of its group committing.
+func TestGroupCommitConflict(t *testing.T) {
+	s := newTestStore(t, WithGroupCommit(100*time.Millisecond))
+	ctx := context.Background()
+	contested, other := NewID(), NewID()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, id := range []uuid.UUID{contested, contested, other} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.RecordAtVersion(ctx, "cart", id, 0, []Event{itemAdded{}})
+		}()
+	}
+	wg.Wait()
+
+	conflicts := 0
+	for _, err := range errs[:2] {
+		if errors.Is(err, ErrConcurrencyConflict) {
+			conflicts++
+		} else if err != nil {
+			t.Errorf("append to the contested stream: %v", err)
+		}
+	}
+	if conflicts != 1 {
+		t.Errorf("appends to the contested stream returned %v, want one conflict", errs[:2])
+	}
+	if errs[2] != nil {
+		t.Errorf("append of another stream in the group: %v", errs[2])
+	}
+	if len(mustLoad(t, s, contested)) != 1 || len(mustLoad(t, s, other)) != 1 {
+		t.Error("streams don't have one event each")
+	}
+}
+
+func TestGroupCommitIdempotency(t *testing.T) {
+	s := newTestStore(t, WithGroupCommit(20*time.Millisecond))
+	bus := cartBus(s, s)
+	id := NewID()
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bus.Send(addItem{ID: id, SKU: "a", Key: "k"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(mustLoad(t, s, id)); n != 1 {
+		t.Errorf("recorded %d events of one command sent 5 times", n)
+	}
+}
@@ -0,0 +1,56 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// WithMaxWALSize makes Healthy fail once the write-ahead log grows past n
+// bytes, a sign that checkpoints can't keep up, usually because a long read
+// holds them back. The default is 64 MiB; n <= 0 disables the check.
+func WithMaxWALSize(n int64) FileStoreOption {
+	return func(s *fileStore) {
+		s.maxWALSize = n
+	}
+}
+
+// Healthy pings the database, checks the events table can be read and the
+// write-ahead log is below the size set by WithMaxWALSize.
+func (s *fileStore) Healthy(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+	if err := lockContext(ctx, &s.mu); err != nil {
+		return fmt.Errorf("wait for store: %w", err)
+	}
+	defer s.mu.Unlock()
+	var seq int64
+	if err := s.db.GetContext(ctx, &seq, `select coalesce(max(sequence), 0) from `+s.table); err != nil {
+		return fmt.Errorf("read %s table: %w", s.table, err)
+	}
+	if s.maxWALSize <= 0 {
+		return nil
+	}
+	var file string
+	if err := s.db.GetContext(ctx, &file, `select file from pragma_database_list where name = 'main'`); err != nil {
+		return fmt.Errorf("find database file: %w", err)
+	}
+	if file == "" {
+		return nil
+	}
+	fi, err := os.Stat(file + "-wal")
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("stat wal: %w", err)
+	}
+	if fi.Size() > s.maxWALSize {
+		return fmt.Errorf("wal is %d bytes, over the limit of %d", fi.Size(), s.maxWALSize)
+	}
+	return nil
+}
+
+func (t *tenantStore) Healthy(ctx context.Context) error {
+	return t.store.Healthy(ctx)
+}
@@ -0,0 +1,54 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthy(t *testing.T) {
+	s := newTestStore(t, WithMaxWALSize(1<<20))
+	for name, store := range map[string]HealthChecker{"file": s, "tenant": s.ForTenant("acme")} {
+		if err := store.Healthy(context.Background()); err != nil {
+			t.Errorf("%s store unhealthy: %v", name, err)
+		}
+	}
+
+	// grow the wal past the limit without checkpointing it
+	if _, err := s.db.Exec(`pragma wal_autocheckpoint = 0`); err != nil {
+		t.Fatal(err)
+	}
+	evs := make([]Event, 500)
+	for i := range evs {
+		evs[i] = itemAdded{SKU: strings.Repeat("x", 3000)}
+	}
+	if err := s.Record(NewID(), evs); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Healthy(context.Background()); err == nil || !strings.Contains(err.Error(), "over the limit") {
+		t.Errorf("Healthy with a large wal returned %v", err)
+	}
+}
+
+func TestHealthyWaitsForStore(t *testing.T) {
+	s := newTestStore(t, WithMaxWALSize(0))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Healthy(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Healthy of a busy store returned %v, want the deadline", err)
+	}
+}
+
+func TestUnhealthyAfterShutdown(t *testing.T) {
+	s := newTestStore(t, WithLogger(&recordingLogger{}))
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Healthy(context.Background()); err == nil {
+		t.Error("a shut down store is healthy")
+	}
+}
@@ -0,0 +1,185 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RawEvent is a stored event with its payload left undecoded, for tools that
+// inspect a store without access to the application's event registry.
+type RawEvent struct {
+	Sequence      int64           `json:"sequence"`
+	RecordedAt    int64           `json:"recordedAt"`
+	AggregateID   uuid.UUID       `json:"aggregateId"`
+	AggregateType string          `json:"aggregateType,omitempty"`
+	Version       int64           `json:"version"`
+	EventType     string          `json:"eventType"`
+	Data          json.RawMessage `json:"data"`
+	Metadata      Metadata        `json:"metadata,omitempty"`
+	TenantID      string          `json:"tenantId,omitempty"`
+	SchemaVersion int             `json:"schemaVersion,omitempty"`
+}
+
+// RawQuery selects events for ScanRaw. Zero values match everything.
+type RawQuery struct {
+	FromSequence  int64
+	AggregateID   uuid.UUID
+	AggregateType string
+	EventType     string
+	Limit         int
+}
+
+// ScanRaw calls fn for each stored event matching q, in sequence order,
+// tiered ones included.
+func (s *fileStore) ScanRaw(q RawQuery, fn func(RawEvent) error) error {
+	match := func(row dbEvent) bool {
+		return (q.AggregateID == uuid.Nil || row.AggregateID == q.AggregateID) &&
+			(q.AggregateType == "" || row.AggregateType == q.AggregateType) &&
+			(q.EventType == "" || row.EventType == q.EventType)
+	}
+	where := []string{"sequence >= ?"}
+	args := []any{q.FromSequence}
+	if q.AggregateID != uuid.Nil {
+		where = append(where, "aggregate_id = ?")
+		args = append(args, q.AggregateID.String())
+	}
+	if q.AggregateType != "" {
+		where = append(where, "aggregate_type = ?")
+		args = append(args, q.AggregateType)
+	}
+	if q.EventType != "" {
+		where = append(where, "event_type = ?")
+		args = append(args, q.EventType)
+	}
+	query := `select * from ` + s.eventsSource + ` where ` + strings.Join(where, " and ") + ` order by sequence asc`
+
+	if q.Limit > 0 {
+		query += ` limit ?`
+		args = append(args, q.Limit)
+	}
+
+	// the database is read before the segments, as by readAll
+	var stored []dbEvent
+	s.mu.Lock()
+	err := s.db.Select(&stored, query, args...)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("select from events: %w", err)
+	}
+	tiered, err := s.segmentRows(q.FromSequence)
+	if err != nil {
+		return err
+	}
+	var rows []dbEvent
+	for _, row := range tiered {
+		if match(row) {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1].Sequence
+		for len(stored) > 0 && stored[0].Sequence <= last {
+			stored = stored[1:]
+		}
+	}
+	rows = append(rows, stored...)
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+
+	s.mu.Lock()
+	// decrypt up front; shredded payloads come out as null
+	keys := newKeyring(s.db)
+	raws := make([]RawEvent, len(rows))
+	for i, row := range rows {
+		data, err := s.payload(&row, keys)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		md, err := decodeMetadata(row.Metadata)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		raws[i] = RawEvent{
+			Sequence:      row.Sequence,
+			RecordedAt:    row.RecordedAt,
+			AggregateID:   row.AggregateID,
+			AggregateType: row.AggregateType,
+			Version:       row.Version,
+			EventType:     row.EventType,
+			Data:          json.RawMessage(data),
+			Metadata:      md,
+			TenantID:      row.TenantID,
+			SchemaVersion: row.schemaVersion(),
+		}
+	}
+	s.mu.Unlock()
+
+	for _, raw := range raws {
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type StoreStats struct {
+	Events          int64
+	Streams         int64
+	LastSequence    int64
+	FirstRecordedAt int64
+	LastRecordedAt  int64
+	EventTypes      map[string]int64
+}
+
+// Stats summarizes the contents of the store.
+func (s *fileStore) Stats() (StoreStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stats StoreStats
+	err := s.db.QueryRow(`
+		select count(*),
+		       count(distinct aggregate_id),
+		       coalesce(max(sequence), 0),
+		       coalesce(min(recorded_at), 0),
+		       coalesce(max(recorded_at), 0)
+		from `+s.eventsSource).Scan(&stats.Events, &stats.Streams, &stats.LastSequence, &stats.FirstRecordedAt, &stats.LastRecordedAt)
+	if err != nil {
+		return StoreStats{}, fmt.Errorf("select stats: %w", err)
+	}
+
+	var types []struct {
+		EventType string `db:"event_type"`
+		Count     int64  `db:"count"`
+	}
+	err = s.db.Select(&types, `select event_type, count(*) as count from `+s.eventsSource+` group by event_type`)
+	if err != nil {
+		return StoreStats{}, fmt.Errorf("select event types: %w", err)
+	}
+	stats.EventTypes = make(map[string]int64, len(types))
+	for _, t := range types {
+		stats.EventTypes[t.EventType] = t.Count
+	}
+
+	return stats, nil
+}
+
+// StoredEventTypes returns the distinct event types found in the store, in
+// sorted order.
+func (s *fileStore) StoredEventTypes() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var types []string
+	err := s.db.Select(&types, `select distinct event_type from `+s.eventsSource+` order by event_type`)
+	if err != nil {
+		return nil, fmt.Errorf("select event types: %w", err)
+	}
+	return types, nil
+}
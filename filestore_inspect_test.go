@@ -0,0 +1,120 @@
+package evoke
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// inspectedStore returns a store holding a cart and an order stream, the
+// cart's events at sequences 1, 2 and 4
+func inspectedStore(t *testing.T) (*fileStore, uuid.UUID, uuid.UUID) {
+	t.Helper()
+	s := newTestStore(t)
+	ctx := context.Background()
+	cartID, orderID := NewID(), NewID()
+	for _, rec := range []struct {
+		typ string
+		id  uuid.UUID
+		e   Event
+	}{
+		{"Cart", cartID, itemAdded{SKU: "a", Qty: 1}},
+		{"Cart", cartID, itemAdded{SKU: "b", Qty: 1}},
+		{"Order", orderID, itemAdded{SKU: "a", Qty: 2}},
+		{"Cart", cartID, itemRemoved{SKU: "a"}},
+	} {
+		if err := s.RecordAs(ctx, rec.typ, rec.id, []Event{rec.e}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, cartID, orderID
+}
+
+func TestScanRaw(t *testing.T) {
+	s, cartID, orderID := inspectedStore(t)
+	tests := []struct {
+		name string
+		q    RawQuery
+		want []int64
+	}{
+		{name: "everything", want: []int64{1, 2, 3, 4}},
+		{name: "from", q: RawQuery{FromSequence: 3}, want: []int64{3, 4}},
+		{name: "aggregate", q: RawQuery{AggregateID: orderID}, want: []int64{3}},
+		{name: "aggregate type", q: RawQuery{AggregateType: "Cart"}, want: []int64{1, 2, 4}},
+		{name: "event type", q: RawQuery{EventType: "itemRemoved"}, want: []int64{4}},
+		{name: "combined", q: RawQuery{AggregateID: cartID, EventType: "itemAdded", FromSequence: 2}, want: []int64{2}},
+		{name: "limit", q: RawQuery{AggregateType: "Cart", Limit: 2}, want: []int64{1, 2}},
+		{name: "nothing", q: RawQuery{EventType: "itemSold"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			err := s.ScanRaw(tt.q, func(e RawEvent) error {
+				got = append(got, e.Sequence)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("scanned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanRawEvent(t *testing.T) {
+	s, _, orderID := inspectedStore(t)
+	var got []RawEvent
+	err := s.ScanRaw(RawQuery{AggregateID: orderID}, func(e RawEvent) error {
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("scanned %d events, want 1", len(got))
+	}
+	e := got[0]
+	if e.AggregateType != "Order" || e.EventType != "itemAdded" || e.Version != 1 || string(e.Data) != `{"SKU":"a","Qty":2}` {
+		t.Errorf("scanned %+v", e)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s, _, _ := inspectedStore(t)
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Events != 4 || stats.Streams != 2 || stats.LastSequence != 4 {
+		t.Errorf("stats %+v, want 4 events in 2 streams", stats)
+	}
+	if stats.FirstRecordedAt == 0 || stats.LastRecordedAt < stats.FirstRecordedAt {
+		t.Errorf("recorded between %d and %d", stats.FirstRecordedAt, stats.LastRecordedAt)
+	}
+	if stats.EventTypes["itemAdded"] != 3 || stats.EventTypes["itemRemoved"] != 1 {
+		t.Errorf("event types %v", stats.EventTypes)
+	}
+
+	types, err := s.StoredEventTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(types, []string{"itemAdded", "itemRemoved"}) {
+		t.Errorf("stored event types %v", types)
+	}
+}
+
+func TestStatsEmpty(t *testing.T) {
+	stats, err := newTestStore(t).Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Events != 0 || stats.LastSequence != 0 || len(stats.EventTypes) != 0 {
+		t.Errorf("stats of an empty store %+v", stats)
+	}
+}
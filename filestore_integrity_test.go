@@ -0,0 +1,105 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// chainedStore returns a hash-chained store of five events over two streams
+func chainedStore(t *testing.T, opts ...FileStoreOption) (*fileStore, [2]uuid.UUID) {
+	t.Helper()
+	s := newTestStore(t, append([]FileStoreOption{WithHashChain()}, opts...)...)
+	ids := [2]uuid.UUID{NewID(), NewID()}
+	for _, id := range []uuid.UUID{ids[0], ids[1], ids[0], ids[0], ids[1]} {
+		if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, ids
+}
+
+// integrityFailure returns the sequence VerifyIntegrity failed at, or 0
+func integrityFailure(t *testing.T, s *fileStore, fromSeq int64) int64 {
+	t.Helper()
+	err := s.VerifyIntegrity(fromSeq)
+	if err == nil {
+		return 0
+	}
+	var ierr *IntegrityError
+	if !errors.As(err, &ierr) || !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("VerifyIntegrity returned %v, want an IntegrityError", err)
+	}
+	return ierr.Sequence
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	s, _ := chainedStore(t)
+	for _, from := range []int64{1, 3, 6} {
+		if seq := integrityFailure(t, s, from); seq != 0 {
+			t.Errorf("intact log failed to verify from %d at %d", from, seq)
+		}
+	}
+}
+
+func TestVerifyIntegrityDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string
+		want   int64
+	}{
+		{"changed payload", `update events set event_json = '{"SKU":"b","Qty":0}' where sequence = 3`, 3},
+		{"changed version", `update events set version = 7 where sequence = 3`, 3},
+		{"removed row", `delete from events where sequence = 3`, 4},
+		{"unchained row", `insert into events(tenant_id, aggregate_id, recorded_at, event_json, event_type, version, encrypted)
+			select tenant_id, aggregate_id, recorded_at, event_json, event_type, 10, 0 from events where sequence = 5`, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := chainedStore(t)
+			if _, err := s.db.Exec(tt.tamper); err != nil {
+				t.Fatal(err)
+			}
+			if seq := integrityFailure(t, s, 1); seq != tt.want {
+				t.Errorf("VerifyIntegrity failed at %d, want %d", seq, tt.want)
+			}
+		})
+	}
+}
+
+// Rows from before the chain was started are skipped, and shredding keeps
+// the chain intact.
+func TestHashChainStartedLater(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	open := func(opts ...FileStoreOption) *fileStore {
+		s, err := NewFileStore(path, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterEvent(s, &itemAdded{})
+		return s
+	}
+	id := NewID()
+	plain := open(WithPayloadEncryption())
+	if err := plain.Record(id, []Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s := open(WithPayloadEncryption(), WithHashChain())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	if err := s.Record(id, []Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ShredAggregate(id); err != nil {
+		t.Fatal(err)
+	}
+	if seq := integrityFailure(t, s, 1); seq != 0 {
+		t.Errorf("VerifyIntegrity failed at %d", seq)
+	}
+}
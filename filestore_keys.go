@@ -0,0 +1,269 @@
+package evoke
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// KeyProvider supplies the master keys payloads are encrypted with, for
+// example from a KMS or Vault. Keys are 32 bytes and never change once
+// issued under an ID. Stores call the provider while recording and reading,
+// so providers backed by a remote service should cache keys.
+type KeyProvider interface {
+	// CurrentKey returns the key new payloads are encrypted with
+	CurrentKey() (keyID string, key []byte, err error)
+	// GetKey returns a key issued before, to read payloads encrypted with it
+	GetKey(keyID string) ([]byte, error)
+}
+
+// MemoryKeyProvider is a KeyProvider holding its keys in memory, for tests
+// and for keys loaded from configuration.
+type MemoryKeyProvider struct {
+	mu      sync.Mutex
+	keys    map[string][]byte
+	current string
+}
+
+func NewMemoryKeyProvider() *MemoryKeyProvider {
+	return &MemoryKeyProvider{keys: make(map[string][]byte)}
+}
+
+// AddKey adds a key and makes it the current one.
+func (p *MemoryKeyProvider) AddKey(keyID string, key []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[keyID] = key
+	p.current = keyID
+}
+
+// GenerateKey adds a random key under keyID and makes it the current one.
+func (p *MemoryKeyProvider) GenerateKey(keyID string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	p.AddKey(keyID, key)
+	return nil
+}
+
+func (p *MemoryKeyProvider) CurrentKey() (string, []byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == "" {
+		return "", nil, errors.New("no current key")
+	}
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *MemoryKeyProvider) GetKey(keyID string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("no key %q", keyID)
+	}
+	return key, nil
+}
+
+// WithKeyProvider encrypts payloads, like WithPayloadEncryption, with keys
+// made from both their aggregate's key and a master key from provider, so
+// ShredAggregate still makes an aggregate's events unreadable and the
+// events can't be read from the database file alone. Each row records the
+// ID of its master key. Rotating the provider's current key is lazy: new
+// events use the new key and older ones stay readable with the old; use
+// RotateKeys to re-encrypt them.
+func WithKeyProvider(provider KeyProvider) FileStoreOption {
+	return func(s *fileStore) {
+		s.encrypt = true
+		s.keyProvider = provider
+	}
+}
+
+// migrateKeyIDColumn adds master key IDs to stores created before them
+func migrateKeyIDColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "key_id")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column key_id text not null default ''`); err != nil {
+			return fmt.Errorf("failed to add key_id column: %w", err)
+		}
+	}
+	return nil
+}
+
+// payloadKey makes the key payloads are encrypted with from a master key
+// and an aggregate key
+func payloadKey(master, aggregateKey []byte) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("evoke payload key\n"))
+	mac.Write(aggregateKey)
+	return mac.Sum(nil)
+}
+
+// currentPayloadKey returns the ID of the provider's current key, or "" for
+// stores without a provider, and the key to encrypt an aggregate's new
+// payloads with
+func (s *fileStore) currentPayloadKey(aggregateKey []byte) (string, []byte, error) {
+	if s.keyProvider == nil {
+		return "", aggregateKey, nil
+	}
+	keyID, master, err := s.keyProvider.CurrentKey()
+	if err != nil {
+		return "", nil, fmt.Errorf("get current key: %w", err)
+	}
+	return keyID, payloadKey(master, aggregateKey), nil
+}
+
+// rowPayloadKey returns the key a row's payload was encrypted with
+func (s *fileStore) rowPayloadKey(keyID string, aggregateKey []byte) ([]byte, error) {
+	if keyID == "" {
+		return aggregateKey, nil
+	}
+	if s.keyProvider == nil {
+		return nil, fmt.Errorf("payload is encrypted with master key %q, but the store has no key provider (hint: use WithKeyProvider)", keyID)
+	}
+	master, err := s.keyProvider.GetKey(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("get key %q: %w", keyID, err)
+	}
+	return payloadKey(master, aggregateKey), nil
+}
+
+// RotateKeys re-encrypts the payloads not encrypted with the provider's
+// current key, including those from before the store had a provider, a
+// batch per transaction, returning how many were re-encrypted. It can be
+// stopped with ctx and run again later, so a large store can be rotated a
+// little at a time. Payloads of shredded aggregates are left as they are.
+//
+// Stores WithHashChain can't rotate keys, as the chain is made of the
+// payloads as stored.
+func (s *fileStore) RotateKeys(ctx context.Context) (int64, error) {
+	if s.keyProvider == nil {
+		return 0, fmt.Errorf("no key provider configured (hint: use WithKeyProvider)")
+	}
+	if s.hashChain {
+		return 0, fmt.Errorf("can't rotate the keys of a hash chained store")
+	}
+	var n, after int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		rotated, last, err := s.rotateBatch(ctx, after)
+		n += rotated
+		if err != nil || last == after {
+			return n, err
+		}
+		after = last
+	}
+}
+
+// rotateBatch re-encrypts the next batch of payloads after the sequence
+// after, returning how many were re-encrypted and the last sequence looked
+// at
+func (s *fileStore) rotateBatch(ctx context.Context, after int64) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keyID, master, err := s.keyProvider.CurrentKey()
+	if err != nil {
+		return 0, after, fmt.Errorf("get current key: %w", err)
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, after, err
+	}
+	defer tx.Rollback()
+
+	var rows []dbEvent
+	err = tx.Select(&rows, `select * from `+s.table+` where sequence > ? and encrypted and key_id != ? order by sequence limit ?`, after, keyID, deliveryBatch)
+	if err != nil {
+		return 0, after, fmt.Errorf("select from events: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, after, nil
+	}
+	keys := newKeyring(tx)
+	var n int64
+	for i := range rows {
+		row := &rows[i]
+		aggregateKey, err := keys.lookup(row.TenantID, row.AggregateID, row.Version)
+		if err != nil {
+			return 0, after, err
+		}
+		if aggregateKey == nil {
+			continue
+		}
+		if err := s.reencrypt(ctx, tx, row, aggregateKey, keyID, payloadKey(master, aggregateKey)); err != nil {
+			return 0, after, fmt.Errorf("re-encrypt event %d: %w", row.Sequence, err)
+		}
+		n++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, after, err
+	}
+	return n, rows[len(rows)-1].Sequence, nil
+}
+
+// reencrypt encrypts a row's payload under key for the master key keyID,
+// keeping it where it was stored
+func (s *fileStore) reencrypt(ctx context.Context, tx *sqlx.Tx, row *dbEvent, aggregateKey []byte, keyID string, key []byte) error {
+	ref := row.PayloadRef
+	if ref != "" {
+		if err := s.externalPayload(row); err != nil {
+			return err
+		}
+	}
+	raw, err := row.rawPayload()
+	if err != nil {
+		return err
+	}
+	oldKey, err := s.rowPayloadKey(row.KeyID, aggregateKey)
+	if err != nil {
+		return err
+	}
+	data, err := decryptPayload(oldKey, row.AggregateID, raw)
+	if err != nil {
+		return err
+	}
+	sealed, err := encryptPayload(key, row.AggregateID, data)
+	if err != nil {
+		return err
+	}
+	asBytes := row.EventData != nil
+	if ref != "" {
+		blob := []byte(textPayload(sealed, true))
+		if asBytes {
+			blob = sealed
+		}
+		// a new blob, so a rollback leaves the row with the old one; the
+		// old blob is left behind
+		ref = "payloads/" + NewID().String()
+		if asBytes {
+			ref += binaryBlobSuffix
+		}
+		if err := s.payloadBlobs.PutSegment(ctx, ref, blob); err != nil {
+			return fmt.Errorf("store payload %s: %w", ref, err)
+		}
+		_, err = tx.Exec(`update `+s.table+` set payload_ref = ?, key_id = ? where sequence = ?`, ref, keyID, row.Sequence)
+	} else if asBytes {
+		_, err = tx.Exec(`update `+s.table+` set event_data = ?, key_id = ? where sequence = ?`, sealed, keyID, row.Sequence)
+	} else {
+		_, err = tx.Exec(`update `+s.table+` set event_json = ?, key_id = ? where sequence = ?`, textPayload(sealed, true), keyID, row.Sequence)
+	}
+	if err != nil {
+		return fmt.Errorf("update events: %w", err)
+	}
+	return nil
+}
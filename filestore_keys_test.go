@@ -0,0 +1,138 @@
+package evoke
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// keyIDs returns the master key ID of each row of s
+func keyIDs(t *testing.T, s *fileStore) []string {
+	t.Helper()
+	var ids []string
+	if err := s.db.Select(&ids, `select key_id from `+s.table+` order by sequence`); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// openKeyStore opens the store at path with provider, or without one if
+// provider is nil
+func openKeyStore(t *testing.T, path string, provider KeyProvider) *fileStore {
+	t.Helper()
+	opts := []FileStoreOption{WithPayloadEncryption()}
+	if provider != nil {
+		opts = []FileStoreOption{WithKeyProvider(provider)}
+	}
+	s, err := NewFileStore(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	RegisterEvent(s, &itemAdded{})
+	return s
+}
+
+func TestKeyProvider(t *testing.T) {
+	provider := NewMemoryKeyProvider()
+	if err := provider.GenerateKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "events.db")
+	s := openKeyStore(t, path, provider)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.GenerateKey("k2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(id, []Event{itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := keyIDs(t, s); !slices.Equal(got, []string{"k1", "k2"}) {
+		t.Errorf("rows keyed %q, want the key current when each was recorded", got)
+	}
+	if recs := mustLoad(t, s, id); len(recs) != 2 || recs[0].Event != (itemAdded{SKU: "a"}) || recs[1].Event != (itemAdded{SKU: "b"}) {
+		t.Errorf("loaded %+v, want both events readable", recs)
+	}
+
+	// the aggregate keys alone don't read the payloads
+	if _, err := openKeyStore(t, path, nil).LoadStream(id); err == nil {
+		t.Error("loaded a stream encrypted with master keys without a provider")
+	}
+	if _, err := openKeyStore(t, path, NewMemoryKeyProvider()).LoadStream(id); err == nil {
+		t.Error("loaded a stream without its master keys")
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	id, shredded := NewID(), NewID()
+	plain := openKeyStore(t, path, nil)
+	if err := plain.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Record(shredded, []Event{itemAdded{SKU: "gone"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.ShredAggregate(shredded); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewMemoryKeyProvider()
+	if err := provider.GenerateKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	s := openKeyStore(t, path, provider)
+	if err := s.Record(id, []Event{itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.GenerateKey("k2"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.RotateKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("re-encrypted %d payloads, want the two not under k2", n)
+	}
+	if got := keyIDs(t, s); !slices.Equal(got, []string{"k2", "", "k2"}) {
+		t.Errorf("rows keyed %q, want all but the shredded one under k2", got)
+	}
+	if n, err := s.RotateKeys(context.Background()); n != 0 || err != nil {
+		t.Errorf("rotating again re-encrypted %d payloads, %v", n, err)
+	}
+
+	// the old key is no longer needed
+	current := NewMemoryKeyProvider()
+	key, _ := provider.GetKey("k2")
+	current.AddKey("k2", key)
+	recs := mustLoad(t, openKeyStore(t, path, current), id)
+	if len(recs) != 2 || recs[0].Event != (itemAdded{SKU: "a"}) || recs[1].Event != (itemAdded{SKU: "b"}) {
+		t.Errorf("loaded %+v after rotation", recs)
+	}
+}
+
+func TestRotateKeysUnsupported(t *testing.T) {
+	if _, err := newTestStore(t, WithPayloadEncryption()).RotateKeys(context.Background()); err == nil {
+		t.Error("rotated keys without a key provider")
+	}
+	provider := NewMemoryKeyProvider()
+	if err := provider.GenerateKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestStore(t, WithKeyProvider(provider), WithHashChain()).RotateKeys(context.Background()); err == nil {
+		t.Error("rotated the keys of a hash chained store")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newTestStore(t, WithKeyProvider(provider)).RotateKeys(ctx); err != context.Canceled {
+		t.Errorf("RotateKeys with a cancelled context returned %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RecordMulti appends to several streams in one transaction, so a process
+// manager updating two aggregates never leaves one of them updated without
+// the other. The streams' events are numbered in the order given; a stream
+// may appear more than once.
+func (s *fileStore) RecordMulti(streams []StreamEvents) error {
+	return s.recordMulti(context.Background(), "", streams)
+}
+
+// RecordMultiContext is RecordMulti as part of the trace in ctx.
+func (s *fileStore) RecordMultiContext(ctx context.Context, streams []StreamEvents) error {
+	return s.recordMulti(ctx, "", streams)
+}
+
+func (t *tenantStore) RecordMulti(streams []StreamEvents) error {
+	return t.store.recordMulti(context.Background(), t.tenantID, streams)
+}
+
+func (s *fileStore) recordMulti(ctx context.Context, tenantID string, streams []StreamEvents) (err error) {
+	ctx, end := s.tracer.Start(ctx, "evoke.append")
+	defer func() { end(err) }()
+
+	md := Metadata{}
+	s.tracer.Inject(ctx, md)
+	correlate(ctx, md)
+
+	claim := claimIn(ctx, s)
+	recs, err := s.appendMulti(tenantID, streams, md, claim)
+	if err != nil {
+		return err
+	}
+	claim.markSaved()
+	s.logger.Debug("evoke: recorded events", "tenant", tenantID, "streams", len(streams), "events", len(recs),
+		"first_sequence", recs[0].Sequence, "last_sequence", recs[len(recs)-1].Sequence)
+	NoteRecorded(ctx, recs)
+
+	return s.publish(tenantID, recs)
+}
+
+func (s *fileStore) appendMulti(tenantID string, streams []StreamEvents, md Metadata, claim *idempotencyClaim) (recs []RecordedEvent, err error) {
+	n := 0
+	for _, stream := range streams {
+		if len(stream.Events) == 0 {
+			return nil, fmt.Errorf("no events to append to stream %s", stream.AggregateID)
+		}
+		n += len(stream.Events)
+	}
+	if n == 0 {
+		return nil, errors.New("no events to append")
+	}
+
+	start := time.Now()
+	defer func() {
+		s.inst.EventsAppended(n, time.Since(start), err)
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stream := range streams {
+		if err := s.prepareAppend(len(stream.Events)); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	out := make([]RecordedEvent, 0, n)
+	for _, stream := range streams {
+		recs, err := s.insertEvents(tx, tenantID, stream.AggregateType, stream.AggregateID, anyVersion, stream.Events, md, nil)
+		if err != nil {
+			return nil, fmt.Errorf("stream %s: %w", stream.AggregateID, err)
+		}
+		out = append(out, recs...)
+	}
+	if err := s.saveClaim(tx, claim, out); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package evoke
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRecordMulti(t *testing.T) {
+	s := newTestStore(t)
+	for name, store := range map[string]MultiRecorder{"file": s, "tenant": s.ForTenant("acme")} {
+		t.Run(name, func(t *testing.T) {
+			var p recordingPublisher
+			store.(EventStore).RegisterPublisher(&p)
+			order, stock := NewID(), NewID()
+			err := store.RecordMulti([]StreamEvents{
+				{AggregateID: order, AggregateType: "order", Events: []Event{itemAdded{SKU: "a"}}},
+				{AggregateID: stock, Events: []Event{itemRemoved{SKU: "a"}}},
+				{AggregateID: order, Events: []Event{itemAdded{SKU: "b"}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			published := p.published()
+			if len(published) != 3 || published[1].AggregateID != stock {
+				t.Fatalf("published %+v, want the events in the order given", published)
+			}
+			recs := mustLoad(t, store.(EventStore), order)
+			if len(recs) != 2 || recs[0].AggregateType != "order" || recs[1].Version != 2 || recs[1].Sequence != published[2].Sequence {
+				t.Errorf("order stream %+v", recs)
+			}
+		})
+	}
+}
+
+func TestRecordMultiIsAtomic(t *testing.T) {
+	s := newTestStore(t)
+	order, closed := NewID(), NewID()
+	if err := s.Record(closed, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TombstoneStream(closed); err != nil {
+		t.Fatal(err)
+	}
+	err := s.RecordMulti([]StreamEvents{
+		{AggregateID: order, Events: []Event{itemAdded{}}},
+		{AggregateID: closed, Events: []Event{itemAdded{}}},
+	})
+	if !errors.Is(err, ErrStreamTombstoned) {
+		t.Errorf("RecordMulti to a tombstoned stream returned %v", err)
+	}
+	if n := len(mustLoad(t, s, order)); n != 0 {
+		t.Errorf("recorded %d events to the other stream of a failed append", n)
+	}
+
+	for name, streams := range map[string][]StreamEvents{
+		"no streams":       nil,
+		"a stream without": {{AggregateID: order, Events: []Event{itemAdded{}}}, {AggregateID: NewID()}},
+	} {
+		if err := s.RecordMulti(streams); err == nil {
+			t.Errorf("RecordMulti with %s events succeeded", name)
+		}
+	}
+	var n int
+	if err := s.db.Get(&n, `select count(*) from `+s.table); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d events stored after failed appends, want 1", n)
+	}
+}
@@ -0,0 +1,101 @@
+package evoke
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Synchronous is a setting of SQLite's synchronous pragma, trading
+// durability of the last transactions on power loss for append throughput.
+type Synchronous string
+
+const (
+	SynchronousOff    Synchronous = "OFF"
+	SynchronousNormal Synchronous = "NORMAL"
+	SynchronousFull   Synchronous = "FULL"
+	SynchronousExtra  Synchronous = "EXTRA"
+)
+
+// JournalMode is a setting of SQLite's journal_mode pragma.
+type JournalMode string
+
+const (
+	JournalModeWAL      JournalMode = "WAL"
+	JournalModeDelete   JournalMode = "DELETE"
+	JournalModeTruncate JournalMode = "TRUNCATE"
+	JournalModePersist  JournalMode = "PERSIST"
+	JournalModeMemory   JournalMode = "MEMORY"
+	JournalModeOff      JournalMode = "OFF"
+)
+
+// sqliteConfig holds the pragmas a database is opened with
+type sqliteConfig struct {
+	journalMode JournalMode
+	synchronous Synchronous
+	busyTimeout time.Duration
+}
+
+func defaultSQLiteConfig() sqliteConfig {
+	return sqliteConfig{
+		journalMode: JournalModeWAL,
+		synchronous: SynchronousNormal,
+		busyTimeout: 5 * time.Second,
+	}
+}
+
+// WithSynchronous sets the synchronous pragma. The default, NORMAL, can lose
+// the last appends on power loss in WAL mode; FULL does not.
+func WithSynchronous(mode Synchronous) FileStoreOption {
+	return func(s *fileStore) {
+		s.sqlite.synchronous = mode
+	}
+}
+
+// WithBusyTimeout sets how long a write waits for another connection to the
+// file, e.g. a Scheduler, before failing with SQLITE_BUSY. The default is 5
+// seconds.
+func WithBusyTimeout(d time.Duration) FileStoreOption {
+	return func(s *fileStore) {
+		s.sqlite.busyTimeout = d
+	}
+}
+
+// WithJournalMode sets the journal_mode pragma. The default is WAL.
+func WithJournalMode(mode JournalMode) FileStoreOption {
+	return func(s *fileStore) {
+		s.sqlite.journalMode = mode
+	}
+}
+
+// WithTableName keeps events in the named table instead of "events", so
+// several stores can share a database file. The store's other tables are
+// not renamed.
+func WithTableName(table string) FileStoreOption {
+	return func(s *fileStore) {
+		s.table = table
+	}
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkOptions rejects option values that would end up spliced into SQL
+func (s *fileStore) checkOptions() error {
+	if !identifierPattern.MatchString(s.table) {
+		return fmt.Errorf("invalid table name %q", s.table)
+	}
+	switch s.sqlite.synchronous {
+	case SynchronousOff, SynchronousNormal, SynchronousFull, SynchronousExtra:
+	default:
+		return fmt.Errorf("invalid synchronous mode %q", s.sqlite.synchronous)
+	}
+	switch s.sqlite.journalMode {
+	case JournalModeWAL, JournalModeDelete, JournalModeTruncate, JournalModePersist, JournalModeMemory, JournalModeOff:
+	default:
+		return fmt.Errorf("invalid journal mode %q", s.sqlite.journalMode)
+	}
+	if s.readConns > 0 && s.sqlite.journalMode != JournalModeWAL {
+		return fmt.Errorf("a read pool needs journal mode %s, not %s", JournalModeWAL, s.sqlite.journalMode)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package evoke
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLitePragmas(t *testing.T) {
+	s := newTestStore(t, WithSynchronous(SynchronousFull), WithJournalMode(JournalModeDelete), WithBusyTimeout(250*time.Millisecond))
+	var synchronous, busyTimeout int
+	var journalMode string
+	if err := s.db.QueryRow(`pragma synchronous`).Scan(&synchronous); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.QueryRow(`pragma journal_mode`).Scan(&journalMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.db.QueryRow(`pragma busy_timeout`).Scan(&busyTimeout); err != nil {
+		t.Fatal(err)
+	}
+	// synchronous reads back as 0 (OFF) to 3 (EXTRA)
+	if synchronous != 2 {
+		t.Errorf("synchronous %d, want 2 (FULL)", synchronous)
+	}
+	if !strings.EqualFold(journalMode, string(JournalModeDelete)) {
+		t.Errorf("journal_mode %q, want DELETE", journalMode)
+	}
+	if busyTimeout != 250 {
+		t.Errorf("busy_timeout %d, want 250", busyTimeout)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for name, opt := range map[string]FileStoreOption{
+		"table name":   WithTableName("events; drop table events"),
+		"synchronous":  WithSynchronous("SOMETIMES"),
+		"journal mode": WithJournalMode("WAL; drop table events"),
+	} {
+		if _, err := NewFileStore(filepath.Join(t.TempDir(), "events.db"), opt); err == nil {
+			t.Errorf("opened a store with an invalid %s", name)
+		}
+	}
+}
+
+// Stores with their own tables share a file without seeing each other's
+// events.
+func TestWithTableName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	open := func(table string) EventStore {
+		s, err := NewFileStore(path, WithTableName(table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Shutdown(context.Background()) })
+		RegisterEvent(s, &itemAdded{})
+		return s
+	}
+	orders, carts := open("orders"), open("carts")
+	id := NewID()
+	if err := orders.Record(id, []Event{itemAdded{SKU: "order"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := carts.Record(id, []Event{itemAdded{SKU: "cart"}, itemAdded{SKU: "cart"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := mustLoad(t, orders, id); len(recs) != 1 || recs[0].Event != (itemAdded{SKU: "order"}) {
+		t.Errorf("orders loaded %+v", recs)
+	}
+	if recs := mustLoad(t, carts, id); len(recs) != 2 || recs[0].Sequence != 1 {
+		t.Errorf("carts loaded %+v, want its own two events from sequence 1", recs)
+	}
+	for table, want := range map[string]int{"orders": 1, "carts": 2} {
+		var n int
+		if err := orders.(*fileStore).db.Get(&n, `select count(*) from `+table); err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("%d rows in %s, want %d", n, table, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// PayloadTooLargeError is returned when recording an event whose JSON is
+// longer than the limit set by WithMaxPayloadSize. It unwraps to
+// ErrPayloadTooLarge.
+type PayloadTooLargeError struct {
+	EventType string
+	Size      int
+	Limit     int
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return e.EventType + " payload is " + strconv.Itoa(e.Size) + " bytes, over the limit of " + strconv.Itoa(e.Limit)
+}
+
+func (e *PayloadTooLargeError) Unwrap() error {
+	return ErrPayloadTooLarge
+}
+
+// WithMaxPayloadSize rejects events whose JSON is longer than n bytes with a
+// *PayloadTooLargeError, failing the whole append, unless WithExternalPayloads
+// is also used.
+func WithMaxPayloadSize(n int) FileStoreOption {
+	return func(s *fileStore) {
+		s.maxPayloadSize = n
+	}
+}
+
+// WithExternalPayloads keeps the payloads of events over the limit set by
+// WithMaxPayloadSize in blobs rather than rejecting them. The events table
+// only holds a reference, and reads fetch the payload back. Payloads are
+// compressed and encrypted, as configured, before they are stored, so
+// ShredAggregate still makes them unreadable. A blob written for an append
+// that then fails is left behind.
+func WithExternalPayloads(blobs SegmentStore) FileStoreOption {
+	return func(s *fileStore) {
+		s.payloadBlobs = blobs
+	}
+}
+
+// migratePayloadRefColumn adds external payload references to stores
+// created before them
+func migratePayloadRefColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "payload_ref")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column payload_ref text not null default ''`); err != nil {
+			return fmt.Errorf("failed to add payload_ref column: %w", err)
+		}
+	}
+	return nil
+}
+
+// storedPayload is an event payload as kept in a row
+type storedPayload struct {
+	text string
+	// data is the payload of stores keeping binary payloads, in place of
+	// text
+	data       []byte
+	compressed bool
+	// ref names the blob holding the payload, which text and data then
+	// leave out
+	ref string
+}
+
+// storePayload checks an event's JSON against the size limit and encodes
+// it, moving oversized payloads to blobs when configured to. Callers hold
+// s.mu.
+func (s *fileStore) storePayload(key []byte, aggregateID uuid.UUID, eventType string, data []byte) (storedPayload, error) {
+	raw, compressed, err := s.encodePayload(key, aggregateID, data)
+	if err != nil {
+		return storedPayload{}, err
+	}
+	p := storedPayload{compressed: compressed}
+	if s.binary {
+		p.data = raw
+	} else {
+		p.text = textPayload(raw, key != nil || compressed)
+	}
+	if s.maxPayloadSize <= 0 || len(data) <= s.maxPayloadSize {
+		return p, nil
+	}
+	if s.payloadBlobs == nil {
+		return storedPayload{}, &PayloadTooLargeError{EventType: eventType, Size: len(data), Limit: s.maxPayloadSize}
+	}
+	p.ref = "payloads/" + NewID().String()
+	blob := []byte(p.text)
+	if s.binary {
+		p.ref += binaryBlobSuffix
+		blob = p.data
+	}
+	if err := s.payloadBlobs.PutSegment(context.Background(), p.ref, blob); err != nil {
+		return storedPayload{}, fmt.Errorf("store payload %s: %w", p.ref, err)
+	}
+	p.text, p.data = "", nil
+	return p, nil
+}
+
+// externalPayload fills in the payload of a row kept in a blob
+func (s *fileStore) externalPayload(row *dbEvent) error {
+	if s.payloadBlobs == nil {
+		return fmt.Errorf("event %d payload is in %s, but the store has no external payloads (hint: use WithExternalPayloads)", row.Sequence, row.PayloadRef)
+	}
+	data, err := s.payloadBlobs.GetSegment(context.Background(), row.PayloadRef)
+	if err != nil {
+		return fmt.Errorf("fetch payload %s of event %d: %w", row.PayloadRef, row.Sequence, err)
+	}
+	if strings.HasSuffix(row.PayloadRef, binaryBlobSuffix) {
+		row.EventData = data
+	} else {
+		row.EventJSON = string(data)
+	}
+	row.PayloadRef = ""
+	return nil
+}
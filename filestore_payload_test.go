@@ -0,0 +1,58 @@
+package evoke
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMaxPayloadSize(t *testing.T) {
+	s := newTestStore(t, WithMaxPayloadSize(100))
+	id := NewID()
+	err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: strings.Repeat("x", 100)}})
+	var tooLarge *PayloadTooLargeError
+	if !errors.As(err, &tooLarge) || !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("recording an oversized event returned %v, want a PayloadTooLargeError", err)
+	}
+	if tooLarge.EventType != "itemAdded" || tooLarge.Size != len(`{"SKU":"`)+100+len(`","Qty":0}`) || tooLarge.Limit != 100 {
+		t.Errorf("error %+v", tooLarge)
+	}
+	if n := len(mustLoad(t, s, id)); n != 0 {
+		t.Errorf("recorded %d events of the failed append", n)
+	}
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Errorf("recording an event under the limit: %v", err)
+	}
+}
+
+func TestExternalPayloads(t *testing.T) {
+	for name, opts := range map[string][]FileStoreOption{
+		"plain":     nil,
+		"encrypted": {WithPayloadEncryption(), WithPayloadCompression()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var blobs memSegments
+			s := newTestStore(t, append([]FileStoreOption{WithMaxPayloadSize(100), WithExternalPayloads(&blobs)}, opts...)...)
+			large := itemAdded{SKU: strings.Repeat("secret", 50)}
+			id := NewID()
+			if err := s.Record(id, []Event{itemAdded{SKU: "small"}, large}); err != nil {
+				t.Fatal(err)
+			}
+			if len(blobs.segments) != 1 {
+				t.Fatalf("%d blobs stored, want one for the large payload", len(blobs.segments))
+			}
+			if p := storedPayloads(t, s); p[0] == "" || p[1] != "" {
+				t.Errorf("stored payloads %q, want the large one left out of the row", p)
+			}
+			for _, blob := range blobs.segments {
+				if name == "encrypted" && strings.Contains(string(blob), "secret") {
+					t.Error("blob stored in the clear")
+				}
+			}
+			recs := mustLoad(t, s, id)
+			if len(recs) != 2 || recs[1].Event != large {
+				t.Errorf("loaded %+v", recs)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package evoke
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// WithReadPool loads streams, reads the log and replays it through a pool
+// of up to n read-only connections, beside the one connection appends go
+// through, so a long replay or load neither waits for appends nor holds
+// them up. SQLite only runs readers alongside a writer in WAL mode, the
+// default, so the option requires it. It is ignored for an in-memory
+// database and with an archive attached, which only the writing connection
+// sees.
+func WithReadPool(n int) FileStoreOption {
+	return func(s *fileStore) {
+		s.readConns = n
+	}
+}
+
+// openReadPool opens the read-only connections of WithReadPool. The
+// pragmas are set in the DSN since each connection of the pool needs them.
+func openReadPool(dbFile string, cfg sqliteConfig, n int) (*sqlx.DB, error) {
+	dsn := withPragmas(dbFile, fmt.Sprintf("busy_timeout(%d)", cfg.busyTimeout.Milliseconds()), "query_only(1)")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(n)
+	db.SetMaxIdleConns(n)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open read pool: %w", err)
+	}
+	return sqlx.NewDb(db, "sqlite3"), nil
+}
+
+// withPragmas adds pragmas to a database file name or file: URI, after any
+// query it already has
+func withPragmas(dsn string, pragmas ...string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	for _, pragma := range pragmas {
+		dsn += sep + "_pragma=" + url.QueryEscape(pragma)
+		sep = "&"
+	}
+	return dsn
+}
+
+// readRecords runs a read query over events and decodes the rows it
+// returns, through the read pool if there is one. Without one it locks the
+// store and shares its cached statements.
+func (s *fileStore) readRecords(query string, args ...any) ([]RecordedEvent, error) {
+	if s.reader == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.selectRecords(query, args...)
+	}
+	var rows []dbEvent
+	if err := s.reader.Select(&rows, query, args...); err != nil {
+		return nil, fmt.Errorf("select from events: %w", err)
+	}
+	return s.decodeRows(s.reader, rows)
+}
+
+// decodeRows decodes rows read through q, which also holds their keys
+func (s *fileStore) decodeRows(q sqlx.Queryer, rows []dbEvent) ([]RecordedEvent, error) {
+	keys := newKeyring(q)
+	recs := make([]RecordedEvent, len(rows))
+	for i, row := range rows {
+		rec, err := s.decodeRow(row, keys)
+		if err != nil {
+			return nil, fmt.Errorf("getRecordedEvent: %w", err)
+		}
+		recs[i] = rec
+	}
+	return recs, nil
+}
@@ -0,0 +1,87 @@
+package evoke
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadPool(t *testing.T) {
+	s := newTestStore(t, WithReadPool(4))
+	if s.reader == nil {
+		t.Fatal("store opened no read pool")
+	}
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemRemoved{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := mustLoad(t, s, id); len(recs) != 2 || recs[1].Event != (itemRemoved{SKU: "a"}) {
+		t.Errorf("loaded %+v through the read pool", recs)
+	}
+	if recs, err := s.ReadAll(1, 10); err != nil || len(recs) != 2 {
+		t.Errorf("ReadAll returned %d events, %v", len(recs), err)
+	}
+	if _, err := s.reader.Exec(`delete from ` + s.table); err == nil {
+		t.Error("read pool deleted events")
+	}
+}
+
+// An append made while a replay through the read pool is under way doesn't
+// wait for the replay to finish.
+func TestReadPoolReplayDoesNotHoldUpAppends(t *testing.T) {
+	s := newTestStore(t, WithReadPool(2))
+	recordItems(t, s, 3)
+	err := s.ReplayFrom(1, func(rec RecordedEvent, replay bool) error {
+		if rec.Sequence != 1 {
+			return nil
+		}
+		appended := make(chan error)
+		go func() { appended <- s.Record(NewID(), []Event{itemAdded{}}) }()
+		select {
+		case err := <-appended:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("append waited for the replay")
+			return nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seqs := sequences(mustReadAll(t, s)); len(seqs) != 4 {
+		t.Errorf("log has %v, want the append made during the replay", seqs)
+	}
+}
+
+func TestReadPoolNeedsWAL(t *testing.T) {
+	_, err := NewFileStore(filepath.Join(t.TempDir(), "events.db"), WithReadPool(2), WithJournalMode(JournalModeDelete))
+	if err == nil || !strings.Contains(err.Error(), "read pool needs journal mode WAL") {
+		t.Errorf("NewFileStore returned %v, want the journal mode refused", err)
+	}
+}
+
+func TestReadPoolIgnoredInMemory(t *testing.T) {
+	s, err := NewFileStore(":memory:", WithReadPool(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	if s.reader != nil {
+		t.Error("in-memory store opened a read pool, which can't see its database")
+	}
+}
+
+func TestWithPragmas(t *testing.T) {
+	tests := []struct{ dsn, want string }{
+		{"events.db", "events.db?_pragma=busy_timeout%285000%29&_pragma=query_only%281%29"},
+		{"file:events.db?mode=ro", "file:events.db?mode=ro&_pragma=busy_timeout%285000%29&_pragma=query_only%281%29"},
+	}
+	for _, tt := range tests {
+		if got := withPragmas(tt.dsn, "busy_timeout(5000)", "query_only(1)"); got != tt.want {
+			t.Errorf("withPragmas(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
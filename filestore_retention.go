@@ -0,0 +1,344 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// events per transaction of ReapExpired
+const retentionBatchSize = 1000
+
+// RetentionRule expires the events it selects once they are older than
+// MaxAge or no longer among the latest MaxCount, for ReapExpired to remove.
+type RetentionRule struct {
+	// EventType selects events of one type, whatever names they are stored
+	// under, and Category those of streams of one aggregate type. With
+	// both set, events have to match both.
+	EventType string
+	Category  string
+	// MaxAge expires events recorded longer ago, 0 for no limit
+	MaxAge time.Duration
+	// MaxCount expires all but the latest MaxCount events the rule selects
+	// in each tenant, 0 for no limit
+	MaxCount int
+	// Archive writes expired events to the store's retention archive,
+	// set WithRetentionArchive, before they are deleted
+	Archive bool
+}
+
+func (r RetentionRule) String() string {
+	var parts []string
+	if r.EventType != "" {
+		parts = append(parts, "type="+r.EventType)
+	}
+	if r.Category != "" {
+		parts = append(parts, "category="+r.Category)
+	}
+	if r.MaxAge > 0 {
+		parts = append(parts, "max-age="+r.MaxAge.String())
+	}
+	if r.MaxCount > 0 {
+		parts = append(parts, fmt.Sprintf("max-count=%d", r.MaxCount))
+	}
+	if r.Archive {
+		parts = append(parts, "archive")
+	}
+	return strings.Join(parts, " ")
+}
+
+// WithRetentionArchive has ReapExpired write the events of rules with
+// Archive set to archive, a gzipped segment of newline-delimited JSON rows
+// per batch, payloads as stored, before it deletes them.
+func WithRetentionArchive(archive SegmentStore) FileStoreOption {
+	return func(s *fileStore) {
+		s.retentionArchive = archive
+	}
+}
+
+// RetentionRecord is the audit record of one batch of events a retention
+// rule removed. ReapedEvents lists the events.
+type RetentionRecord struct {
+	ID       int64
+	ReapedAt time.Time
+	// Rule is the rule that expired the events, as its String
+	Rule   string
+	Events int64
+	// Segment is the segment of the retention archive holding the events,
+	// empty if they were deleted outright
+	Segment string
+}
+
+// ReapedEvent identifies an event ReapExpired removed, without its payload.
+type ReapedEvent struct {
+	Sequence      int64     `db:"sequence"`
+	TenantID      string    `db:"tenant_id"`
+	AggregateID   uuid.UUID `db:"aggregate_id"`
+	AggregateType string    `db:"aggregate_type"`
+	EventType     string    `db:"event_type"`
+	Version       int64     `db:"version"`
+	RecordedAt    int64     `db:"recorded_at"`
+}
+
+// createRetentionTables creates the audit trail of ReapExpired, which also
+// keeps the versions of reaped events so their streams carry on numbering
+// from where they were
+func (s *fileStore) createRetentionTables(db *sql.DB) error {
+	if _, err := db.Exec(`
+		create table if not exists retention_log (
+			id        integer primary key autoincrement,
+			reaped_at integer not null, -- unix milliseconds
+			rule      text not null,
+			events    integer not null,
+			segment   text not null
+		);
+		create table if not exists retention_removed (
+			sequence       integer primary key,
+			reap_id        integer not null,
+			tenant_id      text not null,
+			aggregate_id   text not null,
+			aggregate_type text not null,
+			event_type     text not null,
+			version        integer not null,
+			recorded_at    integer not null
+		);
+		create index if not exists retention_removed_reap on retention_removed(reap_id);
+		create index if not exists retention_removed_stream on retention_removed(tenant_id, aggregate_id, version);
+	`); err != nil {
+		return fmt.Errorf("failed to create retention tables: %w", err)
+	}
+	if err := db.QueryRow(`select exists(select 1 from retention_removed)`).Scan(&s.reaped); err != nil {
+		return fmt.Errorf("select from retention_removed: %w", err)
+	}
+	return nil
+}
+
+// ReapExpired deletes the events the rules expire, in every tenant,
+// recording what it removed in the retention log, and returns how many it
+// removed. Expired events are removed whether their streams are visible or
+// not, from the archive too if one is attached, and the snapshots of their
+// streams are deleted with them since they may hold what the events did.
+//
+// A stream keeps numbering versions from where it was, but aggregates are
+// hydrated from the events that remain: expire events aggregates don't
+// depend on, or truncate streams past their snapshots with
+// TruncateStreamBefore instead. Tiered events, and the blobs of external
+// payloads, are left as they are. Stores WithHashChain can't reap events, as
+// the chain would no longer verify.
+func (s *fileStore) ReapExpired(ctx context.Context, rules ...RetentionRule) (int64, error) {
+	if s.hashChain {
+		return 0, fmt.Errorf("can't reap the events of a hash chained store")
+	}
+	for _, rule := range rules {
+		if rule.EventType == "" && rule.Category == "" {
+			return 0, fmt.Errorf("retention rule %q selects no events (hint: set EventType or Category)", rule)
+		}
+		if rule.MaxAge <= 0 && rule.MaxCount <= 0 {
+			return 0, fmt.Errorf("retention rule %q expires no events (hint: set MaxAge or MaxCount)", rule)
+		}
+		if rule.Archive && s.retentionArchive == nil {
+			return 0, fmt.Errorf("retention rule %q archives, but no retention archive is configured (hint: use WithRetentionArchive)", rule)
+		}
+	}
+
+	var n int64
+	for _, rule := range rules {
+		for {
+			if err := ctx.Err(); err != nil {
+				return n, err
+			}
+			reaped, err := s.reapBatch(ctx, rule)
+			n += reaped
+			if err != nil {
+				return n, err
+			}
+			if reaped < retentionBatchSize {
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// RunRetention calls ReapExpired every interval until ctx is done.
+func (s *fileStore) RunRetention(ctx context.Context, interval time.Duration, rules ...RetentionRule) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.ReapExpired(ctx, rules...)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			s.logger.Info("evoke: reaped expired events", "events", n)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// reapBatch removes a batch of the events rule expires, returning how many
+func (s *fileStore) reapBatch(ctx context.Context, rule RetentionRule) (int64, error) {
+	// expired events can't change, so they can be archived without
+	// holding up appends
+	s.mu.Lock()
+	query, args := s.expiredQuery(rule, time.Now())
+	var rows []dbEvent
+	err := s.db.Select(&rows, query, args...)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("select from events: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	var segment string
+	if rule.Archive {
+		data, err := encodeSegment(rows)
+		if err != nil {
+			return 0, err
+		}
+		segment = fmt.Sprintf("retention-%020d-%020d.ndjson.gz", rows[0].Sequence, rows[len(rows)-1].Sequence)
+		if err := s.retentionArchive.PutSegment(ctx, segment, data); err != nil {
+			return 0, fmt.Errorf("put segment %s: %w", segment, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`insert into retention_log(reaped_at, rule, events, segment) values(?,?,?,?)`,
+		time.Now().UnixMilli(), rule.String(), len(rows), segment)
+	if err != nil {
+		return 0, fmt.Errorf("insert into retention_log: %w", err)
+	}
+	reapID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("insert into retention_log: %w", err)
+	}
+	removed, err := tx.Preparex(`insert into retention_removed(sequence, reap_id, tenant_id, aggregate_id, aggregate_type, event_type, version, recorded_at) values(?,?,?,?,?,?,?,?)`)
+	if err != nil {
+		return 0, fmt.Errorf("prepare: %w", err)
+	}
+	defer removed.Close()
+	for _, row := range rows {
+		if _, err := removed.Exec(row.Sequence, reapID, row.TenantID, row.AggregateID.String(), row.AggregateType, row.EventType, row.Version, row.RecordedAt); err != nil {
+			return 0, fmt.Errorf("insert into retention_removed: %w", err)
+		}
+	}
+
+	tables := []string{s.table}
+	if s.archiveColumns != "" {
+		tables = []string{"main." + s.table, "archive." + s.table}
+	}
+	for _, table := range tables {
+		if _, err := tx.Exec(`delete from `+table+` where sequence in (select sequence from retention_removed where reap_id = ?)`, reapID); err != nil {
+			return 0, fmt.Errorf("delete from events: %w", err)
+		}
+	}
+	_, err = tx.Exec(`delete from snapshots where exists (
+		select 1 from retention_removed r
+		where r.reap_id = ? and r.tenant_id = snapshots.tenant_id and r.aggregate_id = snapshots.aggregate_id)`, reapID)
+	if err != nil {
+		return 0, fmt.Errorf("delete from snapshots: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	s.reaped = true
+	return int64(len(rows)), nil
+}
+
+// expiredQuery returns a query for the first batch of events rule expires
+// as of now. Callers hold s.mu.
+func (s *fileStore) expiredQuery(rule RetentionRule, now time.Time) (string, []any) {
+	match := `1 = 1`
+	var matchArgs []any
+	if rule.EventType != "" {
+		names := s.storedEventTypes(rule.EventType)
+		match += ` and event_type in (?` + strings.Repeat(",?", len(names)-1) + `)`
+		for _, name := range names {
+			matchArgs = append(matchArgs, name)
+		}
+	}
+	if rule.Category != "" {
+		match += ` and aggregate_type = ?`
+		matchArgs = append(matchArgs, rule.Category)
+	}
+
+	var expiry []string
+	args := append([]any(nil), matchArgs...)
+	if rule.MaxAge > 0 {
+		expiry = append(expiry, `recorded_at < ?`)
+		args = append(args, now.Add(-rule.MaxAge).Unix())
+	}
+	if rule.MaxCount > 0 {
+		expiry = append(expiry, `sequence in (
+			select sequence from (
+				select sequence, row_number() over (partition by tenant_id order by sequence desc) as n
+				from `+s.eventsSource+` where `+match+`)
+			where n > ?)`)
+		args = append(args, matchArgs...)
+		args = append(args, rule.MaxCount)
+	}
+	args = append(args, retentionBatchSize)
+	return `select * from ` + s.eventsSource + ` where ` + match + ` and (` + strings.Join(expiry, ` or `) + `) order by sequence asc limit ?`, args
+}
+
+// RetentionLog returns the audit records of the events ReapExpired removed
+// since a time, oldest first.
+func (s *fileStore) RetentionLog(since time.Time) ([]RetentionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []struct {
+		ID       int64  `db:"id"`
+		ReapedAt int64  `db:"reaped_at"`
+		Rule     string `db:"rule"`
+		Events   int64  `db:"events"`
+		Segment  string `db:"segment"`
+	}
+	err := s.db.Select(&rows, `select id, reaped_at, rule, events, segment from retention_log where reaped_at >= ? order by id`, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("select from retention_log: %w", err)
+	}
+	records := make([]RetentionRecord, len(rows))
+	for i, row := range rows {
+		records[i] = RetentionRecord{
+			ID:       row.ID,
+			ReapedAt: time.UnixMilli(row.ReapedAt),
+			Rule:     row.Rule,
+			Events:   row.Events,
+			Segment:  row.Segment,
+		}
+	}
+	return records, nil
+}
+
+// ReapedEvents returns the events removed in the batch of a retention
+// record, in sequence order.
+func (s *fileStore) ReapedEvents(recordID int64) ([]ReapedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []ReapedEvent
+	err := s.db.Select(&events, `select sequence, tenant_id, aggregate_id, aggregate_type, event_type, version, recorded_at
+		from retention_removed where reap_id = ? order by sequence`, recordID)
+	if err != nil {
+		return nil, fmt.Errorf("select from retention_removed: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,236 @@
+package evoke
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// age moves the events of seqs back by d
+func age(t *testing.T, s *fileStore, d time.Duration, seqs ...int64) {
+	t.Helper()
+	for _, seq := range seqs {
+		if _, err := s.db.Exec(`update `+s.table+` set recorded_at = recorded_at - ? where sequence = ?`, int64(d.Seconds()), seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReapExpiredMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterEvent(s, &itemAdded{})
+	RegisterEvent(s, &itemRemoved{})
+	ctx := context.Background()
+	a, b := NewID(), NewID()
+	if err := s.RecordAs(ctx, "cart", a, []Event{itemAdded{}, itemRemoved{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordAs(ctx, "cart", b, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	age(t, s, 2*time.Hour, 1, 2, 4)
+
+	rule := RetentionRule{EventType: "itemAdded", MaxAge: time.Hour}
+	n, err := s.ReapExpired(ctx, rule)
+	if err != nil || n != 2 {
+		t.Fatalf("ReapExpired removed %d events, %v, want the 2 old itemAdded", n, err)
+	}
+	if got := sequences(mustReadAll(t, s)); !slices.Equal(got, []int64{2, 3}) {
+		t.Errorf("log has %v after reaping", got)
+	}
+	if n, err := s.ReapExpired(ctx, rule); err != nil || n != 0 {
+		t.Errorf("reaping again removed %d events, %v", n, err)
+	}
+
+	log, err := s.RetentionLog(time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 || log[0].Rule != "type=itemAdded max-age=1h0m0s" || log[0].Events != 2 || log[0].Segment != "" {
+		t.Fatalf("retention log %+v", log)
+	}
+	reaped, err := s.ReapedEvents(log[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reaped) != 2 || reaped[0].Sequence != 1 || reaped[1].AggregateID != b || reaped[1].Version != 1 || reaped[1].AggregateType != "cart" || reaped[1].EventType != "itemAdded" {
+		t.Errorf("reaped events %+v", reaped)
+	}
+	if log, err := s.RetentionLog(time.Now().Add(time.Minute)); err != nil || len(log) != 0 {
+		t.Errorf("retention log since later %+v, %v", log, err)
+	}
+
+	// b lost its only event but carries on numbering, after a reopen too
+	if err := s.RecordAtVersion(ctx, "cart", b, 1, []Event{itemAdded{}}); err != nil {
+		t.Fatalf("appending to a reaped stream: %v", err)
+	}
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	RegisterEvent(s, &itemAdded{})
+	if err := s.RecordAtVersion(ctx, "cart", b, 2, []Event{itemAdded{}}); err != nil {
+		t.Fatalf("appending to a reaped stream after reopening: %v", err)
+	}
+	if got := versions(mustLoad(t, s, b)); !slices.Equal(got, []int64{2, 3}) {
+		t.Errorf("reaped stream loaded versions %v", got)
+	}
+}
+
+// MaxCount keeps the latest events of each tenant the rule selects.
+func TestReapExpiredMaxCount(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	acme := s.ForTenant("acme")
+	if err := s.RecordAs(ctx, "cart", NewID(), []Event{itemAdded{}, itemAdded{}, itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordAs(ctx, "order", NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.RecordAs(ctx, "cart", NewID(), []Event{itemAdded{}, itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.ReapExpired(ctx, RetentionRule{Category: "cart", MaxCount: 2})
+	if err != nil || n != 3 {
+		t.Fatalf("ReapExpired removed %d events, %v, want 2 of the default tenant's carts and 1 of acme's", n, err)
+	}
+	if got := sequences(mustReadAll(t, s)); !slices.Equal(got, []int64{3, 4, 5}) {
+		t.Errorf("default tenant has %v", got)
+	}
+	if got := sequences(mustReadAll(t, acme)); !slices.Equal(got, []int64{7, 8}) {
+		t.Errorf("acme has %v", got)
+	}
+}
+
+func TestReapExpiredArchive(t *testing.T) {
+	var archive memSegments
+	s := newTestStore(t, WithRetentionArchive(&archive))
+	ctx := context.Background()
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}, itemAdded{SKU: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveSnapshot(Snapshot{AggregateID: id, Version: 3, State: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.ReapExpired(ctx, RetentionRule{EventType: "itemAdded", MaxCount: 1, Archive: true})
+	if err != nil || n != 2 {
+		t.Fatalf("ReapExpired removed %d events, %v", n, err)
+	}
+	log, err := s.RetentionLog(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 || log[0].Segment != "retention-00000000000000000001-00000000000000000002.ndjson.gz" {
+		t.Fatalf("retention log %+v, want the archived segment", log)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(archive.segments[log[0].Segment]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := strings.Split(strings.TrimSpace(string(data)), "\n"); len(rows) != 2 || !strings.Contains(rows[1], `\"SKU\":\"b\"`) {
+		t.Errorf("archived %q, want the 2 reaped events", rows)
+	}
+	if _, ok, err := s.LoadSnapshot(id); ok || err != nil {
+		t.Errorf("snapshot of a reaped stream kept: %v, %v", ok, err)
+	}
+}
+
+// Rules are reaped a batch at a time, each batch logged.
+func TestReapExpiredBatches(t *testing.T) {
+	s := newTestStore(t)
+	evs := make([]Event, retentionBatchSize+5)
+	for i := range evs {
+		evs[i] = itemAdded{}
+	}
+	if err := s.Record(NewID(), evs); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.ReapExpired(context.Background(), RetentionRule{EventType: "itemAdded", MaxCount: 1})
+	if err != nil || n != int64(len(evs)-1) {
+		t.Fatalf("ReapExpired removed %d events, %v", n, err)
+	}
+	log, err := s.RetentionLog(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 || log[0].Events != retentionBatchSize || log[1].Events != 4 {
+		t.Errorf("retention log %+v, want a full batch and the rest", log)
+	}
+}
+
+func TestReapExpiredErrors(t *testing.T) {
+	s := newTestStore(t)
+	for _, rule := range []RetentionRule{
+		{MaxAge: time.Hour},
+		{EventType: "itemAdded"},
+		{Category: "cart", MaxCount: 1, Archive: true},
+	} {
+		if _, err := s.ReapExpired(context.Background(), rule); err == nil {
+			t.Errorf("reaped with rule %q", rule)
+		}
+	}
+	chained := newTestStore(t, WithHashChain())
+	if _, err := chained.ReapExpired(context.Background(), RetentionRule{EventType: "itemAdded", MaxCount: 1}); err == nil {
+		t.Error("reaped the events of a hash chained store")
+	}
+}
+
+func TestRunRetention(t *testing.T) {
+	s := newTestStore(t)
+	var logger recordingLogger
+	s.logger = &logger
+	if err := s.Record(NewID(), []Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.RunRetention(ctx, 10*time.Millisecond, RetentionRule{EventType: "itemAdded", MaxCount: 1})
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); len(mustReadAll(t, s)) != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("RunRetention reaped nothing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(mustReadAll(t, s)) != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("RunRetention didn't reap again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("RunRetention returned %v, want context.Canceled", err)
+	}
+	if got := logger.logged(); !slices.Contains(got, "info: evoke: reaped expired events") {
+		t.Errorf("logged %q, want the reaped events", got)
+	}
+}
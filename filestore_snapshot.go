@@ -0,0 +1,101 @@
+package evoke
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func createSnapshotTable(db *sql.DB) error {
+	if _, err := db.Exec(`
+		create table if not exists snapshots (
+			tenant_id      text not null,
+			aggregate_id   text not null,
+			aggregate_type text not null,
+			version        integer not null,
+			state          blob not null,
+			taken_at       integer not null, -- unix milliseconds
+			primary key (tenant_id, aggregate_id)
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create snapshots table: %w", err)
+	}
+	return nil
+}
+
+// SaveSnapshot saves a snapshot of an aggregate of the default tenant, so
+// the store can serve as the SnapshotStore of WithSnapshots. Soft deleting,
+// tombstoning or shredding the aggregate's stream deletes its snapshot.
+func (s *fileStore) SaveSnapshot(snap Snapshot) error {
+	return s.saveSnapshot("", snap)
+}
+
+// LoadSnapshot returns the snapshot of an aggregate of the default tenant.
+func (s *fileStore) LoadSnapshot(aggregateID uuid.UUID) (Snapshot, bool, error) {
+	return s.loadSnapshot("", aggregateID)
+}
+
+func (t *tenantStore) SaveSnapshot(snap Snapshot) error {
+	return t.store.saveSnapshot(t.tenantID, snap)
+}
+
+func (t *tenantStore) LoadSnapshot(aggregateID uuid.UUID) (Snapshot, bool, error) {
+	return t.store.loadSnapshot(t.tenantID, aggregateID)
+}
+
+func (s *fileStore) saveSnapshot(tenantID string, snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`
+		insert into snapshots(tenant_id, aggregate_id, aggregate_type, version, state, taken_at) values(?,?,?,?,?,?)
+		on conflict(tenant_id, aggregate_id) do update set
+			aggregate_type = excluded.aggregate_type,
+			version = excluded.version,
+			state = excluded.state,
+			taken_at = excluded.taken_at
+		where excluded.version >= snapshots.version`,
+		tenantID, snap.AggregateID.String(), snap.AggregateType, snap.Version, snap.State, snap.TakenAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("insert into snapshots: %w", err)
+	}
+	return nil
+}
+
+func (s *fileStore) loadSnapshot(tenantID string, aggregateID uuid.UUID) (Snapshot, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var row struct {
+		AggregateType string `db:"aggregate_type"`
+		Version       int64  `db:"version"`
+		State         []byte `db:"state"`
+		TakenAt       int64  `db:"taken_at"`
+	}
+	err := s.db.Get(&row, `select aggregate_type, version, state, taken_at from snapshots where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("select from snapshots: %w", err)
+	}
+	return Snapshot{
+		AggregateID:   aggregateID,
+		AggregateType: row.AggregateType,
+		Version:       row.Version,
+		State:         row.State,
+		TakenAt:       time.UnixMilli(row.TakenAt),
+	}, true, nil
+}
+
+// deleteSnapshot deletes the snapshot of an aggregate whose stream is no
+// longer readable. Callers hold s.mu.
+func (s *fileStore) deleteSnapshot(ext sqlx.Execer, tenantID string, aggregateID uuid.UUID) error {
+	_, err := ext.Exec(`delete from snapshots where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if err != nil {
+		return fmt.Errorf("delete from snapshots: %w", err)
+	}
+	return nil
+}
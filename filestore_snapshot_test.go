@@ -0,0 +1,97 @@
+package evoke
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFileSnapshotStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := NewID()
+	if _, ok, err := s.LoadSnapshot(id); ok || err != nil {
+		t.Fatalf("loaded a snapshot of a new aggregate: %v, %v", ok, err)
+	}
+	takenAt := time.UnixMilli(time.Now().UnixMilli())
+	for _, snap := range []Snapshot{
+		{AggregateID: id, AggregateType: "cart", Version: 2, State: []byte(`{"Items":2}`), TakenAt: takenAt},
+		{AggregateID: id, AggregateType: "cart", Version: 1, State: []byte(`{"Items":1}`), TakenAt: takenAt},
+	} {
+		if err := s.SaveSnapshot(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.ForTenant("acme").SaveSnapshot(Snapshot{AggregateID: id, Version: 5, State: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	snap, ok, err := s.LoadSnapshot(id)
+	if err != nil || !ok {
+		t.Fatalf("LoadSnapshot after reopening: %v, %v", ok, err)
+	}
+	want := Snapshot{AggregateID: id, AggregateType: "cart", Version: 2, TakenAt: takenAt}
+	if string(snap.State) != `{"Items":2}` || snap.AggregateType != want.AggregateType || snap.Version != want.Version || !snap.TakenAt.Equal(takenAt) {
+		t.Errorf("loaded %+v, want the later snapshot %+v", snap, want)
+	}
+	if snap, _, _ := s.ForTenant("acme").LoadSnapshot(id); snap.Version != 5 {
+		t.Errorf("tenant loaded the snapshot at version %d, want its own", snap.Version)
+	}
+}
+
+// A stream that is deleted, tombstoned or shredded loses its snapshot, which
+// may hold what its events did.
+func TestFileSnapshotDeletedWithStream(t *testing.T) {
+	s := newTestStore(t)
+	for name, remove := range map[string]func(uuid.UUID) error{
+		"delete":    s.DeleteStream,
+		"tombstone": s.TombstoneStream,
+		"shred":     s.ShredAggregate,
+	} {
+		id := NewID()
+		if err := s.Record(id, []Event{itemAdded{}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveSnapshot(Snapshot{AggregateID: id, Version: 1, State: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := remove(id); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, ok, err := s.LoadSnapshot(id); ok || err != nil {
+			t.Errorf("%s kept the snapshot: %v, %v", name, ok, err)
+		}
+	}
+}
+
+func TestFileStoreAsSnapshotStore(t *testing.T) {
+	s := newTestStore(t)
+	h := NewAggregateHandler(s, newCart, WithSnapshots(s, SnapshotEvery(2)))
+	id := NewID()
+	sendItems(t, h, id, 2)
+	snap, ok, err := s.LoadSnapshot(id)
+	if err != nil || !ok || snap.Version != 2 || string(snap.State) != `{"Items":2}` {
+		t.Fatalf("snapshot %+v, %v, %v, want one at version 2", snap, ok, err)
+	}
+	if err := s.SaveSnapshot(Snapshot{AggregateID: id, AggregateType: "cart", Version: 2, State: []byte(`{"Items":10}`)}); err != nil {
+		t.Fatal(err)
+	}
+	sendItems(t, h, id, 1)
+	if qty := lastQty(t, s, id); qty != 11 {
+		t.Errorf("raised quantity %d, want the cart hydrated from the stored snapshot", qty)
+	}
+}
@@ -0,0 +1,98 @@
+package evoke
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// largest append batch whose insert statement is kept prepared; bigger
+// batches are rare enough to prepare each time
+const maxCachedInsertRows = 32
+
+// prepared returns a cached prepared statement for query, preparing it on
+// first use, and rebound to tx when one is given. The store has a single
+// connection, which an open tx is holding, so statements can't be cached
+// from inside a tx: prepare them beforehand with prepare, otherwise they are
+// prepared on the tx for one use. Callers hold s.mu.
+func (s *fileStore) prepared(tx *sqlx.Tx, query string) (*sqlx.Stmt, error) {
+	stmt, ok := s.stmts[query]
+	if !ok && tx != nil {
+		stmt, err := tx.Preparex(query)
+		if err != nil {
+			return nil, fmt.Errorf("prepare: %w", err)
+		}
+		return stmt, nil
+	}
+	if !ok {
+		if err := s.prepare(query); err != nil {
+			return nil, err
+		}
+		stmt = s.stmts[query]
+	}
+	if tx != nil {
+		return tx.Stmtx(stmt), nil
+	}
+	return stmt, nil
+}
+
+// prepare caches prepared statements for queries. Callers hold s.mu and no tx.
+func (s *fileStore) prepare(queries ...string) error {
+	for _, query := range queries {
+		if _, ok := s.stmts[query]; ok {
+			continue
+		}
+		stmt, err := s.db.Preparex(query)
+		if err != nil {
+			return fmt.Errorf("prepare: %w", err)
+		}
+		s.stmts[query] = stmt
+	}
+	return nil
+}
+
+func (s *fileStore) streamVersionQuery() string {
+	if s.segments == nil && !s.reaped {
+		return `select coalesce(max(version), 0) as version, coalesce(max(aggregate_type), '') as aggregate_type
+		from ` + s.eventsSource + ` where tenant_id = ? and aggregate_id = ?`
+	}
+	// tiered streams continue from their last tiered version, and reaped
+	// ones from their last reaped version
+	q := `select coalesce(max(version), 0) as version, coalesce(max(aggregate_type), '') as aggregate_type from (
+			select version, aggregate_type from ` + s.eventsSource + ` where tenant_id = ?1 and aggregate_id = ?2`
+	if s.segments != nil {
+		q += `
+			union all
+			select last_version, aggregate_type from tier_streams where tenant_id = ?1 and aggregate_id = ?2`
+	}
+	if s.reaped {
+		q += `
+			union all
+			select max(version), max(aggregate_type) from retention_removed where tenant_id = ?1 and aggregate_id = ?2`
+	}
+	return q + `)`
+}
+
+func (s *fileStore) insertEventsQuery(rows int) string {
+	values := strings.Repeat(",(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", rows)[1:]
+	return `insert into ` + s.table + `(tenant_id, aggregate_id, recorded_at, event_json, event_type, version, encrypted, aggregate_type, metadata, schema_version, compressed, payload_ref, event_data, key_id, correlation_id, causation_seq) values ` +
+		values
+}
+
+// prepareAppend readies the statements insertEvents will use for a batch of
+// n events
+func (s *fileStore) prepareAppend(n int) error {
+	queries := []string{s.streamVersionQuery()}
+	if rest := n % insertBatchSize; rest > 0 && rest <= maxCachedInsertRows {
+		queries = append(queries, s.insertEventsQuery(rest))
+	}
+	return s.prepare(queries...)
+}
+
+func (s *fileStore) closeStmts() {
+	for query, stmt := range s.stmts {
+		stmt.Close()
+		delete(s.stmts, query)
+	}
+}
@@ -0,0 +1,69 @@
+package evoke
+
+import (
+	"strings"
+	"testing"
+)
+
+// queryPlan returns how sqlite would run query
+func queryPlan(t *testing.T, s *fileStore, query string, args ...any) string {
+	t.Helper()
+	var steps []struct {
+		ID      int    `db:"id"`
+		Parent  int    `db:"parent"`
+		NotUsed int    `db:"notused"`
+		Detail  string `db:"detail"`
+	}
+	if err := s.db.Select(&steps, `explain query plan `+query, args...); err != nil {
+		t.Fatal(err)
+	}
+	details := make([]string, len(steps))
+	for i, step := range steps {
+		details[i] = step.Detail
+	}
+	return strings.Join(details, "; ")
+}
+
+func TestStreamReadsUseIndex(t *testing.T) {
+	s := newTestStore(t)
+	plan := queryPlan(t, s, `select * from `+s.eventsSource+` where tenant_id = ? and aggregate_id = ? order by sequence`, "", NewID().String())
+	if !strings.Contains(plan, "USING INDEX "+s.table+"_stream_sequence") || strings.Contains(plan, "TEMP B-TREE") {
+		t.Errorf("reading a stream runs as %q, want a search of the stream index in sequence order", plan)
+	}
+}
+
+// Appends of the same size reuse the statements prepared for the first, and
+// batches too large to cache prepare none.
+func TestAppendStatementsAreReused(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	record := func(n int) int {
+		t.Helper()
+		evs := make([]Event, n)
+		for i := range evs {
+			evs[i] = itemAdded{SKU: "a"}
+		}
+		if err := s.Record(id, evs); err != nil {
+			t.Fatal(err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.stmts)
+	}
+
+	first := record(1)
+	for i := 0; i < 5; i++ {
+		if n := record(1); n != first {
+			t.Fatalf("%d statements cached after repeating an append, want %d", n, first)
+		}
+	}
+	if n := record(2); n != first+1 {
+		t.Errorf("%d statements cached after an append of another size, want %d", n, first+1)
+	}
+	if n := record(maxCachedInsertRows + 1); n != first+1 {
+		t.Errorf("%d statements cached after an append too large to cache, want %d", n, first+1)
+	}
+	if recs := mustLoad(t, s, id); len(recs) != 5+1+2+maxCachedInsertRows+1 {
+		t.Errorf("stream has %d events", len(recs))
+	}
+}
@@ -0,0 +1,149 @@
+package evoke
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	streamDeleted    = "deleted"
+	streamTombstoned = "tombstoned"
+)
+
+func createStreamStateTable(db *sql.DB) error {
+	if _, err := db.Exec(`
+		create table if not exists stream_states (
+			tenant_id    text not null,
+			aggregate_id text not null,
+			state        text not null,
+			changed_at   integer not null,
+			primary key (tenant_id, aggregate_id)
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create stream_states table: %w", err)
+	}
+	return nil
+}
+
+// visibleStreams is a where clause fragment hiding deleted and tombstoned
+// streams, and the truncated events of streams, from reads
+const visibleStreams = `not exists (
+	select 1 from stream_states ss
+	where ss.tenant_id = events.tenant_id and ss.aggregate_id = events.aggregate_id) and not exists (
+	select 1 from stream_truncations st
+	where st.tenant_id = events.tenant_id and st.aggregate_id = events.aggregate_id and events.version < st.before_version)`
+
+// DeleteStream soft deletes a stream: its events stay in the database but
+// are hidden from LoadStream and ReplayFrom, and appending to it fails with
+// ErrStreamDeleted until it is restored with RestoreStream. Deleting a
+// stream without events fails with ErrStreamNotFound.
+func (s *fileStore) DeleteStream(aggregateID uuid.UUID) error {
+	return s.setStreamState("", aggregateID, streamDeleted)
+}
+
+// TombstoneStream permanently deletes a stream. Like DeleteStream its events
+// are hidden, but it can never be restored or appended to again; appends
+// fail with ErrStreamTombstoned.
+func (s *fileStore) TombstoneStream(aggregateID uuid.UUID) error {
+	return s.setStreamState("", aggregateID, streamTombstoned)
+}
+
+// RestoreStream undoes DeleteStream. Restoring a stream without events
+// fails with ErrStreamNotFound.
+func (s *fileStore) RestoreStream(aggregateID uuid.UUID) error {
+	return s.restoreStream("", aggregateID)
+}
+
+func (t *tenantStore) DeleteStream(aggregateID uuid.UUID) error {
+	return t.store.setStreamState(t.tenantID, aggregateID, streamDeleted)
+}
+
+func (t *tenantStore) TombstoneStream(aggregateID uuid.UUID) error {
+	return t.store.setStreamState(t.tenantID, aggregateID, streamTombstoned)
+}
+
+func (t *tenantStore) RestoreStream(aggregateID uuid.UUID) error {
+	return t.store.restoreStream(t.tenantID, aggregateID)
+}
+
+func (s *fileStore) setStreamState(tenantID string, aggregateID uuid.UUID, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkStreamWritable(s.db, tenantID, aggregateID); errors.Is(err, ErrStreamTombstoned) {
+		return err
+	} else if err != nil && !errors.Is(err, ErrStreamDeleted) {
+		return err
+	}
+	if state == streamDeleted {
+		if err := s.checkStreamExists(tenantID, aggregateID); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.db.Exec(`insert or replace into stream_states(tenant_id, aggregate_id, state, changed_at) values(?,?,?,?)`,
+		tenantID, aggregateID.String(), state, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("insert into stream_states: %w", err)
+	}
+	return s.deleteSnapshot(s.db, tenantID, aggregateID)
+}
+
+func (s *fileStore) restoreStream(tenantID string, aggregateID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkStreamWritable(s.db, tenantID, aggregateID); errors.Is(err, ErrStreamTombstoned) {
+		return err
+	}
+	if err := s.checkStreamExists(tenantID, aggregateID); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`delete from stream_states where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if err != nil {
+		return fmt.Errorf("delete from stream_states: %w", err)
+	}
+	return nil
+}
+
+// checkStreamExists returns ErrStreamNotFound if the stream has no events,
+// counting hidden and tiered ones. Callers hold s.mu.
+func (s *fileStore) checkStreamExists(tenantID string, aggregateID uuid.UUID) error {
+	var head struct {
+		Version       int64  `db:"version"`
+		AggregateType string `db:"aggregate_type"`
+	}
+	if err := s.db.Get(&head, s.streamVersionQuery(), tenantID, aggregateID.String()); err != nil {
+		return fmt.Errorf("select stream version: %w", err)
+	}
+	if head.Version == 0 {
+		return fmt.Errorf("%w: %s", ErrStreamNotFound, aggregateID)
+	}
+	return nil
+}
+
+// checkStreamWritable returns ErrStreamDeleted or ErrStreamTombstoned if the
+// stream may not be appended to. Callers hold s.mu.
+func (s *fileStore) checkStreamWritable(q sqlx.Queryer, tenantID string, aggregateID uuid.UUID) error {
+	var state string
+	err := sqlx.Get(q, &state, `select state from stream_states where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("select from stream_states: %w", err)
+	}
+	switch state {
+	case streamDeleted:
+		return fmt.Errorf("%w: %s", ErrStreamDeleted, aggregateID)
+	case streamTombstoned:
+		return fmt.Errorf("%w: %s", ErrStreamTombstoned, aggregateID)
+	}
+	return nil
+}
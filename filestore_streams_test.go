@@ -0,0 +1,132 @@
+package evoke
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// replayed returns the sequences ReplayFrom delivers from the start
+func replayed(t *testing.T, s EventStore) []int64 {
+	t.Helper()
+	var seqs []int64
+	err := s.ReplayFrom(1, func(rec RecordedEvent, replay bool) error {
+		seqs = append(seqs, rec.Sequence)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seqs
+}
+
+func TestDeleteStream(t *testing.T) {
+	s := newTestStore(t)
+	deleted, kept := NewID(), NewID()
+	for _, id := range []uuid.UUID{deleted, kept, deleted} {
+		if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.DeleteStream(deleted); err != nil {
+		t.Fatal(err)
+	}
+	if recs := mustLoad(t, s, deleted); len(recs) != 0 {
+		t.Errorf("a deleted stream loaded %d events", len(recs))
+	}
+	if got := replayed(t, s); !slices.Equal(got, []int64{2}) {
+		t.Errorf("replayed %v, want only the kept stream's event 2", got)
+	}
+	if err := s.Record(deleted, []Event{itemAdded{SKU: "b"}}); !errors.Is(err, ErrStreamDeleted) {
+		t.Errorf("appending to a deleted stream: %v, want ErrStreamDeleted", err)
+	}
+
+	if err := s.RestoreStream(deleted); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(mustLoad(t, s, deleted)); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("restored stream loaded %v, want [1 3]", got)
+	}
+	if err := s.Record(deleted, []Event{itemAdded{SKU: "b"}}); err != nil {
+		t.Errorf("appending to a restored stream: %v", err)
+	}
+}
+
+func TestTombstoneStream(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TombstoneStream(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if recs := mustLoad(t, s, id); len(recs) != 0 {
+		t.Errorf("a tombstoned stream loaded %d events", len(recs))
+	}
+	if err := s.Record(id, []Event{itemAdded{SKU: "b"}}); !errors.Is(err, ErrStreamTombstoned) {
+		t.Errorf("appending to a tombstoned stream: %v, want ErrStreamTombstoned", err)
+	}
+	if err := s.RestoreStream(id); !errors.Is(err, ErrStreamTombstoned) {
+		t.Errorf("restoring a tombstoned stream: %v, want ErrStreamTombstoned", err)
+	}
+	if err := s.DeleteStream(id); !errors.Is(err, ErrStreamTombstoned) {
+		t.Errorf("deleting a tombstoned stream: %v, want ErrStreamTombstoned", err)
+	}
+}
+
+// A deleted stream can still be tombstoned, and a stream that was never
+// written to can be tombstoned to reserve its ID.
+func TestTombstoneDeletedOrEmptyStream(t *testing.T) {
+	s := newTestStore(t)
+	id, empty := NewID(), NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteStream(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TombstoneStream(id); err != nil {
+		t.Errorf("tombstoning a deleted stream: %v", err)
+	}
+	if err := s.TombstoneStream(empty); err != nil {
+		t.Fatalf("tombstoning an empty stream: %v", err)
+	}
+	if err := s.Record(empty, []Event{itemAdded{SKU: "a"}}); !errors.Is(err, ErrStreamTombstoned) {
+		t.Errorf("appending to a tombstoned empty stream: %v, want ErrStreamTombstoned", err)
+	}
+}
+
+func TestDeleteMissingStream(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.DeleteStream(NewID()); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("deleting a stream without events: %v, want ErrStreamNotFound", err)
+	}
+	if err := s.RestoreStream(NewID()); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("restoring a stream without events: %v, want ErrStreamNotFound", err)
+	}
+}
+
+func TestDeleteStreamOfTenant(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	acme, globex := s.ForTenant("acme"), s.ForTenant("globex")
+	for _, store := range []EventStore{acme, globex} {
+		if err := store.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := acme.DeleteStream(id); err != nil {
+		t.Fatal(err)
+	}
+	if recs := mustLoad(t, acme, id); len(recs) != 0 {
+		t.Errorf("acme's deleted stream loaded %d events", len(recs))
+	}
+	if recs := mustLoad(t, globex, id); len(recs) != 1 {
+		t.Errorf("globex's stream loaded %d events after acme deleted its own", len(recs))
+	}
+}
@@ -0,0 +1,126 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// tenantStore is a view of a fileStore restricted to one tenant. Events it
+// records are tagged with the tenant, and its reads and publishers only ever
+// see that tenant's events, so several tenants can share one database file.
+type tenantStore struct {
+	store    *fileStore
+	tenantID string
+}
+
+// ForTenant returns an EventStore scoped to tenantID. The fileStore itself
+// acts as the default tenant "".
+func (s *fileStore) ForTenant(tenantID string) *tenantStore {
+	return &tenantStore{store: s, tenantID: tenantID}
+}
+
+func (t *tenantStore) TenantID() string {
+	return t.tenantID
+}
+
+func (t *tenantStore) Record(aggregateID uuid.UUID, evs []Event) error {
+	return t.store.record(context.Background(), t.tenantID, "", aggregateID, anyVersion, evs, nil)
+}
+
+func (t *tenantStore) RecordWithMetadata(aggregateID uuid.UUID, evs []EventWithMeta) error {
+	events, md := splitMetadata(evs)
+	return t.store.record(context.Background(), t.tenantID, "", aggregateID, anyVersion, events, md)
+}
+
+func (t *tenantStore) RecordAs(ctx context.Context, aggregateType string, aggregateID uuid.UUID, evs []Event) error {
+	return t.store.record(ctx, t.tenantID, aggregateType, aggregateID, anyVersion, evs, nil)
+}
+
+func (t *tenantStore) RecordAtVersion(ctx context.Context, aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []Event) error {
+	return t.store.record(ctx, t.tenantID, aggregateType, aggregateID, expectedVersion, evs, nil)
+}
+
+func (t *tenantStore) MustRecord(aggregateID uuid.UUID, evs []Event) {
+	err := t.Record(aggregateID, evs)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (t *tenantStore) LoadStream(aggregateID uuid.UUID) ([]RecordedEvent, error) {
+	return t.store.loadStream(t.tenantID, aggregateID)
+}
+
+func (t *tenantStore) LoadStreams(ids []uuid.UUID) (map[uuid.UUID][]RecordedEvent, error) {
+	return t.store.loadStreams(t.tenantID, ids)
+}
+
+func (t *tenantStore) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	return t.store.loadStreamFrom(t.tenantID, aggregateID, fromVersion, limit)
+}
+
+func (t *tenantStore) LoadStreamAsOf(aggregateID uuid.UUID, asOf AsOf) ([]RecordedEvent, error) {
+	return t.store.loadStreamAsOf(t.tenantID, aggregateID, asOf)
+}
+
+func (t *tenantStore) LoadStreamBackward(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	return t.store.loadStreamBackward(t.tenantID, aggregateID, fromVersion, limit)
+}
+
+func (t *tenantStore) ReadAllBackward(fromSeq int64, limit int) ([]RecordedEvent, error) {
+	return t.store.readAllBackward(t.tenantID, fromSeq, limit)
+}
+
+func (t *tenantStore) ReplayFrom(seq int64, handler RecordedEventHandlerFunc, filters ...EventFilter) error {
+	return t.store.replayFrom(t.tenantID, seq, handler, filters...)
+}
+
+func (t *tenantStore) ReadAll(fromSeq int64, limit int) ([]RecordedEvent, error) {
+	return t.store.readAll(t.tenantID, fromSeq, limit)
+}
+
+func (t *tenantStore) RegisterPublisher(publisher RecordedEventPublisher, filters ...EventFilter) {
+	t.store.registerPublisher(t.tenantID, FilterPublisher(publisher, filters...))
+}
+
+func (t *tenantStore) UnmarshalEvent(eventType string, data []byte) (Event, error) {
+	return t.store.UnmarshalEvent(eventType, data)
+}
+
+func (t *tenantStore) registerEvent(eventType string, ctor func() Event, alias bool) {
+	t.store.registerEvent(eventType, ctor, alias)
+}
+
+func (t *tenantStore) registerSchema(goType string, schema *jsonschema.Schema) {
+	t.store.registerSchema(goType, schema)
+}
+
+func (t *tenantStore) registerCodec(goType string, codec eventCodec) {
+	t.store.registerCodec(goType, codec)
+}
+
+// migrateTenantColumn adds the tenant dimension to stores created before it
+// existed; their events all belong to the default tenant
+func migrateTenantColumn(db *sql.DB, table string) error {
+	ok, err := hasColumn(db, table, "tenant_id")
+	if err != nil {
+		return fmt.Errorf("failed to inspect events table: %w", err)
+	}
+	if !ok {
+		if _, err := db.Exec(`alter table ` + table + ` add column tenant_id text not null default ''`); err != nil {
+			return fmt.Errorf("failed to add tenant_id column: %w", err)
+		}
+	}
+	// versions are per stream, and streams are per tenant
+	if _, err := db.Exec(`drop index if exists ` + table + `_stream_version`); err != nil {
+		return fmt.Errorf("failed to drop version index: %w", err)
+	}
+	if _, err := db.Exec(`create unique index if not exists ` + table + `_tenant_stream_version on ` + table + `(tenant_id, aggregate_id, version)`); err != nil {
+		return fmt.Errorf("failed to create version index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package evoke
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Tenants sharing a file see only their own events, in streams of their own
+// even under the same aggregate id.
+func TestTenantsAreIsolated(t *testing.T) {
+	s := newTestStore(t)
+	acme, globex := s.ForTenant("acme"), s.ForTenant("globex")
+	var acmePublished, defaultPublished recordingPublisher
+	acme.RegisterPublisher(&acmePublished)
+	s.RegisterPublisher(&defaultPublished)
+
+	id := NewID()
+	for _, store := range []EventStore{acme, globex, acme, s} {
+		if err := store.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type tenantReader interface {
+		EventStore
+		StreamPager
+		MultiStreamLoader
+		BackwardReader
+	}
+	tests := []struct {
+		name  string
+		store tenantReader
+		// seqs are the sequences of the tenant's events
+		seqs []int64
+	}{
+		{name: "acme", store: acme, seqs: []int64{1, 3}},
+		{name: "globex", store: globex, seqs: []int64{2}},
+		{name: "default", store: s, seqs: []int64{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := mustLoad(t, tt.store, id)
+			if got := sequences(stream); !slices.Equal(got, tt.seqs) {
+				t.Errorf("stream has events %v, want %v", got, tt.seqs)
+			}
+			if v := stream[len(stream)-1].Version; v != int64(len(tt.seqs)) {
+				t.Errorf("stream is at version %d, want %d", v, len(tt.seqs))
+			}
+
+			reads := map[string]func() ([]RecordedEvent, error){
+				"ReadAll":            func() ([]RecordedEvent, error) { return tt.store.ReadAll(1, 0) },
+				"LoadStreamFrom":     func() ([]RecordedEvent, error) { return tt.store.LoadStreamFrom(id, 1, 0) },
+				"LoadStreamBackward": func() ([]RecordedEvent, error) { return reversed(tt.store.LoadStreamBackward(id, 0, 0)) },
+				"ReadAllBackward":    func() ([]RecordedEvent, error) { return reversed(tt.store.ReadAllBackward(0, 0)) },
+				"LoadStreams": func() ([]RecordedEvent, error) {
+					streams, err := tt.store.LoadStreams([]uuid.UUID{id})
+					return streams[id], err
+				},
+				"ReplayFrom": func() ([]RecordedEvent, error) {
+					var recs []RecordedEvent
+					err := tt.store.ReplayFrom(1, func(rec RecordedEvent, _ bool) error {
+						recs = append(recs, rec)
+						return nil
+					})
+					return recs, err
+				},
+			}
+			for name, read := range reads {
+				recs, err := read()
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if got := sequences(recs); !slices.Equal(got, tt.seqs) {
+					t.Errorf("%s read %v, want %v", name, got, tt.seqs)
+				}
+			}
+		})
+	}
+
+	if got := sequences(acmePublished.published()); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("acme's publisher got %v, want [1 3]", got)
+	}
+	if got := sequences(defaultPublished.published()); !slices.Equal(got, []int64{4}) {
+		t.Errorf("the default tenant's publisher got %v, want [4]", got)
+	}
+
+	var tenants []string
+	err := s.ScanRaw(RawQuery{}, func(e RawEvent) error {
+		tenants = append(tenants, e.TenantID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tenants, []string{"acme", "globex", "acme", ""}) {
+		t.Errorf("events stored for tenants %q", tenants)
+	}
+}
+
+// Version checks are per tenant stream.
+func TestTenantRecordAtVersion(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	id := NewID()
+	if err := s.ForTenant("acme").RecordAtVersion(ctx, "Cart", id, 0, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ForTenant("globex").RecordAtVersion(ctx, "Cart", id, 0, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Errorf("globex's first append conflicted with acme's stream: %v", err)
+	}
+	if err := s.ForTenant("acme").RecordAtVersion(ctx, "Cart", id, 0, []Event{itemAdded{SKU: "a"}}); err == nil {
+		t.Error("a stale append to acme's stream succeeded")
+	}
+}
+
+// reversed reverses the events a backward read returns
+func reversed(recs []RecordedEvent, err error) ([]RecordedEvent, error) {
+	slices.Reverse(recs)
+	return recs, err
+}
@@ -0,0 +1,267 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The events insertEvents returns are built from what it was given rather
+// than read back, so they have to be exactly what a read returns.
+func TestAppendedEventsMatchReadBack(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []FileStoreOption
+		evs     []Event
+		eventMD []Metadata
+		// raw is the payload each event should be stored with
+		raw []string
+	}{
+		{
+			name: "json",
+			evs:  []Event{itemAdded{SKU: "a", Qty: 1}, &itemRemoved{SKU: "a"}},
+			raw:  []string{`{"SKU":"a","Qty":1}`, `{"SKU":"a"}`},
+		},
+		{
+			name:    "event metadata",
+			evs:     []Event{itemAdded{SKU: "b", Qty: 2}, itemAdded{SKU: "c", Qty: 3}},
+			eventMD: []Metadata{{"ip": "10.0.0.1"}, nil},
+			raw:     []string{`{"SKU":"b","Qty":2}`, `{"SKU":"c","Qty":3}`},
+		},
+		{
+			name: "codec",
+			evs:  []Event{pipeEncoded{SKU: "d", Qty: 4}, &pipeEncoded{SKU: "e", Qty: 5}},
+			opts: []FileStoreOption{WithBinaryPayloads()},
+			raw:  []string{"d|4", "e|5"},
+		},
+		{
+			name: "compressed and encrypted",
+			evs:  []Event{itemAdded{SKU: strings.Repeat("f", 500), Qty: 6}},
+			opts: []FileStoreOption{WithPayloadCompression(), WithPayloadEncryption()},
+			raw:  []string{`{"SKU":"` + strings.Repeat("f", 500) + `","Qty":6}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, tt.opts...)
+			registerPipeCodec(s)
+			id := NewID()
+			// an earlier event, so versions and sequences don't start at 1
+			if err := s.Record(id, []Event{itemRemoved{SKU: "z"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			md := Metadata{"trace": "t1"}
+			got, err := s.appendEvents("", "Cart", id, 1, tt.evs, md, tt.eventMD, nil)
+			if err != nil {
+				t.Fatalf("appendEvents: %v", err)
+			}
+
+			read := mustLoad(t, s, id)[1:]
+			if len(got) != len(tt.evs) || len(read) != len(tt.evs) {
+				t.Fatalf("appended %d and read back %d events, want %d", len(got), len(read), len(tt.evs))
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], read[i]) {
+					t.Errorf("event %d appended as\n%+v\nread back as\n%+v", i, got[i], read[i])
+				}
+				if got[i].Version != int64(i)+2 {
+					t.Errorf("event %d has version %d, want %d", i, got[i].Version, i+2)
+				}
+				if got[i].RecordedAt == 0 {
+					t.Errorf("event %d has no timestamp", i)
+				}
+				if got[i].Metadata["trace"] != "t1" {
+					t.Errorf("event %d has metadata %v, want the append's", i, got[i].Metadata)
+				}
+			}
+
+			var raw []string
+			err = s.ScanRaw(RawQuery{AggregateID: id, FromSequence: got[0].Sequence}, func(e RawEvent) error {
+				raw = append(raw, string(e.Data))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ScanRaw: %v", err)
+			}
+			if !reflect.DeepEqual(raw, tt.raw) {
+				t.Errorf("stored payloads %q, want %q", raw, tt.raw)
+			}
+		})
+	}
+}
+
+// A batch too large for one insert statement is still recorded whole and in
+// order.
+func TestAppendLargeBatch(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	evs := make([]Event, insertBatchSize*2+1)
+	for i := range evs {
+		evs[i] = itemAdded{SKU: "a", Qty: i}
+	}
+	got, err := s.appendEvents("", "", id, anyVersion, evs, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := mustLoad(t, s, id)
+	if len(got) != len(evs) || len(read) != len(evs) {
+		t.Fatalf("appended %d and read back %d events, want %d", len(got), len(read), len(evs))
+	}
+	for i := range read {
+		if got[i].Sequence != int64(i+1) || read[i].Version != int64(i+1) || read[i].Event != evs[i] {
+			t.Fatalf("event %d appended at sequence %d and read back as %+v", i, got[i].Sequence, read[i])
+		}
+	}
+}
+
+// unmarshalable can't be encoded as JSON
+type unmarshalable struct {
+	C chan int
+}
+
+// A batch that fails part way records none of its events.
+func TestAppendIsAtomic(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	evs := make([]Event, insertBatchSize+1)
+	for i := range evs {
+		evs[i] = itemAdded{SKU: "a", Qty: i}
+	}
+	evs[len(evs)-1] = unmarshalable{}
+	if err := s.Record(id, evs); err == nil {
+		t.Fatal("recording an event that can't be encoded returned no error")
+	}
+	if recs := mustLoad(t, s, id); len(recs) != 0 {
+		t.Errorf("a failed batch left %d events", len(recs))
+	}
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if recs := mustLoad(t, s, id); len(recs) != 1 || recs[0].Sequence != 1 || recs[0].Version != 1 {
+		t.Errorf("after a failed batch the stream holds %+v", recs)
+	}
+}
+
+func BenchmarkAppendEvents(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("events=%d", n), func(b *testing.B) {
+			s := newTestStore(b)
+			evs := make([]Event, n)
+			for i := range evs {
+				evs[i] = itemAdded{SKU: "sku-" + strconv.Itoa(i), Qty: i}
+			}
+			id := NewID()
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.RecordAtVersion(ctx, "Cart", id, int64(i*n), evs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAppendEventsGrouped(b *testing.B) {
+	s := newTestStore(b, WithGroupCommit(time.Millisecond))
+	evs := []Event{itemAdded{SKU: "a", Qty: 1}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := NewID()
+		for pb.Next() {
+			if err := s.Record(id, evs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRecordAtVersion(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	id := NewID()
+	for _, tt := range []struct {
+		expected int64
+		conflict bool
+	}{
+		{expected: 1, conflict: true},
+		{expected: 0},
+		{expected: 0, conflict: true},
+		{expected: 1},
+		{expected: anyVersion},
+	} {
+		err := s.RecordAtVersion(ctx, "", id, tt.expected, []Event{itemAdded{}})
+		if tt.conflict != errors.Is(err, ErrConcurrencyConflict) {
+			t.Errorf("appending at version %d: %v", tt.expected, err)
+		}
+	}
+	if n := len(mustLoad(t, s, id)); n != 3 {
+		t.Errorf("recorded %d events, want only the appends that didn't conflict", n)
+	}
+}
+
+// itemAddedV2 is the second generation of itemAdded's payload
+type itemAddedV2 struct {
+	SKU string
+	Qty int
+}
+
+func (itemAddedV2) SchemaVersion() int { return 2 }
+
+func TestSchemaVersions(t *testing.T) {
+	s := newTestStore(t)
+	RegisterEvent(s, &itemAddedV2{})
+	for name, s := range map[string]EventStore{"file": s, "tenant": s.ForTenant("acme")} {
+		t.Run(name, func(t *testing.T) {
+			id := NewID()
+			if err := s.Record(id, []Event{itemAdded{}, itemAddedV2{}}); err != nil {
+				t.Fatal(err)
+			}
+			want := []int{1, 2}
+			for i, rec := range mustLoad(t, s, id) {
+				if rec.SchemaVersion != want[i] {
+					t.Errorf("event %d loaded with schema version %d, want %d", rec.Sequence, rec.SchemaVersion, want[i])
+				}
+			}
+		})
+	}
+}
+
+// Events of a store from before schema versions are generation 1.
+func TestSchemaVersionMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	old, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterEvent(old, &itemAdded{})
+	id := NewID()
+	if err := old.Record(id, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.db.Exec(`alter table events drop column schema_version`); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	RegisterEvent(s, &itemAdded{})
+	if recs := mustLoad(t, s, id); len(recs) != 1 || recs[0].SchemaVersion != 1 {
+		t.Errorf("loaded %+v from a migrated store, want schema version 1", recs)
+	}
+}
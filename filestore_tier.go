@@ -0,0 +1,509 @@
+package evoke
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SegmentStore keeps the segments of a tiered store in object storage. The
+// evokes3 package implements it for S3 and S3 compatible services.
+type SegmentStore interface {
+	PutSegment(ctx context.Context, name string, data []byte) error
+	GetSegment(ctx context.Context, name string) ([]byte, error)
+}
+
+// WithSegmentTier lets TierBefore and TierOlderThan move closed ranges of
+// the log into compressed segments kept in segments, deleting them from the
+// database. Reads stitch tiered events back in from their segments, which
+// are fetched without holding up appends; reads that need segments wait on
+// object storage.
+func WithSegmentTier(segments SegmentStore) FileStoreOption {
+	return func(s *fileStore) {
+		s.segments = segments
+	}
+}
+
+// createTierTables records which segments exist and which streams they
+// hold, so stream reads fetch only the segments they need and appends keep
+// numbering tiered streams where they left off
+func (s *fileStore) createTierTables() error {
+	if s.archiveFile != "" {
+		return fmt.Errorf("a store cannot use both an archive and a segment tier")
+	}
+	if _, err := s.db.Exec(`
+		create table if not exists tier_segments (
+			name           text primary key,
+			first_sequence integer not null,
+			last_sequence  integer not null
+		);
+		create table if not exists tier_streams (
+			tenant_id      text not null,
+			aggregate_id   text not null,
+			segment        text not null,
+			last_version   integer not null,
+			aggregate_type text not null,
+			primary key (tenant_id, aggregate_id, segment)
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create tier tables: %w", err)
+	}
+	return nil
+}
+
+// attempts of TierBefore to tier a range that doesn't change under it
+const tierAttempts = 3
+
+// TierBefore moves every event with a sequence lower than seq into a new
+// segment, returning how many were moved.
+func (s *fileStore) TierBefore(ctx context.Context, seq int64) (int64, error) {
+	if s.segments == nil {
+		return 0, fmt.Errorf("no segment tier configured (hint: use WithSegmentTier)")
+	}
+	for attempt := 1; ; attempt++ {
+		n, err := s.tierBefore(ctx, seq)
+		if !errors.Is(err, errTierRangeChanged) || attempt == tierAttempts {
+			return n, err
+		}
+	}
+}
+
+// errTierRangeChanged is returned by tierBefore when the events it uploaded
+// were changed before it could delete them
+var errTierRangeChanged = errors.New("events changed while tiering")
+
+func (s *fileStore) tierBefore(ctx context.Context, seq int64) (int64, error) {
+	// the range is closed, so nothing new can enter it while it uploads
+	s.mu.Lock()
+	var rows []dbEvent
+	err := s.db.Select(&rows, `select * from `+s.table+` where sequence < ? order by sequence asc`, seq)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("select from events: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	data, err := encodeSegment(rows)
+	if err != nil {
+		return 0, err
+	}
+	first, last := rows[0].Sequence, rows[len(rows)-1].Sequence
+	name := fmt.Sprintf("segment-%020d-%020d.ndjson.gz", first, last)
+	if err := s.segments.PutSegment(ctx, name, data); err != nil {
+		return 0, fmt.Errorf("put segment %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// truncation, retention, deletes and key rotation may have changed
+	// the range while it uploaded, and the segment has to hold what is
+	// deleted. The uploaded segment is overwritten by the next attempt.
+	var current []dbEvent
+	if err := tx.Select(&current, `select * from `+s.table+` where sequence between ? and ? order by sequence asc`, first, last); err != nil {
+		return 0, fmt.Errorf("select from events: %w", err)
+	}
+	if !reflect.DeepEqual(current, rows) {
+		return 0, fmt.Errorf("%w: %d..%d", errTierRangeChanged, first, last)
+	}
+
+	_, err = tx.Exec(`insert into tier_segments(name, first_sequence, last_sequence) values(?,?,?)`, name, first, last)
+	if err != nil {
+		return 0, fmt.Errorf("insert into tier_segments: %w", err)
+	}
+	_, err = tx.Exec(`insert into tier_streams(tenant_id, aggregate_id, segment, last_version, aggregate_type)
+		select tenant_id, aggregate_id, ?, max(version), max(aggregate_type)
+		from `+s.table+` where sequence between ? and ?
+		group by tenant_id, aggregate_id`, name, first, last)
+	if err != nil {
+		return 0, fmt.Errorf("insert into tier_streams: %w", err)
+	}
+	res, err := tx.Exec(`delete from `+s.table+` where sequence between ? and ?`, first, last)
+	if err != nil {
+		return 0, fmt.Errorf("delete from events: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// TierOlderThan moves events recorded longer ago than retention into a new
+// segment.
+func (s *fileStore) TierOlderThan(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention).Unix()
+
+	s.mu.Lock()
+	var seq int64
+	err := s.db.Get(&seq, `select coalesce(max(sequence), 0) + 1 from `+s.table+` where recorded_at < ?`, cutoff)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("select from events: %w", err)
+	}
+
+	return s.TierBefore(ctx, seq)
+}
+
+// RunTiering calls TierOlderThan every interval until ctx is done.
+func (s *fileStore) RunTiering(ctx context.Context, interval, retention time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.TierOlderThan(ctx, retention)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			s.logger.Info("evoke: tiered events", "events", n)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func encodeSegment(rows []dbEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return nil, fmt.Errorf("encode segment: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress segment: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// readSegment calls fn on each row of a segment, in sequence order
+func (s *fileStore) readSegment(name string, fn func(dbEvent) error) error {
+	data, err := s.segments.GetSegment(context.Background(), name)
+	if err != nil {
+		return fmt.Errorf("get segment %s: %w", name, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decompress segment %s: %w", name, err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(zr))
+	for {
+		var row dbEvent
+		err := dec.Decode(&row)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("decode segment %s: %w", name, err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// hiddenStreams returns the deleted and tombstoned streams of a tenant, the
+// Go side of visibleStreams. Callers hold s.mu.
+func (s *fileStore) hiddenStreams(tenantID string) (map[uuid.UUID]bool, error) {
+	var ids []uuid.UUID
+	err := s.db.Select(&ids, `select aggregate_id from stream_states where tenant_id = ?`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("select from stream_states: %w", err)
+	}
+	hidden := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	return hidden, nil
+}
+
+// replaySegments hands the tiered events from seq on to handler, returning
+// the sequence the database should continue from
+func (s *fileStore) replaySegments(tenantID string, seq int64, handler RecordedEventHandlerFunc, filters []EventFilter) (int64, error) {
+	return s.scanSegments(tenantID, seq, filters, func(rec RecordedEvent) error {
+		s.inst.EventReplayed(rec.Sequence)
+		if err := handler(rec, true); err != nil {
+			return fmt.Errorf("callback error: %w", err)
+		}
+		return nil
+	})
+}
+
+// errStopScan stops scanSegments early
+var errStopScan = errors.New("stop scan")
+
+// scanSegments calls fn on each visible tiered event of a tenant from seq on
+// that passes filters, returning the sequence the database should continue
+// from. fn returns errStopScan to stop.
+//
+// Segments never change once written, so they are fetched without holding
+// s.mu, which is only taken to read the tier index.
+func (s *fileStore) scanSegments(tenantID string, seq int64, filters []EventFilter, fn func(RecordedEvent) error) (int64, error) {
+	if s.segments == nil {
+		return seq, nil
+	}
+	var names []string
+	var visible func(dbEvent) bool
+	err := s.withTierIndex(func() error {
+		err := s.db.Select(&names, `select name from tier_segments where last_sequence >= ? order by first_sequence`, seq)
+		if err != nil || len(names) == 0 {
+			return err
+		}
+		visible, err = s.segmentVisibility(tenantID)
+		return err
+	})
+	if err != nil || len(names) == 0 {
+		return seq, err
+	}
+
+	next := seq
+	for _, name := range names {
+		var rows []dbEvent
+		err := s.readSegment(name, func(row dbEvent) error {
+			next = max(next, row.Sequence+1)
+			if row.Sequence >= seq && visible(row) {
+				rows = append(rows, row)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		recs, err := s.decodeTiered(rows)
+		if err != nil {
+			return 0, err
+		}
+		for _, rec := range recs {
+			if !MatchesAll(filters, rec) {
+				continue
+			}
+			if err := fn(rec); errors.Is(err, errStopScan) {
+				return rec.Sequence + 1, nil
+			} else if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return next, nil
+}
+
+// withTierIndex runs fn, which reads the tier index from s.db, under s.mu
+func (s *fileStore) withTierIndex(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn()
+}
+
+// decodeTiered decodes rows read from segments, looking up their keys
+// through the read pool if there is one and under s.mu otherwise
+func (s *fileStore) decodeTiered(rows []dbEvent) ([]RecordedEvent, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	if s.reader != nil {
+		return s.decodeRows(s.reader, rows)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.decodeRows(s.db, rows)
+}
+
+// segmentVisibility returns whether a tiered row is of the tenant and shown
+// to reads, the Go side of visibleStreams. Callers hold s.mu.
+func (s *fileStore) segmentVisibility(tenantID string) (func(dbEvent) bool, error) {
+	hidden, err := s.hiddenStreams(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	truncated, err := s.truncations(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return func(row dbEvent) bool {
+		return row.TenantID == tenantID && !hidden[row.AggregateID] && row.Version >= truncated[row.AggregateID]
+	}, nil
+}
+
+// segmentPage returns up to limit tiered events of a tenant from seq on
+// that pass filters, all of them if limit <= 0.
+func (s *fileStore) segmentPage(tenantID string, seq int64, limit int, filters []EventFilter) ([]RecordedEvent, error) {
+	var recs []RecordedEvent
+	_, err := s.scanSegments(tenantID, seq, filters, func(rec RecordedEvent) error {
+		recs = append(recs, rec)
+		if limit > 0 && len(recs) == limit {
+			return errStopScan
+		}
+		return nil
+	})
+	return recs, err
+}
+
+// stitchTiered puts tiered events in front of events read from the
+// database before them, trimming the result to limit. Tiering moves the
+// oldest events out of the database, so any of those read that have since
+// been tiered too are the ones up to the last tiered event, and are dropped.
+func stitchTiered(tiered, recs []RecordedEvent, limit int) []RecordedEvent {
+	if len(tiered) == 0 {
+		return recs
+	}
+	last := tiered[len(tiered)-1].Sequence
+	i := 0
+	for i < len(recs) && recs[i].Sequence <= last {
+		i++
+	}
+	recs = slices.Concat(tiered, recs[i:])
+	if limit > 0 && len(recs) > limit {
+		recs = recs[:limit]
+	}
+	return recs
+}
+
+// segmentsBackward returns up to limit tiered events of a tenant from seq
+// down, newest first, all of them if limit <= 0.
+func (s *fileStore) segmentsBackward(tenantID string, seq int64, limit int) ([]RecordedEvent, error) {
+	if s.segments == nil {
+		return nil, nil
+	}
+	var names []string
+	var visible func(dbEvent) bool
+	err := s.withTierIndex(func() error {
+		err := s.db.Select(&names, `select name from tier_segments where first_sequence <= ? order by first_sequence desc`, seq)
+		if err != nil || len(names) == 0 {
+			return err
+		}
+		visible, err = s.segmentVisibility(tenantID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var recs []RecordedEvent
+	for _, name := range names {
+		var rows []dbEvent
+		err := s.readSegment(name, func(row dbEvent) error {
+			if row.Sequence <= seq && visible(row) {
+				rows = append(rows, row)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.Reverse(rows)
+		if limit > 0 {
+			rows = rows[:min(len(rows), limit-len(recs))]
+		}
+		decoded, err := s.decodeTiered(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, decoded...)
+		if limit > 0 && len(recs) == limit {
+			return recs, nil
+		}
+	}
+	return recs, nil
+}
+
+// segmentRows returns the tiered rows of every tenant from seq on, for raw
+// scans.
+func (s *fileStore) segmentRows(seq int64) ([]dbEvent, error) {
+	if s.segments == nil {
+		return nil, nil
+	}
+	var names []string
+	err := s.withTierIndex(func() error {
+		return s.db.Select(&names, `select name from tier_segments where last_sequence >= ? order by first_sequence`, seq)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("select from tier_segments: %w", err)
+	}
+	var rows []dbEvent
+	for _, name := range names {
+		err := s.readSegment(name, func(row dbEvent) error {
+			if row.Sequence >= seq {
+				rows = append(rows, row)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// segmentStream returns the tiered events of a stream from fromVersion on.
+func (s *fileStore) segmentStream(tenantID string, aggregateID uuid.UUID, fromVersion int64) ([]RecordedEvent, error) {
+	if s.segments == nil {
+		return nil, nil
+	}
+	var names []string
+	err := s.withTierIndex(func() error {
+		truncated, err := s.truncatedBefore(tenantID, aggregateID)
+		if err != nil {
+			return err
+		}
+		fromVersion = max(fromVersion, truncated)
+		err = s.db.Select(&names, `select segment from tier_streams
+			where tenant_id = ? and aggregate_id = ? and last_version >= ? and not exists (
+				select 1 from stream_states ss where ss.tenant_id = tier_streams.tenant_id and ss.aggregate_id = tier_streams.aggregate_id)
+			order by last_version`, tenantID, aggregateID.String(), fromVersion)
+		if err != nil {
+			return fmt.Errorf("select from tier_streams: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []dbEvent
+	for _, name := range names {
+		err := s.readSegment(name, func(row dbEvent) error {
+			if row.TenantID == tenantID && row.AggregateID == aggregateID && row.Version >= fromVersion {
+				rows = append(rows, row)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.decodeTiered(rows)
+}
+
+// withSegmentStream puts the tiered events of a stream in front of those
+// read from the database before them, trimming the result to limit. Events
+// read that have since been tiered too are dropped, as by stitchTiered.
+func (s *fileStore) withSegmentStream(tenantID string, aggregateID uuid.UUID, fromVersion int64, limit int, recs []RecordedEvent) ([]RecordedEvent, error) {
+	tiered, err := s.segmentStream(tenantID, aggregateID, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	return stitchTiered(tiered, recs, limit), nil
+}
@@ -0,0 +1,257 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memSegments keeps segments in memory, calling onGet and onPut, if set,
+// before a segment is fetched or stored
+type memSegments struct {
+	mu       sync.Mutex
+	segments map[string][]byte
+	onGet    func(name string)
+	onPut    func(name string)
+}
+
+func (m *memSegments) PutSegment(ctx context.Context, name string, data []byte) error {
+	if m.onPut != nil {
+		m.onPut(name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.segments == nil {
+		m.segments = make(map[string][]byte)
+	}
+	m.segments[name] = data
+	return nil
+}
+
+func (m *memSegments) GetSegment(ctx context.Context, name string) ([]byte, error) {
+	if m.onGet != nil {
+		m.onGet(name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.segments[name]
+	if !ok {
+		return nil, errors.New("no such segment")
+	}
+	return data, nil
+}
+
+// tieredStore returns a store with two streams interleaved over 20 events,
+// the first 12 of them tiered
+func tieredStore(t *testing.T, segments *memSegments, opts ...FileStoreOption) (*fileStore, [2]uuid.UUID) {
+	t.Helper()
+	s := newTestStore(t, append([]FileStoreOption{WithSegmentTier(segments)}, opts...)...)
+	ids := [2]uuid.UUID{NewID(), NewID()}
+	for i := 0; i < 10; i++ {
+		for _, id := range ids {
+			var e Event = itemAdded{SKU: "a", Qty: i}
+			if i%3 == 0 {
+				e = itemRemoved{SKU: "a"}
+			}
+			if err := s.Record(id, []Event{e}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return s, ids
+}
+
+// Every read returns tiered events as if they were still in the database.
+func TestTieredReads(t *testing.T) {
+	reads := map[string]func(s *fileStore, ids [2]uuid.UUID) ([]RecordedEvent, error){
+		"ReadAll": func(s *fileStore, _ [2]uuid.UUID) ([]RecordedEvent, error) { return s.ReadAll(1, 0) },
+		"ReadAll across the tier": func(s *fileStore, _ [2]uuid.UUID) ([]RecordedEvent, error) {
+			return s.ReadAll(9, 8)
+		},
+		"ReadAll within the tier": func(s *fileStore, _ [2]uuid.UUID) ([]RecordedEvent, error) { return s.ReadAll(2, 5) },
+		"ReadByType": func(s *fileStore, _ [2]uuid.UUID) ([]RecordedEvent, error) {
+			return s.ReadByType(TypeName(itemRemoved{}), 1, 0)
+		},
+		"LoadStream": func(s *fileStore, ids [2]uuid.UUID) ([]RecordedEvent, error) { return s.LoadStream(ids[0]) },
+		"LoadStreams": func(s *fileStore, ids [2]uuid.UUID) ([]RecordedEvent, error) {
+			streams, err := s.LoadStreams(ids[:])
+			return append(streams[ids[0]], streams[ids[1]]...), err
+		},
+		"LoadStreamFrom": func(s *fileStore, ids [2]uuid.UUID) ([]RecordedEvent, error) {
+			return s.LoadStreamFrom(ids[1], 4, 4)
+		},
+		"LoadStreamBackward": func(s *fileStore, ids [2]uuid.UUID) ([]RecordedEvent, error) {
+			return s.LoadStreamBackward(ids[0], 0, 0)
+		},
+		"ReadAllBackward": func(s *fileStore, _ [2]uuid.UUID) ([]RecordedEvent, error) { return s.ReadAllBackward(15, 10) },
+		"ReplayFrom": func(s *fileStore, _ [2]uuid.UUID) ([]RecordedEvent, error) {
+			var recs []RecordedEvent
+			err := s.ReplayFrom(3, func(rec RecordedEvent, _ bool) error {
+				recs = append(recs, rec)
+				return nil
+			})
+			return recs, err
+		},
+	}
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			s, ids := tieredStore(t, &memSegments{})
+			want, err := read(s, ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err := s.TierBefore(context.Background(), 13); err != nil || n != 12 {
+				t.Fatalf("TierBefore(13) = %d, %v; want 12 tiered", n, err)
+			}
+			got, err := read(s, ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sequences(got), sequences(want)) {
+				t.Errorf("read %v after tiering, want %v", sequences(got), sequences(want))
+			}
+		})
+	}
+}
+
+func TestScanRawTiered(t *testing.T) {
+	s, ids := tieredStore(t, &memSegments{})
+	scan := func() []int64 {
+		var seqs []int64
+		err := s.ScanRaw(RawQuery{AggregateID: ids[1], FromSequence: 3, Limit: 6}, func(e RawEvent) error {
+			seqs = append(seqs, e.Sequence)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return seqs
+	}
+	want := scan()
+	if _, err := s.TierBefore(context.Background(), 9); err != nil {
+		t.Fatal(err)
+	}
+	if got := scan(); !reflect.DeepEqual(got, want) {
+		t.Errorf("scanned %v after tiering, want %v", got, want)
+	}
+}
+
+// Segments are fetched without holding the store, so appends carry on while
+// a read waits on object storage.
+func TestTieredReadsDontBlockAppends(t *testing.T) {
+	segments := &memSegments{}
+	s, ids := tieredStore(t, segments)
+	if _, err := s.TierBefore(context.Background(), 13); err != nil {
+		t.Fatal(err)
+	}
+	segments.onGet = func(string) {
+		done := make(chan error, 1)
+		go func() { done <- s.Record(NewID(), []Event{itemAdded{SKU: "b"}}) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("append blocked while a segment was fetched")
+		}
+	}
+
+	if _, err := s.LoadStream(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadAll(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReplayFrom(1, func(RecordedEvent, bool) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Events reaped while their range uploads are not tiered back to life.
+func TestTierBeforeRechecksTheRange(t *testing.T) {
+	segments := &memSegments{}
+	s, ids := tieredStore(t, segments)
+	var puts int
+	segments.onPut = func(string) {
+		puts++
+		if puts > 1 {
+			return
+		}
+		_, err := s.ReapExpired(context.Background(), RetentionRule{EventType: TypeName(itemRemoved{}), MaxCount: 1})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	n, err := s.TierBefore(context.Background(), 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if puts != 2 {
+		t.Errorf("uploaded %d times, want 2", puts)
+	}
+	// itemRemoved events 1, 2, 7 and 8 were reaped, leaving 8 to tier
+	if n != 8 {
+		t.Errorf("tiered %d events, want 8", n)
+	}
+	for _, id := range ids {
+		for _, rec := range mustLoad(t, s, id) {
+			if _, ok := rec.Event.(itemRemoved); ok && rec.Sequence < 13 {
+				t.Errorf("reaped event %d was tiered", rec.Sequence)
+			}
+		}
+	}
+}
+
+// A tiering run while a read fetches segments moves events out of the
+// database after it was read, or into segments the read doesn't know of.
+// Either way each event is read once.
+func TestTieringDuringRead(t *testing.T) {
+	reads := map[string]func(s *fileStore) ([]RecordedEvent, error){
+		"ReadAll": func(s *fileStore) ([]RecordedEvent, error) { return s.ReadAll(1, 0) },
+		"ReplayFrom": func(s *fileStore) ([]RecordedEvent, error) {
+			var recs []RecordedEvent
+			err := s.ReplayFrom(1, func(rec RecordedEvent, _ bool) error {
+				recs = append(recs, rec)
+				return nil
+			})
+			return recs, err
+		},
+	}
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			segments := &memSegments{}
+			s, _ := tieredStore(t, segments)
+			want, err := read(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.TierBefore(context.Background(), 7); err != nil {
+				t.Fatal(err)
+			}
+			var tiered bool
+			segments.onGet = func(string) {
+				if !tiered {
+					tiered = true
+					if _, err := s.TierBefore(context.Background(), 13); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+
+			got, err := read(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sequences(got), sequences(want)) {
+				t.Errorf("read %v while tiering, want %v", sequences(got), sequences(want))
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package evoke
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func createTruncationTable(db *sql.DB) error {
+	if _, err := db.Exec(`
+		create table if not exists stream_truncations (
+			tenant_id      text not null,
+			aggregate_id   text not null,
+			before_version integer not null,
+			truncated_at   integer not null,
+			primary key (tenant_id, aggregate_id)
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create stream_truncations table: %w", err)
+	}
+	return nil
+}
+
+// TruncateStreamBefore logically removes the events of a stream before
+// version: they stay in the database but are hidden from every read and
+// replay, and the stream carries on numbering versions from where it was.
+// Truncating a stream again only ever removes more of it.
+//
+// Aggregates are hydrated from what remains, so truncate a stream only once
+// it has a snapshot at version-1 or later, as taken WithSnapshots. Truncating
+// a stream without events fails with ErrStreamNotFound, and truncating past
+// its next version fails.
+func (s *fileStore) TruncateStreamBefore(aggregateID uuid.UUID, version int64) error {
+	return s.truncateStreamBefore("", aggregateID, version)
+}
+
+func (t *tenantStore) TruncateStreamBefore(aggregateID uuid.UUID, version int64) error {
+	return t.store.truncateStreamBefore(t.tenantID, aggregateID, version)
+}
+
+func (s *fileStore) truncateStreamBefore(tenantID string, aggregateID uuid.UUID, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkStreamWritable(s.db, tenantID, aggregateID); err != nil {
+		return err
+	}
+	var head struct {
+		Version       int64  `db:"version"`
+		AggregateType string `db:"aggregate_type"`
+	}
+	if err := s.db.Get(&head, s.streamVersionQuery(), tenantID, aggregateID.String()); err != nil {
+		return fmt.Errorf("select stream version: %w", err)
+	}
+	if head.Version == 0 {
+		return fmt.Errorf("%w: %s", ErrStreamNotFound, aggregateID)
+	}
+	if version > head.Version+1 {
+		return fmt.Errorf("stream %s is at version %d, can't truncate before %d", aggregateID, head.Version, version)
+	}
+	if version <= 1 {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		insert into stream_truncations(tenant_id, aggregate_id, before_version, truncated_at) values(?,?,?,?)
+		on conflict(tenant_id, aggregate_id) do update set
+			before_version = excluded.before_version,
+			truncated_at = excluded.truncated_at
+		where excluded.before_version > stream_truncations.before_version`,
+		tenantID, aggregateID.String(), version, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("insert into stream_truncations: %w", err)
+	}
+	return nil
+}
+
+// truncatedBefore returns the version a stream is truncated before, 0 if it
+// isn't. Callers hold s.mu.
+func (s *fileStore) truncatedBefore(tenantID string, aggregateID uuid.UUID) (int64, error) {
+	var version int64
+	err := s.db.Get(&version, `select before_version from stream_truncations where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("select from stream_truncations: %w", err)
+	}
+	return version, nil
+}
+
+// truncations returns the version every truncated stream of a tenant is
+// truncated before, the Go side of visibleStreams along with hiddenStreams.
+// Callers hold s.mu.
+func (s *fileStore) truncations(tenantID string) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		AggregateID   uuid.UUID `db:"aggregate_id"`
+		BeforeVersion int64     `db:"before_version"`
+	}
+	err := s.db.Select(&rows, `select aggregate_id, before_version from stream_truncations where tenant_id = ?`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("select from stream_truncations: %w", err)
+	}
+	truncated := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		truncated[row.AggregateID] = row.BeforeVersion
+	}
+	return truncated, nil
+}
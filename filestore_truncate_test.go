@@ -0,0 +1,132 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// versions returns the stream versions of recs
+func versions(recs []RecordedEvent) []int64 {
+	vs := make([]int64, len(recs))
+	for i, rec := range recs {
+		vs[i] = rec.Version
+	}
+	return vs
+}
+
+func TestTruncateStreamBefore(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	a, b := NewID(), NewID()
+	if err := s.Record(a, []Event{itemAdded{}, itemAdded{}, itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(b, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TruncateStreamBefore(a, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(mustLoad(t, s, a)); !slices.Equal(got, []int64{3, 4}) {
+		t.Errorf("loaded versions %v of the truncated stream, want 3 and 4", got)
+	}
+	if got := sequences(mustReadAll(t, s)); !slices.Equal(got, []int64{3, 4, 5}) {
+		t.Errorf("log has %v, want the truncated events hidden", got)
+	}
+	var replayed []int64
+	if err := s.ReplayFrom(1, func(rec RecordedEvent, replay bool) error {
+		replayed = append(replayed, rec.Sequence)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(replayed, []int64{3, 4, 5}) {
+		t.Errorf("replayed %v, want the truncated events skipped", replayed)
+	}
+
+	if err := s.RecordAtVersion(ctx, "cart", a, 4, []Event{itemAdded{}}); err != nil {
+		t.Fatalf("appending after the truncation: %v", err)
+	}
+	if err := s.TruncateStreamBefore(a, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(mustLoad(t, s, a)); !slices.Equal(got, []int64{3, 4, 5}) {
+		t.Errorf("loaded versions %v after truncating less, want the earlier truncation kept", got)
+	}
+
+	if err := s.TruncateStreamBefore(a, 6); err != nil {
+		t.Fatal(err)
+	}
+	if recs := mustLoad(t, s, a); len(recs) != 0 {
+		t.Errorf("loaded %d events of a stream truncated to its end", len(recs))
+	}
+	if err := s.RecordAtVersion(ctx, "cart", a, 5, []Event{itemAdded{}}); err != nil {
+		t.Fatalf("appending to a stream truncated to its end: %v", err)
+	}
+	if got := versions(mustLoad(t, s, a)); !slices.Equal(got, []int64{6}) {
+		t.Errorf("loaded versions %v, want numbering carried on", got)
+	}
+	if got := versions(mustLoad(t, s, b)); !slices.Equal(got, []int64{1}) {
+		t.Errorf("other stream loaded versions %v", got)
+	}
+}
+
+func TestTruncateStreamBeforeErrors(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.TruncateStreamBefore(id, 2); !errors.Is(err, ErrStreamNotFound) {
+		t.Errorf("truncating a stream without events: %v, want ErrStreamNotFound", err)
+	}
+	if err := s.Record(id, []Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TruncateStreamBefore(id, 4); err == nil {
+		t.Error("truncated a stream past its next version")
+	}
+	if err := s.DeleteStream(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TruncateStreamBefore(id, 2); !errors.Is(err, ErrStreamDeleted) {
+		t.Errorf("truncating a deleted stream: %v, want ErrStreamDeleted", err)
+	}
+}
+
+func TestTruncateStreamBeforeOfTenant(t *testing.T) {
+	s := newTestStore(t)
+	acme := s.ForTenant("acme")
+	id := NewID()
+	for _, store := range []EventStore{s, acme} {
+		if err := store.Record(id, []Event{itemAdded{}, itemAdded{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := acme.TruncateStreamBefore(id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(mustLoad(t, acme, id)); !slices.Equal(got, []int64{2}) {
+		t.Errorf("tenant loaded versions %v", got)
+	}
+	if got := versions(mustLoad(t, s, id)); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("other tenant's stream loaded versions %v, want it untruncated", got)
+	}
+}
+
+// An aggregate whose stream is truncated after a snapshot is hydrated from
+// the snapshot and what remains.
+func TestTruncateStreamAfterSnapshot(t *testing.T) {
+	s := newTestStore(t)
+	snapshots := NewMemorySnapshotStore()
+	h := NewAggregateHandler(s, newCart, WithSnapshots(snapshots, SnapshotEvery(4)))
+	id := NewID()
+	sendItems(t, h, id, 5)
+	if err := s.TruncateStreamBefore(id, 5); err != nil {
+		t.Fatal(err)
+	}
+	sendItems(t, h, id, 1)
+	if qty := lastQty(t, s, id); qty != 6 {
+		t.Errorf("raised quantity %d, want the snapshot's 4 items and the 5th applied", qty)
+	}
+}
@@ -0,0 +1,128 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// EventStoreTx appends events within a transaction of a file store, which
+// the caller can update tables of the store's database in too.
+type EventStoreTx interface {
+	// Record appends events to a stream, returning them as recorded
+	Record(aggregateID uuid.UUID, evs []Event) ([]RecordedEvent, error)
+	// RecordAtVersion appends events if the stream is at expectedVersion,
+	// categorizing it under aggregateType unless it is empty
+	RecordAtVersion(aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []Event) ([]RecordedEvent, error)
+	// Tx is the transaction, for the caller's own tables
+	Tx() *sqlx.Tx
+}
+
+// WithinTx calls fn with a transaction that appends events and updates
+// tables of the store's database together, so a synchronous projection is
+// never behind or ahead of the events it reflects. The transaction commits
+// if fn returns nil and rolls back otherwise. Events are published once it
+// has committed.
+//
+// The store is locked while fn runs: fn must only use the store through tx.
+func (s *fileStore) WithinTx(ctx context.Context, fn func(tx EventStoreTx) error) error {
+	return s.withinTx(ctx, "", fn)
+}
+
+func (t *tenantStore) WithinTx(ctx context.Context, fn func(tx EventStoreTx) error) error {
+	return t.store.withinTx(ctx, t.tenantID, fn)
+}
+
+func (s *fileStore) withinTx(ctx context.Context, tenantID string, fn func(tx EventStoreTx) error) (err error) {
+	ctx, end := s.tracer.Start(ctx, "evoke.append")
+	defer func() { end(err) }()
+
+	md := Metadata{}
+	s.tracer.Inject(ctx, md)
+	correlate(ctx, md)
+
+	claim := claimIn(ctx, s)
+	recs, err := s.runTx(tenantID, md, fn, claim)
+	if err != nil {
+		return err
+	}
+	if len(recs) == 0 {
+		return nil
+	}
+	claim.markSaved()
+	s.logger.Debug("evoke: recorded events", "tenant", tenantID, "events", len(recs),
+		"first_sequence", recs[0].Sequence, "last_sequence", recs[len(recs)-1].Sequence)
+	NoteRecorded(ctx, recs)
+
+	return s.publish(tenantID, recs)
+}
+
+// runTx runs fn in a transaction, saving claim with the events it recorded,
+// and returns them once committed
+func (s *fileStore) runTx(tenantID string, md Metadata, fn func(tx EventStoreTx) error, claim *idempotencyClaim) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// statements can't be prepared for the cache once the tx holds the
+	// connection
+	if err := s.prepare(s.streamVersionQuery()); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	stx := &storeTx{store: s, tx: tx, tenantID: tenantID, md: md}
+	if err := fn(stx); err != nil {
+		return nil, err
+	}
+	if err := s.saveClaim(tx, claim, stx.recs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return stx.recs, nil
+}
+
+type storeTx struct {
+	store    *fileStore
+	tx       *sqlx.Tx
+	tenantID string
+	md       Metadata
+	recs     []RecordedEvent
+}
+
+func (t *storeTx) Tx() *sqlx.Tx {
+	return t.tx
+}
+
+func (t *storeTx) Record(aggregateID uuid.UUID, evs []Event) ([]RecordedEvent, error) {
+	return t.RecordAtVersion("", aggregateID, anyVersion, evs)
+}
+
+func (t *storeTx) RecordAtVersion(aggregateType string, aggregateID uuid.UUID, expectedVersion int64, evs []Event) (recs []RecordedEvent, err error) {
+	start := time.Now()
+	defer func() {
+		t.store.inst.EventsAppended(len(evs), time.Since(start), err)
+	}()
+
+	if len(evs) == 0 {
+		return nil, errors.New("no events to append")
+	}
+
+	recs, err = t.store.insertEvents(t.tx, t.tenantID, aggregateType, aggregateID, expectedVersion, evs, t.md, nil)
+	if err != nil {
+		return nil, err
+	}
+	t.recs = append(t.recs, recs...)
+	return recs, nil
+}
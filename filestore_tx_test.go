@@ -0,0 +1,103 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// itemCount returns the count of the cart_items table kept within
+// transactions
+func itemCount(t *testing.T, s *fileStore, id uuid.UUID) int {
+	t.Helper()
+	var n int
+	if err := s.db.Get(&n, `select coalesce(sum(qty), 0) from cart_items where cart_id = ?`, id.String()); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWithinTx(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.db.Exec(`create table cart_items (cart_id text, qty integer)`); err != nil {
+		t.Fatal(err)
+	}
+	var pub recordingPublisher
+	s.RegisterPublisher(&pub)
+	id := NewID()
+	addItems := func(qty int, fail error) error {
+		published := len(pub.published())
+		return s.WithinTx(context.Background(), func(tx EventStoreTx) error {
+			if _, err := tx.RecordAtVersion("cart", id, anyVersion, []Event{itemAdded{SKU: "a", Qty: qty}}); err != nil {
+				return err
+			}
+			if len(pub.published()) != published {
+				t.Error("published before the transaction committed")
+			}
+			if _, err := tx.Tx().Exec(`insert into cart_items values (?, ?)`, id.String(), qty); err != nil {
+				return err
+			}
+			return fail
+		})
+	}
+
+	if err := addItems(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	errRejected := errors.New("rejected")
+	if err := addItems(3, errRejected); !errors.Is(err, errRejected) {
+		t.Fatalf("WithinTx returned %v, want fn's error", err)
+	}
+	recs := mustLoad(t, s, id)
+	if len(recs) != 1 || recs[0].AggregateType != "cart" || itemCount(t, s, id) != 2 {
+		t.Errorf("loaded %+v with %d items, want only the committed transaction's changes", recs, itemCount(t, s, id))
+	}
+	if got := pub.published(); len(got) != 1 || got[0].Sequence != 1 {
+		t.Errorf("published %+v, want the committed event", got)
+	}
+}
+
+func TestWithinTxConflict(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.WithinTx(context.Background(), func(tx EventStoreTx) error {
+		if _, err := tx.Record(NewID(), []Event{itemAdded{}}); err != nil {
+			return err
+		}
+		_, err := tx.RecordAtVersion("", id, 0, []Event{itemAdded{}})
+		return err
+	})
+	if !errors.Is(err, ErrConcurrencyConflict) {
+		t.Fatalf("WithinTx returned %v, want ErrConcurrencyConflict", err)
+	}
+	if recs, err := s.ReadAll(1, 0); err != nil || len(recs) != 1 {
+		t.Errorf("read %d events, %v, want the conflicting transaction rolled back", len(recs), err)
+	}
+	if err := s.WithinTx(context.Background(), func(EventStoreTx) error { return nil }); err != nil {
+		t.Errorf("an empty transaction: %v", err)
+	}
+}
+
+func TestWithinTxOfTenant(t *testing.T) {
+	s := newTestStore(t)
+	acme := s.ForTenant("acme")
+	id := NewID()
+	err := acme.WithinTx(context.Background(), func(tx EventStoreTx) error {
+		_, err := tx.Record(id, []Event{itemAdded{}})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mustLoad(t, acme, id)); n != 1 {
+		t.Errorf("tenant loaded %d events", n)
+	}
+	if n := len(mustLoad(t, s, id)); n != 0 {
+		t.Errorf("store loaded %d events of the tenant's", n)
+	}
+}
@@ -0,0 +1,83 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithWatchInterval sets how often Watch looks for new events. The default
+// is 200ms.
+func WithWatchInterval(d time.Duration) FileStoreOption {
+	return func(s *fileStore) {
+		s.watchInterval = d
+	}
+}
+
+// events Watch reads at a time
+const watchBatch = 500
+
+// Watch calls handler for every event with a sequence >= fromSeq, in order,
+// until ctx is done or handler returns an error. It polls the database, so
+// it also sees events appended by other processes writing to the same file,
+// which publishers registered with this store never hear about. Events
+// already in the store when Watch catches up are passed with replay true.
+func (s *fileStore) Watch(ctx context.Context, fromSeq int64, handler RecordedEventHandlerFunc) error {
+	next := fromSeq
+	deliver := func(replay bool) error {
+		for {
+			recs, err := s.ReadAll(next, watchBatch)
+			if err != nil {
+				return err
+			}
+			for _, rec := range recs {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := handler(rec, replay); err != nil {
+					return fmt.Errorf("callback error: %w", err)
+				}
+				next = rec.Sequence + 1
+			}
+			if len(recs) < watchBatch {
+				return nil
+			}
+		}
+	}
+
+	if err := deliver(true); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		last, err := s.lastSequence()
+		if err != nil {
+			return err
+		}
+		if last < next {
+			continue
+		}
+		if err := deliver(false); err != nil {
+			return err
+		}
+	}
+}
+
+// lastSequence is a cheap check for new events, reading only the end of the
+// events table's primary key
+func (s *fileStore) lastSequence() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seq int64
+	if err := s.db.Get(&seq, `select coalesce(max(sequence), 0) from `+s.table); err != nil {
+		return 0, fmt.Errorf("select from events: %w", err)
+	}
+	return seq, nil
+}
@@ -0,0 +1,78 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Watch sees events another store appends to the same file, after those
+// already there.
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	open := func() *fileStore {
+		s, err := NewFileStore(path, WithWatchInterval(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Shutdown(context.Background()) })
+		RegisterEvent(s, &itemAdded{})
+		return s
+	}
+	watcher, writer := open(), open()
+	id := NewID()
+	if err := writer.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	type watched struct {
+		seq    int64
+		replay bool
+	}
+	got := make(chan watched)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Watch(ctx, 2, func(rec RecordedEvent, replay bool) error {
+			got <- watched{rec.Sequence, replay}
+			return nil
+		})
+	}()
+
+	if w := <-got; w != (watched{2, true}) {
+		t.Errorf("watched %+v first, want sequence 2 as a replay", w)
+	}
+	if err := writer.Record(id, []Event{itemAdded{SKU: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case w := <-got:
+		if w != (watched{3, false}) {
+			t.Errorf("watched %+v, want the new event live", w)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the other store's event was not watched")
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watch returned %v, want context.Canceled", err)
+	}
+}
+
+func TestWatchHandlerError(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Record(NewID(), []Event{itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	calls := 0
+	err := s.Watch(context.Background(), 1, func(RecordedEvent, bool) error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Errorf("Watch returned %v after %d calls, want the handler's error after one", err, calls)
+	}
+}
@@ -0,0 +1,104 @@
+package evoke
+
+import (
+	"slices"
+	"strings"
+)
+
+// EventFilter narrows the events a replay or publisher receives. Stores push
+// EventTypes and AggregateTypes down into their queries where they can, so
+// unwanted rows are never read; Match, if set, is checked against each
+// remaining event. When several filters are given an event has to pass all
+// of them.
+type EventFilter struct {
+	EventTypes     []string
+	AggregateTypes []string
+	Match          func(RecordedEvent) bool
+}
+
+// OnlyEvents filters on the types of the given events, whatever names they
+// are stored under.
+func OnlyEvents(evs ...Event) EventFilter {
+	types := make([]string, len(evs))
+	for i, e := range evs {
+		types[i] = TypeName(e)
+	}
+	return EventFilter{EventTypes: types}
+}
+
+// InCategory filters on the type of the aggregate that recorded the events,
+// e.g. every Order stream.
+func InCategory(aggregateTypes ...string) EventFilter {
+	return EventFilter{AggregateTypes: aggregateTypes}
+}
+
+// Matches reports whether rec passes the filter.
+func (f EventFilter) Matches(rec RecordedEvent) bool {
+	if len(f.EventTypes) > 0 && !slices.Contains(f.EventTypes, rec.EventType) && !slices.Contains(f.EventTypes, TypeName(rec.Event)) {
+		return false
+	}
+	if len(f.AggregateTypes) > 0 && !slices.Contains(f.AggregateTypes, rec.AggregateType) {
+		return false
+	}
+	if f.Match != nil && !f.Match(rec) {
+		return false
+	}
+	return true
+}
+
+// MatchesAll reports whether rec passes every filter.
+func MatchesAll(filters []EventFilter, rec RecordedEvent) bool {
+	for _, f := range filters {
+		if !f.Matches(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+type filteredPublisher struct {
+	publisher RecordedEventPublisher
+	filters   []EventFilter
+}
+
+// FilterPublisher wraps publisher so it is only given events passing the
+// filters.
+func FilterPublisher(publisher RecordedEventPublisher, filters ...EventFilter) RecordedEventPublisher {
+	if len(filters) == 0 {
+		return publisher
+	}
+	return &filteredPublisher{publisher: publisher, filters: filters}
+}
+
+func (p *filteredPublisher) Publish(rec RecordedEvent, replay bool) error {
+	if !MatchesAll(p.filters, rec) {
+		return nil
+	}
+	return p.publisher.Publish(rec, replay)
+}
+
+// filterSQL returns a where clause fragment and its arguments restricting
+// event_type and aggregate_type to the types allowed by every filter.
+// storedNames expands an event type to the names it may be stored under.
+func filterSQL(filters []EventFilter, storedNames func(string) []string) (string, []any) {
+	var clause string
+	var args []any
+	in := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		clause += ` and ` + column + ` in (?` + strings.Repeat(",?", len(values)-1) + `)`
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	for _, f := range filters {
+		var eventTypes []string
+		for _, t := range f.EventTypes {
+			eventTypes = append(eventTypes, storedNames(t)...)
+		}
+		in("event_type", eventTypes)
+		in("aggregate_type", f.AggregateTypes)
+	}
+	return clause, args
+}
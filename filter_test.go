@@ -0,0 +1,138 @@
+package evoke
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEventFilterMatches(t *testing.T) {
+	rec := RecordedEvent{Sequence: 3, EventType: "itemAdded", Event: itemAdded{SKU: "a"}}
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   bool
+	}{
+		{"empty", EventFilter{}, true},
+		{"event type", EventFilter{EventTypes: []string{"itemRemoved", "itemAdded"}}, true},
+		{"other event type", EventFilter{EventTypes: []string{"itemRemoved"}}, false},
+		{"only events", OnlyEvents(&itemAdded{}), true},
+		{"only other events", OnlyEvents(itemRemoved{}), false},
+		{"match", EventFilter{Match: func(rec RecordedEvent) bool { return rec.Sequence > 2 }}, true},
+		{"no match", EventFilter{Match: func(rec RecordedEvent) bool { return rec.Sequence > 3 }}, false},
+		{"event type and no match", EventFilter{EventTypes: []string{"itemAdded"}, Match: func(RecordedEvent) bool { return false }}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Matches(rec); got != tt.want {
+			t.Errorf("%s: Matches %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if MatchesAll([]EventFilter{OnlyEvents(itemAdded{}), OnlyEvents(itemRemoved{})}, rec) {
+		t.Error("MatchesAll passed an event one of the filters rejects")
+	}
+}
+
+// recordMixed records itemAdded, itemRemoved, itemAdded to one stream
+func recordMixed(t *testing.T, s EventStore) {
+	t.Helper()
+	if err := s.Record(NewID(), []Event{itemAdded{SKU: "a"}, itemRemoved{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayFromFiltered(t *testing.T) {
+	secondOn := EventFilter{Match: func(rec RecordedEvent) bool { return rec.Sequence >= 2 }}
+	tests := []struct {
+		name    string
+		filters []EventFilter
+		want    []int64
+	}{
+		{"unfiltered", nil, []int64{1, 2, 3}},
+		{"by type", []EventFilter{OnlyEvents(itemAdded{})}, []int64{1, 3}},
+		{"by type and match", []EventFilter{OnlyEvents(itemAdded{}), secondOn}, []int64{3}},
+		{"by clashing types", []EventFilter{OnlyEvents(itemAdded{}), OnlyEvents(itemRemoved{})}, nil},
+	}
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			recordMixed(t, s)
+			for _, tt := range tests {
+				var got []int64
+				err := s.ReplayFrom(1, func(rec RecordedEvent, replay bool) error {
+					got = append(got, rec.Sequence)
+					return nil
+				}, tt.filters...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s: replayed %v, want %v", tt.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterPublisherFiltered(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			var added, all recordingPublisher
+			s.RegisterPublisher(&added, OnlyEvents(itemAdded{}))
+			s.RegisterPublisher(&all)
+			recordMixed(t, s)
+
+			if got := sequences(added.published()); !slices.Equal(got, []int64{1, 3}) {
+				t.Errorf("filtered publisher was given %v, want [1 3]", got)
+			}
+			if got := sequences(all.published()); !slices.Equal(got, []int64{1, 2, 3}) {
+				t.Errorf("unfiltered publisher was given %v, want [1 2 3]", got)
+			}
+		})
+	}
+}
+
+func TestInCategory(t *testing.T) {
+	stores := map[string]EventStore{"file": newTestStore(t), "tenant": newTestStore(t).ForTenant("acme")}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			bus := NewCommandBus()
+			bus.RegisterHandler(addItem{}, NewAggregateHandler(s, newCart))
+			carts := NewID()
+			if err := bus.Send(addItem{ID: carts, SKU: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			other := NewID()
+			if err := s.(AggregateRecorder).RecordAs(context.Background(), "wishlist", other, []Event{itemAdded{SKU: "b"}}); err != nil {
+				t.Fatal(err)
+			}
+			// later appends keep the stream's category
+			for _, id := range []uuid.UUID{carts, other} {
+				if err := s.Record(id, []Event{itemRemoved{SKU: "a"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, rec := range mustReadAll(t, s) {
+				want := "wishlist"
+				if rec.AggregateID == carts {
+					want = "cart"
+				}
+				if rec.AggregateType != want {
+					t.Errorf("event %d recorded as of a %q, want %q", rec.Sequence, rec.AggregateType, want)
+				}
+			}
+			var got []int64
+			err := s.ReplayFrom(1, func(rec RecordedEvent, replay bool) error {
+				got = append(got, rec.Sequence)
+				return nil
+			}, InCategory("cart"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, []int64{1, 3}) {
+				t.Errorf("replayed %v of the cart category, want [1 3]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package evoke
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// SequenceGapError reports the events a GapDetector found missing.
+type SequenceGapError struct {
+	// From and To are the first and last missing sequences
+	From, To int64
+}
+
+func (e *SequenceGapError) Error() string {
+	return fmt.Sprintf("sequence gap: events %d to %d missing", e.From, e.To)
+}
+
+func (e *SequenceGapError) Unwrap() error {
+	return ErrSequenceGap
+}
+
+// GapDetector wraps a subscriber, checking it is handed every event in
+// sequence order. Events it was already handed are dropped. When events
+// are missing before the one published, the detector reads them from the
+// store, if it was given one, and hands them over first; events the store
+// doesn't have any more, such as those of other tenants, are skipped.
+// Without a store the event is handed over and publishing fails with a
+// *SequenceGapError, to be alerted on.
+//
+// Register a detector unfiltered, wrapping a filtered publisher, so that
+// events filtered out don't look missing. Replayed events come straight
+// from the store, so they are only checked for duplicates.
+type GapDetector struct {
+	publisher RecordedEventPublisher
+	store     EventStore
+	mu        sync.Mutex
+	last      int64
+	logger    Logger
+}
+
+// NewGapDetector returns a detector handing events to publisher. store,
+// which may be nil, is read for missing events.
+func NewGapDetector(publisher RecordedEventPublisher, store EventStore) *GapDetector {
+	return &GapDetector{publisher: publisher, store: store, logger: slog.Default()}
+}
+
+// SetLastSequence sets the sequence of the last event the subscriber was
+// handed, such as a checkpoint, so events after it are checked from the
+// first one published. Until set, the first event published is taken as
+// the start.
+func (g *GapDetector) SetLastSequence(seq int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.last = seq
+}
+
+// LastSequence returns the sequence of the last event handed over.
+func (g *GapDetector) LastSequence() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.last
+}
+
+func (g *GapDetector) SetLogger(logger Logger) {
+	g.logger = logger
+}
+
+func (g *GapDetector) Publish(rec RecordedEvent, replay bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.last > 0 && rec.Sequence <= g.last {
+		g.logger.Debug("evoke: dropping event already handed over", "sequence", rec.Sequence, "last_sequence", g.last)
+		return nil
+	}
+	var gap *SequenceGapError
+	if g.last > 0 && rec.Sequence > g.last+1 && !replay {
+		gap = &SequenceGapError{From: g.last + 1, To: rec.Sequence - 1}
+		g.logger.Warn("evoke: sequence gap", "from", gap.From, "to", gap.To)
+		if g.store != nil {
+			if err := g.fill(rec.Sequence, replay); err != nil {
+				return fmt.Errorf("fill %w: %w", gap, err)
+			}
+			gap = nil
+		}
+	}
+
+	if err := g.publisher.Publish(rec, replay); err != nil {
+		return err
+	}
+	g.last = rec.Sequence
+	if gap != nil {
+		return gap
+	}
+	return nil
+}
+
+// fill hands over the events the store has between the last one handed
+// over and before. Callers hold g.mu.
+func (g *GapDetector) fill(before int64, replay bool) error {
+	from := g.last + 1
+	for from < before {
+		recs, err := g.store.ReadAll(from, deliveryBatch)
+		if err != nil {
+			return fmt.Errorf("ReadAll: %w", err)
+		}
+		for _, missing := range recs {
+			if missing.Sequence >= before {
+				return nil
+			}
+			if err := g.publisher.Publish(missing, replay); err != nil {
+				return err
+			}
+			g.last = missing.Sequence
+		}
+		if len(recs) < deliveryBatch {
+			return nil
+		}
+		from = recs[len(recs)-1].Sequence + 1
+	}
+	return nil
+}
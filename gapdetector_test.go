@@ -0,0 +1,120 @@
+package evoke
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// recordFive records five events and returns them
+func recordFive(t *testing.T, s EventStore) []RecordedEvent {
+	t.Helper()
+	if err := s.Record(NewID(), []Event{itemAdded{}, itemAdded{}, itemAdded{}, itemAdded{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.ReadAll(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recs
+}
+
+func TestGapDetectorFillsGaps(t *testing.T) {
+	s := newTestStore(t)
+	recs := recordFive(t, s)
+	var pub recordingPublisher
+	g := NewGapDetector(&pub, s)
+	g.SetLogger(&recordingLogger{})
+	for _, i := range []int{0, 3, 1, 4} {
+		if err := g.Publish(recs[i], false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := sequences(pub.published()); !slices.Equal(got, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("handed over %v, want the missing events filled in and the late one dropped", got)
+	}
+	if g.LastSequence() != 5 {
+		t.Errorf("last sequence %d", g.LastSequence())
+	}
+}
+
+func TestGapDetectorReportsGaps(t *testing.T) {
+	recs := recordFive(t, newTestStore(t))
+	var pub recordingPublisher
+	var logger recordingLogger
+	g := NewGapDetector(&pub, nil)
+	g.SetLogger(&logger)
+	g.SetLastSequence(1)
+
+	err := g.Publish(recs[3], false)
+	var gap *SequenceGapError
+	if !errors.As(err, &gap) || !errors.Is(err, ErrSequenceGap) || gap.From != 2 || gap.To != 3 {
+		t.Fatalf("Publish returned %v, want a gap of events 2 to 3", err)
+	}
+	if got := sequences(pub.published()); !slices.Equal(got, []int64{4}) {
+		t.Errorf("handed over %v, want the event after the gap anyway", got)
+	}
+	if !slices.Contains(logger.logged(), "warn: evoke: sequence gap") {
+		t.Errorf("logged %q", logger.logged())
+	}
+
+	// replays are only checked for duplicates
+	if err := g.Publish(recs[3], true); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Publish(recs[4], true); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(pub.published()); !slices.Equal(got, []int64{4, 5}) {
+		t.Errorf("handed over %v after the replay", got)
+	}
+}
+
+// Events of other tenants are missing from a tenant's store, and skipped.
+func TestGapDetectorOfTenant(t *testing.T) {
+	s := newTestStore(t)
+	acme := s.ForTenant("acme")
+	for _, store := range []EventStore{acme, s, acme} {
+		if err := store.Record(NewID(), []Event{itemAdded{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recs, err := acme.ReadAll(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pub recordingPublisher
+	g := NewGapDetector(&pub, acme)
+	g.SetLogger(&recordingLogger{})
+	for _, rec := range recs {
+		if err := g.Publish(rec, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := sequences(pub.published()); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("handed over %v, want the tenant's events", got)
+	}
+}
+
+func TestGapDetectorPublisherFails(t *testing.T) {
+	s := newTestStore(t)
+	recs := recordFive(t, s)
+	p := &flakyPublisher{failOn: 2, failures: 1}
+	g := NewGapDetector(p, s)
+	g.SetLogger(&recordingLogger{})
+	if err := g.Publish(recs[0], false); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Publish(recs[2], false); !errors.Is(err, ErrSequenceGap) {
+		t.Fatalf("Publish returned %v, want the failed fill", err)
+	}
+	if g.LastSequence() != 1 {
+		t.Errorf("last sequence %d after the failed fill, want 1", g.LastSequence())
+	}
+	if err := g.Publish(recs[2], false); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(p.published()); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("handed over %v", got)
+	}
+}
@@ -0,0 +1,145 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type itemAdded struct {
+	SKU string
+	Qty int
+}
+
+type itemRemoved struct {
+	SKU string
+}
+
+// newTestStore opens a file store in a temporary directory with the test
+// events registered, closing it when the test ends
+func newTestStore(t testing.TB, opts ...FileStoreOption) *fileStore {
+	t.Helper()
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "events.db"), opts...)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	RegisterEvent(s, &itemAdded{})
+	RegisterEvent(s, &itemRemoved{})
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+// eventStores returns a fresh store of each in-memory and file kind, and a
+// tenant's view of a file store, for tests every EventStore should pass
+func eventStores(t testing.TB) map[string]EventStore {
+	return map[string]EventStore{
+		"file":   newTestStore(t),
+		"simple": NewSimpleStore(nil),
+		"tenant": newTestStore(t).ForTenant("acme"),
+	}
+}
+
+// mustLoad loads a stream, failing the test on error
+func mustLoad(t testing.TB, store EventStore, id uuid.UUID) []RecordedEvent {
+	t.Helper()
+	recs, err := store.LoadStream(id)
+	if err != nil {
+		t.Fatalf("LoadStream(%s): %v", id, err)
+	}
+	return recs
+}
+
+// sequences returns the sequences of recs, in order
+func sequences(recs []RecordedEvent) []int64 {
+	seqs := make([]int64, len(recs))
+	for i, rec := range recs {
+		seqs[i] = rec.Sequence
+	}
+	return seqs
+}
+
+type cartState struct {
+	Items int
+}
+
+// cart counts the items in it, raising each itemAdded with the count it
+// makes
+type cart struct {
+	AggregateBase[cartState]
+}
+
+// addItem is an IdempotentCommand when given a Key
+type addItem struct {
+	ID  uuid.UUID
+	SKU string
+	Key string
+}
+
+func (c addItem) AggregateID() uuid.UUID { return c.ID }
+func (c addItem) IdempotencyKey() string { return c.Key }
+
+func newCart(uuid.UUID) Aggregate {
+	return &cart{NewAggregateBase(func(s *cartState, e Event) error {
+		switch e.(type) {
+		case itemAdded:
+			s.Items++
+		case itemRemoved:
+			s.Items--
+		}
+		return nil
+	})}
+}
+
+func (c *cart) HandleCommand(cmd Command) ([]Event, error) {
+	add := cmd.(addItem)
+	if err := c.Raise(itemAdded{SKU: add.SKU, Qty: c.State.Items + 1}); err != nil {
+		return nil, err
+	}
+	return c.TakeChanges(), nil
+}
+
+// recordingLogger keeps the messages logged to it, as "level: msg"
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) log(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprintf("%s: %s", level, msg))
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.log("debug", msg) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.log("info", msg) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.log("warn", msg) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.log("error", msg) }
+
+func (l *recordingLogger) logged() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+// recordingPublisher keeps the events published to it
+type recordingPublisher struct {
+	mu   sync.Mutex
+	recs []RecordedEvent
+}
+
+func (p *recordingPublisher) Publish(rec RecordedEvent, replay bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recs = append(p.recs, rec)
+	return nil
+}
+
+func (p *recordingPublisher) published() []RecordedEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]RecordedEvent(nil), p.recs...)
+}
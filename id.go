@@ -1,8 +1,167 @@
 package evoke
 
-import "github.com/google/uuid"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
 
-// Make a uuid from a string
+	"github.com/google/uuid"
+)
+
+// Make a uuid from a string. Equal strings make equal uuids whatever they
+// identify, so "123" names the same aggregate for orders and customers; use
+// a Namespace per aggregate type to keep them apart.
 func UUID(str string) uuid.UUID {
-	return uuid.NewSHA1(uuid.Nil, []byte(str))
+	return UUIDIn(uuid.Nil, str)
+}
+
+// UUIDIn makes a uuid from a string within namespace: the same string makes
+// different uuids in different namespaces.
+func UUIDIn(namespace uuid.UUID, str string) uuid.UUID {
+	return uuid.NewSHA1(namespace, []byte(str))
+}
+
+// namespaceRoot is the namespace the uuids of named namespaces are made in,
+// so they never equal a uuid made by UUID
+var namespaceRoot = UUID("evoke:namespace")
+
+// Namespace is the collision domain of deterministic aggregate IDs, usually
+// one per aggregate type.
+type Namespace struct {
+	name string
+	id   uuid.UUID
+}
+
+// NewNamespace returns the namespace called name. Namespaces with the same
+// name make the same IDs.
+func NewNamespace(name string) Namespace {
+	return Namespace{name: name, id: UUIDIn(namespaceRoot, name)}
+}
+
+// NamespaceOf returns the namespace of the aggregate type T, named after it
+// like its streams, so IDs of different aggregate types can't collide:
+//
+//	var orders = evoke.NamespaceOf[Order]()
+//	id := orders.UUID("123")
+//
+// Renaming T changes its IDs.
+func NamespaceOf[T any]() Namespace {
+	return NewNamespace(TypeName(new(T)))
+}
+
+// UUID makes the uuid of str in the namespace.
+func (n Namespace) UUID(str string) uuid.UUID {
+	return UUIDIn(n.id, str)
+}
+
+// ID returns the namespace's own uuid, for use with UUIDIn.
+func (n Namespace) ID() uuid.UUID {
+	return n.id
+}
+
+func (n Namespace) String() string {
+	return n.name
+}
+
+// NewID makes a random, time-ordered uuid (version 7) for a new aggregate.
+// IDs made later sort after earlier ones, which keeps index inserts local
+// and makes logs easier to follow.
+func NewID() uuid.UUID {
+	return uuid.Must(uuid.NewV7())
+}
+
+// IDTime returns when an ID made by NewID was made, to the millisecond, and
+// false for IDs of other versions.
+func IDTime(id uuid.UUID) (time.Time, bool) {
+	if id.Version() != 7 {
+		return time.Time{}, false
+	}
+	ms := int64(id[0])<<40 | int64(id[1])<<32 | int64(id[2])<<24 | int64(id[3])<<16 | int64(id[4])<<8 | int64(id[5])
+	return time.UnixMilli(ms), true
+}
+
+// ID is the ID of an aggregate of type T, so the compiler tells an order's
+// ID from a customer's where raw uuids would mix them up:
+//
+//	type ShipOrder struct {
+//		Order evoke.ID[Order]
+//	}
+//
+//	func (c ShipOrder) AggregateID() uuid.UUID { return c.Order.UUID }
+//
+// It encodes as the uuid it wraps, in JSON and in databases.
+type ID[T any] struct {
+	uuid.UUID
+}
+
+// NewIDOf makes a new ID for an aggregate of type T, like NewID.
+func NewIDOf[T any]() ID[T] {
+	return ID[T]{NewID()}
+}
+
+// IDOf returns id as the ID of an aggregate of type T.
+func IDOf[T any](id uuid.UUID) ID[T] {
+	return ID[T]{id}
+}
+
+// ParseIDOf parses s as the ID of an aggregate of type T.
+func ParseIDOf[T any](s string) (ID[T], error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return ID[T]{}, err
+	}
+	return ID[T]{id}, nil
+}
+
+// Stream returns the ID of the aggregate's stream.
+func (id ID[T]) Stream() StreamID {
+	return StreamID{Type: TypeName(new(T)), ID: id.UUID}
+}
+
+// StreamID names a stream by the type of its aggregate, as streams are
+// categorized, and the aggregate's ID. Its text form is the type and ID
+// joined by a dash, such as "Order-0190d6c2-...".
+type StreamID struct {
+	Type string
+	ID   uuid.UUID
+}
+
+// ParseStreamID parses the text form of a StreamID.
+func ParseStreamID(s string) (StreamID, error) {
+	typ, id, ok := strings.Cut(s, "-")
+	if !ok || typ == "" {
+		return StreamID{}, fmt.Errorf("parse stream id %q: no aggregate type", s)
+	}
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return StreamID{}, fmt.Errorf("parse stream id %q: %w", s, err)
+	}
+	return StreamID{Type: typ, ID: u}, nil
+}
+
+func (s StreamID) String() string {
+	return s.Type + "-" + s.ID.String()
+}
+
+func (s StreamID) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *StreamID) UnmarshalText(text []byte) error {
+	parsed, err := ParseStreamID(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// RecordStream records events to stream, categorized by its type if the
+// store supports it.
+func RecordStream(ctx context.Context, store EventStore, stream StreamID, evs []Event) error {
+	if ar, ok := store.(AggregateRecorder); ok {
+		return ar.RecordAs(ctx, stream.Type, stream.ID, evs)
+	}
+	return store.Record(stream.ID, evs)
 }
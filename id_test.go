@@ -0,0 +1,121 @@
+package evoke
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewIDIsTimeOrdered(t *testing.T) {
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = NewID().String()
+		if i%10 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if !slices.IsSorted(ids) {
+		t.Errorf("IDs made in order don't sort in order: %q", ids)
+	}
+}
+
+func TestIDTime(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	made, ok := IDTime(NewID())
+	if !ok || made.Before(before) || made.After(time.Now()) {
+		t.Errorf("IDTime %v, %v, want the time the ID was made", made, ok)
+	}
+	if _, ok := IDTime(uuid.New()); ok {
+		t.Error("IDTime of a version 4 uuid reported a time")
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	orders, customers := NewNamespace("orders"), NewNamespace("customers")
+	if orders.UUID("123") != NewNamespace("orders").UUID("123") {
+		t.Error("equal namespaces made different IDs")
+	}
+	if orders.UUID("123") == customers.UUID("123") || orders.UUID("123") == UUID("123") {
+		t.Error("IDs of the same string collided across namespaces")
+	}
+	if orders.UUID("123") != UUIDIn(orders.ID(), "123") {
+		t.Error("Namespace.UUID differs from UUIDIn of its ID")
+	}
+	if orders.String() != "orders" {
+		t.Errorf("namespace named %q", orders)
+	}
+	if UUID("123") != UUIDIn(uuid.Nil, "123") {
+		t.Error("UUID isn't made in the nil namespace")
+	}
+	if NamespaceOf[cart]() != NewNamespace("cart") {
+		t.Errorf("NamespaceOf[cart] is %q, want the aggregate's type name", NamespaceOf[cart]())
+	}
+}
+
+func TestTypedIDs(t *testing.T) {
+	id := NewIDOf[cart]()
+	data, err := json.Marshal(struct{ Cart ID[cart] }{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Cart":"` + id.String() + `"}`; string(data) != want {
+		t.Errorf("marshaled %s, want %s", data, want)
+	}
+	var decoded struct{ Cart ID[cart] }
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Cart != id {
+		t.Errorf("unmarshaled %v, %v, want %v", decoded.Cart, err, id)
+	}
+
+	if parsed, err := ParseIDOf[cart](id.String()); err != nil || parsed != id || parsed != IDOf[cart](id.UUID) {
+		t.Errorf("parsed %v, %v, want %v", parsed, err, id)
+	}
+	if _, err := ParseIDOf[cart]("not an id"); err == nil {
+		t.Error("parsed a malformed ID")
+	}
+	if s := id.Stream(); s != (StreamID{Type: "cart", ID: id.UUID}) {
+		t.Errorf("stream %+v, want one of type cart", s)
+	}
+}
+
+func TestStreamID(t *testing.T) {
+	id := NewID()
+	s := StreamID{Type: "Order", ID: id}
+	if s.String() != "Order-"+id.String() {
+		t.Errorf("String %q", s)
+	}
+	data, err := json.Marshal(map[StreamID]int{s: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[StreamID]int
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded[s] != 1 {
+		t.Errorf("unmarshaled %s to %v, %v", data, decoded, err)
+	}
+	for _, bad := range []string{"", id.String()[:8], "-" + id.String(), "Order-nope"} {
+		if _, err := ParseStreamID(bad); err == nil {
+			t.Errorf("parsed %q", bad)
+		}
+	}
+}
+
+func TestRecordStream(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			stream := IDOf[cart](NewID()).Stream()
+			if err := RecordStream(context.Background(), s, stream, []Event{itemAdded{SKU: "a"}}); err != nil {
+				t.Fatal(err)
+			}
+			recs := mustLoad(t, s, stream.ID)
+			if len(recs) != 1 {
+				t.Fatalf("loaded %d events", len(recs))
+			}
+			if _, categorized := s.(AggregateRecorder); categorized && recs[0].AggregateType != "cart" {
+				t.Errorf("recorded to a stream of type %q, want cart", recs[0].AggregateType)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// IdempotentCommand is implemented by commands that may be retried, such as
+// those coming from an HTTP request with an Idempotency-Key header. Commands
+// returning an empty key are handled normally.
+type IdempotentCommand interface {
+	Command
+	IdempotencyKey() string
+}
+
+// IdempotencyStore remembers which commands have already been handled, and
+// what they recorded.
+type IdempotencyStore interface {
+	// IdempotentResult returns the result the command with key was handled
+	// with, if it has been
+	IdempotentResult(key string) (CommandResult, bool, error)
+	// SaveIdempotentResult remembers the result of the command with key,
+	// unless one is already saved
+	SaveIdempotentResult(key string, res CommandResult) error
+}
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	results map[string]CommandResult
+}
+
+func NewMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{results: make(map[string]CommandResult)}
+}
+
+func (s *memoryIdempotencyStore) IdempotentResult(key string) (CommandResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.results[key]
+	return res, ok, nil
+}
+
+func (s *memoryIdempotencyStore) SaveIdempotentResult(key string, res CommandResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.results[key]; !ok {
+		s.results[key] = res
+	}
+	return nil
+}
+
+// migrateIdempotencyTables keeps the outcome of each idempotent command
+// with its key, adding it to stores that only kept keys. Their commands'
+// results are empty.
+func migrateIdempotencyTables(db *sql.DB) error {
+	for _, col := range []struct{ name, def string }{
+		{"claim", "text not null default ''"},
+		{"aggregate_id", "text not null default ''"},
+		{"version", "integer not null default 0"},
+		{"position", "integer not null default 0"},
+	} {
+		ok, err := hasColumn(db, "idempotency_keys", col.name)
+		if err != nil {
+			return fmt.Errorf("failed to inspect idempotency_keys table: %w", err)
+		}
+		if !ok {
+			if _, err := db.Exec(`alter table idempotency_keys add column ` + col.name + ` ` + col.def); err != nil {
+				return fmt.Errorf("failed to add %s column: %w", col.name, err)
+			}
+		}
+	}
+	if _, err := db.Exec(`
+		create table if not exists idempotency_sequences (
+			key      text not null,
+			sequence integer not null,
+			primary key (key, sequence)
+		);
+	`); err != nil {
+		return fmt.Errorf("failed to create idempotency_sequences table: %w", err)
+	}
+	return nil
+}
+
+// IdempotentResult returns the result of a command handled with key,
+// reading its events back from the log. Events no longer in the database,
+// such as tiered or reaped ones, are left out.
+func (s *fileStore) IdempotentResult(key string) (CommandResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var row struct {
+		AggregateID string `db:"aggregate_id"`
+		Version     int64  `db:"version"`
+		Position    int64  `db:"position"`
+	}
+	err := s.db.Get(&row, `select aggregate_id, version, position from idempotency_keys where key = ?`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CommandResult{}, false, nil
+	}
+	if err != nil {
+		return CommandResult{}, false, fmt.Errorf("select from idempotency_keys: %w", err)
+	}
+	res := CommandResult{Version: row.Version, Position: row.Position}
+	if row.AggregateID != "" {
+		if res.AggregateID, err = uuid.Parse(row.AggregateID); err != nil {
+			return CommandResult{}, false, fmt.Errorf("idempotency key %s: %w", key, err)
+		}
+	}
+	res.Events, err = s.selectRecords(`select * from `+s.eventsSource+` where sequence in (
+		select sequence from idempotency_sequences where key = ?) order by sequence asc`, key)
+	if err != nil {
+		return CommandResult{}, false, err
+	}
+	if len(res.Events) == 0 {
+		res.Events = nil
+	}
+	return res, true, nil
+}
+
+// SaveIdempotentResult saves the result of a command whose events weren't
+// recorded in this store, for which the key is saved along with them.
+func (s *fileStore) SaveIdempotentResult(key string, res CommandResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	inserted, err := tx.Exec(`insert or ignore into idempotency_keys(key, processed_at, aggregate_id, version, position) values(?,?,?,?,?)`,
+		key, time.Now().Unix(), res.AggregateID.String(), res.Version, res.Position)
+	if err != nil {
+		return fmt.Errorf("insert into idempotency_keys: %w", err)
+	}
+	if n, err := inserted.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	if err := insertIdempotentSequences(tx, key, res.Events); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+func insertIdempotentSequences(tx *sqlx.Tx, key string, recs []RecordedEvent) error {
+	for _, rec := range recs {
+		if rec.Sequence == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`insert or ignore into idempotency_sequences(key, sequence) values(?,?)`, key, rec.Sequence); err != nil {
+			return fmt.Errorf("insert into idempotency_sequences: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetIdempotencyStore enables deduplication of IdempotentCommands. A command
+// whose key has already been handled successfully is not dispatched again:
+// Send returns nil and SendR the result it was handled with. Failed
+// commands record nothing, so their keys are not saved and a retry is
+// handled afresh.
+//
+// When the store is the file store the command records its events in, the
+// key and result are saved in the transaction of each append, so a crash
+// can't leave events recorded without their key. With other stores they are
+// saved once the command has been handled.
+func (b *simpleCommandBus) SetIdempotencyStore(store IdempotencyStore) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.idempotency = store
+}
+
+// sendOnce dispatches cmd unless its key has been seen, holding a per-key
+// lock so concurrent retries of the same command don't race each other
+func (b *simpleCommandBus) sendOnce(ctx context.Context, store IdempotencyStore, key string, h CommandHandler, cmd Command) error {
+	key = TypeName(cmd) + ":" + key
+
+	b.keysMu.Lock()
+	if b.keyLocks == nil {
+		b.keyLocks = make(map[string]*keyLock)
+	}
+	kl, ok := b.keyLocks[key]
+	if !ok {
+		kl = &keyLock{}
+		b.keyLocks[key] = kl
+	}
+	kl.refs++
+	b.keysMu.Unlock()
+
+	defer func() {
+		b.keysMu.Lock()
+		kl.refs--
+		if kl.refs == 0 {
+			delete(b.keyLocks, key)
+		}
+		b.keysMu.Unlock()
+	}()
+
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	handled, err := noteIdempotentResult(ctx, store, key)
+	if err != nil || handled {
+		return err
+	}
+
+	claim := &idempotencyClaim{store: store, key: key, id: NewID().String(), aggregateID: cmd.AggregateID()}
+	ctx, c := collect(context.WithValue(ctx, claimKey{}, claim))
+	err = handleCommand(ctx, h, cmd)
+	if errors.Is(err, ErrDuplicateCommand) {
+		// another process handled the command first
+		if handled, lerr := noteIdempotentResult(ctx, store, key); lerr != nil || handled {
+			return lerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if claim.isSaved() {
+		return nil
+	}
+
+	c.mu.Lock()
+	res := resultOf(cmd.AggregateID(), c.recs)
+	c.mu.Unlock()
+	if err := store.SaveIdempotentResult(key, res); err != nil {
+		return fmt.Errorf("SaveIdempotentResult: %w", err)
+	}
+	return nil
+}
+
+// noteIdempotentResult hands the saved result of a command to the SendR
+// call in ctx, reporting whether there was one
+func noteIdempotentResult(ctx context.Context, store IdempotencyStore, key string) (bool, error) {
+	res, ok, err := store.IdempotentResult(key)
+	if err != nil {
+		return false, fmt.Errorf("IdempotentResult: %w", err)
+	}
+	if ok {
+		notePrior(ctx, res)
+	}
+	return ok, nil
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+type claimKey struct{}
+
+// idempotencyClaim is the key of an idempotent command being handled, for
+// a file store keeping it to save along with the command's events
+type idempotencyClaim struct {
+	store IdempotencyStore
+	key   string
+	// id tells the appends of this handling of the command apart from those
+	// of another process handling it too
+	id          string
+	aggregateID uuid.UUID
+
+	mu    sync.Mutex
+	saved bool
+}
+
+// claimIn returns the claim of the command handled in ctx, if store keeps it
+func claimIn(ctx context.Context, store IdempotencyStore) *idempotencyClaim {
+	c, _ := ctx.Value(claimKey{}).(*idempotencyClaim)
+	if c == nil || c.store != store {
+		return nil
+	}
+	return c
+}
+
+// markSaved notes that an append saving the claim has committed
+func (c *idempotencyClaim) markSaved() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.saved = true
+	c.mu.Unlock()
+}
+
+func (c *idempotencyClaim) isSaved() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saved
+}
+
+// saveClaim saves the key of the command being appended for within tx,
+// with what the append recorded, failing with ErrDuplicateCommand if
+// another handling of the command saved it first. Callers hold s.mu.
+func (s *fileStore) saveClaim(tx *sqlx.Tx, claim *idempotencyClaim, recs []RecordedEvent) error {
+	if claim == nil || len(recs) == 0 {
+		return nil
+	}
+	res := resultOf(claim.aggregateID, recs)
+	upserted, err := tx.Exec(`
+		insert into idempotency_keys(key, processed_at, claim, aggregate_id, version, position) values(?,?,?,?,?,?)
+		on conflict(key) do update set
+			version = max(version, excluded.version),
+			position = max(position, excluded.position)
+		where idempotency_keys.claim = excluded.claim`,
+		claim.key, time.Now().Unix(), claim.id, claim.aggregateID.String(), res.Version, res.Position)
+	if err != nil {
+		return fmt.Errorf("insert into idempotency_keys: %w", err)
+	}
+	n, err := upserted.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("insert into idempotency_keys: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateCommand, claim.key)
+	}
+	return insertIdempotentSequences(tx, claim.key, recs)
+}
@@ -0,0 +1,215 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// cartBus returns a bus handling addItem with a cart over store,
+// deduplicating commands with idempotency
+func cartBus(store EventStore, idempotency IdempotencyStore) *simpleCommandBus {
+	bus := NewCommandBus()
+	bus.RegisterHandler(addItem{}, NewAggregateHandler(store, newCart))
+	bus.SetIdempotencyStore(idempotency)
+	return bus
+}
+
+// idempotencyStores returns an event store and the idempotency stores to
+// try it with: one in memory, and the event store itself, which saves keys
+// with the events
+func idempotencyStores(t *testing.T) map[string]func() (EventStore, IdempotencyStore) {
+	return map[string]func() (EventStore, IdempotencyStore){
+		"memory": func() (EventStore, IdempotencyStore) { return newTestStore(t), NewMemoryIdempotencyStore() },
+		"file": func() (EventStore, IdempotencyStore) {
+			s := newTestStore(t)
+			return s, s
+		},
+	}
+}
+
+func TestIdempotentCommands(t *testing.T) {
+	for name, stores := range idempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store, idempotency := stores()
+			bus := cartBus(store, idempotency)
+			id := NewID()
+
+			first, err := bus.SendR(addItem{ID: id, SKU: "a", Key: "k1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			retried, err := bus.SendR(addItem{ID: id, SKU: "a", Key: "k1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mustLoad(t, store, id)) != 1 {
+				t.Fatalf("a retried command recorded again")
+			}
+			if retried.Version != first.Version || retried.Position != first.Position || len(retried.Events) != 1 {
+				t.Errorf("retry returned %+v, want the first result %+v", retried, first)
+			}
+			if retried.Events[0].Sequence != first.Events[0].Sequence {
+				t.Errorf("retry returned event %d, want %d", retried.Events[0].Sequence, first.Events[0].Sequence)
+			}
+
+			for _, cmd := range []addItem{
+				{ID: id, SKU: "a", Key: "k2"},
+				{ID: id, SKU: "a"},
+				{ID: id, SKU: "a"},
+			} {
+				if err := bus.Send(cmd); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n := len(mustLoad(t, store, id)); n != 4 {
+				t.Errorf("stream has %d events, want 4: commands with other or no keys are handled", n)
+			}
+		})
+	}
+}
+
+// failOnce fails the first command it is given, handling the rest with h
+type failOnce struct {
+	h      *AggregateHandler
+	failed bool
+}
+
+func (f *failOnce) Handle(cmd Command) error {
+	return f.HandleContext(context.Background(), cmd)
+}
+
+func (f *failOnce) HandleContext(ctx context.Context, cmd Command) error {
+	if !f.failed {
+		f.failed = true
+		return errors.New("handler failed")
+	}
+	return f.h.HandleContext(ctx, cmd)
+}
+
+// A command that fails saves no key, so its retry is handled.
+func TestIdempotentCommandRetriedAfterFailure(t *testing.T) {
+	for name, stores := range idempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store, idempotency := stores()
+			bus := NewCommandBus()
+			bus.RegisterHandler(addItem{}, &failOnce{h: NewAggregateHandler(store, newCart)})
+			bus.SetIdempotencyStore(idempotency)
+			id := NewID()
+
+			if err := bus.Send(addItem{ID: id, SKU: "a", Key: "k"}); err == nil {
+				t.Fatal("the failing command returned no error")
+			}
+			if err := bus.Send(addItem{ID: id, SKU: "a", Key: "k"}); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(mustLoad(t, store, id)); n != 1 {
+				t.Errorf("stream has %d events, want the retry's 1", n)
+			}
+		})
+	}
+}
+
+func TestIdempotentCommandsConcurrent(t *testing.T) {
+	for name, stores := range idempotencyStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store, idempotency := stores()
+			bus := cartBus(store, idempotency)
+			id := NewID()
+
+			var wg sync.WaitGroup
+			results := make([]CommandResult, 8)
+			for i := range results {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := bus.SendR(addItem{ID: id, SKU: "a", Key: "k"})
+					if err != nil {
+						t.Error(err)
+					}
+					results[i] = res
+				}()
+			}
+			wg.Wait()
+
+			if n := len(mustLoad(t, store, id)); n != 1 {
+				t.Errorf("stream has %d events, want 1", n)
+			}
+			for _, res := range results {
+				if res.Version != 1 {
+					t.Errorf("a concurrent duplicate returned version %d, want 1", res.Version)
+				}
+			}
+		})
+	}
+}
+
+// The file store keeps the keys it saved with events across restarts.
+func TestIdempotencyKeysPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	open := func() *fileStore {
+		s, err := NewFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterEvent(s, &itemAdded{})
+		return s
+	}
+	id := NewID()
+
+	s := open()
+	if err := cartBus(s, s).Send(addItem{ID: id, SKU: "a", Key: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s = open()
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	res, ok, err := s.IdempotentResult("addItem:k")
+	if err != nil || !ok {
+		t.Fatalf("IdempotentResult after reopening: %v, %v", ok, err)
+	}
+	if res.AggregateID != id || res.Version != 1 || len(res.Events) != 1 {
+		t.Errorf("saved result %+v", res)
+	}
+	if err := cartBus(s, s).Send(addItem{ID: id, SKU: "a", Key: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mustLoad(t, s, id)); n != 1 {
+		t.Errorf("stream has %d events after retrying in a new process, want 1", n)
+	}
+}
+
+// Buses not sharing key locks, as in separate processes, still record a
+// command once when the file store keeps its keys.
+func TestIdempotentCommandsAcrossBuses(t *testing.T) {
+	s := newTestStore(t)
+	id := NewID()
+	var wg sync.WaitGroup
+	results := make([]CommandResult, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := cartBus(s, s).SendR(addItem{ID: id, SKU: "a", Key: "k"})
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	if n := len(mustLoad(t, s, id)); n != 1 {
+		t.Errorf("stream has %d events, want 1", n)
+	}
+	for _, res := range results {
+		if res.Version != 1 || res.Position != 1 {
+			t.Errorf("a duplicate returned %+v, want the result at version 1", res)
+		}
+	}
+}
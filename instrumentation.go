@@ -0,0 +1,55 @@
+package evoke
+
+import "time"
+
+// Instrumentation receives measurements from stores and buses so they can be
+// exported to a metrics system; the evokemetrics package implements it for
+// Prometheus. Methods are called inline on hot paths and must not block.
+type Instrumentation interface {
+	// EventsAppended is called after every append to a store with the
+	// number of events it held and how long it took.
+	EventsAppended(n int, elapsed time.Duration, err error)
+	// HandlerDone is called after a command, event or query handler
+	// returns. kind is "command", "event" or "query" and name the type name
+	// of what was handled.
+	HandlerDone(kind, name string, elapsed time.Duration, err error)
+	// EventReplayed is called for each event handed to a replay.
+	EventReplayed(seq int64)
+}
+
+type nopInstrumentation struct{}
+
+func (nopInstrumentation) EventsAppended(int, time.Duration, error)         {}
+func (nopInstrumentation) HandlerDone(string, string, time.Duration, error) {}
+func (nopInstrumentation) EventReplayed(int64)                              {}
+
+// WithInstrumentation reports appends and replays of the store to inst.
+func WithInstrumentation(inst Instrumentation) FileStoreOption {
+	return func(s *fileStore) {
+		s.inst = inst
+	}
+}
+
+// SetInstrumentation reports the duration and outcome of every command
+// handled through the bus to inst.
+func (b *simpleCommandBus) SetInstrumentation(inst Instrumentation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inst = inst
+}
+
+// SetInstrumentation reports the duration and outcome of every query
+// answered through the bus to inst.
+func (b *simpleQueryBus) SetInstrumentation(inst Instrumentation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inst = inst
+}
+
+// SetInstrumentation reports the duration and outcome of every event handler
+// called by the bus to inst.
+func (b *simpleEventBus) SetInstrumentation(inst Instrumentation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inst = inst
+}
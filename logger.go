@@ -0,0 +1,41 @@
+package evoke
+
+import "log/slog"
+
+// Logger receives the diagnostic output of stores, buses and the scheduler
+// as a message with key/value pairs. *slog.Logger satisfies it, and
+// slog.Default() is used unless another logger is set.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// WithLogger sends the store's diagnostic output to logger.
+func WithLogger(logger Logger) FileStoreOption {
+	return func(s *fileStore) {
+		s.logger = logger
+	}
+}
+
+// SetLogger sends the bus's diagnostic output to logger.
+func (b *simpleEventBus) SetLogger(logger Logger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.logger = logger
+}
+
+// SetLogger sends the handler's diagnostic output to logger.
+func (h *AggregateHandler) SetLogger(logger Logger) {
+	h.logger = logger
+}
+
+// SetLogger sends the scheduler's diagnostic output to logger.
+func (s *Scheduler) SetLogger(logger Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+var _ Logger = (*slog.Logger)(nil)
@@ -0,0 +1,84 @@
+package evoke
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreLogger(t *testing.T) {
+	var logger recordingLogger
+	s := newTestStore(t, WithLogger(&logger))
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	mustLoad(t, s, id)
+
+	got := logger.logged()
+	for _, want := range []string{"debug: evoke: recorded events", "debug: evoke: loaded stream"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("logged %q, want %q", got, want)
+		}
+	}
+}
+
+func TestEventBusLogger(t *testing.T) {
+	var logger recordingLogger
+	bus := NewEventBus()
+	bus.SetLogger(&logger)
+	if err := bus.Publish(RecordedEvent{Sequence: 1, Event: itemAdded{}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := logger.logged(); !slices.Equal(got, []string{"warn: evoke: no subscriptions for event"}) {
+		t.Errorf("logged %q, want a warning about the unsubscribed event", got)
+	}
+}
+
+func TestSchedulerLogger(t *testing.T) {
+	var logger recordingLogger
+	s := newTestScheduler(t, filepath.Join(t.TempDir(), "scheduler.db"), &recordingSender{err: errors.New("bus down")})
+	s.SetLogger(&logger)
+	if err := s.SendAfter(addItem{ID: NewID(), SKU: "a"}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < schedulerMaxAttempts; i++ {
+		if _, err := s.db.Exec(`update scheduled_commands set due_at = 0`); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RunDue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := logger.logged()
+	if len(got) != schedulerMaxAttempts {
+		t.Fatalf("logged %q, want one entry per attempt", got)
+	}
+	for _, entry := range got[:len(got)-1] {
+		if entry != "warn: evoke: scheduled command will be retried" {
+			t.Errorf("logged %q for a failure to be retried", entry)
+		}
+	}
+	if last := got[len(got)-1]; last != "error: evoke: scheduled command failed" {
+		t.Errorf("logged %q for the last failure", last)
+	}
+}
+
+// A *slog.Logger is a Logger, getting the key/value pairs of each message.
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestStore(t, WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, `msg="evoke: recorded events"`) || !strings.Contains(out, "aggregate_id="+id.String()) {
+		t.Errorf("slog wrote %q", out)
+	}
+}
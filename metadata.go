@@ -0,0 +1,63 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Metadata is string data stored alongside an event rather than in it.
+type Metadata map[string]string
+
+// EventWithMeta is an event to record along with metadata of its own, such
+// as the request, user or source it came from.
+type EventWithMeta struct {
+	Event    Event
+	Metadata Metadata
+}
+
+// splitMetadata separates events from their metadata
+func splitMetadata(evs []EventWithMeta) ([]Event, []Metadata) {
+	events := make([]Event, len(evs))
+	md := make([]Metadata, len(evs))
+	for i, e := range evs {
+		events[i] = e.Event
+		md[i] = e.Metadata
+	}
+	return events, md
+}
+
+// mergeMetadata returns the keys of both, those of over winning
+func mergeMetadata(base, over Metadata) Metadata {
+	out := make(Metadata, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
+
+// encodeMetadata serializes metadata for storage; empty metadata is stored
+// as an empty string
+func encodeMetadata(md Metadata) (string, error) {
+	if len(md) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(md)
+	if err != nil {
+		return "", fmt.Errorf("Marshal metadata: %w", err)
+	}
+	return string(b), nil
+}
+
+func decodeMetadata(s string) (Metadata, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var md Metadata
+	if err := json.Unmarshal([]byte(s), &md); err != nil {
+		return nil, fmt.Errorf("Unmarshal metadata: %w", err)
+	}
+	return md, nil
+}
@@ -0,0 +1,45 @@
+package evoke
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestRecordWithMetadata(t *testing.T) {
+	for name, s := range eventStores(t) {
+		r, ok := s.(MetadataRecorder)
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			var pub recordingPublisher
+			s.RegisterPublisher(&pub)
+			id := NewID()
+			err := r.RecordWithMetadata(id, []EventWithMeta{
+				{Event: itemAdded{SKU: "a"}, Metadata: Metadata{"request_id": "r1", "user_id": "u1"}},
+				{Event: itemAdded{SKU: "b"}},
+				{Event: itemRemoved{SKU: "a"}, Metadata: Metadata{"request_id": "r2"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []Metadata{{"request_id": "r1", "user_id": "u1"}, nil, {"request_id": "r2"}}
+			recs := mustLoad(t, s, id)
+			published := pub.published()
+			if len(recs) != 3 || len(published) != 3 {
+				t.Fatalf("loaded %d and published %d events, want 3", len(recs), len(published))
+			}
+			for i := range want {
+				// the store's own metadata is recorded along with the event's
+				delete(recs[i].Metadata, "correlation_id")
+				delete(published[i].Metadata, "correlation_id")
+				if !maps.Equal(recs[i].Metadata, want[i]) || !maps.Equal(published[i].Metadata, want[i]) {
+					t.Errorf("event %d loaded with %v and published with %v, want %v", i+1, recs[i].Metadata, published[i].Metadata, want[i])
+				}
+			}
+			if recs[1].Event != (itemAdded{SKU: "b"}) || recs[2].Version != 3 {
+				t.Errorf("loaded %+v", recs)
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Projection is a read model a ProjectionManager keeps up to date.
+type Projection interface {
+	// Handle applies an event; replay is true while rebuilding
+	Handle(rec RecordedEvent, replay bool) error
+	// Reset empties the read model before a rebuild
+	Reset() error
+}
+
+// VersionedProjection is a projection whose read model changes shape
+// between releases. The manager keeps the version the read model was built
+// with next to its checkpoint, and rebuilds it when catching up finds the
+// projection's Version is higher.
+type VersionedProjection interface {
+	Projection
+	Version() int64
+}
+
+// versionCheckpoint is the checkpoint a projection's version is saved under
+func versionCheckpoint(name string) string {
+	return name + "#version"
+}
+
+type managedProjection struct {
+	// mu keeps a rebuild and a catch up of the projection from interleaving
+	mu         sync.Mutex
+	projection Projection
+	filters    []EventFilter
+	// versionChecked is set once the built version has been compared with
+	// the projection's
+	versionChecked bool
+	// retired is set once a promoted standby replaced the projection
+	retired bool
+	// rebuilding is set while RebuildBlueGreen builds a standby of the
+	// projection, guarded by the manager's mu
+	rebuilding bool
+}
+
+// ProjectionManager keeps named projections up to date with a store, each
+// from its own checkpoint, and rebuilds them on demand.
+type ProjectionManager struct {
+	store       EventStore
+	checkpoints CheckpointStore
+	mu          sync.Mutex
+	projections map[string]*managedProjection
+	interval    time.Duration
+	logger      Logger
+	wake        chan struct{}
+}
+
+// NewProjectionManager returns a manager of projections of store, saving
+// their progress in checkpoints under their names.
+func NewProjectionManager(store EventStore, checkpoints CheckpointStore) *ProjectionManager {
+	m := &ProjectionManager{
+		store:       store,
+		checkpoints: checkpoints,
+		projections: make(map[string]*managedProjection),
+		interval:    time.Second,
+		logger:      slog.Default(),
+		wake:        make(chan struct{}, 1),
+	}
+	store.RegisterPublisher(deliveryWaker{m.wake})
+	return m
+}
+
+// SetInterval sets how often Run polls the store for events recorded by
+// other processes. The default is one second.
+func (m *ProjectionManager) SetInterval(d time.Duration) {
+	m.interval = d
+}
+
+func (m *ProjectionManager) SetLogger(logger Logger) {
+	m.logger = logger
+}
+
+// Register adds a projection of the events passing the filters. A
+// projection registered again under the same name replaces the first.
+func (m *ProjectionManager) Register(name string, p Projection, filters ...EventFilter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.projections[name] = &managedProjection{projection: p, filters: filters}
+}
+
+// Names returns the names of the registered projections, sorted.
+func (m *ProjectionManager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.projections))
+	for name := range m.projections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checkpoint returns the sequence of the last event applied to a
+// projection.
+func (m *ProjectionManager) Checkpoint(name string) (int64, error) {
+	if _, err := m.lookup(name); err != nil {
+		return 0, err
+	}
+	return m.checkpoints.LoadCheckpoint(name)
+}
+
+func (m *ProjectionManager) lookup(name string) (*managedProjection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.projections[name]
+	if !ok {
+		return nil, fmt.Errorf("no projection %q", name)
+	}
+	return p, nil
+}
+
+// acquire returns the projection under name locked, skipping over one
+// retired while waiting for the lock
+func (m *ProjectionManager) acquire(name string) (*managedProjection, error) {
+	for {
+		p, err := m.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		if !p.retired {
+			return p, nil
+		}
+		p.mu.Unlock()
+	}
+}
+
+// CatchUp applies the events recorded since the projection's checkpoint.
+func (m *ProjectionManager) CatchUp(name string) error {
+	p, err := m.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer p.mu.Unlock()
+	if !p.versionChecked {
+		if err := m.checkVersion(name, p); err != nil {
+			return err
+		}
+	}
+	return m.catchUp(name, p, false)
+}
+
+// checkVersion rebuilds a versioned projection built by an older version.
+// Callers hold p.mu.
+func (m *ProjectionManager) checkVersion(name string, p *managedProjection) error {
+	vp, ok := p.projection.(VersionedProjection)
+	if !ok {
+		p.versionChecked = true
+		return nil
+	}
+	built, err := m.checkpoints.LoadCheckpoint(versionCheckpoint(name))
+	if err != nil {
+		return fmt.Errorf("load version: %w", err)
+	}
+	switch want := vp.Version(); {
+	case built < want:
+		m.logger.Info("evoke: projection version changed, rebuilding", "projection", name, "from", built, "to", want)
+		if err := m.rebuild(name, p); err != nil {
+			return err
+		}
+	case built > want:
+		m.logger.Warn("evoke: projection was built by a newer version", "projection", name, "built", built, "version", want)
+	}
+	p.versionChecked = true
+	return nil
+}
+
+// CatchUpAll catches up every projection, returning the first error after
+// trying them all.
+func (m *ProjectionManager) CatchUpAll() error {
+	var first error
+	for _, name := range m.Names() {
+		if err := m.CatchUp(name); err != nil && first == nil {
+			first = fmt.Errorf("projection %s: %w", name, err)
+		}
+	}
+	return first
+}
+
+// Rebuild resets a projection's read model and checkpoint and replays the
+// whole log into it, with replay true, recording the version it was built
+// with for a VersionedProjection. Catching up the projection waits
+// while it rebuilds, then resumes live from where the rebuild got to.
+func (m *ProjectionManager) Rebuild(name string) error {
+	p, err := m.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer p.mu.Unlock()
+	return m.rebuild(name, p)
+}
+
+// rebuild rebuilds a projection, then records the version it was built
+// with. Callers hold p.mu.
+func (m *ProjectionManager) rebuild(name string, p *managedProjection) error {
+	start := time.Now()
+	if err := p.projection.Reset(); err != nil {
+		return fmt.Errorf("reset projection %s: %w", name, err)
+	}
+	if err := m.checkpoints.SaveCheckpoint(name, 0); err != nil {
+		return fmt.Errorf("reset checkpoint: %w", err)
+	}
+	if err := m.catchUp(name, p, true); err != nil {
+		return err
+	}
+	if vp, ok := p.projection.(VersionedProjection); ok {
+		if err := m.checkpoints.SaveCheckpoint(versionCheckpoint(name), vp.Version()); err != nil {
+			return fmt.Errorf("save version: %w", err)
+		}
+	}
+	m.logger.Info("evoke: rebuilt projection", "projection", name, "duration", time.Since(start))
+	return nil
+}
+
+// catchUp applies the events after the checkpoint in batches, saving the
+// checkpoint after each. Callers hold p.mu.
+func (m *ProjectionManager) catchUp(name string, p *managedProjection, replay bool) error {
+	seq, err := m.checkpoints.LoadCheckpoint(name)
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
+	}
+	for {
+		batch, err := m.store.ReadAll(seq+1, deliveryBatch)
+		if err != nil {
+			return fmt.Errorf("read store: %w", err)
+		}
+		applied := seq
+		for _, rec := range batch {
+			if MatchesAll(p.filters, rec) {
+				if err = p.projection.Handle(rec, replay); err != nil {
+					err = fmt.Errorf("handle event %d: %w", rec.Sequence, err)
+					break
+				}
+			}
+			applied = rec.Sequence
+		}
+		if applied > seq {
+			if err := m.checkpoints.SaveCheckpoint(name, applied); err != nil {
+				return fmt.Errorf("save checkpoint: %w", err)
+			}
+			seq = applied
+		}
+		if err != nil || len(batch) < deliveryBatch {
+			return err
+		}
+	}
+}
+
+// Run keeps every projection up to date until ctx is done. Failures are
+// logged and retried at the next interval.
+func (m *ProjectionManager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		if err := m.CatchUpAll(); err != nil {
+			m.logger.Warn("evoke: projection failed, will retry", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// BlueGreenProjection is a projection that can build a second copy of its
+// read model, for example in tables with another suffix, while the first
+// keeps serving reads and following the log.
+type BlueGreenProjection interface {
+	Projection
+	// Standby returns a projection building an empty copy of the read
+	// model. The standby is itself a BlueGreenProjection, since once
+	// promoted it is the one rebuilt next time.
+	Standby() (BlueGreenProjection, error)
+	// Promote makes the caught up standby the read model served, in one
+	// step such as renaming tables in a transaction
+	Promote(standby Projection) error
+}
+
+// standbyCheckpoint is the checkpoint a standby copy is built under
+func standbyCheckpoint(name string) string {
+	return name + "#standby"
+}
+
+// RebuildBlueGreen rebuilds a BlueGreenProjection without downtime: a
+// standby copy replays the log while the live projection keeps catching up,
+// then with the live one paused the standby handles the last few events,
+// is promoted and takes over as the projection under name. If ctx is done
+// or the rebuild fails before promotion, the live projection is left as it
+// was.
+//
+// The checkpoint moves to the standby's right after promotion, so a crash
+// in between can apply again, to the promoted read model, the events
+// recorded since the live projection's last checkpoint.
+//
+// Rebuilding a projection while it is already being rebuilt fails with
+// ErrRebuildInProgress.
+func (m *ProjectionManager) RebuildBlueGreen(ctx context.Context, name string) error {
+	p, err := m.lookup(name)
+	if err != nil {
+		return err
+	}
+	bg, ok := p.projection.(BlueGreenProjection)
+	if !ok {
+		return fmt.Errorf("projection %s can't be rebuilt blue/green", name)
+	}
+	m.mu.Lock()
+	if p.rebuilding {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrRebuildInProgress, name)
+	}
+	p.rebuilding = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		p.rebuilding = false
+		m.mu.Unlock()
+	}()
+
+	standby, err := bg.Standby()
+	if err != nil {
+		return fmt.Errorf("create standby of %s: %w", name, err)
+	}
+	next := &managedProjection{projection: standby, filters: p.filters, versionChecked: true}
+	start := time.Now()
+	if err := m.checkpoints.SaveCheckpoint(standbyCheckpoint(name), 0); err != nil {
+		return fmt.Errorf("reset standby checkpoint: %w", err)
+	}
+	// the bulk of the replay, without blocking the live projection
+	if err := m.catchUp(standbyCheckpoint(name), next, true); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	live, err := m.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer live.mu.Unlock()
+	if live != p {
+		return fmt.Errorf("projection %s was replaced during the rebuild", name)
+	}
+	if err := m.catchUp(standbyCheckpoint(name), next, true); err != nil {
+		return err
+	}
+	seq, err := m.checkpoints.LoadCheckpoint(standbyCheckpoint(name))
+	if err != nil {
+		return fmt.Errorf("load standby checkpoint: %w", err)
+	}
+	if err := bg.Promote(standby); err != nil {
+		return fmt.Errorf("promote standby of %s: %w", name, err)
+	}
+	if err := m.checkpoints.SaveCheckpoint(name, seq); err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	if vp, ok := standby.(VersionedProjection); ok {
+		if err := m.checkpoints.SaveCheckpoint(versionCheckpoint(name), vp.Version()); err != nil {
+			return fmt.Errorf("save version: %w", err)
+		}
+	}
+	// the promoted standby handles events from now on; catch ups waiting
+	// for p.mu move on to it
+	m.mu.Lock()
+	m.projections[name] = next
+	m.mu.Unlock()
+	p.retired = true
+	m.logger.Info("evoke: rebuilt projection blue/green", "projection", name, "duration", time.Since(start))
+	return nil
+}
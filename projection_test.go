@@ -0,0 +1,315 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countTables stands in for a read model kept in numbered copies of its
+// tables, counting the events handled into each
+type countTables struct {
+	mu     sync.Mutex
+	counts map[int]int
+	served int
+	copies int
+	// if set, standbys signal started and wait for gate before handling
+	// their first event
+	gate    chan struct{}
+	started chan struct{}
+	once    sync.Once
+}
+
+type countProjection struct {
+	tables *countTables
+	copy   int
+}
+
+func newCountTables() (*countTables, *countProjection) {
+	tables := &countTables{counts: make(map[int]int)}
+	return tables, &countProjection{tables: tables}
+}
+
+func (p *countProjection) Handle(rec RecordedEvent, replay bool) error {
+	t := p.tables
+	if p.copy > 0 && t.gate != nil {
+		t.once.Do(func() { close(t.started) })
+		<-t.gate
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counts[p.copy]++
+	return nil
+}
+
+func (p *countProjection) Reset() error {
+	p.tables.mu.Lock()
+	defer p.tables.mu.Unlock()
+	p.tables.counts[p.copy] = 0
+	return nil
+}
+
+func (p *countProjection) Standby() (BlueGreenProjection, error) {
+	p.tables.mu.Lock()
+	defer p.tables.mu.Unlock()
+	p.tables.copies++
+	return &countProjection{tables: p.tables, copy: p.tables.copies}, nil
+}
+
+func (p *countProjection) Promote(standby Projection) error {
+	p.tables.mu.Lock()
+	defer p.tables.mu.Unlock()
+	p.tables.served = standby.(*countProjection).copy
+	return nil
+}
+
+func recordItems(t *testing.T, s EventStore, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := s.Record(NewID(), []Event{itemAdded{SKU: "a", Qty: i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// The promoted standby is itself rebuilt blue/green the next time, and
+// handles the events recorded after it took over.
+func TestRebuildBlueGreenTwice(t *testing.T) {
+	s := newTestStore(t)
+	m := NewProjectionManager(s, NewMemoryCheckpointStore())
+	tables, p := newCountTables()
+	m.Register("items", p)
+	recordItems(t, s, 3)
+	if err := m.CatchUp("items"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := m.RebuildBlueGreen(context.Background(), "items"); err != nil {
+			t.Fatalf("rebuild %d: %v", i, err)
+		}
+		if tables.served != i || tables.counts[i] != 3 {
+			t.Fatalf("after rebuild %d copy %d is served with counts %v, want copy %d with 3", i, tables.served, tables.counts, i)
+		}
+	}
+
+	recordItems(t, s, 1)
+	if err := m.CatchUp("items"); err != nil {
+		t.Fatal(err)
+	}
+	if tables.counts[2] != 4 || tables.counts[1] != 3 {
+		t.Errorf("counts %v after catching up, want the promoted copy 2 to have all 4", tables.counts)
+	}
+}
+
+func TestRebuildBlueGreenConcurrent(t *testing.T) {
+	s := newTestStore(t)
+	m := NewProjectionManager(s, NewMemoryCheckpointStore())
+	tables, p := newCountTables()
+	tables.gate, tables.started = make(chan struct{}), make(chan struct{})
+	m.Register("items", p)
+	recordItems(t, s, 3)
+
+	done := make(chan error)
+	go func() { done <- m.RebuildBlueGreen(context.Background(), "items") }()
+	<-tables.started
+	if err := m.RebuildBlueGreen(context.Background(), "items"); !errors.Is(err, ErrRebuildInProgress) {
+		t.Errorf("second rebuild during the first: %v, want ErrRebuildInProgress", err)
+	}
+	close(tables.gate)
+	if err := <-done; err != nil {
+		t.Fatalf("first rebuild: %v", err)
+	}
+	if tables.copies != 1 {
+		t.Errorf("%d standbys created, want 1", tables.copies)
+	}
+
+	// the rebuild is over, so the projection can be rebuilt again
+	if err := m.RebuildBlueGreen(context.Background(), "items"); err != nil {
+		t.Fatalf("rebuild after the first finished: %v", err)
+	}
+	if tables.served != 2 || tables.counts[2] != 3 {
+		t.Errorf("copy %d is served with counts %v, want copy 2 with 3", tables.served, tables.counts)
+	}
+}
+
+// listProjection keeps the sequences it handled, failing on failOn
+type listProjection struct {
+	mu      sync.Mutex
+	seqs    []int64
+	replays int
+	resets  int
+	failOn  int64
+}
+
+func (p *listProjection) Handle(rec RecordedEvent, replay bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rec.Sequence == p.failOn {
+		return errors.New("boom")
+	}
+	p.seqs = append(p.seqs, rec.Sequence)
+	if replay {
+		p.replays++
+	}
+	return nil
+}
+
+func (p *listProjection) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seqs = nil
+	p.resets++
+	return nil
+}
+
+func (p *listProjection) handled() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]int64(nil), p.seqs...)
+}
+
+func TestProjectionManagerCatchUp(t *testing.T) {
+	s := newTestStore(t)
+	checkpoints := NewMemoryCheckpointStore()
+	m := NewProjectionManager(s, checkpoints)
+	all, adds := &listProjection{failOn: 2}, &listProjection{}
+	m.Register("all", all)
+	m.Register("adds", adds, OnlyEvents(itemAdded{}))
+	if got := m.Names(); !slices.Equal(got, []string{"adds", "all"}) {
+		t.Errorf("Names %q", got)
+	}
+
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{}, itemRemoved{}, itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CatchUpAll(); err == nil || !strings.Contains(err.Error(), "projection all") {
+		t.Errorf("CatchUpAll returned %v, want the failing projection's error", err)
+	}
+	if got := adds.handled(); !slices.Equal(got, []int64{1, 3}) {
+		t.Errorf("adds handled %v despite the other projection failing", got)
+	}
+	if seq, _ := checkpoints.LoadCheckpoint("all"); seq != 1 {
+		t.Errorf("failing projection checkpointed at %d, want 1", seq)
+	}
+
+	all.failOn = 0
+	if err := m.CatchUp("all"); err != nil {
+		t.Fatal(err)
+	}
+	if got := all.handled(); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("all handled %v", got)
+	}
+	if err := m.CatchUp("missing"); err == nil {
+		t.Error("caught up a projection that isn't registered")
+	}
+}
+
+func TestProjectionManagerRebuild(t *testing.T) {
+	s := newTestStore(t)
+	m := NewProjectionManager(s, NewMemoryCheckpointStore())
+	m.SetLogger(&recordingLogger{})
+	p := &listProjection{}
+	m.Register("items", p)
+	recordItems(t, s, 3)
+	if err := m.CatchUp("items"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Rebuild("items"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.handled(); p.resets != 1 || p.replays != 3 || !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("rebuild reset %d times, handled %v with %d replays", p.resets, got, p.replays)
+	}
+	recordItems(t, s, 1)
+	if err := m.CatchUp("items"); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.handled(); !slices.Equal(got, []int64{1, 2, 3, 4}) || p.replays != 3 {
+		t.Errorf("handled %v after the rebuild, want the new event live", got)
+	}
+}
+
+func TestProjectionManagerRun(t *testing.T) {
+	s := newTestStore(t)
+	m := NewProjectionManager(s, NewMemoryCheckpointStore())
+	m.SetInterval(time.Hour)
+	p := &listProjection{}
+	m.Register("items", p)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	recordItems(t, s, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(p.handled()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event not projected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v", err)
+	}
+}
+
+// versionedProjection is a listProjection of a version
+type versionedProjection struct {
+	*listProjection
+	version int64
+}
+
+func (p versionedProjection) Version() int64 { return p.version }
+
+func TestVersionedProjection(t *testing.T) {
+	s := newTestStore(t)
+	checkpoints := NewMemoryCheckpointStore()
+	recordItems(t, s, 2)
+	manage := func(p Projection) *recordingLogger {
+		var logger recordingLogger
+		m := NewProjectionManager(s, checkpoints)
+		m.SetLogger(&logger)
+		m.Register("items", p)
+		if err := m.CatchUp("items"); err != nil {
+			t.Fatal(err)
+		}
+		return &logger
+	}
+
+	v1 := versionedProjection{&listProjection{}, 1}
+	manage(v1)
+	if v1.resets != 1 || v1.replays != 2 {
+		t.Errorf("first build reset %d times with %d replays, want the log rebuilt", v1.resets, v1.replays)
+	}
+	if built, _ := checkpoints.LoadCheckpoint(versionCheckpoint("items")); built != 1 {
+		t.Errorf("built version %d saved, want 1", built)
+	}
+
+	same := versionedProjection{&listProjection{}, 1}
+	manage(same)
+	if same.resets != 0 || len(same.handled()) != 0 {
+		t.Errorf("the same version was rebuilt: %d resets", same.resets)
+	}
+
+	v2 := versionedProjection{&listProjection{}, 2}
+	manage(v2)
+	if v2.resets != 1 || !slices.Equal(v2.handled(), []int64{1, 2}) {
+		t.Errorf("bumped version reset %d times and handled %v, want a rebuild", v2.resets, v2.handled())
+	}
+
+	older := versionedProjection{&listProjection{}, 1}
+	logger := manage(older)
+	if older.resets != 0 {
+		t.Error("an older version rebuilt the read model of a newer one")
+	}
+	if got := logger.logged(); !slices.Contains(got, "warn: evoke: projection was built by a newer version") {
+		t.Errorf("logged %q, want a warning about the newer build", got)
+	}
+}
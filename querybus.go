@@ -0,0 +1,194 @@
+package evoke
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QueryHandler answers queries of the read side, usually from a read
+// model. Queries are plain values, dispatched by type name like commands.
+type QueryHandler interface {
+	HandleQuery(ctx context.Context, query any) (any, error)
+}
+
+// QueryHandlerFunc adapts a function answering queries of type Q with an R
+// to a QueryHandler. QueryHandlerFunc[any, any] takes any query, for
+// middleware wrapping another handler.
+type QueryHandlerFunc[Q, R any] func(ctx context.Context, query Q) (R, error)
+
+func (f QueryHandlerFunc[Q, R]) HandleQuery(ctx context.Context, query any) (any, error) {
+	q, ok := asType[Q](query)
+	if !ok {
+		var want Q
+		return nil, fmt.Errorf("QueryHandlerFunc: handler for %T got %T", want, query)
+	}
+	return f(ctx, q)
+}
+
+// QueryMiddleware wraps the handler of every query asked through a bus, to
+// cache, log or check queries.
+type QueryMiddleware func(next QueryHandler) QueryHandler
+
+// QueryBus dispatches queries to their handlers.
+type QueryBus interface {
+	RegisterQueryHandler(query any, handler QueryHandler)
+	Ask(ctx context.Context, query any) (any, error)
+}
+
+type simpleQueryBus struct {
+	mu         sync.RWMutex
+	handlers   map[string]QueryHandler
+	middleware []QueryMiddleware
+	inst       Instrumentation
+	tracer     Tracer
+}
+
+// NewQueryBus returns a bus answering queries with the handlers registered
+// for their types, the read side counterpart of NewCommandBus.
+func NewQueryBus() *simpleQueryBus {
+	return &simpleQueryBus{
+		handlers: make(map[string]QueryHandler),
+		inst:     nopInstrumentation{},
+		tracer:   nopTracer{},
+	}
+}
+
+func (b *simpleQueryBus) RegisterQueryHandler(query any, handler QueryHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, exists := b.handlers[TypeName(query)]
+	if exists {
+		panic("query handler already registered")
+	}
+	b.handlers[TypeName(query)] = handler
+}
+
+// Use wraps every query handler of the bus in mw, the first middleware
+// outermost.
+func (b *simpleQueryBus) Use(mw ...QueryMiddleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.middleware = append(b.middleware, mw...)
+}
+
+// Ask answers a query as part of the trace in ctx, through the bus's
+// middleware.
+func (b *simpleQueryBus) Ask(ctx context.Context, query any) (any, error) {
+	b.mu.RLock()
+	h, ok := b.handlers[TypeName(query)]
+	middleware := b.middleware
+	inst, tracer := b.inst, b.tracer
+	b.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("simpleQueryBus: %w: %s (hint: call RegisterQueryHandler)", ErrNoQueryHandler, TypeName(query))
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+
+	var result any
+	err := traced(ctx, tracer, inst, "query", TypeName(query), func(ctx context.Context) error {
+		var err error
+		result, err = h.HandleQuery(ctx, query)
+		return err
+	})
+	return result, err
+}
+
+// RegisterQueryFunc registers fn as the handler for queries of type Q.
+func RegisterQueryFunc[Q, R any](bus QueryBus, fn func(context.Context, Q) (R, error)) {
+	var query Q
+	bus.RegisterQueryHandler(query, QueryHandlerFunc[Q, R](fn))
+}
+
+// Ask asks bus a query whose answer is an R.
+//
+//	order, err := evoke.Ask[OrderView](ctx, queries, GetOrder{ID: id})
+func Ask[R any](ctx context.Context, bus QueryBus, query any) (R, error) {
+	result, err := bus.Ask(ctx, query)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+	r, ok := asType[R](result)
+	if !ok {
+		var zero R
+		return zero, fmt.Errorf("Ask: %s answered with %T, not %T", TypeName(query), result, zero)
+	}
+	return r, nil
+}
+
+// CacheQueries is middleware answering a query asked again within ttl with
+// the earlier answer, keeping up to maxSize answers. Queries are told apart
+// by type and JSON encoding, so they must marshal; those that don't, and
+// errors, are never cached. Answers are shared between those asking, who
+// must not modify them.
+func CacheQueries(ttl time.Duration, maxSize int) QueryMiddleware {
+	c := &queryCache{ttl: ttl, maxSize: maxSize, entries: map[string]cachedAnswer{}}
+	return func(next QueryHandler) QueryHandler {
+		return QueryHandlerFunc[any, any](func(ctx context.Context, query any) (any, error) {
+			b, err := json.Marshal(query)
+			if err != nil {
+				return next.HandleQuery(ctx, query)
+			}
+			key := TypeName(query) + " " + string(b)
+			if result, ok := c.get(key); ok {
+				return result, nil
+			}
+			result, err := next.HandleQuery(ctx, query)
+			if err == nil {
+				c.put(key, result)
+			}
+			return result, err
+		})
+	}
+}
+
+type cachedAnswer struct {
+	result  any
+	expires time.Time
+}
+
+type queryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]cachedAnswer
+}
+
+func (c *queryCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.result, true
+}
+
+// put caches an answer, making room by dropping expired answers, then
+// arbitrary ones
+func (c *queryCache) put(key string, result any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= c.maxSize {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	for k := range c.entries {
+		if len(c.entries) < c.maxSize {
+			break
+		}
+		delete(c.entries, k)
+	}
+	if c.maxSize > 0 {
+		c.entries[key] = cachedAnswer{result: result, expires: now.Add(c.ttl)}
+	}
+}
@@ -0,0 +1,166 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type getCart struct{ ID uuid.UUID }
+
+type cartView struct{ Items int }
+
+// cartViews answers getCart from its map, counting the queries it answers
+type cartViews struct {
+	carts map[uuid.UUID]cartView
+	asked int
+}
+
+func (v *cartViews) getCart(_ context.Context, q getCart) (cartView, error) {
+	v.asked++
+	view, ok := v.carts[q.ID]
+	if !ok {
+		return cartView{}, errors.New("no such cart")
+	}
+	return view, nil
+}
+
+// handlersDone keeps the handlers reported done, as "kind name"
+type handlersDone struct {
+	nopInstrumentation
+	done []string
+}
+
+func (h *handlersDone) HandlerDone(kind, name string, _ time.Duration, err error) {
+	h.done = append(h.done, fmt.Sprintf("%s %s %v", kind, name, err))
+}
+
+func TestQueryBus(t *testing.T) {
+	id := NewID()
+	views := &cartViews{carts: map[uuid.UUID]cartView{id: {Items: 2}}}
+	bus := NewQueryBus()
+	var inst handlersDone
+	bus.SetInstrumentation(&inst)
+	RegisterQueryFunc(bus, views.getCart)
+
+	view, err := Ask[cartView](context.Background(), bus, getCart{ID: id})
+	if err != nil || view.Items != 2 {
+		t.Errorf("Ask returned %+v, %v", view, err)
+	}
+	if view, err := Ask[cartView](context.Background(), bus, &getCart{ID: id}); err != nil || view.Items != 2 {
+		t.Errorf("Ask by pointer returned %+v, %v", view, err)
+	}
+	if _, err := Ask[cartView](context.Background(), bus, getCart{ID: NewID()}); err == nil || err.Error() != "no such cart" {
+		t.Errorf("Ask of a missing cart returned %v, want the handler's error", err)
+	}
+	if _, err := Ask[string](context.Background(), bus, getCart{ID: id}); err == nil {
+		t.Error("Ask took a cartView for a string")
+	}
+	if _, err := bus.Ask(context.Background(), addItem{}); !errors.Is(err, ErrNoQueryHandler) {
+		t.Errorf("Ask of an unregistered query returned %v, want ErrNoQueryHandler", err)
+	}
+	want := []string{"query getCart <nil>", "query getCart <nil>", "query getCart no such cart", "query getCart <nil>"}
+	if !slices.Equal(inst.done, want) {
+		t.Errorf("reported %q, want %q", inst.done, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registered a second handler for getCart")
+		}
+	}()
+	RegisterQueryFunc(bus, views.getCart)
+}
+
+func TestQueryHandlerFuncWrongType(t *testing.T) {
+	h := QueryHandlerFunc[getCart, cartView](func(context.Context, getCart) (cartView, error) { return cartView{}, nil })
+	if _, err := h.HandleQuery(context.Background(), addItem{}); err == nil {
+		t.Error("handled a query of another type")
+	}
+}
+
+func TestQueryMiddleware(t *testing.T) {
+	var calls []string
+	named := func(name string) QueryMiddleware {
+		return func(next QueryHandler) QueryHandler {
+			return QueryHandlerFunc[any, any](func(ctx context.Context, query any) (any, error) {
+				calls = append(calls, name)
+				return next.HandleQuery(ctx, query)
+			})
+		}
+	}
+	bus := NewQueryBus()
+	bus.Use(named("outer"), named("inner"))
+	RegisterQueryFunc(bus, func(context.Context, getCart) (cartView, error) {
+		calls = append(calls, "handler")
+		return cartView{}, nil
+	})
+	if _, err := bus.Ask(context.Background(), getCart{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"outer", "inner", "handler"}; !slices.Equal(calls, want) {
+		t.Errorf("called %q, want %q", calls, want)
+	}
+}
+
+// uncacheable doesn't marshal as JSON
+type uncacheable struct{ Ch chan int }
+
+func TestCacheQueries(t *testing.T) {
+	a, b := NewID(), NewID()
+	views := &cartViews{carts: map[uuid.UUID]cartView{a: {Items: 1}, b: {Items: 2}}}
+	bus := NewQueryBus()
+	bus.Use(CacheQueries(50*time.Millisecond, 1))
+	RegisterQueryFunc(bus, views.getCart)
+	uncached := 0
+	RegisterQueryFunc(bus, func(context.Context, uncacheable) (int, error) {
+		uncached++
+		return uncached, nil
+	})
+	ask := func(id uuid.UUID) {
+		t.Helper()
+		if _, err := Ask[cartView](context.Background(), bus, getCart{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ask(a)
+	ask(a)
+	if views.asked != 1 {
+		t.Errorf("handler asked %d times for the same cart, want the answer cached", views.asked)
+	}
+	ask(b)
+	ask(a)
+	if views.asked != 3 {
+		t.Errorf("handler asked %d times, want a's answer evicted by b's", views.asked)
+	}
+	time.Sleep(60 * time.Millisecond)
+	ask(a)
+	if views.asked != 4 {
+		t.Errorf("handler asked %d times, want the expired answer asked again", views.asked)
+	}
+
+	missing := getCart{ID: NewID()}
+	for range 2 {
+		if _, err := bus.Ask(context.Background(), missing); err == nil {
+			t.Fatal("no error for a missing cart")
+		}
+	}
+	if views.asked != 6 {
+		t.Errorf("handler asked %d times, want errors not cached", views.asked)
+	}
+
+	for range 2 {
+		if _, err := bus.Ask(context.Background(), uncacheable{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if uncached != 2 {
+		t.Errorf("handler of a query that doesn't marshal asked %d times, want it never cached", uncached)
+	}
+}
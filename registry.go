@@ -4,45 +4,198 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
 )
 
 func RegisterEvent[T Event](er EventRegisterer, ctor T) {
-	er.registerEvent(TypeName(ctor), func() Event {
+	RegisterEventNamed(er, TypeName(ctor), ctor)
+}
+
+// RegisterEventNamed registers an event type to be stored under name rather
+// than its Go type name, so the type can be renamed without breaking the
+// events already in a store.
+func RegisterEventNamed[T Event](er EventRegisterer, name string, ctor T) {
+	er.registerEvent(name, func() Event {
 		return ctor
-	})
+	}, false)
+}
+
+// RegisterEventAlias decodes events stored under alias as ctor's type. Use
+// it to keep reading events stored under a name a type no longer has.
+func RegisterEventAlias[T Event](er EventRegisterer, alias string, ctor T) {
+	er.registerEvent(alias, func() Event {
+		return ctor
+	}, true)
 }
 
 type EventRegisterer interface {
-	registerEvent(eventType string, ctor func() Event)
+	registerEvent(eventType string, ctor func() Event, alias bool)
+	registerSchema(goType string, schema *jsonschema.Schema)
+	registerCodec(goType string, codec eventCodec)
 	UnmarshalEvent(eventType string, data []byte) (Event, error)
 }
 
 type EventRegistry struct {
 	registry map[string]func() Event
+	// names maps Go type names to the name their events are stored under
+	names map[string]string
+	// storedAs maps Go type names to every name their events are read from
+	storedAs map[string][]string
+	// schemas maps Go type names to the schema their payloads must match
+	schemas map[string]*jsonschema.Schema
+	// codecs maps Go type names to the codec their payloads are stored with
+	codecs map[string]eventCodec
 }
 
-func (er *EventRegistry) registerEvent(eventType string, ctor func() Event) {
+func (er *EventRegistry) registerEvent(eventType string, ctor func() Event, alias bool) {
 	if er.registry == nil {
 		er.registry = make(map[string]func() Event)
+		er.names = make(map[string]string)
+		er.storedAs = make(map[string][]string)
 	}
 	er.registry[eventType] = ctor
+	goType := TypeName(ctor())
+	if !alias {
+		er.names[goType] = eventType
+	}
+	if !slices.Contains(er.storedAs[goType], eventType) {
+		er.storedAs[goType] = append(er.storedAs[goType], eventType)
+	}
+}
+
+// RegisteredTypes returns every event type name the registry decodes,
+// including aliases, in sorted order.
+func (er *EventRegistry) RegisteredTypes() []string {
+	types := make([]string, 0, len(er.registry))
+	for t := range er.registry {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// VerifiableStore is a store that can be checked with VerifyStore.
+type VerifiableStore interface {
+	RegisteredTypes() []string
+	StoredEventTypes() ([]string, error)
+}
+
+// VerifyStore checks that every event type found in the store is
+// registered, so a replay won't fail halfway through on an event it can't
+// decode. The error wraps ErrEventNotRegistered and names the missing types.
+func VerifyStore(store VerifiableStore) error {
+	stored, err := store.StoredEventTypes()
+	if err != nil {
+		return err
+	}
+	registered := store.RegisteredTypes()
+	var missing []string
+	for _, t := range stored {
+		if _, ok := slices.BinarySearch(registered, t); !ok {
+			missing = append(missing, t)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrEventNotRegistered, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// EventName is the name events like e are stored under.
+func (er *EventRegistry) EventName(e Event) string {
+	if name, ok := er.names[TypeName(e)]; ok {
+		return name
+	}
+	return TypeName(e)
+}
+
+// registered reports whether events like e are registered, and so can be
+// decoded once stored
+func (er *EventRegistry) registered(e Event) bool {
+	_, ok := er.names[TypeName(e)]
+	return ok
+}
+
+// storedEventTypes returns the names events of eventType may be stored
+// under, which is eventType itself when given a stored name rather than a
+// Go type name
+func (er *EventRegistry) storedEventTypes(eventType string) []string {
+	names := []string{eventType}
+	for _, name := range er.storedAs[eventType] {
+		if name != eventType {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 func (er *EventRegistry) UnmarshalEvent(eventType string, data []byte) (Event, error) {
 	ctor, ok := er.registry[eventType]
 	if !ok {
-		return nil, fmt.Errorf("event not registered %q (hint call evoke.RegisterEvent(...)", eventType)
+		return nil, fmt.Errorf("%w %q (hint call evoke.RegisterEvent(...)", ErrEventNotRegistered, eventType)
 	}
 	e := ctor()
+	if codec, ok := er.codecs[TypeName(e)]; ok {
+		decoded, err := codec.unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return underlying(decoded), nil
+	}
 	if err := json.Unmarshal(data, e); err != nil {
 		return nil, err
 	}
 
 	// return underlying values not pointers
-	v := reflect.ValueOf(e)
-	if v.Kind() == reflect.Ptr {
-		return v.Elem().Interface().(Event), nil
+	return underlying(e), nil
+}
+
+func RegisterCommand[T Command](cr CommandRegisterer, ctor T) {
+	t := reflect.TypeOf(ctor)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
+	cr.registerCommand(TypeName(ctor), func() any {
+		return reflect.New(t).Interface()
+	})
+}
+
+type CommandRegisterer interface {
+	registerCommand(commandType string, ctor func() any)
+	UnmarshalCommand(commandType string, data []byte) (Command, error)
+}
+
+type CommandRegistry struct {
+	registry map[string]func() any
+}
 
-	return e, nil
+func (cr *CommandRegistry) registerCommand(commandType string, ctor func() any) {
+	if cr.registry == nil {
+		cr.registry = make(map[string]func() any)
+	}
+	cr.registry[commandType] = ctor
+}
+
+func (cr *CommandRegistry) UnmarshalCommand(commandType string, data []byte) (Command, error) {
+	ctor, ok := cr.registry[commandType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (hint call evoke.RegisterCommand(...)", ErrCommandNotRegistered, commandType)
+	}
+	c := ctor()
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	// return underlying values not pointers, unless only the pointer is a Command
+	if cmd, ok := reflect.ValueOf(c).Elem().Interface().(Command); ok {
+		return cmd, nil
+	}
+	cmd, ok := c.(Command)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a Command", c)
+	}
+	return cmd, nil
 }
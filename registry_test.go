@@ -0,0 +1,141 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegisterEventNamed(t *testing.T) {
+	var er EventRegistry
+	RegisterEventNamed(&er, "cart.item_added", &itemAdded{})
+	if got := er.EventName(itemAdded{}); got != "cart.item_added" {
+		t.Errorf("EventName %q, want the registered name", got)
+	}
+	if got := er.EventName(itemRemoved{}); got != "itemRemoved" {
+		t.Errorf("EventName of an unregistered type %q, want its Go name", got)
+	}
+	e, err := er.UnmarshalEvent("cart.item_added", []byte(`{"SKU":"a","Qty":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e != (itemAdded{SKU: "a", Qty: 2}) {
+		t.Errorf("decoded %#v", e)
+	}
+	if _, err := er.UnmarshalEvent("itemAdded", []byte(`{}`)); err == nil {
+		t.Error("decoded an event by its Go name when it is registered under another")
+	}
+}
+
+// A type renamed with RegisterEventNamed keeps reading the events stored
+// under its old name through an alias, and filters on it match both.
+func TestRegisterEventAlias(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	id := NewID()
+	old, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterEvent(old, &itemAdded{})
+	if err := old.Record(id, []Event{itemAdded{SKU: "old"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	RegisterEventNamed(s, "cart.item_added", &itemAdded{})
+	RegisterEventAlias(s, "itemAdded", &itemAdded{})
+	if err := s.Record(id, []Event{itemAdded{SKU: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := mustLoad(t, s, id)
+	if len(recs) != 2 || recs[0].Event != (itemAdded{SKU: "old"}) || recs[1].Event != (itemAdded{SKU: "new"}) {
+		t.Fatalf("loaded %+v", recs)
+	}
+	if recs[0].EventType != "itemAdded" || recs[1].EventType != "cart.item_added" {
+		t.Errorf("events stored as %q and %q, want the old name and the new", recs[0].EventType, recs[1].EventType)
+	}
+
+	var replayed []int64
+	err = s.ReplayFrom(1, func(rec RecordedEvent, replay bool) error {
+		replayed = append(replayed, rec.Sequence)
+		return nil
+	}, OnlyEvents(itemAdded{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(replayed, []int64{1, 2}) {
+		t.Errorf("replayed %v of the renamed type, want both names' events", replayed)
+	}
+}
+
+func TestRegisteredTypes(t *testing.T) {
+	var er EventRegistry
+	RegisterEvent(&er, &itemRemoved{})
+	RegisterEventNamed(&er, "cart.item_added", &itemAdded{})
+	RegisterEventAlias(&er, "ItemAdded", &itemAdded{})
+	want := []string{"ItemAdded", "cart.item_added", "itemRemoved"}
+	if got := er.RegisteredTypes(); !slices.Equal(got, want) {
+		t.Errorf("RegisteredTypes %q, want %q", got, want)
+	}
+}
+
+func TestVerifyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	RegisterEvent(s, &itemAdded{})
+	RegisterEvent(s, &itemRemoved{})
+	RegisterEvent(s, &pipeEncoded{})
+	if err := VerifyStore(s); err != nil {
+		t.Errorf("VerifyStore of an empty store: %v", err)
+	}
+	if err := s.Record(NewID(), []Event{itemAdded{}, itemRemoved{}, pipeEncoded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.StoredEventTypes(); err != nil || !slices.Equal(got, []string{"itemAdded", "itemRemoved", "pipeEncoded"}) {
+		t.Fatalf("StoredEventTypes %q, %v", got, err)
+	}
+	if err := VerifyStore(s); err != nil {
+		t.Errorf("VerifyStore with every type registered: %v", err)
+	}
+
+	other, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { other.Shutdown(context.Background()) })
+	RegisterEvent(other, &itemAdded{})
+	err = VerifyStore(other)
+	if !errors.Is(err, ErrEventNotRegistered) || !strings.Contains(err.Error(), "itemRemoved, pipeEncoded") {
+		t.Errorf("VerifyStore with types missing: %v, want ErrEventNotRegistered naming them", err)
+	}
+}
+
+func TestUnmarshalCommand(t *testing.T) {
+	var cr CommandRegistry
+	RegisterCommand(&cr, addItem{})
+	cmd, err := cr.UnmarshalCommand("addItem", []byte(`{"SKU":"a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != (addItem{SKU: "a"}) {
+		t.Errorf("decoded %#v", cmd)
+	}
+	if _, err := cr.UnmarshalCommand("removeItem", []byte(`{}`)); !errors.Is(err, ErrCommandNotRegistered) {
+		t.Errorf("decoding an unregistered command: %v, want ErrCommandNotRegistered", err)
+	}
+}
@@ -0,0 +1,65 @@
+package evoke
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reminder is a command an aggregate asked to be sent back to it later, for
+// rules like expiring a trial or timing out an unpaid order.
+type Reminder struct {
+	At      time.Time
+	Command Command
+}
+
+// Reminding is implemented by aggregates that set reminders while handling
+// commands, as AggregateBase does with RemindAt.
+type Reminding interface {
+	TakeReminders() []Reminder
+}
+
+// ReminderScheduler persists reminders until they are due. *Scheduler
+// satisfies it; the reminders' command types must be registered with it.
+type ReminderScheduler interface {
+	SendAt(cmd Command, t time.Time) error
+}
+
+// WithReminders schedules the reminders aggregates set with scheduler.
+// Reminders are scheduled right after the command's events are recorded,
+// not in the same transaction, so a crash in between loses them.
+func WithReminders(scheduler ReminderScheduler) AggregateHandlerOption {
+	return func(h *AggregateHandler) {
+		h.reminders = scheduler
+	}
+}
+
+// takeReminders returns the reminders agg set while handling a command,
+// checking they are addressed to it and can be scheduled
+func (h *AggregateHandler) takeReminders(agg Aggregate, aggID uuid.UUID) ([]Reminder, error) {
+	r, ok := agg.(Reminding)
+	if !ok {
+		return nil, nil
+	}
+	reminders := r.TakeReminders()
+	if len(reminders) > 0 && h.reminders == nil {
+		return nil, fmt.Errorf("%T set reminder %T without WithReminders", agg, reminders[0].Command)
+	}
+	for _, rem := range reminders {
+		if id := rem.Command.AggregateID(); id != aggID {
+			return nil, fmt.Errorf("%T reminder %T is for aggregate %s, not %s", agg, rem.Command, id, aggID)
+		}
+	}
+	return reminders, nil
+}
+
+// scheduleReminders hands reminders to the handler's scheduler
+func (h *AggregateHandler) scheduleReminders(reminders []Reminder) error {
+	for _, rem := range reminders {
+		if err := h.reminders.SendAt(rem.Command, rem.At); err != nil {
+			return fmt.Errorf("schedule reminder %T: %w", rem.Command, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package evoke
+
+import (
+	"context"
+	"time"
+)
+
+// ReplayProgress reports how far a Replay has got.
+type ReplayProgress struct {
+	// Events is the number of events handled so far
+	Events int64
+	// Sequence is the sequence of the last event handled
+	Sequence int64
+	Elapsed  time.Duration
+	// Rate is the average number of events handled per second
+	Rate float64
+}
+
+// ReplayOption configures a Replay.
+type ReplayOption func(*replay)
+
+type replay struct {
+	filters       []EventFilter
+	progress      func(ReplayProgress)
+	progressEvery time.Duration
+	rate          float64
+}
+
+// ReplayFilters only replays the events passing filters.
+func ReplayFilters(filters ...EventFilter) ReplayOption {
+	return func(r *replay) {
+		r.filters = append(r.filters, filters...)
+	}
+}
+
+// OnProgress calls fn at most once every interval while replaying, and once
+// when the replay ends, however it ends.
+func OnProgress(every time.Duration, fn func(ReplayProgress)) ReplayOption {
+	return func(r *replay) {
+		r.progress = fn
+		r.progressEvery = every
+	}
+}
+
+// ThrottleReplay handles at most eventsPerSecond events a second, on
+// average, to leave room for live traffic on a shared store or handler.
+func ThrottleReplay(eventsPerSecond float64) ReplayOption {
+	return func(r *replay) {
+		r.rate = eventsPerSecond
+	}
+}
+
+// Replay calls handler for every event of store from sequence seq onwards,
+// like ReplayFrom, until it has handled them all or ctx is done, returning
+// ctx's error in that case.
+func Replay(ctx context.Context, store EventStore, seq int64, handler RecordedEventHandlerFunc, opts ...ReplayOption) error {
+	var r replay
+	for _, opt := range opts {
+		opt(&r)
+	}
+	start := time.Now()
+	var p ReplayProgress
+	reported := start
+	report := func(now time.Time) {
+		p.Elapsed = now.Sub(start)
+		if secs := p.Elapsed.Seconds(); secs > 0 {
+			p.Rate = float64(p.Events) / secs
+		}
+		reported = now
+		r.progress(p)
+	}
+	err := store.ReplayFrom(seq, func(rec RecordedEvent, replay bool) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := handler(rec, replay); err != nil {
+			return err
+		}
+		p.Events++
+		p.Sequence = rec.Sequence
+		if r.rate > 0 {
+			// wait until handling this many events is on schedule
+			due := start.Add(time.Duration(float64(p.Events) / r.rate * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 {
+				if err := sleepContext(ctx, wait); err != nil {
+					return err
+				}
+			}
+		}
+		if r.progress != nil {
+			if now := time.Now(); now.Sub(reported) >= r.progressEvery {
+				report(now)
+			}
+		}
+		return nil
+	}, r.filters...)
+	if r.progress != nil {
+		report(time.Now())
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// sleepContext sleeps for d, or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
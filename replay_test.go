@@ -0,0 +1,108 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id := NewID()
+			if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemRemoved{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			var reports []ReplayProgress
+			err := Replay(context.Background(), s, 1, func(rec RecordedEvent, replay bool) error {
+				if !replay {
+					t.Error("replayed event not flagged a replay")
+				}
+				got = append(got, rec.Sequence)
+				return nil
+			}, ReplayFilters(OnlyEvents(itemAdded{})), OnProgress(time.Hour, func(p ReplayProgress) {
+				reports = append(reports, p)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, []int64{1, 3}) {
+				t.Errorf("replayed %v, want the items added", got)
+			}
+			if len(reports) != 1 || reports[0].Events != 2 || reports[0].Sequence != 3 || reports[0].Elapsed <= 0 {
+				t.Errorf("reported %+v, want one final report", reports)
+			}
+		})
+	}
+}
+
+func TestReplayStops(t *testing.T) {
+	s := newTestStore(t)
+	for range 5 {
+		if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := 0
+	var final ReplayProgress
+	err := Replay(ctx, s, 1, func(RecordedEvent, bool) error {
+		handled++
+		if handled == 2 {
+			cancel()
+		}
+		return nil
+	}, OnProgress(time.Hour, func(p ReplayProgress) { final = p }))
+	if !errors.Is(err, context.Canceled) || handled != 2 {
+		t.Errorf("Replay returned %v after %d events, want it cancelled after 2", err, handled)
+	}
+	if final.Events != 2 || final.Sequence != 2 {
+		t.Errorf("reported %+v when cancelled", final)
+	}
+
+	errStop := errors.New("stop")
+	err = Replay(context.Background(), s, 1, func(rec RecordedEvent, _ bool) error {
+		if rec.Sequence == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("Replay returned %v, want the handler's error", err)
+	}
+}
+
+func TestThrottleReplay(t *testing.T) {
+	s := newTestStore(t)
+	for range 5 {
+		if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var reports []ReplayProgress
+	err := Replay(context.Background(), s, 1, func(RecordedEvent, bool) error { return nil },
+		ThrottleReplay(100), OnProgress(0, func(p ReplayProgress) { reports = append(reports, p) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := reports[len(reports)-1]
+	if len(reports) != 6 || last.Events != 5 {
+		t.Errorf("reported %+v, want a report per event and a final one", reports)
+	}
+	if last.Elapsed < 50*time.Millisecond || last.Rate > 100 {
+		t.Errorf("replayed 5 events in %v at %.0f a second, want at most 100 a second", last.Elapsed, last.Rate)
+	}
+
+	// a throttled replay stops waiting when cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = Replay(ctx, s, 1, func(RecordedEvent, bool) error { return nil }, ThrottleReplay(0.1))
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Errorf("throttled Replay returned %v after %v", err, time.Since(start))
+	}
+}
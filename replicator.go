@@ -0,0 +1,177 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// CheckpointStore remembers how far named consumers of the log have got.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the last sequence saved under name, or 0
+	LoadCheckpoint(name string) (int64, error)
+	SaveCheckpoint(name string, seq int64) error
+}
+
+type memoryCheckpointStore struct {
+	mu   sync.Mutex
+	seqs map[string]int64
+}
+
+func NewMemoryCheckpointStore() *memoryCheckpointStore {
+	return &memoryCheckpointStore{seqs: make(map[string]int64)}
+}
+
+func (s *memoryCheckpointStore) LoadCheckpoint(name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seqs[name], nil
+}
+
+func (s *memoryCheckpointStore) SaveCheckpoint(name string, seq int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seqs[name] = seq
+	return nil
+}
+
+func (s *fileStore) LoadCheckpoint(name string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seq int64
+	err := s.db.Get(&seq, `select coalesce(max(sequence), 0) from checkpoints where name = ?`, name)
+	if err != nil {
+		return 0, fmt.Errorf("select from checkpoints: %w", err)
+	}
+	return seq, nil
+}
+
+func (s *fileStore) SaveCheckpoint(name string, seq int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`insert into checkpoints(name, sequence, saved_at) values(?,?,?)
+		on conflict(name) do update set sequence = excluded.sequence, saved_at = excluded.saved_at`, name, seq, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("insert into checkpoints: %w", err)
+	}
+	return nil
+}
+
+// replicationBatch is how many events a Replicator reads from its source at
+// a time, and how often it saves its checkpoint
+const replicationBatch = 500
+
+// Replicator copies the log of a source store into a target store, event by
+// event in sequence order, keeping aggregate IDs and types. The target
+// numbers events with sequences of its own; the checkpoint tracks the
+// source's. Metadata is not copied.
+//
+// An event whose stream version already exists in a target implementing
+// StreamPager is skipped, so events replicated after the last saved
+// checkpoint are not appended twice when a Replicator restarts.
+type Replicator struct {
+	name        string
+	source      EventStore
+	target      EventStore
+	checkpoints CheckpointStore
+	interval    time.Duration
+	logger      Logger
+}
+
+// NewReplicator returns a replicator saving its progress in checkpoints
+// under name.
+func NewReplicator(name string, source, target EventStore, checkpoints CheckpointStore) *Replicator {
+	return &Replicator{
+		name:        name,
+		source:      source,
+		target:      target,
+		checkpoints: checkpoints,
+		interval:    time.Second,
+		logger:      slog.Default(),
+	}
+}
+
+// SetInterval sets how often Run polls the source once caught up. The
+// default is one second.
+func (r *Replicator) SetInterval(d time.Duration) {
+	r.interval = d
+}
+
+func (r *Replicator) SetLogger(logger Logger) {
+	r.logger = logger
+}
+
+// CatchUp replicates every event recorded in the source since the
+// checkpoint, returning how many were appended to the target.
+func (r *Replicator) CatchUp() (int, error) {
+	seq, err := r.checkpoints.LoadCheckpoint(r.name)
+	if err != nil {
+		return 0, fmt.Errorf("load checkpoint: %w", err)
+	}
+
+	n := 0
+	for {
+		batch, err := r.source.ReadAll(seq+1, replicationBatch)
+		if err != nil {
+			return n, fmt.Errorf("read source: %w", err)
+		}
+		for _, rec := range batch {
+			ok, err := r.replicate(rec)
+			if err != nil {
+				return n, fmt.Errorf("replicate event %d: %w", rec.Sequence, err)
+			}
+			if ok {
+				n++
+			}
+			seq = rec.Sequence
+		}
+		if len(batch) > 0 {
+			if err := r.checkpoints.SaveCheckpoint(r.name, seq); err != nil {
+				return n, fmt.Errorf("save checkpoint: %w", err)
+			}
+		}
+		if len(batch) < replicationBatch {
+			return n, nil
+		}
+	}
+}
+
+// replicate appends rec to the target unless it is already there
+func (r *Replicator) replicate(rec RecordedEvent) (bool, error) {
+	if pager, ok := r.target.(StreamPager); ok {
+		existing, err := pager.LoadStreamFrom(rec.AggregateID, rec.Version, 1)
+		if err != nil {
+			return false, fmt.Errorf("load target stream: %w", err)
+		}
+		if len(existing) > 0 {
+			return false, nil
+		}
+	}
+	evs := []Event{rec.Event}
+	if recorder, ok := r.target.(AggregateRecorder); ok {
+		return true, recorder.RecordAs(context.Background(), rec.AggregateType, rec.AggregateID, evs)
+	}
+	return true, r.target.Record(rec.AggregateID, evs)
+}
+
+// Run keeps the target caught up with the source until ctx is done.
+func (r *Replicator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		n, err := r.CatchUp()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			r.logger.Debug("evoke: replicated events", "replicator", r.name, "events", n)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReplicatorCatchUp(t *testing.T) {
+	source, target := newTestStore(t), newTestStore(t)
+	id, other := NewID(), NewID()
+	if err := source.RecordAs(context.Background(), "cart", id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Record(other, []Event{itemAdded{SKU: "b"}, itemRemoved{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Record(NewID(), []Event{itemAdded{SKU: "target's own"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReplicator("copy", source, target, target)
+	if n, err := r.CatchUp(); err != nil || n != 3 {
+		t.Fatalf("CatchUp replicated %d, %v, want 3", n, err)
+	}
+	if seq, err := target.LoadCheckpoint("copy"); err != nil || seq != 3 {
+		t.Errorf("checkpoint %d, %v, want the source's last sequence", seq, err)
+	}
+	recs := mustLoad(t, target, id)
+	if len(recs) != 1 || recs[0].Event != (itemAdded{SKU: "a"}) || recs[0].AggregateType != "cart" || recs[0].Sequence != 2 {
+		t.Errorf("target loaded %+v, want the event with its type at a sequence of its own", recs)
+	}
+	if got := len(mustLoad(t, target, other)); got != 2 {
+		t.Errorf("target loaded %d events of the other stream, want 2", got)
+	}
+
+	if err := source.Record(id, []Event{itemRemoved{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.CatchUp(); err != nil || n != 1 {
+		t.Errorf("second CatchUp replicated %d, %v, want only the new event", n, err)
+	}
+}
+
+// A replicator restarting from an older checkpoint skips the events it had
+// already appended.
+func TestReplicatorRestart(t *testing.T) {
+	source, target := newTestStore(t), NewSimpleStore(nil)
+	id := NewID()
+	if err := source.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	checkpoints := NewMemoryCheckpointStore()
+	if _, err := NewReplicator("copy", source, target, checkpoints).CatchUp(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoints.SaveCheckpoint("copy", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Record(id, []Event{itemRemoved{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := NewReplicator("copy", source, target, checkpoints).CatchUp(); err != nil || n != 1 {
+		t.Errorf("restarted CatchUp replicated %d, %v, want only the new event", n, err)
+	}
+	if got := sequences(mustLoad(t, target, id)); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("target holds %v", got)
+	}
+}
+
+func TestReplicatorRun(t *testing.T) {
+	source, target := newTestStore(t), newTestStore(t)
+	r := NewReplicator("copy", source, target, NewMemoryCheckpointStore())
+	r.SetInterval(time.Millisecond)
+	r.SetLogger(&recordingLogger{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	id := NewID()
+	if err := source.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mustLoad(t, target, id)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event not replicated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned %v, want context.Canceled", err)
+	}
+}
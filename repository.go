@@ -0,0 +1,70 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// SavableAggregate is an aggregate a Repository can save: it knows its ID,
+// the number of events applied to it and which of those were raised since
+// it was loaded, as an aggregate embedding AggregateBase does once it has
+// an AggregateID method.
+type SavableAggregate interface {
+	Aggregate
+	AggregateID() uuid.UUID
+	Version() int64
+	TakeChanges() []Event
+}
+
+// Repository loads and saves aggregates of one type directly, for code
+// that changes aggregates without sending commands through a bus.
+//
+//	order, err := orders.Load(ctx, id)
+//	...
+//	order.Raise(OrderShipped{})
+//	err = orders.Save(ctx, order)
+type Repository[T SavableAggregate] struct {
+	store     EventStore
+	factory   func(id uuid.UUID) T
+	snapshots *snapshots
+}
+
+// NewRepository returns a repository hydrating aggregates created by
+// factory from store.
+func NewRepository[T SavableAggregate](store EventStore, factory func(id uuid.UUID) T) *Repository[T] {
+	return &Repository[T]{store: store, factory: factory}
+}
+
+// Load returns the aggregate with the events of its stream applied, a new
+// one if the stream is empty.
+func (r *Repository[T]) Load(ctx context.Context, id uuid.UUID) (T, error) {
+	info, err := hydrateAggregate(r.store, r.snapshots, func() Aggregate { return r.factory(id) }, id)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if r.snapshots != nil {
+		r.snapshots.take(info, slog.Default())
+	}
+	return info.Aggregate.(T), nil
+}
+
+// Save records the events raised on agg since it was loaded, or last
+// saved. On stores supporting optimistic concurrency it fails with an error
+// wrapping ErrConcurrencyConflict if others were recorded in the meantime.
+// A failed save drops the changes all the same: load the aggregate again
+// to retry.
+func (r *Repository[T]) Save(ctx context.Context, agg T) error {
+	changes := agg.TakeChanges()
+	if len(changes) == 0 {
+		return nil
+	}
+	version := agg.Version() - int64(len(changes))
+	if err := recordAggregate(ctx, r.store, agg, agg.AggregateID(), version, changes); err != nil {
+		return fmt.Errorf("save %s %s: %w", TypeName(agg), agg.AggregateID(), err)
+	}
+	return nil
+}
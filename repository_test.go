@@ -0,0 +1,95 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// savedCart is a cart a Repository can save
+type savedCart struct {
+	cart
+	id uuid.UUID
+}
+
+func (c *savedCart) AggregateID() uuid.UUID { return c.id }
+
+func newSavedCart(id uuid.UUID) *savedCart {
+	return &savedCart{cart: *newCart(id).(*cart), id: id}
+}
+
+func TestRepository(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			carts := NewRepository(s, newSavedCart)
+			id := NewID()
+			c, err := carts.Load(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Version() != 0 || c.AggregateID() != id {
+				t.Fatalf("loaded version %d of %s, want a new cart", c.Version(), c.AggregateID())
+			}
+			for _, e := range []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}} {
+				if err := c.Raise(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := carts.Save(ctx, c); err != nil {
+				t.Fatal(err)
+			}
+			if err := carts.Save(ctx, c); err != nil {
+				t.Errorf("saving without changes: %v", err)
+			}
+			if err := c.Raise(itemRemoved{SKU: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := carts.Save(ctx, c); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err = carts.Load(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Version() != 3 || c.State.Items != 1 || len(c.Changes()) != 0 {
+				t.Errorf("loaded %d items at version %d with changes %v, want the saved cart", c.State.Items, c.Version(), c.Changes())
+			}
+		})
+	}
+}
+
+func TestRepositorySaveConflict(t *testing.T) {
+	ctx := context.Background()
+	carts := NewRepository(newTestStore(t), newSavedCart)
+	id := NewID()
+	first, err := carts.Load(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := carts.Load(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*savedCart{first, second} {
+		if err := c.Raise(itemAdded{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := carts.Save(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := carts.Save(ctx, second); !errors.Is(err, ErrConcurrencyConflict) {
+		t.Errorf("saving a stale cart returned %v, want ErrConcurrencyConflict", err)
+	}
+	c, err := carts.Load(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version() != 1 {
+		t.Errorf("loaded version %d, want only the first save", c.Version())
+	}
+}
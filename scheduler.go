@@ -0,0 +1,183 @@
+package evoke
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maximum dispatch attempts before a scheduled command is marked failed
+const schedulerMaxAttempts = 5
+
+var errSchedulerClosed = errors.New("scheduler shut down")
+
+// Scheduler persists commands to be sent at a future time and dispatches
+// them through a CommandSender when they come due. Pending commands survive
+// restarts. Command types must be registered with RegisterCommand.
+type Scheduler struct {
+	CommandRegistry
+	mu sync.Mutex
+	// running is held by RunDue while it sends, so Shutdown can wait for it
+	running sync.Mutex
+	closed  bool
+	db      *sqlx.DB
+	sender  CommandSender
+	logger  Logger
+}
+
+// NewScheduler opens the scheduler database, which may be the same file as
+// a file store.
+func NewScheduler(dbFile string, sender CommandSender) (*Scheduler, error) {
+	db, err := openSQLite(dbFile, defaultSQLiteConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(`
+		create table if not exists scheduled_commands (
+			id           integer primary key autoincrement,
+			due_at       integer not null, -- unix milliseconds
+			command_type text not null,
+			command_json text not null,
+			attempts     integer not null default 0,
+			last_error   text not null default '',
+			status       text not null default 'pending'
+		);
+		create index if not exists scheduled_commands_due on scheduled_commands(status, due_at);
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create scheduled_commands table: %w", err)
+	}
+
+	return &Scheduler{
+		db:     sqlx.NewDb(db, "sqlite3"),
+		sender: sender,
+		logger: slog.Default(),
+	}, nil
+}
+
+// Close closes the scheduler database without waiting for RunDue; see
+// Shutdown.
+func (s *Scheduler) Close() error {
+	return s.db.Close()
+}
+
+// SendAt schedules cmd to be sent at t.
+func (s *Scheduler) SendAt(cmd Command, t time.Time) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("Marshal: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.db.Exec(`insert into scheduled_commands(due_at, command_type, command_json) values(?,?,?)`,
+		t.UnixMilli(),
+		TypeName(cmd),
+		string(data))
+	if err != nil {
+		return fmt.Errorf("insert into scheduled_commands: %w", err)
+	}
+	return nil
+}
+
+// SendAfter schedules cmd to be sent once d has elapsed.
+func (s *Scheduler) SendAfter(cmd Command, d time.Duration) error {
+	return s.SendAt(cmd, time.Now().Add(d))
+}
+
+type scheduledCommand struct {
+	ID          int64  `db:"id"`
+	DueAt       int64  `db:"due_at"`
+	CommandType string `db:"command_type"`
+	CommandJSON string `db:"command_json"`
+	Attempts    int    `db:"attempts"`
+	LastError   string `db:"last_error"`
+	Status      string `db:"status"`
+}
+
+// RunDue sends every command that is due now. A command that fails to send
+// is retried with backoff, and marked failed after repeated errors.
+func (s *Scheduler) RunDue() error {
+	s.running.Lock()
+	defer s.running.Unlock()
+	if s.closed {
+		return errSchedulerClosed
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	var rows []scheduledCommand
+	err := s.db.Select(&rows, `select * from scheduled_commands where status = 'pending' and due_at <= ? order by due_at, id`, now.UnixMilli())
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("select from scheduled_commands: %w", err)
+	}
+
+	for _, row := range rows {
+		cmd, err := s.UnmarshalCommand(row.CommandType, []byte(row.CommandJSON))
+		if err == nil {
+			err = s.sender.Send(cmd)
+		}
+		if err := s.settle(row, now, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) settle(row scheduledCommand, now time.Time, sendErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sendErr == nil {
+		_, err := s.db.Exec(`delete from scheduled_commands where id = ?`, row.ID)
+		if err != nil {
+			return fmt.Errorf("delete from scheduled_commands: %w", err)
+		}
+		return nil
+	}
+
+	attempts := row.Attempts + 1
+	status := "pending"
+	if attempts >= schedulerMaxAttempts {
+		status = "failed"
+	}
+	if status == "failed" {
+		s.logger.Error("evoke: scheduled command failed", "id", row.ID, "command_type", row.CommandType, "attempts", attempts, "error", sendErr)
+	} else {
+		s.logger.Warn("evoke: scheduled command will be retried", "id", row.ID, "command_type", row.CommandType, "attempts", attempts, "error", sendErr)
+	}
+	retryAt := now.Add(time.Duration(1<<attempts) * time.Second)
+	_, err := s.db.Exec(`update scheduled_commands set attempts = ?, last_error = ?, status = ?, due_at = ? where id = ?`,
+		attempts, sendErr.Error(), status, retryAt.UnixMilli(), row.ID)
+	if err != nil {
+		return fmt.Errorf("update scheduled_commands: %w", err)
+	}
+	return nil
+}
+
+// Run calls RunDue every interval until ctx is done or the scheduler is shut
+// down.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunDue(); errors.Is(err, errSchedulerClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSender keeps the commands sent through it, failing with err if
+// set
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []Command
+	err  error
+}
+
+func (s *recordingSender) Send(cmd Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, cmd)
+	return nil
+}
+
+func (s *recordingSender) MustSend(cmd Command) {
+	if err := s.Send(cmd); err != nil {
+		panic(err)
+	}
+}
+
+func (s *recordingSender) skus() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var skus []string
+	for _, cmd := range s.sent {
+		skus = append(skus, cmd.(addItem).SKU)
+	}
+	return skus
+}
+
+func newTestScheduler(t *testing.T, path string, sender CommandSender) *Scheduler {
+	t.Helper()
+	s, err := NewScheduler(path, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterCommand(s, &addItem{})
+	s.SetLogger(&recordingLogger{})
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	var sender recordingSender
+	s := newTestScheduler(t, filepath.Join(t.TempDir(), "scheduler.db"), &sender)
+	id := NewID()
+	now := time.Now()
+	for _, cmd := range []struct {
+		sku string
+		at  time.Time
+	}{
+		{"later", now.Add(time.Hour)},
+		{"second", now.Add(-time.Minute)},
+		{"first", now.Add(-time.Hour)},
+	} {
+		if err := s.SendAt(addItem{ID: id, SKU: cmd.sku}, cmd.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.RunDue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := sender.skus(); !slices.Equal(got, []string{"first", "second"}) {
+		t.Errorf("sent %v, want the due commands once each, in due order", got)
+	}
+}
+
+// Pending commands are kept in the database, so a scheduler opened after a
+// restart sends them.
+func TestSchedulerPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	s, err := NewScheduler(path, &recordingSender{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendAfter(addItem{ID: NewID(), SKU: "a"}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sender recordingSender
+	if err := newTestScheduler(t, path, &sender).RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sender.skus(); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("sent %v after reopening, want [a]", got)
+	}
+}
+
+// A command that fails to send is retried later, and marked failed after
+// schedulerMaxAttempts.
+func TestSchedulerRetries(t *testing.T) {
+	sender := recordingSender{err: errors.New("bus down")}
+	s := newTestScheduler(t, filepath.Join(t.TempDir(), "scheduler.db"), &sender)
+	if err := s.SendAfter(addItem{ID: NewID(), SKU: "a"}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	scheduled := func() scheduledCommand {
+		t.Helper()
+		var row scheduledCommand
+		if err := s.db.Get(&row, `select * from scheduled_commands`); err != nil {
+			t.Fatal(err)
+		}
+		return row
+	}
+
+	if err := s.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	row := scheduled()
+	if row.Attempts != 1 || row.Status != "pending" || row.LastError != "bus down" {
+		t.Fatalf("after a failed send the command is %+v", row)
+	}
+	if row.DueAt <= time.Now().UnixMilli() {
+		t.Errorf("a failed command is due again at once")
+	}
+
+	for i := 1; i < schedulerMaxAttempts; i++ {
+		// skip the backoff
+		if _, err := s.db.Exec(`update scheduled_commands set due_at = 0`); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RunDue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if row := scheduled(); row.Attempts != schedulerMaxAttempts || row.Status != "failed" {
+		t.Fatalf("after %d failed sends the command is %+v", schedulerMaxAttempts, row)
+	}
+
+	sender.err = nil
+	if _, err := s.db.Exec(`update scheduled_commands set due_at = 0`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunDue(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sender.skus(); len(got) != 0 {
+		t.Errorf("sent %v, a failed command is not retried", got)
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	var sender recordingSender
+	s := newTestScheduler(t, filepath.Join(t.TempDir(), "scheduler.db"), &sender)
+	if err := s.SendAfter(addItem{ID: NewID(), SKU: "a"}, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- s.Run(ctx, 10*time.Millisecond) }()
+	for len(sender.skus()) == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Run after Shutdown: %v", err)
+	}
+	if got := sender.skus(); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("sent %v, want [a]", got)
+	}
+}
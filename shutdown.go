@@ -0,0 +1,101 @@
+package evoke
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// lockContext locks mu, or gives up when ctx is done. A lock acquired after
+// giving up is released straight away.
+func lockContext(ctx context.Context, mu sync.Locker) error {
+	locked := make(chan struct{})
+	go func() {
+		mu.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			mu.Unlock()
+		}()
+		return ctx.Err()
+	}
+}
+
+// Shutdown waits for the append or read in progress, if any, then
+// checkpoints the write-ahead log into the database file and closes it. If
+// ctx is done first it returns ctx's error and leaves the store open.
+func (s *fileStore) Shutdown(ctx context.Context) error {
+	if err := lockContext(ctx, &s.mu); err != nil {
+		return fmt.Errorf("wait for store: %w", err)
+	}
+	defer s.mu.Unlock()
+	s.closeStmts()
+	if s.reader != nil {
+		if err := s.reader.Close(); err != nil {
+			return fmt.Errorf("close read pool: %w", err)
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, `pragma wal_checkpoint(truncate)`); err != nil {
+		s.logger.Warn("evoke: final wal checkpoint failed", "error", err)
+	}
+	return s.db.Close()
+}
+
+// Shutdown waits for the commands being sent by RunDue, if any, then closes
+// the scheduler database. If ctx is done first it returns ctx's error and
+// leaves the scheduler open. Commands still pending are sent once a
+// scheduler is opened on the database again.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	if err := lockContext(ctx, &s.running); err != nil {
+		return fmt.Errorf("wait for scheduler: %w", err)
+	}
+	defer s.running.Unlock()
+	s.closed = true
+	return s.db.Close()
+}
+
+// Shutdown waits for the queues of Buffered subscriptions to drain, giving
+// up when ctx is done. Events published to them afterwards fail.
+func (b *simpleEventBus) Shutdown(ctx context.Context) error {
+	b.mu.RLock()
+	var lanes []*lane
+	for _, subs := range b.subscribers {
+		for _, sub := range subs {
+			if sub.lane != nil {
+				lanes = append(lanes, sub.lane)
+			}
+		}
+	}
+	for _, sub := range b.matchers {
+		if sub.lane != nil {
+			lanes = append(lanes, sub.lane)
+		}
+	}
+	b.mu.RUnlock()
+	for _, l := range lanes {
+		if err := l.shutdown(ctx); err != nil {
+			return fmt.Errorf("wait for subscriber: %w", err)
+		}
+	}
+	return nil
+}
+
+// Shutdown stops accepting events and waits for the queued ones to be
+// handled like Wait, giving up when ctx is done.
+func (d *ParallelDispatcher) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
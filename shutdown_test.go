@@ -0,0 +1,99 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreShutdown(t *testing.T) {
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterEvent(s, &itemAdded{})
+
+	// an append in progress holds the store's lock
+	s.mu.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown during an append returned %v, want the deadline", err)
+	}
+	s.mu.Unlock()
+	if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatalf("recording after a Shutdown gave up: %v", err)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(NewID(), []Event{itemAdded{}}); err == nil {
+		t.Error("recorded events after Shutdown")
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	var sender recordingSender
+	s := newTestScheduler(t, filepath.Join(t.TempDir(), "scheduler.db"), &sender)
+	if err := s.SendAfter(addItem{ID: NewID(), SKU: "a"}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// RunDue blocks sending until the sender is unlocked
+	sender.mu.Lock()
+	ran := make(chan error)
+	go func() { ran <- s.RunDue() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.running.TryLock() {
+		s.running.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatal("RunDue didn't start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown while sending returned %v, want the deadline", err)
+	}
+	sender.mu.Unlock()
+	if err := <-ran; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := sender.skus(); len(got) != 1 {
+		t.Errorf("sent %q, want the due command", got)
+	}
+	if err := s.RunDue(); !errors.Is(err, errSchedulerClosed) {
+		t.Errorf("RunDue after Shutdown returned %v", err)
+	}
+	if err := s.Run(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Run after Shutdown returned %v, want nil", err)
+	}
+}
+
+func TestParallelDispatcherShutdown(t *testing.T) {
+	release := make(chan struct{})
+	d := NewParallelDispatcher(1, func(RecordedEvent, bool) error {
+		<-release
+		return nil
+	})
+	if err := d.Handle(RecordedEvent{AggregateID: NewID()}, false); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown with an event being handled returned %v, want the deadline", err)
+	}
+	close(release)
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,38 +1,217 @@
 package evoke
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"path"
 	"sync"
 )
 
 type simpleEventBus struct {
-	subscribers map[string][]EventHandler
-	mu          sync.RWMutex
+	subscribers map[string][]subscription
+	// matchers receive the events they match whatever their type
+	matchers []subscription
+	mu       sync.RWMutex
+	inst     Instrumentation
+	tracer   Tracer
+	logger   Logger
+	// deadLetters receives the failures of DeadLetter subscriptions
+	deadLetters DeadLetterQueue
 }
 
 func NewEventBus() *simpleEventBus {
 	return &simpleEventBus{
-		subscribers: make(map[string][]EventHandler),
+		subscribers: make(map[string][]subscription),
+		inst:        nopInstrumentation{},
+		tracer:      nopTracer{},
+		logger:      slog.Default(),
 	}
 }
 
-func (b *simpleEventBus) Subscribe(evt Event, handler EventHandler) {
+type subscription struct {
+	// match is nil for subscriptions by type
+	match   func(RecordedEvent) bool
+	where   []func(RecordedEvent) bool
+	handler EventHandler
+	policy  ErrorPolicy
+	// reaction subscriptions are skipped during replays
+	reaction bool
+	limit    *rateLimiter
+	// buffer is the queue size of a Buffered subscription, which lane
+	// hands events to
+	buffer int
+	lane   *lane
+}
+
+// matches reports whether rec passes the subscription's match and
+// predicates, and isn't a replay the subscription skips
+func (s subscription) matches(rec RecordedEvent, replay bool) bool {
+	if s.reaction && replay {
+		return false
+	}
+	if s.match != nil && !s.match(rec) {
+		return false
+	}
+	for _, pred := range s.where {
+		if !pred(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+// SubscribeOption refines a subscription.
+type SubscribeOption func(*subscription)
+
+// Where only hands the subscriber events for which pred returns true, such
+// as Where(InCategory("Order").Matches). Predicates run before dispatch, so
+// skipped events are never handled or traced.
+func Where(pred func(rec RecordedEvent) bool) SubscribeOption {
+	return func(s *subscription) {
+		s.where = append(s.where, pred)
+	}
+}
+
+func newSubscription(match func(RecordedEvent) bool, handler EventHandler, opts []SubscribeOption) subscription {
+	s := subscription{match: match, handler: handler}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if s.buffer > 0 {
+		s.lane = newLane(s.buffer, s.limit)
+	}
+	return s
+}
+
+func (b *simpleEventBus) Subscribe(evt Event, handler EventHandler, opts ...SubscribeOption) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[TypeName(evt)] = append(b.subscribers[TypeName(evt)], newSubscription(nil, handler, opts))
+}
+
+// SubscribeProjection subscribes a handler that builds state from events, such
+// as a read model, and so receives replayed events as well as new ones. It is
+// the same as Subscribe, naming the intent.
+func (b *simpleEventBus) SubscribeProjection(evt Event, handler EventHandler, opts ...SubscribeOption) {
+	b.Subscribe(evt, handler, opts...)
+}
+
+// SubscribeReaction subscribes a handler with side effects outside the
+// system, such as sending email or calling webhooks, which must only happen
+// once: it receives new events but never replayed ones, so rebuilding
+// projections can't fire it again.
+func (b *simpleEventBus) SubscribeReaction(evt Event, handler EventHandler, opts ...SubscribeOption) {
+	b.Subscribe(evt, handler, append(opts, func(s *subscription) { s.reaction = true })...)
+}
+
+// Subscriber is implemented by event buses taking subscriptions by event
+// type.
+type Subscriber interface {
+	Subscribe(evt Event, handler EventHandler, opts ...SubscribeOption)
+}
+
+// On subscribes fn to events of type T, handing it each event as a T along
+// with the recorded event it came in, for its sequence, stream and
+// metadata:
+//
+//	evoke.On(bus, func(e OrderPlaced, rec evoke.RecordedEvent, replay bool) error {
+//		...
+//	})
+func On[T Event](bus Subscriber, fn func(e T, rec RecordedEvent, replay bool) error, opts ...SubscribeOption) {
+	var evt T
+	bus.Subscribe(evt, onHandler[T](fn), opts...)
+}
+
+// onHandler is the handler of a subscription made with On
+type onHandler[T Event] func(e T, rec RecordedEvent, replay bool) error
+
+func (f onHandler[T]) Handle(e Event, replay bool) error {
+	return f.HandleRecorded(context.Background(), RecordedEvent{Event: e, EventType: TypeName(e)}, replay)
+}
+
+func (f onHandler[T]) HandleRecorded(_ context.Context, rec RecordedEvent, replay bool) error {
+	e, ok := asType[T](rec.Event)
+	if !ok {
+		var want T
+		return fmt.Errorf("On: handler for %T got %T", want, rec.Event)
+	}
+	return f(e, rec, replay)
+}
+
+// SubscribeAll subscribes handler to every event, for audit logs and
+// generic projections.
+func (b *simpleEventBus) SubscribeAll(handler EventHandler, opts ...SubscribeOption) {
+	b.subscribeMatching(func(RecordedEvent) bool { return true }, handler, opts)
+}
+
+// SubscribePattern subscribes handler to events whose type name or stored
+// name matches pattern, using the syntax of path.Match: "Order*" matches
+// OrderPlaced and OrderShipped. A malformed pattern panics.
+func (b *simpleEventBus) SubscribePattern(pattern string, handler EventHandler, opts ...SubscribeOption) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic("evoke: bad subscription pattern " + pattern + ": " + err.Error())
+	}
+	b.subscribeMatching(func(rec RecordedEvent) bool {
+		ok, _ := path.Match(pattern, TypeName(rec.Event))
+		if !ok && rec.EventType != "" {
+			ok, _ = path.Match(pattern, rec.EventType)
+		}
+		return ok
+	}, handler, opts)
+}
+
+func (b *simpleEventBus) subscribeMatching(match func(RecordedEvent) bool, handler EventHandler, opts []SubscribeOption) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.subscribers[TypeName(evt)] = append(b.subscribers[TypeName(evt)], handler)
+	b.matchers = append(b.matchers, newSubscription(match, handler, opts))
 }
 
+// Publish hands an event to the handlers subscribed to its type, then to
+// those whose subscriptions match it, in the order they subscribed. A
+// handler that panics fails like one returning an error, and what follows a
+// failure depends on the subscription's ErrorPolicy.
 func (b *simpleEventBus) Publish(evt RecordedEvent, replay bool) error {
 	b.mu.RLock()
-	handlers, ok := b.subscribers[TypeName(evt.Event)]
+	subs := b.subscribers[TypeName(evt.Event)]
+	matchers := b.matchers
+	inst, tracer, logger, dlq := b.inst, b.tracer, b.logger, b.deadLetters
 	b.mu.RUnlock()
-	if !ok {
-		fmt.Printf("WARN: simpleEventBus.Publish: no subscriptions on %T\n", evt.Event)
+	// events only Where predicates turned away were still subscribed to
+	subscribed := len(subs) > 0
+	var matched []subscription
+	for _, sub := range subs {
+		if sub.matches(evt, replay) {
+			matched = append(matched, sub)
+		}
+	}
+	for _, sub := range matchers {
+		if sub.match(evt) {
+			subscribed = true
+		}
+		if sub.matches(evt, replay) {
+			matched = append(matched, sub)
+		}
+	}
+	if !subscribed {
+		logger.Warn("evoke: no subscriptions for event", "event_type", TypeName(evt.Event), "sequence", evt.Sequence)
 		return nil
 	}
-	for _, h := range handlers {
-		err := h.Handle(evt.Event, replay)
-		if err != nil {
+	ctx := tracer.Extract(context.Background(), evt.Metadata)
+	for _, sub := range matched {
+		if sub.lane != nil {
+			err := sub.lane.enqueue(func() {
+				if err := b.dispatch(ctx, sub, evt, replay, tracer, inst, logger, dlq); err != nil {
+					logger.Error("evoke: buffered event handler failed", "event_type", TypeName(evt.Event), "sequence", evt.Sequence, "error", err)
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("publish event %d: %w", evt.Sequence, err)
+			}
+			continue
+		}
+		sub.limit.wait()
+		if err := b.dispatch(ctx, sub, evt, replay, tracer, inst, logger, dlq); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,142 @@
+package evoke
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+// handlerLog records, in order, which handler got which event type
+type handlerLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *handlerLog) handler(name string) EventHandler {
+	return loggingHandler{name, l}
+}
+
+func (l *handlerLog) handled() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+type loggingHandler struct {
+	name string
+	log  *handlerLog
+}
+
+func (h loggingHandler) Handle(e Event, replay bool) error {
+	h.log.mu.Lock()
+	defer h.log.mu.Unlock()
+	h.log.entries = append(h.log.entries, h.name+" "+TypeName(e))
+	return nil
+}
+
+func TestSubscribeAllAndPattern(t *testing.T) {
+	var log handlerLog
+	bus := NewEventBus()
+	bus.SubscribeAll(log.handler("all"))
+	bus.SubscribePattern("item*", log.handler("items"))
+	bus.SubscribePattern("cart.*", log.handler("stored"))
+	bus.Subscribe(itemRemoved{}, log.handler("removed"))
+
+	for _, rec := range []RecordedEvent{
+		{Event: itemAdded{}, EventType: "cart.item_added"},
+		{Event: itemRemoved{}},
+		{Event: quantityChanged{}},
+	} {
+		if err := bus.Publish(rec, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"all itemAdded", "items itemAdded", "stored itemAdded",
+		"removed itemRemoved", "all itemRemoved", "items itemRemoved",
+		"all quantityChanged",
+	}
+	if got := log.handled(); !slices.Equal(got, want) {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+}
+
+func TestSubscribePatternMalformed(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("subscribing with a malformed pattern didn't panic")
+		}
+	}()
+	NewEventBus().SubscribePattern("item[", (&handlerLog{}).handler("x"))
+}
+
+func TestSubscribeWhere(t *testing.T) {
+	var log handlerLog
+	var logger recordingLogger
+	bus := NewEventBus()
+	bus.SetLogger(&logger)
+	carts := Where(InCategory("cart").Matches)
+	bus.Subscribe(itemAdded{}, log.handler("cart adds"), carts)
+	bus.SubscribeAll(log.handler("big carts"), carts, Where(func(rec RecordedEvent) bool { return rec.Version > 1 }))
+
+	for _, rec := range []RecordedEvent{
+		{Event: itemAdded{}, AggregateType: "cart", Version: 1},
+		{Event: itemAdded{}, AggregateType: "wishlist", Version: 2},
+		{Event: itemRemoved{}, AggregateType: "cart", Version: 2},
+	} {
+		if err := bus.Publish(rec, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"cart adds itemAdded", "big carts itemRemoved"}
+	if got := log.handled(); !slices.Equal(got, want) {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+	if got := logger.logged(); len(got) != 0 {
+		t.Errorf("logged %q for events subscribers turned away", got)
+	}
+}
+
+// Reactions get new events only; projections get replays too, including
+// those of ReplayFrom.
+func TestSubscribeReaction(t *testing.T) {
+	s := newTestStore(t)
+	var log handlerLog
+	bus := NewEventBus()
+	bus.SetLogger(&recordingLogger{})
+	bus.SubscribeProjection(itemAdded{}, log.handler("projection"))
+	bus.SubscribeReaction(itemAdded{}, log.handler("reaction"))
+	s.RegisterPublisher(bus)
+
+	if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReplayFrom(1, bus.Publish); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"projection itemAdded", "reaction itemAdded", "projection itemAdded"}
+	if got := log.handled(); !slices.Equal(got, want) {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+}
+
+func TestOn(t *testing.T) {
+	s := newTestStore(t)
+	bus := NewEventBus()
+	s.RegisterPublisher(bus)
+	var got []RecordedEvent
+	On(bus, func(e itemAdded, rec RecordedEvent, replay bool) error {
+		if e != rec.Event || replay {
+			t.Errorf("handled %#v of %+v, replay %v", e, rec, replay)
+		}
+		got = append(got, rec)
+		return nil
+	})
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemRemoved{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Sequence != 3 || got[1].AggregateID != id || got[1].Version != 3 {
+		t.Errorf("handled %+v, want the recorded envelopes of both items added", got)
+	}
+}
@@ -3,7 +3,9 @@ package evoke
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -25,13 +27,31 @@ func NewSimpleStore(bus EventBus) *simpleStore {
 	}
 }
 
-func (s *simpleStore) RegisterPublisher(publisher RecordedEventPublisher) {
-	s.publishers = append(s.publishers, publisher)
+func (s *simpleStore) RegisterPublisher(publisher RecordedEventPublisher, filters ...EventFilter) {
+	s.publishers = append(s.publishers, FilterPublisher(publisher, filters...))
 }
 
-// Return all events, not really a long term method here
+// Return all events
+//
+// Deprecated: use ReadAll, which pages
 func (s *simpleStore) DebugEvents() ([]RecordedEvent, error) {
-	return s.events, nil
+	return s.ReadAll(0, 0)
+}
+
+func (s *simpleStore) ReadAll(fromSeq int64, limit int) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RecordedEvent, 0)
+	for _, rec := range s.events {
+		if rec.Sequence < fromSeq {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, rec)
+	}
+	return out, nil
 }
 
 func (s *simpleStore) appendEvents(aggregateID uuid.UUID, evs []Event) ([]RecordedEvent, error) {
@@ -46,9 +66,10 @@ func (s *simpleStore) appendEvents(aggregateID uuid.UUID, evs []Event) ([]Record
 	for _, e := range evs {
 		rec := RecordedEvent{
 			Sequence:    s.nextSequence,
+			Version:     int64(len(s.streams[aggregateID]) + 1),
 			AggregateID: aggregateID,
 			Event:       e,
-			//Timestamp:   time.Now(),
+			RecordedAt:  time.Now().Unix(),
 		}
 		s.nextSequence++
 
@@ -79,6 +100,28 @@ func (s *simpleStore) Record(aggregateID uuid.UUID, evs []Event) error {
 	return nil
 }
 
+func (s *simpleStore) MustRecord(aggregateID uuid.UUID, evs []Event) {
+	if err := s.Record(aggregateID, evs); err != nil {
+		panic(err)
+	}
+}
+
+func (s *simpleStore) ReplayFrom(seq int64, handler RecordedEventHandlerFunc, filters ...EventFilter) error {
+	recs, err := s.ReadAll(seq, 0)
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if !MatchesAll(filters, rec) {
+			continue
+		}
+		if err := handler(rec, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *simpleStore) LoadStream(aggregateID uuid.UUID) ([]RecordedEvent, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -88,6 +131,89 @@ func (s *simpleStore) LoadStream(aggregateID uuid.UUID) ([]RecordedEvent, error)
 	return cpy, nil
 }
 
+func (s *simpleStore) LoadStreams(ids []uuid.UUID) (map[uuid.UUID][]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	streams := make(map[uuid.UUID][]RecordedEvent, len(ids))
+	for _, id := range ids {
+		if stream := s.streams[id]; len(stream) > 0 {
+			streams[id] = slices.Clone(stream)
+		}
+	}
+	return streams, nil
+}
+
+func (s *simpleStore) LoadStreamFrom(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pageStream(s.streams[aggregateID], fromVersion, limit), nil
+}
+
+func (s *simpleStore) LoadStreamBackward(aggregateID uuid.UUID, fromVersion int64, limit int) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream := s.streams[aggregateID]
+	return pageBackward(stream, func(rec RecordedEvent) bool {
+		return fromVersion <= 0 || rec.Version <= fromVersion
+	}, limit), nil
+}
+
+func (s *simpleStore) ReadByType(eventType string, fromSeq int64, limit int) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filter := EventFilter{EventTypes: []string{eventType}}
+	out := make([]RecordedEvent, 0)
+	for _, rec := range s.events {
+		if rec.Sequence < fromSeq || !filter.Matches(rec) {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *simpleStore) ReadAllBackward(fromSeq int64, limit int) ([]RecordedEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pageBackward(s.events, func(rec RecordedEvent) bool {
+		return fromSeq <= 0 || rec.Sequence <= fromSeq
+	}, limit), nil
+}
+
+// pageBackward copies up to limit events of recs, newest first, starting
+// with the newest one that matches from
+func pageBackward(recs []RecordedEvent, from func(RecordedEvent) bool, limit int) []RecordedEvent {
+	out := make([]RecordedEvent, 0)
+	for i := len(recs) - 1; i >= 0; i-- {
+		if !from(recs[i]) {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, recs[i])
+	}
+	return out
+}
+
+// pageStream copies up to limit events of stream starting at fromVersion
+func pageStream(stream []RecordedEvent, fromVersion int64, limit int) []RecordedEvent {
+	out := make([]RecordedEvent, 0)
+	for _, rec := range stream {
+		if rec.Version < fromVersion {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
 func (s *simpleStore) TailFrom(seq int64, callback func(RecordedEvent) error) error {
 	s.mu.Lock()
 	start := seq
@@ -0,0 +1,264 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Snapshot is the state of an aggregate at a version of its stream, saved
+// so hydrating it only has to apply the events recorded since.
+type Snapshot struct {
+	AggregateID   uuid.UUID
+	AggregateType string
+	Version       int64
+	// State is the aggregate's state as its MarshalSnapshot returned it
+	State   []byte
+	TakenAt time.Time
+}
+
+// SnapshotStore keeps the latest snapshot of each aggregate.
+type SnapshotStore interface {
+	// SaveSnapshot saves snap unless a snapshot of a later version of the
+	// aggregate is already saved
+	SaveSnapshot(snap Snapshot) error
+	// LoadSnapshot returns the latest snapshot of an aggregate, false if it
+	// has none
+	LoadSnapshot(aggregateID uuid.UUID) (Snapshot, bool, error)
+}
+
+// SnapshotAggregate is an aggregate whose state can be snapshotted.
+// AggregateBase implements it by serializing its State as JSON, so only its
+// exported fields are kept.
+type SnapshotAggregate interface {
+	Aggregate
+	MarshalSnapshot() ([]byte, error)
+	// UnmarshalSnapshot restores the state MarshalSnapshot returned, as of
+	// version
+	UnmarshalSnapshot(data []byte, version int64) error
+}
+
+// SnapshotInfo describes how an aggregate was hydrated, for a
+// SnapshotPolicy to decide whether to snapshot it.
+type SnapshotInfo struct {
+	AggregateID uuid.UUID
+	Aggregate   Aggregate
+	// Version is the version the aggregate is at, which a snapshot would
+	// be taken at
+	Version int64
+	// Snapshot is the latest snapshot of the aggregate, without its State;
+	// zero if it has none
+	Snapshot Snapshot
+	// Replayed are the events hydration applied after the snapshot, or
+	// after the version a cached aggregate was at
+	Replayed []RecordedEvent
+	// HydrationTime is how long loading and applying took
+	HydrationTime time.Duration
+}
+
+// SnapshotPolicy decides when aggregates are snapshotted. It is consulted
+// whenever an aggregate is at a version later than its latest snapshot.
+type SnapshotPolicy interface {
+	ShouldSnapshot(info SnapshotInfo) bool
+}
+
+// SnapshotPolicyFunc is a SnapshotPolicy as a function, for example one
+// choosing between policies by the type of the aggregate.
+type SnapshotPolicyFunc func(info SnapshotInfo) bool
+
+func (f SnapshotPolicyFunc) ShouldSnapshot(info SnapshotInfo) bool {
+	return f(info)
+}
+
+// SnapshotEvery snapshots an aggregate once n events were recorded since
+// its latest snapshot.
+func SnapshotEvery(n int64) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(info SnapshotInfo) bool {
+		return info.Version-info.Snapshot.Version >= n
+	})
+}
+
+// SnapshotInterval snapshots an aggregate at most every d, and right away
+// if it has no snapshot yet.
+func SnapshotInterval(d time.Duration) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(info SnapshotInfo) bool {
+		return info.Snapshot.TakenAt.IsZero() || time.Since(info.Snapshot.TakenAt) >= d
+	})
+}
+
+// SnapshotSize snapshots an aggregate once the events hydration replayed
+// after its latest snapshot add up to bytes or more, serialized as JSON,
+// so aggregates with large events are snapshotted sooner than those with
+// small ones.
+func SnapshotSize(bytes int) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(info SnapshotInfo) bool {
+		size := 0
+		for _, rec := range info.Replayed {
+			b, err := json.Marshal(rec.Event)
+			if err != nil {
+				continue
+			}
+			size += len(b)
+			if size >= bytes {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// SnapshotAdaptive snapshots an aggregate once hydrating it took target or
+// longer, however many events that was, so aggregates that are slow to
+// load or apply are snapshotted soonest.
+func SnapshotAdaptive(target time.Duration) SnapshotPolicy {
+	return SnapshotPolicyFunc(func(info SnapshotInfo) bool {
+		return info.HydrationTime >= target
+	})
+}
+
+// WithSnapshots hydrates aggregates from their latest snapshot in store and
+// the events recorded since, snapshotting them after a command as policy
+// decides. Aggregates that aren't a SnapshotAggregate are hydrated from
+// their events alone, and only stores checking the expected version, a
+// VersionedRecorder, get snapshots taken.
+//
+// A snapshot that can't be restored, say after the aggregate's state changed
+// shape, is ignored: the aggregate is hydrated from its events, and
+// snapshotted afresh. One that fails to save is logged and skipped.
+func WithSnapshots(store SnapshotStore, policy SnapshotPolicy) AggregateHandlerOption {
+	return func(h *AggregateHandler) {
+		h.snapshots = &snapshots{store: store, policy: policy}
+	}
+}
+
+// SetSnapshots has the repository hydrate aggregates from their latest
+// snapshot in store and the events recorded since, as WithSnapshots does
+// for an AggregateHandler. Aggregates are snapshotted as they are loaded,
+// as policy decides. Set it before the repository is used.
+func (r *Repository[T]) SetSnapshots(store SnapshotStore, policy SnapshotPolicy) {
+	r.snapshots = &snapshots{store: store, policy: policy}
+}
+
+type snapshots struct {
+	store  SnapshotStore
+	policy SnapshotPolicy
+}
+
+// hydrateAggregate creates an aggregate with factory and applies its stream
+// to it, from its latest snapshot on if sn is set
+func hydrateAggregate(store EventStore, sn *snapshots, factory func() Aggregate, id uuid.UUID) (SnapshotInfo, error) {
+	start := time.Now()
+	info := SnapshotInfo{AggregateID: id, Aggregate: factory()}
+	if sn != nil {
+		snap, ok, err := sn.restore(info.Aggregate, id)
+		if err != nil {
+			return info, err
+		}
+		if ok {
+			info.Snapshot, info.Version = snap, snap.Version
+		} else {
+			info.Aggregate = factory()
+		}
+	}
+
+	recs, err := loadStreamAfter(store, id, info.Version)
+	if err != nil {
+		return info, err
+	}
+	if info.Version > 0 && len(recs) > 0 && recs[0].Version == 0 {
+		// the store doesn't number versions, so which of its events the
+		// snapshot covers isn't known
+		info = SnapshotInfo{AggregateID: id, Aggregate: factory()}
+	}
+	info.Version, err = hydrate(info.Aggregate, recs, info.Version)
+	if err != nil {
+		return info, err
+	}
+	for len(recs) > 0 && recs[0].Version != 0 && recs[0].Version <= info.Snapshot.Version {
+		recs = recs[1:]
+	}
+	info.Replayed = recs
+	info.HydrationTime = time.Since(start)
+	return info, nil
+}
+
+// restore restores agg from its latest snapshot, returning the snapshot
+// without its state, or a zero one if there is none. It returns false if
+// agg can't be restored from the snapshot, in which case agg may be left
+// half restored.
+func (sn *snapshots) restore(agg Aggregate, id uuid.UUID) (Snapshot, bool, error) {
+	sa, ok := agg.(SnapshotAggregate)
+	if !ok {
+		return Snapshot{}, true, nil
+	}
+	snap, ok, err := sn.store.LoadSnapshot(id)
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("LoadSnapshot(%s): %w", id, err)
+	}
+	if !ok {
+		return Snapshot{}, true, nil
+	}
+	if err := sa.UnmarshalSnapshot(snap.State, snap.Version); err != nil {
+		return Snapshot{}, false, nil
+	}
+	snap.State = nil
+	return snap, true, nil
+}
+
+// take snapshots the aggregate info describes if the policy says to,
+// returning its latest snapshot without its state
+func (sn *snapshots) take(info SnapshotInfo, logger Logger) Snapshot {
+	sa, ok := info.Aggregate.(SnapshotAggregate)
+	if !ok || info.Version <= info.Snapshot.Version || !sn.policy.ShouldSnapshot(info) {
+		return info.Snapshot
+	}
+	state, err := sa.MarshalSnapshot()
+	if err == nil {
+		snap := Snapshot{
+			AggregateID:   info.AggregateID,
+			AggregateType: TypeName(info.Aggregate),
+			Version:       info.Version,
+			State:         state,
+			TakenAt:       time.Now(),
+		}
+		if err = sn.store.SaveSnapshot(snap); err == nil {
+			snap.State = nil
+			return snap
+		}
+	}
+	logger.Warn("evoke: snapshot failed", "aggregate_type", TypeName(info.Aggregate), "aggregate_id", info.AggregateID, "version", info.Version, "error", err)
+	return info.Snapshot
+}
+
+type memorySnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[uuid.UUID]Snapshot
+}
+
+// NewMemorySnapshotStore returns a SnapshotStore keeping snapshots in
+// memory, for tests and for processes that hydrate the same aggregates
+// many times over.
+func NewMemorySnapshotStore() *memorySnapshotStore {
+	return &memorySnapshotStore{snapshots: map[uuid.UUID]Snapshot{}}
+}
+
+func (m *memorySnapshotStore) SaveSnapshot(snap Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := m.snapshots[snap.AggregateID]; ok && prev.Version > snap.Version {
+		return nil
+	}
+	snap.State = append([]byte(nil), snap.State...)
+	m.snapshots[snap.AggregateID] = snap
+	return nil
+}
+
+func (m *memorySnapshotStore) LoadSnapshot(aggregateID uuid.UUID) (Snapshot, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap, ok := m.snapshots[aggregateID]
+	return snap, ok, nil
+}
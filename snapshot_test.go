@@ -0,0 +1,202 @@
+package evoke
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSnapshotPolicies(t *testing.T) {
+	big := []RecordedEvent{{Event: itemAdded{SKU: "a long sku of an item"}}}
+	small := []RecordedEvent{{Event: itemAdded{SKU: "a"}}}
+	tests := []struct {
+		name   string
+		policy SnapshotPolicy
+		info   SnapshotInfo
+		want   bool
+	}{
+		{"every, too soon", SnapshotEvery(3), SnapshotInfo{Version: 5, Snapshot: Snapshot{Version: 3}}, false},
+		{"every, due", SnapshotEvery(3), SnapshotInfo{Version: 6, Snapshot: Snapshot{Version: 3}}, true},
+		{"interval, first", SnapshotInterval(time.Hour), SnapshotInfo{Version: 1}, true},
+		{"interval, too soon", SnapshotInterval(time.Hour), SnapshotInfo{Version: 2, Snapshot: Snapshot{Version: 1, TakenAt: time.Now()}}, false},
+		{"interval, due", SnapshotInterval(time.Hour), SnapshotInfo{Version: 2, Snapshot: Snapshot{Version: 1, TakenAt: time.Now().Add(-2 * time.Hour)}}, true},
+		{"size, small", SnapshotSize(30), SnapshotInfo{Version: 1, Replayed: small}, false},
+		{"size, large", SnapshotSize(30), SnapshotInfo{Version: 1, Replayed: big}, true},
+		{"adaptive, fast", SnapshotAdaptive(time.Second), SnapshotInfo{Version: 1, HydrationTime: time.Millisecond}, false},
+		{"adaptive, slow", SnapshotAdaptive(time.Second), SnapshotInfo{Version: 1, HydrationTime: 2 * time.Second}, true},
+		{"func", SnapshotPolicyFunc(func(info SnapshotInfo) bool { return info.Version == 7 }), SnapshotInfo{Version: 7}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.ShouldSnapshot(tt.info); got != tt.want {
+			t.Errorf("%s: ShouldSnapshot %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMemorySnapshotStore(t *testing.T) {
+	m := NewMemorySnapshotStore()
+	id := NewID()
+	if _, ok, err := m.LoadSnapshot(id); ok || err != nil {
+		t.Fatalf("loaded a snapshot of a new aggregate: %v, %v", ok, err)
+	}
+	state := []byte(`{"Items":2}`)
+	if err := m.SaveSnapshot(Snapshot{AggregateID: id, Version: 2, State: state}); err != nil {
+		t.Fatal(err)
+	}
+	state[0] = 'x'
+	if err := m.SaveSnapshot(Snapshot{AggregateID: id, Version: 1, State: []byte(`{"Items":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+	snap, ok, err := m.LoadSnapshot(id)
+	if err != nil || !ok || snap.Version != 2 || string(snap.State) != `{"Items":2}` {
+		t.Errorf("loaded %+v, %v, %v, want the later snapshot as saved", snap, ok, err)
+	}
+}
+
+// failingSnapshotStore fails to save snapshots
+type failingSnapshotStore struct{ SnapshotStore }
+
+func (failingSnapshotStore) SaveSnapshot(Snapshot) error { return errors.New("disk full") }
+
+// sendItems sends n addItem commands for id to h
+func sendItems(t *testing.T, h *AggregateHandler, id uuid.UUID, n int) {
+	t.Helper()
+	for range n {
+		if err := h.Handle(addItem{ID: id, SKU: "a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// lastQty returns the quantity of the last event of the stream id
+func lastQty(t *testing.T, s EventStore, id uuid.UUID) int {
+	t.Helper()
+	recs := mustLoad(t, s, id)
+	return recs[len(recs)-1].Event.(itemAdded).Qty
+}
+
+func TestAggregateHandlerSnapshots(t *testing.T) {
+	for name, opts := range map[string][]AggregateHandlerOption{
+		"uncached": nil,
+		"cached":   {WithHydrationCache(10)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestStore(t)
+			snapshots := NewMemorySnapshotStore()
+			h := NewAggregateHandler(s, newCart, append(opts, WithSnapshots(snapshots, SnapshotEvery(2)))...)
+			id := NewID()
+
+			var versions []int64
+			for range 4 {
+				sendItems(t, h, id, 1)
+				snap, _, _ := snapshots.LoadSnapshot(id)
+				versions = append(versions, snap.Version)
+			}
+			if !slices.Equal(versions, []int64{0, 2, 2, 4}) {
+				t.Errorf("snapshot at versions %v after each command, want one every 2 events", versions)
+			}
+			snap, _, _ := snapshots.LoadSnapshot(id)
+			if snap.AggregateType != "cart" || string(snap.State) != `{"Items":4}` || snap.TakenAt.IsZero() {
+				t.Errorf("snapshot %+v", snap)
+			}
+		})
+	}
+}
+
+// An aggregate is hydrated from its snapshot and the events after it, or
+// from its events alone if the snapshot can't be restored.
+func TestAggregateHandlerHydratesFromSnapshot(t *testing.T) {
+	s := newTestStore(t)
+	snapshots := NewMemorySnapshotStore()
+	h := NewAggregateHandler(s, newCart, WithSnapshots(snapshots, SnapshotEvery(2)))
+	id := NewID()
+	sendItems(t, h, id, 4)
+
+	snapshots.SaveSnapshot(Snapshot{AggregateID: id, Version: 4, State: []byte(`{"Items":100}`)})
+	sendItems(t, h, id, 1)
+	if qty := lastQty(t, s, id); qty != 101 {
+		t.Errorf("raised quantity %d, want the snapshot's 100 items and one more", qty)
+	}
+
+	snapshots.SaveSnapshot(Snapshot{AggregateID: id, Version: 5, State: []byte("not json")})
+	sendItems(t, h, id, 1)
+	if qty := lastQty(t, s, id); qty != 6 {
+		t.Errorf("raised quantity %d past a broken snapshot, want the 5 items of the stream and one more", qty)
+	}
+	if snap, _, _ := snapshots.LoadSnapshot(id); snap.Version != 6 || string(snap.State) != `{"Items":6}` {
+		t.Errorf("snapshot %+v, want a fresh one replacing the broken one", snap)
+	}
+}
+
+func TestAggregateHandlerSnapshotFails(t *testing.T) {
+	s := newTestStore(t)
+	h := NewAggregateHandler(s, newCart, WithSnapshots(failingSnapshotStore{NewMemorySnapshotStore()}, SnapshotEvery(1)))
+	var logger recordingLogger
+	h.logger = &logger
+	id := NewID()
+	sendItems(t, h, id, 2)
+	if qty := lastQty(t, s, id); qty != 2 {
+		t.Errorf("raised quantity %d, want commands handled despite the snapshot failing", qty)
+	}
+	if got := logger.logged(); !slices.Contains(got, "warn: evoke: snapshot failed") {
+		t.Errorf("logged %q, want the failed snapshot", got)
+	}
+}
+
+// Without a version check no snapshots are taken, since events recorded in
+// between would be missed.
+func TestAggregateHandlerSnapshotsNeedVersions(t *testing.T) {
+	s := NewSimpleStore(nil)
+	snapshots := NewMemorySnapshotStore()
+	h := NewAggregateHandler(s, newCart, WithSnapshots(snapshots, SnapshotEvery(1)))
+	id := NewID()
+	sendItems(t, h, id, 2)
+	if _, ok, _ := snapshots.LoadSnapshot(id); ok {
+		t.Error("snapshotted an aggregate of a store without version checks")
+	}
+}
+
+func TestRepositorySnapshots(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	carts := NewRepository(s, newSavedCart)
+	snapshots := NewMemorySnapshotStore()
+	carts.SetSnapshots(snapshots, SnapshotEvery(3))
+	id := NewID()
+
+	c, err := carts.Load(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := c.Raise(itemAdded{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := carts.Save(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := snapshots.LoadSnapshot(id); ok {
+		t.Error("saving snapshotted the cart, want it snapshotted as loaded")
+	}
+	if _, err := carts.Load(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	snap, ok, _ := snapshots.LoadSnapshot(id)
+	if !ok || snap.Version != 3 || snap.AggregateType != "savedCart" {
+		t.Fatalf("snapshot %+v after loading, want one at version 3", snap)
+	}
+
+	snapshots.SaveSnapshot(Snapshot{AggregateID: id, Version: 3, State: []byte(`{"Items":100}`)})
+	c, err = carts.Load(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.State.Items != 100 || c.Version() != 3 {
+		t.Errorf("loaded %+v at version %d, want the snapshot's state", c.State, c.Version())
+	}
+}
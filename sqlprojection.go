@@ -0,0 +1,184 @@
+package evoke
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// events a SQLProjection applies per read-model transaction
+const projectionBatch = 500
+
+// SQLProjectionHandler applies an event to a read model within tx.
+type SQLProjectionHandler func(tx *sqlx.Tx, rec RecordedEvent) error
+
+// SQLProjection keeps a read model in a SQL database, such as SQLite or
+// Postgres, up to date with a store. The events of a batch are applied in a
+// transaction of the read-model database that also advances the
+// projection's checkpoint, kept in the evoke_projections table of the same
+// database, so each event's effects are committed exactly once: a failure
+// or crash rolls back both and the batch is applied again.
+//
+// Handlers must only write through tx; effects outside the read-model
+// database are not rolled back. Run one instance of a projection at a time.
+type SQLProjection struct {
+	name     string
+	db       *sqlx.DB
+	store    EventStore
+	handler  SQLProjectionHandler
+	filters  []EventFilter
+	interval time.Duration
+	logger   Logger
+	wake     chan struct{}
+}
+
+// NewSQLProjection returns the projection called name of the events of
+// store passing the filters into db, creating its checkpoint table if
+// needed. Queries are rebound to db's placeholder style.
+func NewSQLProjection(name string, db *sqlx.DB, store EventStore, handler SQLProjectionHandler, filters ...EventFilter) (*SQLProjection, error) {
+	if _, err := db.Exec(`
+		create table if not exists evoke_projections (
+			name     varchar(255) not null primary key,
+			sequence bigint not null
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("failed to create evoke_projections table: %w", err)
+	}
+	p := &SQLProjection{
+		name:     name,
+		db:       db,
+		store:    store,
+		handler:  handler,
+		filters:  filters,
+		interval: time.Second,
+		logger:   slog.Default(),
+		wake:     make(chan struct{}, 1),
+	}
+	store.RegisterPublisher(deliveryWaker{p.wake})
+	return p, nil
+}
+
+// SetInterval sets how often Run polls the store for events recorded by
+// other processes. The default is one second.
+func (p *SQLProjection) SetInterval(d time.Duration) {
+	p.interval = d
+}
+
+func (p *SQLProjection) SetLogger(logger Logger) {
+	p.logger = logger
+}
+
+// Position returns the sequence of the last event applied to the read
+// model, or 0.
+func (p *SQLProjection) Position() (int64, error) {
+	return p.position(p.db)
+}
+
+func (p *SQLProjection) position(q sqlx.Queryer) (int64, error) {
+	var seq int64
+	err := sqlx.Get(q, &seq, p.db.Rebind(`select sequence from evoke_projections where name = ?`), p.name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("select from evoke_projections: %w", err)
+	}
+	return seq, nil
+}
+
+// CatchUp applies every event recorded since the checkpoint, returning how
+// many were handed to the handler.
+func (p *SQLProjection) CatchUp() (int, error) {
+	n := 0
+	for {
+		applied, read, err := p.applyBatch()
+		n += applied
+		if err != nil || read < projectionBatch {
+			return n, err
+		}
+	}
+}
+
+// applyBatch applies the next batch of events in one transaction
+func (p *SQLProjection) applyBatch() (applied, read int, err error) {
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	seq, err := p.position(tx)
+	if err != nil {
+		return 0, 0, err
+	}
+	batch, err := p.store.ReadAll(seq+1, projectionBatch)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read store: %w", err)
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+	for _, rec := range batch {
+		if !MatchesAll(p.filters, rec) {
+			continue
+		}
+		if err := p.handler(tx, rec); err != nil {
+			return 0, 0, fmt.Errorf("apply event %d: %w", rec.Sequence, err)
+		}
+		applied++
+	}
+
+	last := batch[len(batch)-1].Sequence
+	_, err = tx.Exec(p.db.Rebind(`insert into evoke_projections(name, sequence) values(?, ?)
+		on conflict(name) do update set sequence = excluded.sequence`), p.name, last)
+	if err != nil {
+		return 0, 0, fmt.Errorf("save checkpoint: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit: %w", err)
+	}
+	return applied, len(batch), nil
+}
+
+// Rebuild empties the read model with reset, in the same transaction as
+// rewinding the checkpoint, then applies the whole log again.
+func (p *SQLProjection) Rebuild(reset func(tx *sqlx.Tx) error) (int, error) {
+	tx, err := p.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+	if err := reset(tx); err != nil {
+		return 0, fmt.Errorf("reset read model: %w", err)
+	}
+	if _, err := tx.Exec(p.db.Rebind(`delete from evoke_projections where name = ?`), p.name); err != nil {
+		return 0, fmt.Errorf("delete from evoke_projections: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return p.CatchUp()
+}
+
+// Run keeps the read model up to date until ctx is done. A failed batch is
+// logged and applied again at the next interval.
+func (p *SQLProjection) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.CatchUp(); err != nil {
+			p.logger.Warn("evoke: projection failed, will retry", "projection", p.name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package evoke
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// newReadModel opens a sqlite read model holding the added SKUs
+func newReadModel(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "read.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`create table items (sku text not null)`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func countItems(t *testing.T, db *sqlx.DB) int {
+	t.Helper()
+	var n int
+	if err := db.Get(&n, `select count(*) from items`); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSQLProjection(t *testing.T) {
+	s := newTestStore(t)
+	db := newReadModel(t)
+	fail := false
+	p, err := NewSQLProjection("items", db, s, func(tx *sqlx.Tx, rec RecordedEvent) error {
+		if _, err := tx.Exec(`insert into items(sku) values(?)`, rec.Event.(itemAdded).SKU); err != nil {
+			return err
+		}
+		if fail && rec.Event.(itemAdded).SKU == "c" {
+			return errors.New("boom")
+		}
+		return nil
+	}, OnlyEvents(itemAdded{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemRemoved{SKU: "a"}, itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := p.CatchUp(); err != nil || n != 2 {
+		t.Fatalf("CatchUp applied %d, %v, want the two matching events", n, err)
+	}
+	if seq, err := p.Position(); err != nil || seq != 3 {
+		t.Errorf("position %d, %v, want 3", seq, err)
+	}
+
+	// a failing batch leaves neither its rows nor its checkpoint behind
+	fail = true
+	if err := s.Record(id, []Event{itemAdded{SKU: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CatchUp(); err == nil {
+		t.Fatal("CatchUp returned no error when the handler failed")
+	}
+	if n := countItems(t, db); n != 2 {
+		t.Errorf("%d items after a failed batch, want 2", n)
+	}
+	if seq, _ := p.Position(); seq != 3 {
+		t.Errorf("position %d after a failed batch, want 3", seq)
+	}
+	fail = false
+	if n, err := p.CatchUp(); err != nil || n != 1 || countItems(t, db) != 3 {
+		t.Errorf("retried CatchUp applied %d, %v", n, err)
+	}
+
+	n, err := p.Rebuild(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`delete from items`)
+		return err
+	})
+	if err != nil || n != 3 || countItems(t, db) != 3 {
+		t.Errorf("Rebuild applied %d, %v; %d items", n, err, countItems(t, db))
+	}
+}
+
+func TestSQLProjectionsShareADatabase(t *testing.T) {
+	s := newTestStore(t)
+	db := newReadModel(t)
+	apply := func(tx *sqlx.Tx, rec RecordedEvent) error { return nil }
+	first, err := NewSQLProjection("first", db, s, apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewSQLProjection("second", db, s, apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(NewID(), []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.CatchUp(); err != nil {
+		t.Fatal(err)
+	}
+	if seq, err := second.Position(); err != nil || seq != 0 {
+		t.Errorf("second projection at %d, %v, want its own checkpoint", seq, err)
+	}
+}
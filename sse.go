@@ -0,0 +1,80 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type sseHandler struct {
+	feed *Feed
+}
+
+// NewSSEHandler returns an http.Handler that streams recorded events as
+// Server-Sent Events. Each message id is the event sequence, so a client
+// reconnecting with Last-Event-ID resumes right after the last event it saw.
+// Without a Last-Event-ID the stream starts at the "from" query parameter,
+// or at the beginning of the log.
+func NewSSEHandler(feed *Feed) http.Handler {
+	return &sseHandler{feed: feed}
+}
+
+type sseEvent struct {
+	Sequence    int64  `json:"sequence"`
+	RecordedAt  int64  `json:"recordedAt"`
+	AggregateID string `json:"aggregateId"`
+	EventType   string `json:"eventType"`
+	Event       Event  `json:"event"`
+}
+
+func (h *sseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	from := int64(1)
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		seq, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			http.Error(w, "bad Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		from = seq + 1
+	} else if q := r.URL.Query().Get("from"); q != "" {
+		seq, err := strconv.ParseInt(q, 10, 64)
+		if err != nil {
+			http.Error(w, "bad from", http.StatusBadRequest)
+			return
+		}
+		from = seq
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// the feed runs until the client goes away or a write fails
+	_ = h.feed.SubscribeFrom(r.Context(), from, func(rec RecordedEvent) error {
+		data, err := json.Marshal(sseEvent{
+			Sequence:    rec.Sequence,
+			RecordedAt:  rec.RecordedAt,
+			AggregateID: rec.AggregateID.String(),
+			EventType:   rec.EventType,
+			Event:       rec.Event,
+		})
+		if err != nil {
+			return fmt.Errorf("Marshal: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", rec.Sequence, rec.EventType, data)
+		if err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
@@ -0,0 +1,131 @@
+package evoke
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSSE reads n messages from an event stream, returning their ids and
+// decoded data
+func readSSE(t *testing.T, resp *http.Response, n int) ([]string, []sseEvent) {
+	t.Helper()
+	var ids []string
+	var events []sseEvent
+	sc := bufio.NewScanner(resp.Body)
+	for len(events) < n && sc.Scan() {
+		line := sc.Text()
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, id)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var e sseEvent
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				t.Fatalf("decode %q: %v", data, err)
+			}
+			events = append(events, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids, events
+}
+
+func TestSSEHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		lastID  string
+		status  int
+		wantIDs []string
+	}{
+		{name: "from the start", status: http.StatusOK, wantIDs: []string{"1", "2", "3"}},
+		{name: "from", query: "?from=2", status: http.StatusOK, wantIDs: []string{"2", "3"}},
+		{name: "resumed", lastID: "2", status: http.StatusOK, wantIDs: []string{"3"}},
+		{name: "resume wins over from", query: "?from=1", lastID: "1", status: http.StatusOK, wantIDs: []string{"2", "3"}},
+		{name: "bad from", query: "?from=x", status: http.StatusBadRequest},
+		{name: "bad Last-Event-ID", lastID: "x", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			id := NewID()
+			if err := s.Record(id, []Event{itemAdded{SKU: "a"}, itemAdded{SKU: "b"}, itemRemoved{SKU: "a"}}); err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewServer(NewSSEHandler(NewFeed(s)))
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.lastID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type %q", ct)
+			}
+
+			ids, events := readSSE(t, resp, len(tt.wantIDs))
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("streamed ids %v, want %v", ids, tt.wantIDs)
+			}
+			for _, e := range events {
+				if e.AggregateID != id.String() {
+					t.Errorf("event %d of aggregate %s, want %s", e.Sequence, e.AggregateID, id)
+				}
+			}
+		})
+	}
+}
+
+// Events recorded while a client is connected are streamed as they are
+// recorded.
+func TestSSEHandlerLive(t *testing.T) {
+	s := newTestStore(t)
+	srv := httptest.NewServer(NewSSEHandler(NewFeed(s)))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if err := s.Record(NewID(), []Event{itemAdded{SKU: "live"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, events := readSSE(t, resp, 1)
+	if len(events) != 1 || events[0].EventType != "itemAdded" {
+		t.Fatalf("streamed %+v, want the itemAdded recorded", events)
+	}
+	if e, _ := events[0].Event.(map[string]any); e["SKU"] != "live" {
+		t.Errorf("streamed event %v, want SKU live", events[0].Event)
+	}
+}
@@ -0,0 +1,116 @@
+package evoke
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// StreamInfo describes a stream without its events.
+type StreamInfo struct {
+	AggregateID   uuid.UUID
+	Exists        bool
+	AggregateType string
+	// Version is the version of the last event, 0 for a stream that
+	// doesn't exist
+	Version int64
+	// Events is the number of events in the stream; versions have no gaps,
+	// so it is the same as Version
+	Events int64
+	// FirstRecordedAt and LastRecordedAt are the timestamps of the oldest
+	// and latest events, 0 for a stream that doesn't exist
+	FirstRecordedAt int64
+	LastRecordedAt  int64
+	// Deleted and Tombstoned report streams hidden by DeleteStream and
+	// TombstoneStream, which still exist
+	Deleted    bool
+	Tombstoned bool
+}
+
+// StreamInspector is implemented by stores that can describe a stream
+// without loading it.
+type StreamInspector interface {
+	StreamInfo(aggregateID uuid.UUID) (StreamInfo, error)
+}
+
+// GetStreamInfo describes a stream of store, loading it if the store isn't
+// a StreamInspector.
+func GetStreamInfo(store EventStore, aggregateID uuid.UUID) (StreamInfo, error) {
+	if inspector, ok := store.(StreamInspector); ok {
+		return inspector.StreamInfo(aggregateID)
+	}
+	recs, err := store.LoadStream(aggregateID)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	return streamInfoOf(aggregateID, recs), nil
+}
+
+// streamInfoOf describes the stream made of recs
+func streamInfoOf(aggregateID uuid.UUID, recs []RecordedEvent) StreamInfo {
+	info := StreamInfo{AggregateID: aggregateID}
+	if len(recs) == 0 {
+		return info
+	}
+	first, last := recs[0], recs[len(recs)-1]
+	info.Exists = true
+	info.AggregateType = last.AggregateType
+	info.Version = last.Version
+	info.Events = last.Version
+	info.FirstRecordedAt = first.RecordedAt
+	info.LastRecordedAt = last.RecordedAt
+	return info
+}
+
+// StreamInfo describes a stream from its indexed columns. The timestamps of
+// a tiered stream are those of its events still in the database.
+func (s *fileStore) StreamInfo(aggregateID uuid.UUID) (StreamInfo, error) {
+	return s.streamInfo("", aggregateID)
+}
+
+func (t *tenantStore) StreamInfo(aggregateID uuid.UUID) (StreamInfo, error) {
+	return t.store.streamInfo(t.tenantID, aggregateID)
+}
+
+func (s *fileStore) streamInfo(tenantID string, aggregateID uuid.UUID) (StreamInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := StreamInfo{AggregateID: aggregateID}
+	var head struct {
+		Version       int64  `db:"version"`
+		AggregateType string `db:"aggregate_type"`
+	}
+	if err := s.db.Get(&head, s.streamVersionQuery(), tenantID, aggregateID.String()); err != nil {
+		return info, fmt.Errorf("select stream version: %w", err)
+	}
+	if head.Version == 0 {
+		return info, nil
+	}
+	info.Exists = true
+	info.AggregateType = head.AggregateType
+	info.Version = head.Version
+	info.Events = head.Version
+
+	var times struct {
+		First int64 `db:"first"`
+		Last  int64 `db:"last"`
+	}
+	err := s.db.Get(&times, `select coalesce(min(recorded_at), 0) as first, coalesce(max(recorded_at), 0) as last
+		from `+s.eventsSource+` where tenant_id = ? and aggregate_id = ?`, tenantID, aggregateID.String())
+	if err != nil {
+		return info, fmt.Errorf("select stream timestamps: %w", err)
+	}
+	info.FirstRecordedAt, info.LastRecordedAt = times.First, times.Last
+
+	switch err := s.checkStreamWritable(s.db, tenantID, aggregateID); {
+	case errors.Is(err, ErrStreamDeleted):
+		info.Deleted = true
+	case errors.Is(err, ErrStreamTombstoned):
+		info.Tombstoned = true
+	case err != nil:
+		return info, err
+	}
+	return info, nil
+}
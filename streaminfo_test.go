@@ -0,0 +1,71 @@
+package evoke
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetStreamInfo(t *testing.T) {
+	for name, s := range eventStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id := NewID()
+			if info, err := GetStreamInfo(s, id); err != nil || info != (StreamInfo{AggregateID: id}) {
+				t.Errorf("info of a missing stream %+v, %v", info, err)
+			}
+			if rec, ok := s.(AggregateRecorder); ok {
+				if err := rec.RecordAs(context.Background(), "cart", id, []Event{itemAdded{}}); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := s.Record(id, []Event{itemAdded{}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Record(id, []Event{itemAdded{}, itemRemoved{}}); err != nil {
+				t.Fatal(err)
+			}
+			recs := mustLoad(t, s, id)
+
+			info, err := GetStreamInfo(s, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := StreamInfo{
+				AggregateID:     id,
+				Exists:          true,
+				AggregateType:   recs[2].AggregateType,
+				Version:         3,
+				Events:          3,
+				FirstRecordedAt: recs[0].RecordedAt,
+				LastRecordedAt:  recs[2].RecordedAt,
+			}
+			if info != want {
+				t.Errorf("info %+v, want %+v", info, want)
+			}
+			if _, ok := s.(AggregateRecorder); ok && info.AggregateType != "cart" {
+				t.Errorf("info of aggregate type %q, want cart", info.AggregateType)
+			}
+		})
+	}
+}
+
+func TestStreamInfoOfHiddenStreams(t *testing.T) {
+	s := newTestStore(t)
+	deleted, tombstoned := NewID(), NewID()
+	if err := s.Record(deleted, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(tombstoned, []Event{itemAdded{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteStream(deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TombstoneStream(tombstoned); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := s.StreamInfo(deleted); err != nil || !info.Exists || !info.Deleted || info.Tombstoned {
+		t.Errorf("info of a deleted stream %+v, %v", info, err)
+	}
+	if info, err := s.StreamInfo(tombstoned); err != nil || !info.Exists || info.Deleted || !info.Tombstoned {
+		t.Errorf("info of a tombstoned stream %+v, %v", info, err)
+	}
+}
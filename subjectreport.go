@@ -0,0 +1,197 @@
+package evoke
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Redactor returns what a report shows in place of a sensitive field of an
+// event, given the event's type, the field's JSON path and its value.
+type Redactor func(eventType, path string, value any) any
+
+// RedactAll replaces every sensitive field with "[redacted]".
+func RedactAll(eventType, path string, value any) any {
+	return "[redacted]"
+}
+
+// SubjectAccessReport is a portable report of the events of the streams of
+// a data subject, as written by SubjectReport.
+type SubjectAccessReport struct {
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Subjects    []SubjectStream `json:"subjects"`
+}
+
+// SubjectStream is the part of a SubjectAccessReport about one stream.
+type SubjectStream struct {
+	AggregateID   uuid.UUID      `json:"aggregateId"`
+	AggregateType string         `json:"aggregateType,omitempty"`
+	Events        []SubjectEvent `json:"events"`
+}
+
+// SubjectEvent is an event of a SubjectAccessReport. Data is nil for events
+// whose payload was shredded.
+type SubjectEvent struct {
+	Sequence   int64     `json:"sequence"`
+	Version    int64     `json:"version"`
+	EventType  string    `json:"eventType"`
+	RecordedAt time.Time `json:"recordedAt"`
+	Data       any       `json:"data"`
+	Metadata   Metadata  `json:"metadata,omitempty"`
+	Shredded   bool      `json:"shredded,omitempty"`
+}
+
+// SubjectReportOption configures SubjectReport.
+type SubjectReportOption func(*subjectReport)
+
+type subjectReport struct {
+	redact Redactor
+}
+
+// WithRedactor sets how sensitive fields are shown. The default is
+// RedactAll.
+func WithRedactor(r Redactor) SubjectReportOption {
+	return func(sr *subjectReport) {
+		sr.redact = r
+	}
+}
+
+// SubjectReport writes a JSON SubjectAccessReport of every event of the
+// given aggregates' streams, with their metadata and timestamps, to w, for
+// answering a subject access request. Event fields tagged
+// `evoke:"sensitive"`, at any depth, are passed through the report's
+// Redactor:
+//
+//	type CustomerRegistered struct {
+//		Name     string
+//		Password string `evoke:"sensitive"`
+//	}
+func SubjectReport(store EventStore, aggregateIDs []uuid.UUID, w io.Writer, opts ...SubjectReportOption) error {
+	sr := subjectReport{redact: RedactAll}
+	for _, opt := range opts {
+		opt(&sr)
+	}
+	report := SubjectAccessReport{GeneratedAt: time.Now().UTC(), Subjects: make([]SubjectStream, 0, len(aggregateIDs))}
+	for _, id := range aggregateIDs {
+		recs, err := store.LoadStream(id)
+		if err != nil {
+			return fmt.Errorf("load stream %s: %w", id, err)
+		}
+		stream := SubjectStream{AggregateID: id, Events: make([]SubjectEvent, 0, len(recs))}
+		for _, rec := range recs {
+			ev, err := sr.event(rec)
+			if err != nil {
+				return fmt.Errorf("event %d: %w", rec.Sequence, err)
+			}
+			stream.AggregateType = rec.AggregateType
+			stream.Events = append(stream.Events, ev)
+		}
+		report.Subjects = append(report.Subjects, stream)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func (sr *subjectReport) event(rec RecordedEvent) (SubjectEvent, error) {
+	ev := SubjectEvent{
+		Sequence:   rec.Sequence,
+		Version:    rec.Version,
+		EventType:  rec.EventType,
+		RecordedAt: time.Unix(rec.RecordedAt, 0).UTC(),
+		Metadata:   rec.Metadata,
+	}
+	if _, ok := rec.Event.(ShreddedEvent); ok {
+		ev.Shredded = true
+		return ev, nil
+	}
+	b, err := json.Marshal(rec.Event)
+	if err != nil {
+		return SubjectEvent{}, err
+	}
+	if err := json.Unmarshal(b, &ev.Data); err != nil {
+		return SubjectEvent{}, err
+	}
+	ev.Data = sr.redactValue(rec.EventType, "", reflect.ValueOf(rec.Event), ev.Data)
+	return ev, nil
+}
+
+// redactValue redacts the sensitive fields of doc, the decoded JSON of v
+func (sr *subjectReport) redactValue(eventType, path string, v reflect.Value, doc any) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return doc
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if obj, ok := doc.(map[string]any); ok {
+			sr.redactFields(eventType, path, v, obj)
+		}
+	case reflect.Slice, reflect.Array:
+		if list, ok := doc.([]any); ok {
+			for i := range list {
+				if i < v.Len() {
+					list[i] = sr.redactValue(eventType, fmt.Sprintf("%s[%d]", path, i), v.Index(i), list[i])
+				}
+			}
+		}
+	case reflect.Map:
+		if obj, ok := doc.(map[string]any); ok {
+			iter := v.MapRange()
+			for iter.Next() {
+				k := fmt.Sprint(iter.Key().Interface())
+				if _, ok := obj[k]; ok {
+					obj[k] = sr.redactValue(eventType, joinPath(path, k), iter.Value(), obj[k])
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// redactFields redacts the fields of a struct decoded into obj
+func (sr *subjectReport) redactFields(eventType, path string, v reflect.Value, obj map[string]any) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && field.Anonymous {
+			// embedded fields are promoted into the same object
+			sr.redactValue(eventType, path, v.Field(i), obj)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		val, ok := obj[name]
+		if !ok {
+			continue
+		}
+		fieldPath := joinPath(path, name)
+		if field.Tag.Get("evoke") == "sensitive" {
+			obj[name] = sr.redact(eventType, fieldPath, val)
+			continue
+		}
+		obj[name] = sr.redactValue(eventType, fieldPath, v.Field(i), val)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,121 @@
+package evoke
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type address struct {
+	Street string `evoke:"sensitive"`
+	City   string
+}
+
+type contact struct {
+	Kind  string
+	Value string `evoke:"sensitive"`
+}
+
+type customerRegistered struct {
+	Name     string
+	Password string `json:"password" evoke:"sensitive"`
+	Home     *address
+	Contacts []contact
+	Tags     map[string]address
+	Ignored  string `json:"-" evoke:"sensitive"`
+}
+
+// readSubjectReport returns the report SubjectReport writes of ids
+func readSubjectReport(t *testing.T, s EventStore, ids []uuid.UUID, opts ...SubjectReportOption) SubjectAccessReport {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := SubjectReport(s, ids, &buf, opts...); err != nil {
+		t.Fatal(err)
+	}
+	var report SubjectAccessReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestSubjectReport(t *testing.T) {
+	s := newTestStore(t)
+	RegisterEvent(s, &customerRegistered{})
+	id, other := NewID(), NewID()
+	ev := customerRegistered{
+		Name:     "Ann",
+		Password: "hunter2",
+		Home:     &address{Street: "1 Main St", City: "Springfield"},
+		Contacts: []contact{{Kind: "email", Value: "ann@example.com"}},
+		Tags:     map[string]address{"work": {Street: "2 Side St", City: "Shelbyville"}},
+	}
+	if err := s.Record(id, []Event{ev, itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(other, []Event{itemAdded{SKU: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report := readSubjectReport(t, s, []uuid.UUID{id})
+	if len(report.Subjects) != 1 || report.Subjects[0].AggregateID != id || len(report.Subjects[0].Events) != 2 {
+		t.Fatalf("reported %+v, want the subject's two events", report.Subjects)
+	}
+	got := report.Subjects[0].Events[0]
+	if got.Sequence != 1 || got.EventType != "customerRegistered" || got.RecordedAt.IsZero() {
+		t.Errorf("reported event %+v", got)
+	}
+	want := map[string]any{
+		"Name":     "Ann",
+		"password": "[redacted]",
+		"Home":     map[string]any{"Street": "[redacted]", "City": "Springfield"},
+		"Contacts": []any{map[string]any{"Kind": "email", "Value": "[redacted]"}},
+		"Tags":     map[string]any{"work": map[string]any{"Street": "[redacted]", "City": "Shelbyville"}},
+	}
+	gotJSON, _ := json.Marshal(got.Data)
+	wantJSON, _ := json.Marshal(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("reported data %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestSubjectReportRedactor(t *testing.T) {
+	s := newTestStore(t)
+	RegisterEvent(s, &customerRegistered{})
+	id := NewID()
+	ev := customerRegistered{Name: "Ann", Password: "hunter2", Contacts: []contact{{Value: "a"}, {Value: "b"}}}
+	if err := s.Record(id, []Event{ev}); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	redact := func(eventType, path string, value any) any {
+		paths = append(paths, eventType+" "+path)
+		return len(value.(string))
+	}
+	report := readSubjectReport(t, s, []uuid.UUID{id}, WithRedactor(redact))
+	slices.Sort(paths)
+	if want := []string{"customerRegistered Contacts[0].Value", "customerRegistered Contacts[1].Value", "customerRegistered password"}; !slices.Equal(paths, want) {
+		t.Errorf("redacted %q, want %q", paths, want)
+	}
+	if data := report.Subjects[0].Events[0].Data.(map[string]any); data["password"] != 7.0 {
+		t.Errorf("reported password %v, want the redactor's value", data["password"])
+	}
+}
+
+func TestSubjectReportOfShreddedStream(t *testing.T) {
+	s := newTestStore(t, WithPayloadEncryption())
+	id := NewID()
+	if err := s.Record(id, []Event{itemAdded{SKU: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ShredAggregate(id); err != nil {
+		t.Fatal(err)
+	}
+	events := readSubjectReport(t, s, []uuid.UUID{id}).Subjects[0].Events
+	if len(events) != 1 || !events[0].Shredded || events[0].Data != nil {
+		t.Errorf("reported %+v, want the event marked shredded without data", events)
+	}
+}
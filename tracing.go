@@ -0,0 +1,113 @@
+package evoke
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer creates spans around the work done by stores, buses and aggregate
+// handlers, and carries trace context from a command to the handlers of the
+// events it produced through event metadata. The evokeotel package
+// implements it for OpenTelemetry.
+type Tracer interface {
+	// Start begins a span named name as a child of any span in ctx. The
+	// returned function ends it, recording err if it isn't nil.
+	Start(ctx context.Context, name string) (context.Context, func(err error))
+	// Inject writes the trace context of ctx into md.
+	Inject(ctx context.Context, md Metadata)
+	// Extract returns ctx carrying the trace context found in md.
+	Extract(ctx context.Context, md Metadata) context.Context
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, _ string) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+func (nopTracer) Inject(context.Context, Metadata)                        {}
+func (nopTracer) Extract(ctx context.Context, _ Metadata) context.Context { return ctx }
+
+// ContextCommandHandler is implemented by command handlers that take part
+// in the trace of the command they handle.
+type ContextCommandHandler interface {
+	HandleContext(ctx context.Context, cmd Command) error
+}
+
+// ContextEventHandler is implemented by event handlers that take part in
+// the trace of the command that produced the event, for example to send
+// further commands within it.
+type ContextEventHandler interface {
+	HandleContext(ctx context.Context, evt Event, replay bool) error
+}
+
+// RecordedHandler is implemented by event handlers that want the whole
+// recorded event, with its sequence, stream and metadata, rather than just
+// the event. Buses call HandleRecorded in place of Handle.
+type RecordedHandler interface {
+	HandleRecorded(ctx context.Context, rec RecordedEvent, replay bool) error
+}
+
+// WithTracer traces appends to the store and records their trace context in
+// the metadata of the appended events.
+func WithTracer(tracer Tracer) FileStoreOption {
+	return func(s *fileStore) {
+		s.tracer = tracer
+	}
+}
+
+// SetTracer traces every command sent through the bus.
+func (b *simpleCommandBus) SetTracer(tracer Tracer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tracer = tracer
+}
+
+// SetTracer traces every query asked through the bus.
+func (b *simpleQueryBus) SetTracer(tracer Tracer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tracer = tracer
+}
+
+// SetTracer traces every event handler called by the bus, continuing the
+// trace found in the event's metadata.
+func (b *simpleEventBus) SetTracer(tracer Tracer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tracer = tracer
+}
+
+// SetTracer traces the aggregate's handling of each command.
+func (h *AggregateHandler) SetTracer(tracer Tracer) {
+	h.tracer = tracer
+}
+
+// handleCommand calls h within ctx if it accepts one
+func handleCommand(ctx context.Context, h CommandHandler, cmd Command) error {
+	if ch, ok := h.(ContextCommandHandler); ok {
+		return ch.HandleContext(ctx, cmd)
+	}
+	return h.Handle(cmd)
+}
+
+// handleEvent calls h within ctx, caused by rec, if it accepts one
+func handleEvent(ctx context.Context, h EventHandler, rec RecordedEvent, replay bool) error {
+	ctx = CausedBy(ctx, rec)
+	if rh, ok := h.(RecordedHandler); ok {
+		return rh.HandleRecorded(ctx, rec, replay)
+	}
+	if eh, ok := h.(ContextEventHandler); ok {
+		return eh.HandleContext(ctx, rec.Event, replay)
+	}
+	return h.Handle(rec.Event, replay)
+}
+
+// traced runs fn in a span, reporting its duration to inst
+func traced(ctx context.Context, tracer Tracer, inst Instrumentation, kind, name string, fn func(context.Context) error) error {
+	ctx, end := tracer.Start(ctx, "evoke."+kind+" "+name)
+	start := time.Now()
+	err := fn(ctx)
+	inst.HandlerDone(kind, name, time.Since(start), err)
+	end(err)
+	return err
+}
@@ -0,0 +1,86 @@
+package evoke
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// EventValidator is implemented by events that check their own contents.
+// Stores call Validate before appending and reject the whole append with
+// ErrInvalidEvent if any event fails.
+type EventValidator interface {
+	Validate() error
+}
+
+// RegisterEventSchema makes stores reject events of ctor's type whose JSON
+// doesn't conform to schema, a JSON Schema document.
+func RegisterEventSchema[T Event](er EventRegisterer, ctor T, schema []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return fmt.Errorf("parse schema for %s: %w", TypeName(ctor), err)
+	}
+	url := "evoke:///" + TypeName(ctor) + ".json"
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return fmt.Errorf("add schema for %s: %w", TypeName(ctor), err)
+	}
+	compiled, err := c.Compile(url)
+	if err != nil {
+		return fmt.Errorf("compile schema for %s: %w", TypeName(ctor), err)
+	}
+	er.registerSchema(TypeName(ctor), compiled)
+	return nil
+}
+
+func (er *EventRegistry) registerSchema(goType string, schema *jsonschema.Schema) {
+	if er.schemas == nil {
+		er.schemas = make(map[string]*jsonschema.Schema)
+	}
+	er.schemas[goType] = schema
+}
+
+// ValidateEvent checks e, marshaled as data, against its Validate method and
+// registered schema
+func (er *EventRegistry) ValidateEvent(e Event, data []byte) error {
+	if v, ok := e.(EventValidator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidEvent, TypeName(e), err)
+		}
+	}
+	schema, ok := er.schemas[TypeName(e)]
+	if !ok {
+		return nil
+	}
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidEvent, TypeName(e), err)
+	}
+	if err := schema.Validate(inst); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidEvent, TypeName(e), err)
+	}
+	return nil
+}
+
+// CommandValidator is implemented by commands that check their own
+// contents. The command bus calls Validate before dispatching and returns
+// its error as a *ValidationError without calling any handler.
+type CommandValidator interface {
+	Validate() error
+}
+
+// ValidationError is returned by Send for a command that failed its
+// Validate method.
+type ValidationError struct {
+	Command string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid command " + e.Command + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,121 @@
+package evoke
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// quantityChanged is valid with a positive quantity
+type quantityChanged struct {
+	SKU string
+	Qty int
+}
+
+func (e quantityChanged) Validate() error {
+	if e.Qty <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	return nil
+}
+
+const itemAddedSchema = `{
+	"type": "object",
+	"properties": {"SKU": {"type": "string", "minLength": 1}},
+	"required": ["SKU"]
+}`
+
+func TestValidateEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		evs   []Event
+		valid bool
+	}{
+		{"valid", []Event{quantityChanged{SKU: "a", Qty: 1}, itemAdded{SKU: "a"}}, true},
+		{"failing Validate", []Event{quantityChanged{SKU: "a"}}, false},
+		{"failing Validate by pointer", []Event{&quantityChanged{SKU: "a"}}, false},
+		{"failing schema", []Event{itemAdded{}}, false},
+		{"one invalid of several", []Event{itemAdded{SKU: "a"}, quantityChanged{SKU: "a", Qty: -1}}, false},
+		{"without a schema or Validate", []Event{itemRemoved{}}, true},
+	}
+	stores := map[string]func() EventStore{
+		"file":   func() EventStore { return newTestStore(t) },
+		"tenant": func() EventStore { return newTestStore(t).ForTenant("acme") },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range tests {
+				s := open()
+				RegisterEvent(s.(EventRegisterer), &quantityChanged{})
+				if err := RegisterEventSchema(s.(EventRegisterer), itemAdded{}, []byte(itemAddedSchema)); err != nil {
+					t.Fatal(err)
+				}
+				id := NewID()
+				err := s.Record(id, tt.evs)
+				if tt.valid && err != nil {
+					t.Errorf("%s: %v", tt.name, err)
+				}
+				if !tt.valid && !errors.Is(err, ErrInvalidEvent) {
+					t.Errorf("%s: %v, want ErrInvalidEvent", tt.name, err)
+				}
+				if n := len(mustLoad(t, s, id)); !tt.valid && n != 0 {
+					t.Errorf("%s: recorded %d events of an invalid append", tt.name, n)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterEventSchemaInvalid(t *testing.T) {
+	var er EventRegistry
+	for _, schema := range []string{`{`, `{"type": 5}`} {
+		if err := RegisterEventSchema(&er, itemAdded{}, []byte(schema)); err == nil {
+			t.Errorf("registered schema %s", schema)
+		}
+	}
+}
+
+// removeItem is valid with a SKU
+type removeItem struct {
+	ID  uuid.UUID
+	SKU string
+}
+
+func (c removeItem) AggregateID() uuid.UUID { return c.ID }
+
+func (c removeItem) Validate() error {
+	if c.SKU == "" {
+		return errors.New("sku is required")
+	}
+	return nil
+}
+
+// countingHandler counts the commands it handles
+type countingHandler struct{ handled int }
+
+func (h *countingHandler) Handle(Command) error {
+	h.handled++
+	return nil
+}
+
+func TestCommandValidation(t *testing.T) {
+	bus := NewCommandBus()
+	var h countingHandler
+	bus.RegisterHandler(removeItem{}, &h)
+
+	err := bus.Send(removeItem{ID: NewID()})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Command != "removeItem" || verr.Err.Error() != "sku is required" {
+		t.Fatalf("Send returned %v, want a ValidationError", err)
+	}
+	if err.Error() != "invalid command removeItem: sku is required" {
+		t.Errorf("error %q", err)
+	}
+	if h.handled != 0 {
+		t.Error("handled an invalid command")
+	}
+	if err := bus.Send(removeItem{ID: NewID(), SKU: "a"}); err != nil || h.handled != 1 {
+		t.Errorf("Send of a valid command returned %v after %d calls", err, h.handled)
+	}
+}